@@ -0,0 +1,369 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/codecutil"
+	"github.com/indigo-web/indigo/internal/protocol/http1"
+	"github.com/indigo-web/indigo/internal/strutil"
+	"github.com/indigo-web/indigo/kv"
+	"github.com/indigo-web/indigo/transport"
+)
+
+var (
+	ErrUnsupportedScheme = errors.New("unsupported scheme")
+	ErrMissingHost       = errors.New("missing host")
+	// ErrStopRedirects is returned by a RedirectPolicy in order to stop following redirects.
+	// The last redirect response is returned as is then.
+	ErrStopRedirects    = errors.New("redirects are not followed")
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// RedirectPolicy decides whether the next request of a redirect chain is going to be sent. The via
+// contains all the requests sent so far, the oldest first. Returning ErrStopRedirects results in the
+// redirect response being returned, any other error is returned from Client.Do instead.
+type RedirectPolicy func(next *Request, via []*Request) error
+
+// MaxRedirects follows at most n redirects in a row.
+func MaxRedirects(n int) RedirectPolicy {
+	return func(_ *Request, via []*Request) error {
+		if len(via) > n {
+			return ErrTooManyRedirects
+		}
+
+		return nil
+	}
+}
+
+// NoRedirects never follows redirects.
+func NoRedirects(*Request, []*Request) error {
+	return ErrStopRedirects
+}
+
+type Timeouts struct {
+	// Dial limits the time to establish a connection, including the TLS handshake.
+	Dial time.Duration
+	// Read limits the time of waiting for any data from the server, including the response head.
+	Read time.Duration
+	// Idle is how long an idle connection is kept in the pool. Zero value disables the limit.
+	Idle time.Duration
+}
+
+// Client is an HTTP/1.1 client. It keeps idle connections per host and reuses them for further
+// requests, therefore must be reused itself. It's safe for concurrent use.
+type Client struct {
+	cfg      *config.Config
+	codecs   []codec.Codec
+	tls      *tls.Config
+	timeouts Timeouts
+	redirect RedirectPolicy
+	pool     *pool
+}
+
+// New returns a new Client instance.
+func New() *Client {
+	timeouts := Timeouts{
+		Dial: 30 * time.Second,
+		Read: 60 * time.Second,
+		Idle: 90 * time.Second,
+	}
+
+	return &Client{
+		cfg:      config.Default(),
+		timeouts: timeouts,
+		redirect: MaxRedirects(10),
+		pool:     newPool(4, timeouts.Idle),
+	}
+}
+
+// Tune replaces default config. Headers, body and buffer limits are applied to the responses.
+func (c *Client) Tune(cfg *config.Config) *Client {
+	c.cfg = cfg
+	return c
+}
+
+// Codec appends a new codec into the list of supported. The codecs are advertised via the
+// Accept-Encoding and used to decompress the response bodies.
+func (c *Client) Codec(codecs ...codec.Codec) *Client {
+	c.codecs = append(c.codecs, codecs...)
+	return c
+}
+
+// TLS sets the TLS configuration used for https requests. The server name is filled
+// automatically, if empty.
+func (c *Client) TLS(cfg *tls.Config) *Client {
+	c.tls = cfg
+	return c
+}
+
+// Timeouts replaces the default timeouts.
+func (c *Client) Timeouts(timeouts Timeouts) *Client {
+	c.timeouts = timeouts
+	c.pool.timeout = timeouts.Idle
+	return c
+}
+
+// MaxIdleConns limits the number of idle connections kept per host.
+func (c *Client) MaxIdleConns(perHost int) *Client {
+	c.pool.maxIdle = perHost
+	return c
+}
+
+// Redirects sets the redirect policy. By default, at most 10 redirects are followed.
+func (c *Client) Redirects(policy RedirectPolicy) *Client {
+	c.redirect = policy
+	return c
+}
+
+// Get is a shortcut for sending a GET request.
+func (c *Client) Get(rawURL string) (*Response, error) {
+	request, err := NewRequest(method.GET, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(request)
+}
+
+// Head is a shortcut for sending a HEAD request.
+func (c *Client) Head(rawURL string) (*Response, error) {
+	request, err := NewRequest(method.HEAD, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(request)
+}
+
+// Post is a shortcut for sending a POST request with the body.
+func (c *Client) Post(rawURL string, contentType mime.MIME, body []byte) (*Response, error) {
+	request, err := NewRequest(method.POST, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(request.ContentType(contentType).Bytes(body))
+}
+
+// Do sends the request and returns the response, following redirects according to the policy.
+// The response must be closed after use.
+func (c *Client) Do(request *Request) (*Response, error) {
+	if request.err != nil {
+		return nil, request.err
+	}
+
+	var via []*Request
+
+	for {
+		response, err := c.roundtrip(request)
+		if err != nil {
+			return nil, err
+		}
+
+		next := redirect(request, response)
+		if next == nil {
+			return response, nil
+		}
+
+		via = append(via, request)
+		if err = c.redirect(next, via); err != nil {
+			if errors.Is(err, ErrStopRedirects) {
+				return response, nil
+			}
+
+			_ = response.Close()
+			return nil, err
+		}
+
+		// redirect responses are normally tiny, so consume them in order to reuse the connection.
+		_ = response.Body.Discard()
+		_ = response.Close()
+		request = next
+	}
+}
+
+// CloseIdle closes all the idle connections.
+func (c *Client) CloseIdle() {
+	c.pool.closeIdle()
+}
+
+func (c *Client) roundtrip(request *Request) (*Response, error) {
+	ctx := request.context()
+	key := request.URL.Scheme + "://" + hostport(request.URL)
+
+	for {
+		conn, reused := c.pool.get(key), true
+		if conn == nil {
+			var err error
+			if conn, err = c.dial(ctx, request.URL, key); err != nil {
+				return nil, err
+			}
+
+			reused = false
+		}
+
+		stop := context.AfterFunc(ctx, func() {
+			_ = conn.Close()
+		})
+
+		head, err := exchange(conn, request)
+		if err != nil {
+			stop()
+			_ = conn.Close()
+
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			if reused && request.replayable() && errors.Is(err, http1.ErrNoResponse) {
+				// the idle connection was closed by the server in the meantime.
+				continue
+			}
+
+			return nil, err
+		}
+
+		return &Response{
+			Protocol:      head.Protocol,
+			Code:          head.Code,
+			Status:        head.Status,
+			Headers:       head.Headers,
+			ContentLength: head.ContentLength,
+			ContentType:   head.ContentType,
+			Body:          head.Body,
+			Request:       request,
+			conn:          conn,
+			pool:          c.pool,
+			stop:          stop,
+		}, nil
+	}
+}
+
+func exchange(conn *conn, request *Request) (http1.ResponseHead, error) {
+	headers := make([]kv.Pair, 0, request.Headers.Len()+1)
+	if !request.Headers.Has("host") {
+		headers = append(headers, kv.Pair{Key: "Host", Value: request.URL.Host})
+	}
+
+	for key, value := range request.Headers.Pairs() {
+		headers = append(headers, kv.Pair{Key: key, Value: value})
+	}
+
+	body, length := request.payload()
+	if err := conn.WriteRequest(request.Method, request.target(), headers, body, length); err != nil {
+		return http1.ResponseHead{}, err
+	}
+
+	return conn.ReadResponse(request.Method)
+}
+
+func (c *Client) dial(ctx context.Context, u *url.URL, key string) (*conn, error) {
+	if c.timeouts.Dial > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeouts.Dial)
+		defer cancel()
+	}
+
+	dialer := new(net.Dialer)
+	raw, err := dialer.DialContext(ctx, "tcp", hostport(u))
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "https" {
+		cfg := new(tls.Config)
+		if c.tls != nil {
+			cfg = c.tls.Clone()
+		}
+
+		if len(cfg.ServerName) == 0 {
+			cfg.ServerName = u.Hostname()
+		}
+
+		cfg.NextProtos = []string{"http/1.1"}
+		tlsConn := tls.Client(raw, cfg)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = raw.Close()
+			return nil, err
+		}
+
+		raw = tlsConn
+	}
+
+	client := transport.NewClient(raw, c.timeouts.Read, make([]byte, c.cfg.NET.ReadBufferSize))
+	codecs := codecutil.NewCache(c.codecs, codecutil.AcceptEncoding(c.codecs))
+
+	return &conn{
+		ClientConn: http1.NewClientConn(c.cfg, client, codecs),
+		key:        key,
+	}, nil
+}
+
+// redirect returns the next request if the response is a redirect, which can be followed.
+func redirect(request *Request, response *Response) *Request {
+	switch response.Code {
+	case status.MovedPermanently, status.Found, status.SeeOther,
+		status.TemporaryRedirect, status.PermanentRedirect:
+	default:
+		return nil
+	}
+
+	location, found := response.Headers.Lookup("location")
+	if !found {
+		return nil
+	}
+
+	target, err := request.URL.Parse(location)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return nil
+	}
+
+	next := request.clone()
+	next.URL = target
+	next.URL.Fragment = ""
+	next.Headers.Delete("host")
+
+	switch response.Code {
+	case status.TemporaryRedirect, status.PermanentRedirect:
+		// both method and body must be preserved.
+		if !request.replayable() {
+			return nil
+		}
+	default:
+		if request.Method != method.HEAD && (response.Code == status.SeeOther || request.Method == method.POST) {
+			next.Method = method.GET
+			next.Bytes(nil)
+			next.Headers.Delete("content-type")
+		}
+	}
+
+	if !strutil.CmpFoldSafe(hostport(target), hostport(request.URL)) {
+		// credentials must not leak to other hosts.
+		next.Headers.Delete("authorization").Delete("proxy-authorization").Delete("cookie")
+	}
+
+	return next
+}
+
+// hostport returns the host with the port, which is derived from the scheme if omitted.
+func hostport(u *url.URL) string {
+	port := u.Port()
+	if len(port) == 0 {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
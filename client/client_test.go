@@ -0,0 +1,364 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/indigo-web/indigo"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/router/inbuilt"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	addr    = "localhost:16200"
+	tlsAddr = "localhost:16201"
+)
+
+func serve(t *testing.T) {
+	redirect := func(code status.Code, location string) inbuilt.Handler {
+		return func(request *http.Request) *http.Response {
+			return http.Code(request, code).Header("Location", location)
+		}
+	}
+
+	r := inbuilt.New().
+		Get("/hello", func(request *http.Request) *http.Response {
+			return http.String(request, "hello").Header("X-Remote", request.Remote.String())
+		}).
+		Post("/echo", func(request *http.Request) *http.Response {
+			body, err := request.Body.Bytes()
+			if err != nil {
+				return http.Error(request, err)
+			}
+
+			return request.Respond().
+				ContentType(mime.MIME(request.ContentType)).
+				Header("X-Method", request.Method.String()).
+				Header("X-Chunked", strconv.FormatBool(request.Chunked)).
+				Bytes(body)
+		}).
+		Get("/echo", func(request *http.Request) *http.Response {
+			return http.String(request, "GET").Header("X-Method", "GET")
+		}).
+		Get("/compressed", func(request *http.Request) *http.Response {
+			return http.String(request, strings.Repeat("compressed ", 1000)).Compression("gzip")
+		}).
+		Get("/chunked", func(request *http.Request) *http.Response {
+			return request.Respond().Stream(strings.NewReader("streamed body"), -1)
+		}).
+		Get("/slow", func(request *http.Request) *http.Response {
+			time.Sleep(300 * time.Millisecond)
+			return http.String(request, "slow")
+		}).
+		Get("/found", redirect(status.Found, "/hello")).
+		Post("/found", redirect(status.Found, "/echo")).
+		Post("/temporary", redirect(status.TemporaryRedirect, "/echo")).
+		Get("/loop", redirect(status.Found, "/loop"))
+
+	app := indigo.New(addr).
+		TLS(tlsAddr, indigo.LocalCert()).
+		Codec(codec.NewGZIP())
+
+	started, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		_ = app.OnStart(func() {
+			close(started)
+		}).Serve(r)
+		close(stopped)
+	}()
+
+	<-started
+	t.Cleanup(func() {
+		require.NoError(t, app.Shutdown(context.Background()))
+		<-stopped
+	})
+
+	for i := 0; ; i++ {
+		resp, err := New().Get("http://" + addr + "/hello")
+		if err == nil {
+			require.NoError(t, resp.Close())
+			break
+		}
+
+		require.Less(t, i, 40, err)
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func readString(t *testing.T, resp *Response) string {
+	body, err := resp.Body.String()
+	require.NoError(t, err)
+	return body
+}
+
+func TestClient(t *testing.T) {
+	serve(t)
+	url := "http://" + addr
+
+	t.Run("keep-alive", func(t *testing.T) {
+		c := New()
+		defer c.CloseIdle()
+
+		var remotes []string
+		for range 3 {
+			resp, err := c.Get(url + "/hello")
+			require.NoError(t, err)
+			require.Equal(t, status.OK, resp.Code)
+			require.Equal(t, "hello", readString(t, resp))
+			remotes = append(remotes, resp.Headers.Value("x-remote"))
+			require.NoError(t, resp.Close())
+		}
+
+		require.Equal(t, remotes[0], remotes[1])
+		require.Equal(t, remotes[0], remotes[2])
+	})
+
+	t.Run("unread body closes connection", func(t *testing.T) {
+		c := New()
+		defer c.CloseIdle()
+
+		resp, err := c.Get(url + "/hello")
+		require.NoError(t, err)
+		first := resp.Headers.Value("x-remote")
+		require.NoError(t, resp.Close())
+
+		resp, err = c.Get(url + "/hello")
+		require.NoError(t, err)
+		require.NotEqual(t, first, resp.Headers.Value("x-remote"))
+		require.NoError(t, resp.Close())
+	})
+
+	t.Run("sized body", func(t *testing.T) {
+		resp, err := New().Post(url+"/echo", mime.JSON, []byte(`{"hello":"world"}`))
+		require.NoError(t, err)
+		defer resp.Close()
+
+		var model struct {
+			Hello string `json:"hello"`
+		}
+		require.NoError(t, resp.Body.JSON(&model))
+		require.Equal(t, "world", model.Hello)
+		require.Equal(t, "false", resp.Headers.Value("x-chunked"))
+	})
+
+	t.Run("chunked body", func(t *testing.T) {
+		request, err := NewRequest(method.POST, url+"/echo")
+		require.NoError(t, err)
+
+		resp, err := New().Do(request.Stream(io.MultiReader(
+			strings.NewReader("Hello, "), strings.NewReader("world!"),
+		)))
+		require.NoError(t, err)
+		defer resp.Close()
+
+		require.Equal(t, "Hello, world!", readString(t, resp))
+		require.Equal(t, "true", resp.Headers.Value("x-chunked"))
+	})
+
+	t.Run("chunked response", func(t *testing.T) {
+		resp, err := New().Get(url + "/chunked")
+		require.NoError(t, err)
+		defer resp.Close()
+
+		require.Equal(t, int64(-1), resp.ContentLength)
+		require.Equal(t, "streamed body", readString(t, resp))
+	})
+
+	t.Run("decompression", func(t *testing.T) {
+		resp, err := New().Codec(codec.NewGZIP()).Get(url + "/compressed")
+		require.NoError(t, err)
+		defer resp.Close()
+
+		require.Equal(t, strings.Repeat("compressed ", 1000), readString(t, resp))
+		require.False(t, resp.Headers.Has("content-encoding"))
+
+		resp, err = New().Get(url + "/compressed")
+		require.NoError(t, err)
+		defer resp.Close()
+		require.Equal(t, "gzip", resp.Headers.Value("content-encoding"))
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		c := New()
+		defer c.CloseIdle()
+
+		resp, err := c.Head(url + "/hello")
+		require.NoError(t, err)
+		require.Equal(t, int64(0), resp.ContentLength)
+		require.Empty(t, readString(t, resp))
+		require.NoError(t, resp.Close())
+
+		resp, err = c.Get(url + "/hello")
+		require.NoError(t, err)
+		require.Equal(t, "hello", readString(t, resp))
+		require.NoError(t, resp.Close())
+	})
+
+	t.Run("redirects", func(t *testing.T) {
+		resp, err := New().Get(url + "/found")
+		require.NoError(t, err)
+		require.Equal(t, "hello", readString(t, resp))
+		require.Equal(t, "/hello", resp.Request.URL.Path)
+		require.NoError(t, resp.Close())
+
+		resp, err = New().Post(url+"/found", mime.Plain, []byte("body"))
+		require.NoError(t, err)
+		require.Equal(t, "GET", resp.Headers.Value("x-method"))
+		require.NoError(t, resp.Close())
+
+		resp, err = New().Post(url+"/temporary", mime.Plain, []byte("body"))
+		require.NoError(t, err)
+		require.Equal(t, "POST", resp.Headers.Value("x-method"))
+		require.Equal(t, "body", readString(t, resp))
+		require.NoError(t, resp.Close())
+
+		resp, err = New().Redirects(NoRedirects).Get(url + "/found")
+		require.NoError(t, err)
+		require.Equal(t, status.Found, resp.Code)
+		require.Equal(t, "/hello", resp.Headers.Value("location"))
+		require.NoError(t, resp.Close())
+
+		_, err = New().Redirects(MaxRedirects(3)).Get(url + "/loop")
+		require.ErrorIs(t, err, ErrTooManyRedirects)
+	})
+
+	t.Run("read timeout", func(t *testing.T) {
+		_, err := New().Timeouts(Timeouts{Read: 100 * time.Millisecond}).Get(url + "/slow")
+		require.Error(t, err)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		request, err := NewRequest(method.GET, url+"/slow")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err = New().Do(request.WithContext(ctx))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		resp, err := New().
+			TLS(&tls.Config{InsecureSkipVerify: true}).
+			Get("https://" + tlsAddr + "/hello")
+		require.NoError(t, err)
+		defer resp.Close()
+
+		require.Equal(t, "hello", readString(t, resp))
+	})
+
+	t.Run("bad URL", func(t *testing.T) {
+		_, err := New().Get("ftp://" + addr)
+		require.ErrorIs(t, err, ErrUnsupportedScheme)
+
+		_, err = New().Get("http:///path")
+		require.ErrorIs(t, err, ErrMissingHost)
+	})
+}
+
+func TestStaleConnection(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			_, _ = conn.Read(make([]byte, 4096))
+			_, _ = io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+			// pretend the idle timeout exceeded right after the response.
+			time.Sleep(50 * time.Millisecond)
+			_ = conn.Close()
+		}
+	}()
+
+	c := New()
+	for range 2 {
+		resp, err := c.Get("http://" + l.Addr().String())
+		require.NoError(t, err)
+		require.Equal(t, "ok", readString(t, resp))
+		require.NoError(t, resp.Close())
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestResponseParsing(t *testing.T) {
+	respond := func(t *testing.T, raw string) *Response {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = l.Close()
+		})
+
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			_, _ = conn.Read(make([]byte, 4096))
+			_, _ = io.WriteString(conn, raw)
+			_ = conn.Close()
+		}()
+
+		resp, err := New().Get("http://" + l.Addr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = resp.Close()
+		})
+
+		return resp
+	}
+
+	t.Run("informational and till EOF", func(t *testing.T) {
+		resp := respond(t, "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.0 201 Created\r\nContent-Type: text/plain\r\n\r\nuntil the end")
+		require.Equal(t, status.Created, resp.Code)
+		require.Equal(t, "Created", resp.Status)
+		require.Equal(t, "text/plain", resp.ContentType)
+		require.Equal(t, int64(-1), resp.ContentLength)
+		require.Equal(t, "until the end", readString(t, resp))
+	})
+
+	t.Run("no reason phrase", func(t *testing.T) {
+		resp := respond(t, "HTTP/1.1 204\r\n\r\n")
+		require.Equal(t, status.NoContent, resp.Code)
+		require.Empty(t, resp.Status)
+		require.Empty(t, readString(t, resp))
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			_, _ = conn.Read(make([]byte, 4096))
+			_, _ = io.WriteString(conn, "HTTP/1.1 2OO OK\r\n\r\n")
+			_ = conn.Close()
+		}()
+
+		_, err = New().Get("http://" + l.Addr().String())
+		require.Error(t, err)
+	})
+}
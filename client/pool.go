@@ -0,0 +1,82 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/indigo-web/indigo/internal/protocol/http1"
+)
+
+type conn struct {
+	*http1.ClientConn
+	key       string
+	idleSince time.Time
+}
+
+// pool keeps idle connections per host. The most recently used connections are reused first,
+// so the excessive ones expire and get closed.
+type pool struct {
+	mu      sync.Mutex
+	idle    map[string][]*conn
+	maxIdle int
+	timeout time.Duration
+}
+
+func newPool(maxIdle int, timeout time.Duration) *pool {
+	return &pool{
+		idle:    make(map[string][]*conn),
+		maxIdle: maxIdle,
+		timeout: timeout,
+	}
+}
+
+func (p *pool) get(key string) *conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// connections are ordered by the moment they became idle, so the expired ones are
+	// always in the beginning.
+	conns := p.idle[key]
+	for len(conns) > 0 && p.timeout > 0 && time.Since(conns[0].idleSince) > p.timeout {
+		_ = conns[0].Close()
+		conns = conns[1:]
+	}
+
+	if len(conns) == 0 {
+		delete(p.idle, key)
+		return nil
+	}
+
+	c := conns[len(conns)-1]
+	p.idle[key] = conns[:len(conns)-1]
+
+	return c
+}
+
+func (p *pool) put(c *conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := p.idle[c.key]
+	if len(conns) >= p.maxIdle {
+		_ = c.Close()
+		return
+	}
+
+	c.idleSince = time.Now()
+	p.idle[c.key] = append(conns, c)
+}
+
+// closeIdle closes all the idle connections.
+func (p *pool) closeIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, conns := range p.idle {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+
+		delete(p.idle, key)
+	}
+}
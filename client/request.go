@@ -0,0 +1,155 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+
+	"github.com/flrdv/uf"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/kv"
+	json "github.com/json-iterator/go"
+)
+
+// Request is an outbound HTTP request.
+type Request struct {
+	Method method.Method
+	URL    *url.URL
+	// Headers are sent as is. The Host header is added automatically unless set explicitly.
+	Headers http.Headers
+	// Ctx limits the lifetime of the request, including reading the response body. Nil means
+	// context.Background().
+	Ctx    context.Context
+	body   io.Reader
+	buff   []byte
+	length int64
+	err    error
+}
+
+// NewRequest returns a new request. Only http and https schemes are supported.
+func NewRequest(m method.Method, rawURL string) (*Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return nil, ErrUnsupportedScheme
+	}
+
+	if len(u.Host) == 0 {
+		return nil, ErrMissingHost
+	}
+
+	return &Request{
+		Method:  m,
+		URL:     u,
+		Headers: kv.New(),
+	}, nil
+}
+
+// Header appends a key-values pair into the list of headers.
+func (r *Request) Header(key string, values ...string) *Request {
+	for _, value := range values {
+		r.Headers.Add(key, value)
+	}
+
+	return r
+}
+
+// ContentType is a shorthand for Header("Content-Type", value).
+func (r *Request) ContentType(value mime.MIME) *Request {
+	return r.Header("Content-Type", value)
+}
+
+// String sets the request body.
+func (r *Request) String(body string) *Request {
+	return r.Bytes(uf.S2B(body))
+}
+
+// Bytes sets the request body. Please note that the passed slice must not be modified until
+// the request is done. Unlike streams, such bodies can be re-sent, e.g. when following
+// redirects or retrying on a stale connection.
+func (r *Request) Bytes(body []byte) *Request {
+	r.buff = body
+	r.body = nil
+	r.length = int64(len(body))
+	return r
+}
+
+// Stream sets a reader to be the source of the request's body. If no size is provided, the body
+// is sent using the chunked transfer encoding.
+func (r *Request) Stream(reader io.Reader, size ...int64) *Request {
+	r.buff = nil
+	r.body = reader
+	r.length = -1
+	if len(size) > 0 {
+		r.length = size[0]
+	}
+
+	return r
+}
+
+// JSON serializes the model into JSON and sets the Content-Type to application/json. If the
+// serialization fails, the error is returned by Client.Do.
+func (r *Request) JSON(model any) *Request {
+	data, err := json.ConfigDefault.Marshal(model)
+	if err != nil {
+		r.err = err
+		return r
+	}
+
+	return r.ContentType(mime.JSON).Bytes(data)
+}
+
+// WithContext sets the request context.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	r.Ctx = ctx
+	return r
+}
+
+func (r *Request) context() context.Context {
+	if r.Ctx == nil {
+		return context.Background()
+	}
+
+	return r.Ctx
+}
+
+// replayable tells whether the body can be sent more than once.
+func (r *Request) replayable() bool {
+	return r.body == nil
+}
+
+// payload returns the body reader and its length. Nil reader means no body.
+func (r *Request) payload() (io.Reader, int64) {
+	if r.body != nil {
+		return r.body, r.length
+	}
+
+	if r.buff != nil {
+		return bytes.NewReader(r.buff), r.length
+	}
+
+	return nil, 0
+}
+
+// target returns the request target in the origin form.
+func (r *Request) target() string {
+	return r.URL.RequestURI()
+}
+
+// clone returns a shallow copy of the request with its own headers.
+func (r *Request) clone() *Request {
+	clone := *r
+	clone.URL = new(url.URL)
+	*clone.URL = *r.URL
+	clone.Headers = r.Headers.Clone()
+
+	return &clone
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/proto"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/transport"
+)
+
+// ErrResponseClosed is returned by Response.Hijack if the response is already closed.
+var ErrResponseClosed = errors.New("response is already closed")
+
+// Response is an inbound HTTP response. It must be closed after use, even if the body isn't
+// read. None of its fields may be used after closing.
+type Response struct {
+	Protocol proto.Protocol
+	Code     status.Code
+	Status   string
+	Headers  http.Headers
+	// ContentLength is -1 if the length isn't known in advance, e.g. the body is chunked or
+	// was decompressed transparently.
+	ContentLength int64
+	ContentType   string
+	// Body is decompressed automatically if the Content-Encoding is supported by the client's
+	// codecs. In this case, both Content-Encoding and Content-Length headers are removed.
+	Body *http.Body
+	// Request is the request, to which the response was received. It differs from the original
+	// one if any redirects were followed.
+	Request *Request
+	conn    *conn
+	pool    *pool
+	stop    func() bool
+}
+
+// Close releases the connection. If the body was read completely, the connection is returned
+// into the pool in order to be reused, otherwise it's closed.
+func (r *Response) Close() error {
+	conn := r.conn
+	if conn == nil {
+		return nil
+	}
+
+	r.conn = nil
+
+	if !r.stop() || !conn.Reusable() {
+		// the context was already cancelled, so the connection is being closed.
+		return conn.Close()
+	}
+
+	r.pool.put(conn)
+	return nil
+}
+
+// Hijack takes the connection over, e.g. after 101 Switching Protocols. The data following the
+// response head is preserved, so it's returned by the first read. The response must not be used
+// afterward, and closing the connection is up to the caller.
+func (r *Response) Hijack() (transport.Client, error) {
+	conn := r.conn
+	if conn == nil {
+		return nil, ErrResponseClosed
+	}
+
+	r.conn = nil
+
+	if !r.stop() {
+		// the context was already cancelled, so the connection is being closed.
+		_ = conn.Close()
+		return nil, r.Request.context().Err()
+	}
+
+	return conn.Hijack(), nil
+}
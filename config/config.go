@@ -3,7 +3,10 @@ package config
 import (
 	"time"
 
+	"github.com/indigo-web/indigo/http/json"
+	"github.com/indigo-web/indigo/http/media"
 	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/validate"
 )
 
 type (
@@ -26,6 +29,28 @@ type (
 		// DefaultContentType sets the default form body MIME (as for multipart) unless one is
 		// explicitly set.
 		DefaultContentType mime.MIME
+		// SpillThreshold limits how big can a file of multipart/form-data be in order to be kept
+		// in memory. Bigger files are written into temporary files instead.
+		SpillThreshold int64
+		// MaxFieldSize limits the size of a multipart/form-data value, other than a file. Exceeding
+		// it results in status.ErrRequestEntityTooLarge.
+		MaxFieldSize int64
+		// TempDir is the directory the temporary files are created in. Empty value stands for
+		// the os.TempDir().
+		TempDir string `test:"nullable"`
+	}
+
+	BodyDecompression struct {
+		// MaxSize limits how big a request body may become after being decompressed. Exceeding
+		// it results in status.ErrRequestEntityTooLarge. In order to disable the setting, use the
+		// math.MaxUint64 value.
+		MaxSize uint64
+		// MaxRatio limits how many times a request body may expand while being decompressed.
+		// Exceeding it results in status.ErrRequestEntityTooLarge. 0 disables the setting.
+		MaxRatio uint64
+		// RatioThreshold is the decompressed size after which the MaxRatio is enforced, as small
+		// bodies are fairly expected to be compressed extremely well.
+		RatioThreshold uint64
 	}
 
 	NETWriteBufferSize struct {
@@ -75,6 +100,10 @@ type (
 		// Form is either application/x-www-form-urlencoded or multipart/form-data. Due to their common
 		// nature, they are easy to be generalized.
 		Form BodyForm
+		// Decompression restricts request bodies decoded from their content or transfer codings.
+		// It protects against so-called decompression bombs, where a tiny payload expands into
+		// gigabytes of data.
+		Decompression BodyDecompression
 	}
 
 	NET struct {
@@ -84,6 +113,11 @@ type (
 		// ReadTimeout controls the maximal lifetime of IDLE connections. If no data was
 		// received in this period of time, it'll be closed.
 		ReadTimeout time.Duration
+		// RequestTimeout is the deadline of the request context (Request.Ctx), counting from the
+		// moment the request is received. Zero value disables it.
+		RequestTimeout time.Duration `test:"nullable"`
+		// ProxyHeaderTimeout limits the time to receive the PROXY protocol header, if enabled.
+		ProxyHeaderTimeout time.Duration
 		// AcceptLoopInterruptPeriod controls how often will the Accept() call be interrupted
 		// in order to check whether it's time to stop. Defaults to 5 seconds.
 		AcceptLoopInterruptPeriod time.Duration
@@ -101,6 +135,22 @@ type (
 		// options and unsized streams.
 		SmallBody int64
 	}
+
+	HTTP2 struct {
+		// MaxConcurrentStreams limits how many streams a single client is allowed to keep open
+		// at once. Each stream is served by its own goroutine.
+		MaxConcurrentStreams uint32
+		// InitialWindowSize is the flow-control window advertised for every stream. Effectively,
+		// this is how much of a request body can be buffered per stream until the handler reads it.
+		InitialWindowSize uint32
+		// ConnWindowSize is the flow-control window shared by all the streams of a connection.
+		ConnWindowSize uint32
+		// MaxFrameSize is the largest frame payload a client is allowed to send. Must be in range
+		// from 16384 to 16777215.
+		MaxFrameSize uint32
+		// HeaderTableSize is the size of the HPACK dynamic table used to decode request headers.
+		HeaderTableSize uint32
+	}
 )
 
 // Config holds settings used across various parts of indigo, mainly restrictions, limitations
@@ -113,6 +163,17 @@ type Config struct {
 	Headers Headers
 	Body    Body
 	NET     NET
+	HTTP2   HTTP2
+	// JSON serializes models in http.Body.JSON and http.Response.JSON. Defaults to the
+	// json-iterator backed codec.
+	JSON json.Codec
+	// Media holds codecs of media types other than JSON, used by http.Body.Decode and
+	// http.Response.Negotiate. Defaults to XML, CBOR and MessagePack.
+	Media *media.Registry
+	// Validator checks models decoded by http.Body.JSON, http.Body.Decode and http.Bind against
+	// their validate tags. Nil disables the validation, which is the default one, so the tags
+	// of other validation libraries don't interfere.
+	Validator *validate.Validator `test:"nullable"`
 }
 
 // Default returns default config. Those are initially well-balanced, however maximal defaults
@@ -152,17 +213,34 @@ func Default() *Config {
 				BufferPrealloc:     1024,
 				DefaultCoding:      mime.UTF8,
 				DefaultContentType: mime.Plain,
+				SpillThreshold:     1024 * 1024, // 1 megabyte
+				MaxFieldSize:       1024 * 1024, // 1 megabyte
+			},
+			Decompression: BodyDecompression{
+				MaxSize:        512 * 1024 * 1024, // 512 megabytes, the same as the MaxSize
+				MaxRatio:       100,
+				RatioThreshold: 1024 * 1024,
 			},
 		},
 		NET: NET{
 			ReadBufferSize:            2 * 1024, // 4kb is more than enough for ordinary requests.
 			ReadTimeout:               90 * time.Second,
 			AcceptLoopInterruptPeriod: 5 * time.Second,
+			ProxyHeaderTimeout:        5 * time.Second,
 			WriteBufferSize: NETWriteBufferSize{
 				Default: 2 * 1024,
 				Maximal: 64 * 1024,
 			},
 			SmallBody: 4 * 1024,
 		},
+		HTTP2: HTTP2{
+			MaxConcurrentStreams: 100,
+			InitialWindowSize:    256 * 1024,
+			ConnWindowSize:       1024 * 1024,
+			MaxFrameSize:         16 * 1024, // the smallest allowed one
+			HeaderTableSize:      4 * 1024,
+		},
+		JSON:  json.Iterator(),
+		Media: media.Default(),
 	}
 }
@@ -46,13 +46,11 @@ func Easter(request *http.Request) *http.Response {
 }
 
 func Stressful(request *http.Request) *http.Response {
-	resp := request.Respond().
+	request.Respond().
 		Header("Should", "never be seen").
 		String("Hello, world!")
 
 	panic("TOO MUCH STRESS")
-
-	return resp
 }
 
 func main() {
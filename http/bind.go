@@ -0,0 +1,434 @@
+package http
+
+import (
+	"cmp"
+	"encoding"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indigo-web/indigo/http/form"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/strutil"
+)
+
+// Source is where a bound value comes from.
+type Source string
+
+const (
+	SourcePath   Source = "path"
+	SourceQuery  Source = "query"
+	SourceHeader Source = "header"
+	SourceCookie Source = "cookie"
+	SourceForm   Source = "form"
+	SourceBody   Source = "body"
+)
+
+var sources = []Source{SourcePath, SourceQuery, SourceHeader, SourceCookie, SourceForm}
+
+// FieldError describes a value, which couldn't be bound to the field.
+type FieldError struct {
+	// Field is the name of the struct field.
+	Field string
+	// Source and Key tell where the value was taken from.
+	Source Source
+	Key    string
+	Err    error
+}
+
+func (f FieldError) Error() string {
+	if f.Source == SourceBody {
+		return "body: " + f.Err.Error()
+	}
+
+	return fmt.Sprintf("%s %q: %s", f.Source, f.Key, f.Err)
+}
+
+// BindError aggregates all the errors occurred while binding. It's a status.ErrBadRequest, so
+// passing it to Response.Error results in 400 Bad Request listing the errors.
+type BindError []FieldError
+
+func (b BindError) Error() string {
+	messages := make([]string, len(b))
+	for i, err := range b {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (b BindError) Unwrap() error {
+	return status.ErrBadRequest
+}
+
+// Bind fills the struct pointed by the dst from the request. Fields are bound by the tags
+// naming their source:
+//   - path:"name" takes the value from the Request.Vars,
+//   - query:"name" from the Request.Params,
+//   - header:"name" from the Request.Headers,
+//   - cookie:"name" from the Request.Cookies(),
+//   - form:"name" from the Body.Form(). Fields of the form.Data type get the whole entry.
+//
+// Strings, integers, floats, booleans, time.Duration, time.Time (parsed by the layout tag,
+// time.RFC3339 by default), encoding.TextUnmarshaler and pointers to them are supported, as
+// well as slices, which collect all the values of the key. Absent values are replaced by the
+// default tag, if any. Embedded structs are bound, too.
+//
+// Unless the request body is a form, it's decoded into the dst by the Body.Decode first, so
+// the tagged fields are taking precedence. The body is decoded only if the struct has exported
+// fields not bound otherwise and not excluded by the json:"-" tag, so structs made up of e.g.
+// path and query fields only leave the body intact. Conversion errors are aggregated into
+// BindError, whereas other errors, e.g. exceeding the body size limit, are returned as is. The
+// bound struct is finally validated by the config.Config.Validator if set, returning
+// validate.Errors on violations.
+func Bind(request *Request, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		panic("http: Bind destination must be a pointer to a struct")
+	}
+
+	var errs BindError
+	b := binder{request: request}
+	v = v.Elem()
+	binding := bindingOf(v.Type())
+	fields := binding.fields
+
+	for _, f := range fields {
+		if len(f.def) > 0 {
+			if err := f.set(v.FieldByIndex(f.index), single(f.def)); err != nil {
+				errs = append(errs, f.error(err))
+			}
+		}
+	}
+
+	if binding.body && (request.ContentLength > 0 || request.Chunked) {
+		if !b.isForm() {
+			if err := request.Body.decode(dst); err != nil {
+				var httpErr status.HTTPError
+				if errors.As(err, &httpErr) {
+					return err
+				}
+
+				errs = append(errs, FieldError{Source: SourceBody, Err: err})
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if f.data {
+			data, found, err := b.formData(f.key)
+			if err != nil {
+				return err
+			}
+
+			if found {
+				v.FieldByIndex(f.index).Set(reflect.ValueOf(data))
+			}
+
+			continue
+		}
+
+		values, err := b.values(f.source, f.key)
+		if err != nil {
+			if f.source == SourceForm {
+				// the body is malformed or exceeds the limits.
+				return err
+			}
+
+			errs = append(errs, f.error(err))
+			continue
+		}
+
+		if values == nil {
+			continue
+		}
+
+		if err = f.set(v.FieldByIndex(f.index), values); err != nil {
+			errs = append(errs, f.error(err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return request.cfg.Validator.Struct(dst)
+}
+
+// binder lazily retrieves the values from the request.
+type binder struct {
+	request *Request
+	form    form.Form
+	formErr error
+	parsed  bool
+}
+
+func (b *binder) isForm() bool {
+	contentType, _ := strutil.CutHeader(b.request.ContentType)
+	contentType = strings.TrimSpace(contentType)
+
+	return strutil.CmpFoldSafe(contentType, mime.FormUrlencoded) ||
+		strutil.CmpFoldSafe(contentType, mime.Multipart)
+}
+
+// values returns the values of the key. Nil is returned if there are none.
+func (b *binder) values(source Source, key string) (values iter.Seq[string], err error) {
+	switch source {
+	case SourcePath:
+		values = b.request.Vars.Values(key)
+	case SourceQuery:
+		values = b.request.Params.Values(key)
+	case SourceHeader:
+		values = b.request.Headers.Values(key)
+	case SourceCookie:
+		jar, err := b.request.Cookies()
+		if err != nil {
+			return nil, err
+		}
+
+		values = jar.Values(key)
+	case SourceForm:
+		if !b.isForm() {
+			return nil, nil
+		}
+
+		if err = b.parseForm(); err != nil {
+			return nil, err
+		}
+
+		values = func(yield func(string) bool) {
+			for data := range b.form.Names(key) {
+				if !yield(data.Value) {
+					return
+				}
+			}
+		}
+	}
+
+	for range values {
+		return values, nil
+	}
+
+	return nil, nil
+}
+
+func (b *binder) parseForm() error {
+	if !b.parsed {
+		b.form, b.formErr = b.request.Body.Form()
+		b.parsed = true
+	}
+
+	return b.formErr
+}
+
+// formData returns the whole form entry of the name.
+func (b *binder) formData(name string) (form.Data, bool, error) {
+	if !b.isForm() {
+		return form.Data{}, false, nil
+	}
+
+	if err := b.parseForm(); err != nil {
+		return form.Data{}, false, err
+	}
+
+	data, found := b.form.Name(name)
+	return data, found, nil
+}
+
+func single(value string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		yield(value)
+	}
+}
+
+type boundField struct {
+	name   string
+	index  []int
+	source Source
+	key    string
+	def    string
+	layout string
+	// data marks fields of the form.Data type, which are bound to the whole form entry.
+	data bool
+}
+
+func (f boundField) error(err error) FieldError {
+	return FieldError{Field: f.name, Source: f.source, Key: f.key, Err: err}
+}
+
+// binding describes how the struct type is bound.
+type binding struct {
+	fields []boundField
+	// body tells whether there are fields, which are decoded from the body.
+	body bool
+}
+
+var bindings sync.Map
+
+// bindingOf returns the binding of the struct type, caching the result.
+func bindingOf(typ reflect.Type) binding {
+	if cached, ok := bindings.Load(typ); ok {
+		return cached.(binding)
+	}
+
+	var b binding
+	b.collect(typ, nil)
+	bindings.Store(typ, b)
+
+	return b
+}
+
+func (b *binding) collect(typ reflect.Type, index []int) {
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		idx := append(index[:len(index):len(index)], i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			b.collect(field.Type, idx)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		bound := false
+		for _, source := range sources {
+			key, found := field.Tag.Lookup(string(source))
+			if !found {
+				continue
+			}
+
+			bound = true
+			b.fields = append(b.fields, boundField{
+				name:   field.Name,
+				index:  idx,
+				source: source,
+				key:    key,
+				def:    field.Tag.Get("default"),
+				layout: field.Tag.Get("layout"),
+				data:   field.Type == formDataType,
+			})
+			break
+		}
+
+		if !bound {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			b.body = b.body || name != "-"
+		}
+	}
+}
+
+var (
+	formDataType        = reflect.TypeOf(form.Data{})
+	bytesType           = reflect.TypeOf([]byte(nil))
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (f boundField) set(field reflect.Value, values iter.Seq[string]) error {
+	if field.Kind() == reflect.Slice && field.Type() != bytesType {
+		slice := reflect.MakeSlice(field.Type(), 0, 1)
+		for value := range values {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := f.convert(elem, value); err != nil {
+				return err
+			}
+
+			slice = reflect.Append(slice, elem)
+		}
+
+		field.Set(slice)
+		return nil
+	}
+
+	for value := range values {
+		return f.convert(field, value)
+	}
+
+	return nil
+}
+
+func (f boundField) convert(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		ptr := reflect.New(field.Type().Elem())
+		if err := f.convert(ptr.Elem(), value); err != nil {
+			return err
+		}
+
+		field.Set(ptr)
+		return nil
+	}
+
+	switch field.Type() {
+	case bytesType:
+		field.SetBytes([]byte(value))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("invalid duration")
+		}
+
+		field.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := time.Parse(cmp.Or(f.layout, time.RFC3339), value)
+		if err != nil {
+			return errors.New("invalid time")
+		}
+
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	// time.Time is a text unmarshaler too, however it must respect the layout tag, so the check
+	// goes after it.
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("invalid boolean")
+		}
+
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("invalid integer")
+		}
+
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("invalid unsigned integer")
+		}
+
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return errors.New("invalid number")
+		}
+
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
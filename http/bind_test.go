@@ -0,0 +1,209 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http/form"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/http/validate"
+	"github.com/indigo-web/indigo/kv"
+	"github.com/indigo-web/indigo/transport/dummy"
+	"github.com/stretchr/testify/require"
+)
+
+func newBindRequest(contentType string, body ...string) *Request {
+	request := NewRequest(config.Default(), NewResponse(), dummy.NewNopClient(), kv.New(), kv.New(), kv.New())
+	chunks := make([][]byte, len(body))
+	for i, chunk := range body {
+		chunks[i] = []byte(chunk)
+		request.ContentLength += len(chunk)
+	}
+
+	request.ContentType = contentType
+	request.Body = NewBody(dummy.NewMockClient(chunks...))
+	request.Body.Reset(request)
+
+	return request
+}
+
+type Pagination struct {
+	Page  int `query:"page" default:"1"`
+	Limit int `query:"limit" default:"20"`
+}
+
+func TestBind(t *testing.T) {
+	t.Run("sources", func(t *testing.T) {
+		type model struct {
+			Pagination
+			ID      uint64        `path:"id"`
+			Tags    []string      `query:"tag"`
+			Verbose bool          `query:"verbose"`
+			Ratio   *float64      `query:"ratio"`
+			Timeout time.Duration `query:"timeout"`
+			Since   time.Time     `query:"since" layout:"2006-01-02"`
+			Token   string        `header:"X-Token"`
+			Addr    netip.Addr    `header:"X-Addr"`
+			Session string        `cookie:"session"`
+			Missing string        `query:"missing"`
+			ignored string        `query:"ignored"`
+		}
+
+		request := newBindRequest("")
+		request.Vars.Add("id", "42")
+		request.Params.
+			Add("tag", "a").Add("tag", "b").
+			Add("verbose", "true").
+			Add("ratio", "0.5").
+			Add("timeout", "1m30s").
+			Add("since", "2024-05-01").
+			Add("limit", "50").
+			Add("ignored", "value")
+		request.Headers.
+			Add("x-token", "secret").
+			Add("x-addr", "127.0.0.1").
+			Add("cookie", "session=abc")
+
+		var m model
+		require.NoError(t, Bind(request, &m))
+		require.Equal(t, uint64(42), m.ID)
+		require.Equal(t, []string{"a", "b"}, m.Tags)
+		require.True(t, m.Verbose)
+		require.Equal(t, 0.5, *m.Ratio)
+		require.Equal(t, 90*time.Second, m.Timeout)
+		require.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), m.Since)
+		require.Equal(t, "secret", m.Token)
+		require.Equal(t, netip.MustParseAddr("127.0.0.1"), m.Addr)
+		require.Equal(t, "abc", m.Session)
+		require.Equal(t, Pagination{Page: 1, Limit: 50}, m.Pagination)
+		require.Empty(t, m.Missing)
+		require.Empty(t, m.ignored)
+	})
+
+	t.Run("JSON body", func(t *testing.T) {
+		type model struct {
+			ID   int    `json:"-" path:"id"`
+			Name string `json:"name"`
+		}
+
+		request := newBindRequest(mime.JSON, `{"name": "Pavlo"}`)
+		request.Vars.Add("id", "1")
+
+		var m model
+		require.NoError(t, Bind(request, &m))
+		require.Equal(t, model{ID: 1, Name: "Pavlo"}, m)
+	})
+
+	t.Run("form", func(t *testing.T) {
+		type model struct {
+			Name   string    `form:"name"`
+			Age    int       `form:"age"`
+			Avatar form.Data `form:"avatar"`
+		}
+
+		request := newBindRequest(mime.Multipart+"; boundary=b",
+			"--b\r\nContent-Disposition: form-data; name=name\r\n\r\nPavlo\r\n",
+			"--b\r\nContent-Disposition: form-data; name=age\r\n\r\n20\r\n",
+			"--b\r\nContent-Disposition: form-data; name=avatar; filename=a.png\r\n",
+			"Content-Type: image/png\r\n\r\npng\r\n--b--\r\n",
+		)
+
+		var m model
+		require.NoError(t, Bind(request, &m))
+		require.Equal(t, "Pavlo", m.Name)
+		require.Equal(t, 20, m.Age)
+		require.Equal(t, "a.png", m.Avatar.Filename)
+		require.Equal(t, "png", m.Avatar.Value)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		type model struct {
+			Page    int       `query:"page"`
+			Verbose bool      `query:"verbose"`
+			Since   time.Time `header:"since"`
+			Name    string    `json:"name"`
+		}
+
+		request := newBindRequest(mime.JSON, `{"name": `)
+		request.Params.Add("page", "first").Add("verbose", "maybe")
+		request.Headers.Add("since", "yesterday")
+
+		var m model
+		err := Bind(request, &m)
+		var bindErr BindError
+		require.True(t, errors.As(err, &bindErr))
+		require.Len(t, bindErr, 4)
+		require.Equal(t, SourceBody, bindErr[0].Source)
+		require.Equal(t, FieldError{Field: "Page", Source: SourceQuery, Key: "page", Err: errors.New("invalid integer")}, bindErr[1])
+		require.Equal(t, "Verbose", bindErr[2].Field)
+		require.Equal(t, "Since", bindErr[3].Field)
+		require.True(t, errors.Is(err, status.ErrBadRequest))
+
+		resp := Error(request, err)
+		require.Equal(t, status.BadRequest, resp.fields.Code)
+		body, err := io.ReadAll(resp.fields.Stream)
+		require.NoError(t, err)
+		require.Contains(t, string(body), `query "page": invalid integer`)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		type model struct {
+			ID   int    `json:"-" path:"id" validate:"required"`
+			Name string `json:"name" validate:"min=2"`
+		}
+
+		request := newBindRequest(mime.JSON, `{"name": "P"}`)
+		request.cfg.Validator = validate.New()
+
+		err := Bind(request, &model{})
+		require.Equal(t, validate.Errors{
+			{Field: "ID", Rule: "required", Message: "is required"},
+			{Field: "name", Rule: "min", Message: "must be at least 2 characters long"},
+		}, err)
+
+		resp := Error(request, err)
+		require.Equal(t, status.UnprocessableEntity, resp.fields.Code)
+		require.Equal(t, mime.JSON, kv.NewFromPairs(resp.fields.Headers).Value("Content-Type"))
+	})
+
+	t.Run("body error", func(t *testing.T) {
+		type model struct {
+			Name string `json:"name"`
+		}
+
+		request := newBindRequest(mime.YAML, "name: Pavlo")
+		require.Equal(t, status.ErrUnsupportedMediaType, Bind(request, &model{}))
+	})
+
+	t.Run("no body fields", func(t *testing.T) {
+		type model struct {
+			ID     uint64 `path:"id"`
+			Page   int    `query:"page"`
+			Secret string `json:"-"`
+		}
+
+		request := newBindRequest(mime.Plain, "just some text")
+		request.Vars.Add("id", "42")
+		request.Params.Add("page", "2")
+
+		var m model
+		require.NoError(t, Bind(request, &m))
+		require.Equal(t, model{ID: 42, Page: 2}, m)
+
+		data, err := request.Body.Bytes()
+		require.NoError(t, err)
+		require.Equal(t, "just some text", string(data))
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		require.Panics(t, func() {
+			var n int
+			_ = Bind(newBindRequest(""), &n)
+		})
+	})
+}
@@ -1,8 +1,13 @@
 package http
 
 import (
+	"bytes"
+	"cmp"
 	"io"
+	"iter"
+	"os"
 	"slices"
+	"strings"
 
 	"github.com/flrdv/uf"
 	"github.com/indigo-web/indigo/http/form"
@@ -10,7 +15,7 @@ import (
 	"github.com/indigo-web/indigo/http/status"
 	"github.com/indigo-web/indigo/internal/formdata"
 	"github.com/indigo-web/indigo/internal/strutil"
-	json "github.com/json-iterator/go"
+	"github.com/indigo-web/indigo/kv"
 )
 
 // Fetcher abstracts the underlying protocol-dependant body source. Even though the signature
@@ -30,6 +35,10 @@ type Body struct {
 	formbuff []byte
 	pending  []byte
 	form     form.Form
+	// multipart and part are reused by Multipart
+	multipart *formdata.Reader
+	part      form.Part
+	files     []*os.File
 }
 
 func NewBody(src Fetcher) *Body {
@@ -123,34 +132,72 @@ func (b *Body) Read(into []byte) (n int, err error) {
 	return n, err
 }
 
-// JSON convoys the request's body to a json unmarshaller automatically and behaves
-// in a similar manner.
+// JSON decodes the request's body into the model by the codec set in config.Config.JSON.
+// The body is decoded as a stream, so it isn't buffered completely unless already done so.
+// The decoded model is then validated by the config.Config.Validator if set, returning
+// validate.Errors on violations.
 //
 // Please note: this method cannot be used on requests with Content-Type incompatible
-// with mime.JSON (in this case, status.ErrUnsupportedMediaType is returned).
-//
-// TODO: make possible to choose and use different from json-iterator json marshall/unmarshall
+// with mime.JSON (in this case, status.ErrUnsupportedMediaType is returned). Requests without
+// the Content-Type are considered JSON.
 func (b *Body) JSON(model any) error {
 	if !mime.Complies(mime.JSON, b.request.ContentType) {
 		return status.ErrUnsupportedMediaType
 	}
 
-	data, err := b.Bytes()
-	if err != nil {
+	if err := b.request.cfg.JSON.Decode(b.source(), model); err != nil {
+		return err
+	}
+
+	return b.request.cfg.Validator.Struct(model)
+}
+
+// Decode decodes the request's body into the model by the codec chosen by the Content-Type.
+// JSON, as well as media types with the +json suffix, is decoded by the codec set in
+// config.Config.JSON. So is the body without the Content-Type, the same way JSON does. Other
+// codecs are looked up in the config.Config.Media. If there's none, status.ErrUnsupportedMediaType
+// is returned. The decoded model is validated the same way as by JSON.
+func (b *Body) Decode(model any) error {
+	if err := b.decode(model); err != nil {
 		return err
 	}
 
-	iterator := json.ConfigDefault.BorrowIterator(data)
-	iterator.ReadVal(model)
-	err = iterator.Error
-	json.ConfigDefault.ReturnIterator(iterator)
+	return b.request.cfg.Validator.Struct(model)
+}
+
+func (b *Body) decode(model any) error {
+	contentType, _ := strutil.CutHeader(b.request.ContentType)
+	contentType = strings.TrimSpace(contentType)
+	if len(contentType) == 0 || strutil.CmpFoldSafe(contentType, mime.JSON) || strings.HasSuffix(contentType, "+json") {
+		return b.request.cfg.JSON.Decode(b.source(), model)
+	}
+
+	codec := b.request.cfg.Media.Lookup(contentType)
+	if codec == nil {
+		return status.ErrUnsupportedMediaType
+	}
+
+	return codec.Decode(b.source(), model)
+}
+
+// source returns the reader of the body. If the body was already consumed by Bytes, the
+// buffered data is read instead.
+func (b *Body) source() io.Reader {
+	if len(b.buff) != 0 {
+		return bytes.NewReader(b.buff)
+	}
 
-	return err
+	return b
 }
 
-// Form interprets the request's body as a mime.FormUrlencoded data and
-// returns parsed key-value pairs. If the request's MIME type is defined and is different
-// from mime.FormUrlencoded, status.ErrUnsupportedMediaType is returned
+// Form interprets the request's body as either mime.FormUrlencoded or mime.Multipart data and
+// returns parsed key-value pairs. If the request's MIME type is defined and is neither of them,
+// status.ErrUnsupportedMediaType is returned.
+//
+// Multipart bodies are parsed as a stream, unless already buffered. Files exceeding the
+// config.BodyForm.SpillThreshold are written into temporary files, which are removed after
+// the request is processed. Other values exceeding the config.BodyForm.MaxFieldSize result
+// in status.ErrRequestEntityTooLarge.
 func (b *Body) Form() (f form.Form, err error) {
 	if b.form == nil {
 		b.form = make(form.Form, b.request.cfg.Body.Form.EntriesPrealloc)
@@ -159,27 +206,217 @@ func (b *Body) Form() (f form.Form, err error) {
 		b.formbuff = make([]byte, b.request.cfg.Body.Form.BufferPrealloc)
 	}
 
-	raw, err := b.Bytes()
-	if err != nil {
-		return nil, err
-	}
-
 	switch {
 	case mime.Complies(mime.FormUrlencoded, b.request.ContentType):
+		raw, err := b.Bytes()
+		if err != nil {
+			return nil, err
+		}
+
 		f, b.formbuff, err = formdata.ParseFormURLEncoded(b.form[:0], raw, b.formbuff[:0])
 		return f, err
 	case mime.Complies(mime.Multipart, b.request.ContentType):
+		if len(b.buff) == 0 {
+			return b.multipartForm()
+		}
+
 		boundary, ok := b.multipartBoundary()
 		if !ok {
 			return nil, status.ErrBadRequest
 		}
 
-		return formdata.ParseMultipart(b.request.cfg, b.form[:0], raw, b.formbuff[:0], boundary)
+		return formdata.ParseMultipart(b.request.cfg, b.form[:0], b.buff, b.formbuff[:0], boundary)
 	default:
 		return nil, status.ErrUnsupportedMediaType
 	}
 }
 
+// Multipart iterates over the parts of the mime.Multipart body. The parts are read directly
+// from the connection, so the body is never held in memory as a whole. A part is valid until
+// the next one is requested, and its unread content is skipped. Any error terminates the
+// iteration.
+//
+// Please note: this method cannot be used on requests with Content-Type incompatible
+// with mime.Multipart (in this case, status.ErrUnsupportedMediaType is returned).
+func (b *Body) Multipart() iter.Seq2[*form.Part, error] {
+	return func(yield func(*form.Part, error) bool) {
+		if !mime.Complies(mime.Multipart, b.request.ContentType) {
+			yield(nil, status.ErrUnsupportedMediaType)
+			return
+		}
+
+		boundary, ok := b.multipartBoundary()
+		if !ok {
+			yield(nil, status.ErrBadRequest)
+			return
+		}
+
+		if b.multipart == nil {
+			b.multipart = formdata.NewReader(b.request.cfg.Headers.Space.Maximal)
+			b.part.Headers = kv.New()
+		}
+
+		b.multipart.Reset(b.source(), boundary)
+		cfg := b.request.cfg.Body.Form
+		charset := cfg.DefaultCoding
+
+		for {
+			hdr, content, err := b.multipart.Next()
+			switch err {
+			case nil:
+			case io.EOF:
+				return
+			default:
+				yield(nil, err)
+				return
+			}
+
+			if hdr.Name == "_charset_" {
+				// sets the default charset of the following parts (RFC 7578, 4.6).
+				value, err := io.ReadAll(io.LimitReader(content, maxCharsetLen))
+				if err != nil || len(value) == 0 {
+					yield(nil, status.ErrBadRequest)
+					return
+				}
+
+				charset = mime.Charset(value)
+				continue
+			}
+
+			b.part = form.Part{
+				Name:     hdr.Name,
+				Filename: hdr.File,
+				Type:     cmp.Or(hdr.ContentType, cfg.DefaultContentType),
+				Charset:  cmp.Or(hdr.Charset, charset),
+				Headers:  hdr.Fields(b.part.Headers.Clear()),
+				Reader:   content,
+			}
+
+			if !yield(&b.part, nil) {
+				return
+			}
+		}
+	}
+}
+
+// maxCharsetLen limits the length of the _charset_ field value.
+const maxCharsetLen = 64
+
+func (b *Body) multipartForm() (form.Form, error) {
+	var (
+		f         = b.form[:0]
+		buff      = b.formbuff[:0]
+		threshold = b.request.cfg.Body.Form.SpillThreshold
+		maxField  = b.request.cfg.Body.Form.MaxFieldSize
+	)
+
+	for part, err := range b.Multipart() {
+		if err != nil {
+			return nil, err
+		}
+
+		// the part is valid only until the next one is requested, so its properties are copied.
+		var data form.Data
+		buff, data.Name = appendString(buff, part.Name)
+		buff, data.Filename = appendString(buff, part.Filename)
+		buff, data.Type = appendString(buff, part.Type)
+		buff, data.Charset = appendString(buff, part.Charset)
+
+		isFile := len(part.Filename) > 0
+		limit := maxField
+		if isFile {
+			limit = threshold
+		}
+
+		offset := len(buff)
+		buff, err = readAll(buff, part, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		exceeds := int64(len(buff)-offset) > limit
+		if exceeds && !isFile {
+			return nil, status.ErrRequestEntityTooLarge
+		}
+
+		if exceeds {
+			data.File, data.Size, err = b.spill(buff[offset:], part)
+			if err != nil {
+				return nil, err
+			}
+
+			buff = buff[:offset]
+		} else {
+			data.Value = uf.B2S(buff[offset:])
+		}
+
+		f = append(f, data)
+	}
+
+	b.formbuff = buff
+
+	return f, nil
+}
+
+// spill writes the content into a temporary file, which is removed by Cleanup.
+func (b *Body) spill(head []byte, rest io.Reader) (*os.File, int64, error) {
+	file, err := os.CreateTemp(b.request.cfg.Body.Form.TempDir, "indigo-form-*")
+	if err != nil {
+		return nil, 0, status.ErrInternalServerError
+	}
+
+	b.files = append(b.files, file)
+
+	if _, err = file.Write(head); err != nil {
+		return nil, 0, status.ErrInternalServerError
+	}
+
+	n, err := io.Copy(file, rest)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return file, int64(len(head)) + n, nil
+}
+
+// Cleanup removes the temporary files created by Form. It's called automatically after the
+// request is processed.
+func (b *Body) Cleanup() {
+	for _, file := range b.files {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}
+
+	b.files = b.files[:0]
+}
+
+func appendString(buff []byte, str string) ([]byte, string) {
+	offset := len(buff)
+	buff = append(buff, str...)
+	return buff, uf.B2S(buff[offset:])
+}
+
+// readAll appends the data read from the reader until either the EOF or exceeding the limit.
+// Negative limit means no limit.
+func readAll(buff []byte, r io.Reader, limit int64) ([]byte, error) {
+	offset := len(buff)
+
+	for limit < 0 || int64(len(buff)-offset) <= limit {
+		buff = slices.Grow(buff, 512)
+		n, err := r.Read(buff[len(buff):cap(buff)])
+		buff = buff[:len(buff)+n]
+		switch err {
+		case nil:
+		case io.EOF:
+			return buff, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return buff, nil
+}
+
 func (b *Body) Len() int {
 	if b.request.Chunked {
 		return -1
@@ -207,6 +444,7 @@ func (b *Body) Reset(request *Request) {
 	b.buff = b.buff[:0]
 	b.pending = b.pending[:0]
 	b.request = request
+	b.Cleanup()
 }
 
 func (b *Body) multipartBoundary() (boundary string, ok bool) {
@@ -2,11 +2,14 @@ package http
 
 import (
 	"io"
+	"os"
 	"testing"
 
 	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http/json"
 	"github.com/indigo-web/indigo/http/mime"
 	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/http/validate"
 	"github.com/indigo-web/indigo/transport/dummy"
 	"github.com/stretchr/testify/require"
 )
@@ -126,6 +129,94 @@ func TestBody(t *testing.T) {
 			_, err := parseJSON(mime.HTTP, jsonSample)
 			require.EqualError(t, err, status.ErrUnsupportedMediaType.Error())
 		})
+
+		t.Run("streaming", func(t *testing.T) {
+			body := newBody(`{"Hel`, `lo": "wo`, `rld"}`)
+			body.request = newRequest(mime.JSON)
+
+			var m sampleModel
+			require.NoError(t, body.JSON(&m))
+			require.Equal(t, "world", m.Hello)
+		})
+
+		t.Run("after Bytes", func(t *testing.T) {
+			body := newBody(jsonSample)
+			body.request = newRequest(mime.JSON)
+			_, err := body.Bytes()
+			require.NoError(t, err)
+
+			var m sampleModel
+			require.NoError(t, body.JSON(&m))
+			require.Equal(t, "world", m.Hello)
+		})
+
+		t.Run("custom codec", func(t *testing.T) {
+			body := newBody(`{"Hello": "world", "unknown": 1}`)
+			body.request = newRequest(mime.JSON)
+			body.request.cfg.JSON = json.Std(json.StdOptions{DisallowUnknownFields: true})
+
+			var m sampleModel
+			require.Error(t, body.JSON(&m))
+		})
+
+		t.Run("validation", func(t *testing.T) {
+			type model struct {
+				Name string `json:"name" validate:"required"`
+				Age  int    `json:"age" validate:"min=18"`
+			}
+
+			body := newBody(`{"age": 16}`)
+			body.request = newRequest(mime.JSON)
+			body.request.cfg.Validator = validate.New()
+
+			var m model
+			err := body.JSON(&m)
+			require.Equal(t, validate.Errors{
+				{Field: "name", Rule: "required", Message: "is required"},
+				{Field: "age", Rule: "min", Message: "must be at least 18"},
+			}, err)
+
+			body = newBody(`{"age": 16}`)
+			body.request = newRequest(mime.JSON)
+			require.NoError(t, body.JSON(&m), "the validation is disabled by default")
+		})
+	})
+
+	t.Run("Decode", func(t *testing.T) {
+		type sampleModel struct {
+			Hello string `json:"Hello" xml:"Hello"`
+		}
+
+		decode := func(contentType string, data string) (sampleModel, error) {
+			body := newBody(data)
+			body.request = &Request{
+				cfg: config.Default(),
+				commonHeaders: commonHeaders{
+					ContentType: contentType,
+				},
+			}
+
+			var m sampleModel
+			err := body.Decode(&m)
+			return m, err
+		}
+
+		for _, contentType := range []string{mime.JSON, "application/problem+json"} {
+			m, err := decode(contentType, `{"Hello": "world"}`)
+			require.NoError(t, err)
+			require.Equal(t, "world", m.Hello)
+		}
+
+		m, err := decode(mime.XML+"; charset=utf-8", `<sampleModel><Hello>world</Hello></sampleModel>`)
+		require.NoError(t, err)
+		require.Equal(t, "world", m.Hello)
+
+		_, err = decode(mime.YAML, "Hello: world")
+		require.EqualError(t, err, status.ErrUnsupportedMediaType.Error())
+
+		m, err = decode("", `{"Hello": "world"}`)
+		require.NoError(t, err)
+		require.Equal(t, "world", m.Hello)
 	})
 
 	t.Run("Form", func(t *testing.T) {
@@ -158,6 +249,73 @@ func TestBody(t *testing.T) {
 			require.Equal(t, "bar", bar.Value)
 		})
 
+		t.Run("multipart after Bytes", func(t *testing.T) {
+			body := newBody("--foo\r\nContent-Disposition: form-data; name=foo\r\n\r\nbar\r\n--foo--\r\n")
+			body.request = newRequest(mime.Multipart + "; boundary=foo")
+			_, err := body.Bytes()
+			require.NoError(t, err)
+			form, err := body.Form()
+			require.NoError(t, err)
+			bar, found := form.Name("foo")
+			require.True(t, found)
+			require.Equal(t, "bar", bar.Value)
+		})
+
+		t.Run("spill", func(t *testing.T) {
+			const (
+				small = "small file"
+				large = "a rather large file, exceeding the threshold"
+			)
+
+			body := newBody(
+				"--foo\r\nContent-Disposition: form-data; name=field\r\n\r\n"+large+"\r\n",
+				"--foo\r\nContent-Disposition: form-data; name=a; filename=a.txt\r\n\r\n"+small+"\r\n",
+				"--foo\r\nContent-Disposition: form-data; name=b; filename=b.txt\r\n\r\n"+large+"\r\n",
+				"--foo--\r\n",
+			)
+			body.request = newRequest(mime.Multipart + "; boundary=foo")
+			body.request.cfg.Body.Form.SpillThreshold = int64(len(small))
+			body.request.cfg.Body.Form.TempDir = t.TempDir()
+
+			f, err := body.Form()
+			require.NoError(t, err)
+			require.Len(t, f, 3)
+
+			field, _ := f.Name("field")
+			require.Equal(t, large, field.Value)
+			require.Nil(t, field.File)
+
+			a, _ := f.Name("a")
+			require.Equal(t, small, a.Value)
+			require.Nil(t, a.File)
+
+			b, _ := f.Name("b")
+			require.Empty(t, b.Value)
+			require.NotNil(t, b.File)
+			require.Equal(t, int64(len(large)), b.Size)
+			content, err := io.ReadAll(b.Reader())
+			require.NoError(t, err)
+			require.Equal(t, large, string(content))
+
+			body.Cleanup()
+			_, err = os.Stat(b.File.Name())
+			require.True(t, os.IsNotExist(err))
+		})
+
+		t.Run("oversized field", func(t *testing.T) {
+			const value = "a rather large value, exceeding the limit"
+
+			body := newBody(
+				"--foo\r\nContent-Disposition: form-data; name=field\r\n\r\n"+value+"\r\n",
+				"--foo--\r\n",
+			)
+			body.request = newRequest(mime.Multipart + "; boundary=foo")
+			body.request.cfg.Body.Form.MaxFieldSize = int64(len(value)) - 1
+
+			_, err := body.Form()
+			require.EqualError(t, err, status.ErrRequestEntityTooLarge.Error())
+		})
+
 		t.Run("incompatible", func(t *testing.T) {
 			body := newBody("hello")
 			body.request = newRequest(mime.HTTP)
@@ -166,6 +324,93 @@ func TestBody(t *testing.T) {
 		})
 	})
 
+	t.Run("Multipart", func(t *testing.T) {
+		newMultipart := func(data ...string) *Body {
+			body := newBody(data...)
+			body.request = &Request{
+				cfg: config.Default(),
+				commonHeaders: commonHeaders{
+					ContentType: mime.Multipart + "; boundary=foo",
+				},
+			}
+
+			return body
+		}
+
+		t.Run("parts", func(t *testing.T) {
+			body := newMultipart(
+				"--foo\r\nContent-Disposition: form-data; name=_charset_\r\n\r\niso-8859-1\r\n",
+				"--foo\r\nContent-Disposition: form-data; name=text\r\n\r\nhello\r\n",
+				"--fo", "o\r\nContent-Disposition: form-data; name=file; filename=f.bin\r\n",
+				"Content-Type: application/octet-stream\r\nX-Custom: yes\r\n\r\nbin", "ary\r\n--foo--",
+			)
+
+			var names, values []string
+			for part, err := range body.Multipart() {
+				require.NoError(t, err)
+				names = append(names, part.Name)
+				value, err := io.ReadAll(part)
+				require.NoError(t, err)
+				values = append(values, string(value))
+				require.Equal(t, "iso-8859-1", part.Charset)
+
+				if part.Name == "file" {
+					require.Equal(t, "f.bin", part.Filename)
+					require.Equal(t, mime.OctetStream, part.Type)
+					require.Equal(t, "yes", part.Headers.Value("x-custom"))
+				} else {
+					require.Equal(t, mime.Plain, part.Type)
+				}
+			}
+
+			require.Equal(t, []string{"text", "file"}, names)
+			require.Equal(t, []string{"hello", "binary"}, values)
+		})
+
+		t.Run("skip unread", func(t *testing.T) {
+			body := newMultipart(
+				"--foo\r\nContent-Disposition: form-data; name=a\r\n\r\nskipped\r\n",
+				"--foo\r\nContent-Disposition: form-data; name=b\r\n\r\nread\r\n--foo--\r\n",
+			)
+
+			var last string
+			for part, err := range body.Multipart() {
+				require.NoError(t, err)
+				if part.Name == "b" {
+					value, err := io.ReadAll(part)
+					require.NoError(t, err)
+					last = string(value)
+				}
+			}
+
+			require.Equal(t, "read", last)
+		})
+
+		t.Run("malformed", func(t *testing.T) {
+			body := newMultipart("--foo\r\nContent-Disposition: form-data; name=a\r\n\r\ntruncated")
+			var errs []error
+			for part, err := range body.Multipart() {
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+
+				_, err = io.ReadAll(part)
+				require.Equal(t, status.ErrBadRequest, err)
+			}
+
+			require.Equal(t, []error{status.ErrBadRequest}, errs)
+		})
+
+		t.Run("incompatible", func(t *testing.T) {
+			body := newMultipart("hello")
+			body.request.ContentType = mime.JSON
+			for _, err := range body.Multipart() {
+				require.Equal(t, status.ErrUnsupportedMediaType, err)
+			}
+		})
+	})
+
 	t.Run("reader", func(t *testing.T) {
 		data := dummy.NewMockClient([]byte("Hello, world!"))
 		request := &Request{cfg: config.Default()}
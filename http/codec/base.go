@@ -50,14 +50,12 @@ type baseInstance struct {
 	buff    []byte
 }
 
-func newBaseInstance(encoder writeResetter, decoder io.Reader, reset decoderResetter) instantiator {
-	return func() Instance {
-		return &baseInstance{
-			reset:   reset,
-			adapter: newAdapter(),
-			w:       encoder,
-			r:       decoder,
-		}
+func newBaseInstance(encoder writeResetter, decoder io.Reader, reset decoderResetter) Instance {
+	return &baseInstance{
+		reset:   reset,
+		adapter: newAdapter(),
+		w:       encoder,
+		r:       decoder,
 	}
 }
 
@@ -74,6 +72,15 @@ func (b *baseInstance) Write(p []byte) (n int, err error) {
 	return b.w.Write(p)
 }
 
+// Flush emits the data compressed so far without finalizing the stream.
+func (b *baseInstance) Flush() error {
+	if f, ok := b.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
 func (b *baseInstance) Close() error {
 	if err := b.w.Close(); err != nil {
 		return err
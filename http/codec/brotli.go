@@ -0,0 +1,39 @@
+package codec
+
+import (
+	"github.com/andybalholm/brotli"
+)
+
+// BrotliOptions are the parameters of the brotli compressor.
+type BrotliOptions struct {
+	// Quality controls the compression speed vs density trade-off. The range is from 1 to 11,
+	// the higher the quality, the slower the compression. Zero selects the default one.
+	Quality int
+	// Window is the base 2 logarithm of the sliding window size. The range is from 10 to 24,
+	// 0 selects it automatically based on the Quality.
+	Window int
+}
+
+// NewBrotli returns the brotli codec. By default, quality 4 is used, as it compresses denser
+// than gzip does at the comparable speed, which suits on-the-fly compression. Only the first
+// options are considered, all others are ignored.
+func NewBrotli(options ...BrotliOptions) Codec {
+	var opts BrotliOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	if opts.Quality == 0 {
+		opts.Quality = 4
+	}
+
+	return newBaseCodec("br", func() Instance {
+		writer := brotli.NewWriterOptions(nil, brotli.WriterOptions{
+			Quality: opts.Quality,
+			LGWin:   opts.Window,
+		})
+		reader := brotli.NewReader(nil)
+
+		return newBaseInstance(writer, reader, genericResetter)
+	})
+}
@@ -0,0 +1,24 @@
+package codec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrotli(t *testing.T) {
+	testCodec(t, NewBrotli().New())
+
+	t.Run("options", func(t *testing.T) {
+		testCodec(t, NewBrotli(BrotliOptions{Quality: 11, Window: 10}).New())
+	})
+
+	t.Run("zero quality", func(t *testing.T) {
+		text := strings.Repeat("Hello, world! Lorem ipsum! ", 100)
+		require.Equal(t,
+			compress(NewBrotli().New(), text),
+			compress(NewBrotli(BrotliOptions{}).New(), text),
+		)
+	})
+}
@@ -22,6 +22,13 @@ type Compressor interface {
 	ResetCompressor(w io.Writer)
 }
 
+// Flusher is implemented by compressors able to emit the data compressed so far without
+// finalizing the stream. Unbuffered responses, e.g. server-sent events, are flushed after
+// every write, so the compression doesn't hold them back.
+type Flusher interface {
+	Flush() error
+}
+
 type Decompressor interface {
 	http.Fetcher
 	ResetDecompressor(source http.Fetcher, bufferSize int) error
@@ -31,6 +38,7 @@ type Decompressor interface {
 //   - gzip
 //   - deflate
 //   - zstd
+//   - br
 func Suit() []Codec {
-	return []Codec{NewGZIP(), NewDeflate(), NewZSTD()}
+	return []Codec{NewGZIP(), NewDeflate(), NewZSTD(), NewBrotli()}
 }
@@ -6,16 +6,31 @@ import (
 	"github.com/klauspost/compress/flate"
 )
 
-func NewDeflate() Codec {
-	writer, err := flate.NewWriter(nil, 5)
-	if err != nil {
+// DeflateOptions are the parameters of the deflate compressor.
+type DeflateOptions struct {
+	// Level is the compression level in range from 1 (best speed) to 9 (best compression).
+	// Zero selects the default one, which is 5.
+	Level int
+}
+
+// NewDeflate returns the deflate codec. Only the first options are considered, all others
+// are ignored.
+func NewDeflate(options ...DeflateOptions) Codec {
+	level := 5
+	if len(options) > 0 && options[0].Level != 0 {
+		level = options[0].Level
+	}
+
+	if _, err := flate.NewWriter(nil, level); err != nil {
 		panic(err)
 	}
 
-	reader := flate.NewReader(nil)
-	instantiator := newBaseInstance(writer, reader, func(r io.Reader, a *readerAdapter) error {
-		return r.(flate.Resetter).Reset(a, nil)
-	})
+	return newBaseCodec("deflate", func() Instance {
+		writer, _ := flate.NewWriter(nil, level)
+		reader := flate.NewReader(nil)
 
-	return newBaseCodec("deflate", instantiator)
+		return newBaseInstance(writer, reader, func(r io.Reader, a *readerAdapter) error {
+			return r.(flate.Resetter).Reset(a, nil)
+		})
+	})
 }
@@ -2,8 +2,20 @@ package codec
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestFlate(t *testing.T) {
 	testCodec(t, NewDeflate().New())
+
+	t.Run("options", func(t *testing.T) {
+		testCodec(t, NewDeflate(DeflateOptions{Level: 9}).New())
+	})
+
+	t.Run("bad level", func(t *testing.T) {
+		require.Panics(t, func() {
+			NewDeflate(DeflateOptions{Level: 42})
+		})
+	})
 }
@@ -4,10 +4,28 @@ import (
 	"github.com/klauspost/compress/gzip"
 )
 
-func NewGZIP() Codec {
-	writer := gzip.NewWriter(nil)
-	reader := new(gzip.Reader)
-	instantiator := newBaseInstance(writer, reader, genericResetter)
+// GZIPOptions are the parameters of the gzip compressor.
+type GZIPOptions struct {
+	// Level is the compression level in range from 1 (best speed) to 9 (best compression).
+	// Zero selects the default one.
+	Level int
+}
+
+// NewGZIP returns the gzip codec. Only the first options are considered, all others are ignored.
+func NewGZIP(options ...GZIPOptions) Codec {
+	level := gzip.DefaultCompression
+	if len(options) > 0 && options[0].Level != 0 {
+		level = options[0].Level
+	}
+
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		panic(err)
+	}
+
+	return newBaseCodec("gzip", func() Instance {
+		writer, _ := gzip.NewWriterLevel(nil, level)
+		reader := new(gzip.Reader)
 
-	return newBaseCodec("gzip", instantiator)
+		return newBaseInstance(writer, reader, genericResetter)
+	})
 }
@@ -75,4 +75,53 @@ func testCodec(t *testing.T, inst Instance) {
 
 func TestGZIP(t *testing.T) {
 	testCodec(t, NewGZIP().New())
+
+	t.Run("options", func(t *testing.T) {
+		testCodec(t, NewGZIP(GZIPOptions{Level: 1}).New())
+	})
+
+	t.Run("independent instances", func(t *testing.T) {
+		c := NewGZIP()
+		a, b := c.New(), c.New()
+		a.ResetCompressor(io.Discard)
+		_, err := a.Write([]byte("Hello, "))
+		require.NoError(t, err)
+
+		// writing into another instance must not interfere with the first one.
+		result, err := decompress(b, compress(b, "world!"))
+		require.NoError(t, err)
+		require.Equal(t, "world!", result)
+	})
+}
+
+func TestInstances(t *testing.T) {
+	for _, codec := range []Codec{NewGZIP(), NewDeflate(), NewZSTD()} {
+		t.Run(codec.Token(), func(t *testing.T) {
+			// instances are used by different connections simultaneously, so they must not
+			// share the state.
+			a, b := codec.New(), codec.New()
+			first, second := dummy.NewMockClient().Journaling(), dummy.NewMockClient().Journaling()
+			a.ResetCompressor(first)
+			b.ResetCompressor(second)
+
+			for _, step := range []struct {
+				inst Instance
+				text string
+			}{{a, "Hello, "}, {b, "Lorem "}, {a, "world!"}, {b, "ipsum!"}} {
+				_, err := step.inst.Write([]byte(step.text))
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, a.Close())
+			require.NoError(t, b.Close())
+
+			result, err := decompress(codec.New(), first.Written())
+			require.NoError(t, err)
+			require.Equal(t, "Hello, world!", result)
+
+			result, err = decompress(codec.New(), second.Written())
+			require.NoError(t, err)
+			require.Equal(t, "Lorem ipsum!", result)
+		})
+	}
 }
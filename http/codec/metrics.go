@@ -0,0 +1,18 @@
+package codec
+
+import "sync/atomic"
+
+// Metrics counts request bodies rejected due to the decompression limits, as set in
+// config.Body.Decompression. The counters are shared across all the connections and
+// can be read at any moment.
+type Metrics struct {
+	// Size counts bodies exceeding the decompressed size limit.
+	Size atomic.Uint64
+	// Ratio counts bodies exceeding the expansion ratio limit.
+	Ratio atomic.Uint64
+}
+
+// Rejected returns the total number of rejected bodies.
+func (m *Metrics) Rejected() uint64 {
+	return m.Size.Load() + m.Ratio.Load()
+}
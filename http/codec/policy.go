@@ -0,0 +1,75 @@
+package codec
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/internal/strutil"
+)
+
+// Incompressible lists the content types skipped by the default policy. Those are already
+// compressed by their nature, so compressing them once again wastes the CPU time for nothing.
+var Incompressible = []mime.MIME{
+	mime.ZIP, mime.GZIP, mime.ZLIB, mime.ZSTD,
+	"application/x-7z-compressed", "application/vnd.rar", "application/x-bzip2", "application/x-xz",
+	mime.PNG, mime.JPEG, mime.GIF, mime.WEBP, mime.AVIF,
+	"font/woff", "font/woff2",
+	"audio/*", "video/*",
+}
+
+// Policy decides whether responses are compressed automatically based on their content types.
+// Patterns ending with /* match the whole type, e.g. video/*. Responses without a content type
+// are always compressible.
+type Policy struct {
+	skip []mime.MIME
+}
+
+// NewPolicy returns the policy skipping the Incompressible content types.
+func NewPolicy() *Policy {
+	return new(Policy).Skip(Incompressible...)
+}
+
+// Skip excludes the content types from automatic compression.
+func (p *Policy) Skip(types ...mime.MIME) *Policy {
+	p.skip = append(p.skip, types...)
+	return p
+}
+
+// Allow reverts the content types skipped before. The patterns are compared literally, so
+// allowing image/png doesn't affect the image/* pattern.
+func (p *Policy) Allow(types ...mime.MIME) *Policy {
+	p.skip = slices.DeleteFunc(p.skip, func(skipped mime.MIME) bool {
+		return slices.ContainsFunc(types, func(allowed mime.MIME) bool {
+			return strutil.CmpFoldSafe(skipped, allowed)
+		})
+	})
+
+	return p
+}
+
+// Compressible tells whether the response of the content type may be compressed automatically.
+// Nil policy considers everything compressible.
+func (p *Policy) Compressible(contentType string) bool {
+	if p == nil {
+		return true
+	}
+
+	contentType, _ = strutil.CutHeader(contentType)
+	contentType = strings.TrimSpace(contentType)
+	if len(contentType) == 0 {
+		return true
+	}
+
+	for _, pattern := range p.skip {
+		if prefix, found := strings.CutSuffix(pattern, "*"); found {
+			if len(contentType) >= len(prefix) && strutil.CmpFoldSafe(contentType[:len(prefix)], prefix) {
+				return false
+			}
+		} else if strutil.CmpFoldSafe(contentType, pattern) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,36 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		p := NewPolicy()
+		require.True(t, p.Compressible(mime.HTML))
+		require.True(t, p.Compressible("application/json; charset=utf8"))
+		require.True(t, p.Compressible(mime.SVG))
+		require.True(t, p.Compressible(""))
+		require.False(t, p.Compressible(mime.PNG))
+		require.False(t, p.Compressible(mime.ZIP))
+		require.False(t, p.Compressible(mime.GZIP))
+		require.False(t, p.Compressible("Video/MP4"))
+		require.False(t, p.Compressible("audio/ogg; codecs=opus"))
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		p := NewPolicy().Allow(mime.PNG, "video/*").Skip("application/x-custom")
+		require.True(t, p.Compressible(mime.PNG))
+		require.True(t, p.Compressible("video/mp4"))
+		require.False(t, p.Compressible("application/x-custom"))
+		require.False(t, p.Compressible(mime.JPEG))
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var p *Policy
+		require.True(t, p.Compressible(mime.PNG))
+	})
+}
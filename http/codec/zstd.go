@@ -4,18 +4,65 @@ import (
 	"github.com/klauspost/compress/zstd"
 )
 
-func NewZSTD() Codec {
-	w, err := zstd.NewWriter(nil)
-	if err != nil {
-		panic(err)
+// ZSTDOptions are the parameters of the zstd codec.
+type ZSTDOptions struct {
+	// Level is the compression level in the terms of the reference implementation, i.e. in range
+	// from 1 (best speed) to 22 (best compression). Zero selects the default one.
+	Level int
+	// Window is the base 2 logarithm of the window size in range from 10 to 29. Zero selects it
+	// automatically based on the Level.
+	Window int
+	// Dictionary is used both to compress and decompress. It must be in the zstd dictionary
+	// format, e.g. produced by `zstd --train`. Payloads compressed with a dictionary can be
+	// decompressed by the clients having the same dictionary only.
+	Dictionary []byte
+}
+
+// NewZSTD returns the zstd codec. Only the first options are considered, all others are ignored.
+func NewZSTD(options ...ZSTDOptions) Codec {
+	var opts ZSTDOptions
+	if len(options) > 0 {
+		opts = options[0]
 	}
 
-	r, err := zstd.NewReader(nil)
-	if err != nil {
-		panic(err)
+	// instances are owned by a single connection each, so there's no need in concurrency.
+	encoderOpts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	decoderOpts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+
+	if opts.Level != 0 {
+		encoderOpts = append(encoderOpts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.Level)))
+	}
+
+	if opts.Window != 0 {
+		encoderOpts = append(encoderOpts, zstd.WithWindowSize(1<<opts.Window))
 	}
 
-	instantiator := newBaseInstance(w, r, genericResetter)
+	if len(opts.Dictionary) > 0 {
+		encoderOpts = append(encoderOpts, zstd.WithEncoderDict(opts.Dictionary))
+		decoderOpts = append(decoderOpts, zstd.WithDecoderDicts(opts.Dictionary))
+	}
+
+	newInstance := func() (Instance, error) {
+		w, err := zstd.NewWriter(nil, encoderOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := zstd.NewReader(nil, decoderOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return newBaseInstance(w, r, genericResetter), nil
+	}
+
+	// validate the options in advance, so misconfiguration is revealed as early as possible.
+	if _, err := newInstance(); err != nil {
+		panic(err)
+	}
 
-	return newBaseCodec("zstd", instantiator)
+	return newBaseCodec("zstd", func() Instance {
+		inst, _ := newInstance()
+		return inst
+	})
 }
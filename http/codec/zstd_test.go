@@ -1,7 +1,46 @@
 package codec
 
-import "testing"
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
 
 func TestZSTD(t *testing.T) {
 	testCodec(t, NewZSTD().New())
+
+	t.Run("options", func(t *testing.T) {
+		testCodec(t, NewZSTD(ZSTDOptions{Level: 19, Window: 16}).New())
+	})
+
+	t.Run("dictionary", func(t *testing.T) {
+		var samples [][]byte
+		for i := range 64 {
+			samples = append(samples, []byte(fmt.Sprintf(
+				`{"id":%d,"name":"user-%d","email":"user%d@example.com","active":%t}`,
+				i, i*7, i*13, i%3 == 0,
+			)))
+		}
+
+		dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+			ID:       1,
+			Contents: samples,
+			History:  bytes.Join(samples[:16], nil),
+			Offsets:  [3]int{1, 4, 8},
+		})
+		require.NoError(t, err)
+
+		inst := NewZSTD(ZSTDOptions{Dictionary: dict}).New()
+		testCodec(t, inst)
+
+		text := `{"id":1000,"name":"user-7000","email":"user13000@example.com","active":false}`
+		withDict, withoutDict := compress(inst, text), compress(NewZSTD().New(), text)
+		require.Less(t, len(withDict), len(withoutDict))
+
+		_, err = decompress(NewZSTD().New(), withDict)
+		require.Error(t, err, "must not be decompressed without the dictionary")
+	})
 }
@@ -1,6 +1,13 @@
 package form
 
-import "iter"
+import (
+	"io"
+	"iter"
+	"os"
+	"strings"
+
+	"github.com/indigo-web/indigo/kv"
+)
 
 type Data struct {
 	Name     string
@@ -8,6 +15,33 @@ type Data struct {
 	Type     string
 	Charset  string
 	Value    string
+	// File holds the content of a file exceeding the config.BodyForm.SpillThreshold, in which
+	// case the Value is empty. The file is temporary and is removed after the request is
+	// processed.
+	File *os.File
+	// Size is the length of the content stored in the File.
+	Size int64
+}
+
+// Reader returns the reader of the content.
+func (d Data) Reader() io.Reader {
+	if d.File != nil {
+		return io.NewSectionReader(d.File, 0, d.Size)
+	}
+
+	return strings.NewReader(d.Value)
+}
+
+// Part is a single part of the multipart/form-data body, which content is read directly from
+// the request body. Therefore, it is valid only until the next part is requested.
+type Part struct {
+	Name     string
+	Filename string
+	Type     string
+	Charset  string
+	// Headers contain all the headers of the part.
+	Headers *kv.Storage
+	io.Reader
 }
 
 type Form []Data
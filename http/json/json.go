@@ -0,0 +1,145 @@
+// Package json abstracts JSON serialization used by http.Body.JSON and http.Response.JSON.
+// The default codec is backed by json-iterator, but any other one can be plugged in via
+// config.Config.JSON.
+package json
+
+import (
+	stdjson "encoding/json"
+	"io"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Codec encodes and decodes JSON values. Decoding is streaming, so the implementation must
+// not rely on the reader providing the whole document at once.
+type Codec interface {
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+// readBufferSize is the size of the buffer the iterator reads the stream by.
+const readBufferSize = 4096
+
+type iterator struct {
+	api      jsoniter.API
+	decoders sync.Pool
+}
+
+// Iterator returns the codec backed by the json-iterator API. If none is passed,
+// jsoniter.ConfigDefault is used.
+func Iterator(api ...jsoniter.API) Codec {
+	it := &iterator{api: jsoniter.ConfigDefault}
+	if len(api) > 0 {
+		it.api = api[0]
+	}
+
+	it.decoders.New = func() any {
+		d := new(decoder)
+		d.iter = jsoniter.Parse(it.api, &d.src, readBufferSize)
+		return d
+	}
+
+	return it
+}
+
+func (it *iterator) Encode(w io.Writer, v any) error {
+	stream := it.api.BorrowStream(w)
+	stream.WriteVal(v)
+	err := stream.Flush()
+	if err == nil {
+		err = stream.Error
+	}
+
+	it.api.ReturnStream(stream)
+
+	return err
+}
+
+func (it *iterator) Decode(r io.Reader, v any) error {
+	d := it.decoders.Get().(*decoder)
+	d.src = source{r: r}
+	d.iter.Reset(&d.src)
+	d.iter.Error = nil
+	d.iter.ReadVal(v)
+	err := d.iter.Error
+	if d.src.err != nil && d.src.err != io.EOF {
+		// json-iterator wraps read errors into strings, however they must be preserved, as
+		// they could be status.HTTPError, e.g. exceeded body limits.
+		err = d.src.err
+	}
+
+	d.src = source{}
+	it.decoders.Put(d)
+
+	if err == io.EOF {
+		// the value was read completely, but the stream ended right after it.
+		return nil
+	}
+
+	return err
+}
+
+type decoder struct {
+	iter *jsoniter.Iterator
+	src  source
+}
+
+// source remembers the error returned by the reader.
+type source struct {
+	r   io.Reader
+	err error
+}
+
+func (s *source) Read(b []byte) (n int, err error) {
+	n, err = s.r.Read(b)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+
+	return n, err
+}
+
+// StdOptions tunes the decoder of the standard library codec.
+type StdOptions struct {
+	// DisallowUnknownFields makes objects with keys not matching any field of the
+	// destination struct an error.
+	DisallowUnknownFields bool
+	// UseNumber decodes numbers into interface values as stdjson.Number instead of float64.
+	UseNumber bool
+	// EscapeHTML escapes <, > and & in strings while encoding.
+	EscapeHTML bool
+}
+
+type std struct {
+	options StdOptions
+}
+
+// Std returns the codec backed by the encoding/json package of the standard library.
+func Std(options ...StdOptions) Codec {
+	var opts StdOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return std{opts}
+}
+
+func (s std) Encode(w io.Writer, v any) error {
+	enc := stdjson.NewEncoder(w)
+	enc.SetEscapeHTML(s.options.EscapeHTML)
+	return enc.Encode(v)
+}
+
+func (s std) Decode(r io.Reader, v any) error {
+	dec := stdjson.NewDecoder(r)
+	if s.options.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if s.options.UseNumber {
+		dec.UseNumber()
+	}
+
+	return dec.Decode(v)
+}
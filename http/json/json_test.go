@@ -0,0 +1,68 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+type model struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func testCodec(t *testing.T, codec Codec) {
+	t.Run("round trip", func(t *testing.T) {
+		var buff bytes.Buffer
+		require.NoError(t, codec.Encode(&buff, model{Name: "Pavlo", Age: 20}))
+
+		var m model
+		require.NoError(t, codec.Decode(&buff, &m))
+		require.Equal(t, model{Name: "Pavlo", Age: 20}, m)
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		src := iotest.OneByteReader(strings.NewReader(`{"name": "Pavlo", "age": 20}`))
+		var m model
+		require.NoError(t, codec.Decode(src, &m))
+		require.Equal(t, model{Name: "Pavlo", Age: 20}, m)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		var m model
+		require.Error(t, codec.Decode(strings.NewReader(`{"name": "Pa`), &m))
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		failure := errors.New("failure")
+		src := io.MultiReader(strings.NewReader(`{"name": `), iotest.ErrReader(failure))
+		var m model
+		require.ErrorIs(t, codec.Decode(src, &m), failure)
+	})
+}
+
+func TestIterator(t *testing.T) {
+	testCodec(t, Iterator())
+}
+
+func TestStd(t *testing.T) {
+	testCodec(t, Std())
+
+	t.Run("disallow unknown fields", func(t *testing.T) {
+		codec := Std(StdOptions{DisallowUnknownFields: true})
+		var m model
+		require.Error(t, codec.Decode(strings.NewReader(`{"name": "Pavlo", "height": 180}`), &m))
+	})
+
+	t.Run("use number", func(t *testing.T) {
+		codec := Std(StdOptions{UseNumber: true})
+		var v map[string]any
+		require.NoError(t, codec.Decode(strings.NewReader(`{"big": 12345678901234567890}`), &v))
+		require.Equal(t, "12345678901234567890", v["big"].(interface{ String() string }).String())
+	})
+}
@@ -0,0 +1,47 @@
+package media
+
+import (
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBOROptions tunes the CBOR codec.
+type CBOROptions struct {
+	Encoding cbor.EncOptions
+	Decoding cbor.DecOptions
+}
+
+type cborCodec struct {
+	enc cbor.EncMode
+	dec cbor.DecMode
+}
+
+// CBOR returns the codec of the Concise Binary Object Representation (RFC 8949). Invalid
+// options result in panic.
+func CBOR(options ...CBOROptions) Codec {
+	var opts CBOROptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	enc, err := opts.Encoding.EncMode()
+	if err != nil {
+		panic(err)
+	}
+
+	dec, err := opts.Decoding.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return cborCodec{enc: enc, dec: dec}
+}
+
+func (c cborCodec) Encode(w io.Writer, v any) error {
+	return c.enc.NewEncoder(w).Encode(v)
+}
+
+func (c cborCodec) Decode(r io.Reader, v any) error {
+	return c.dec.NewDecoder(r).Decode(v)
+}
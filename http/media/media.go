@@ -0,0 +1,106 @@
+// Package media maps media types onto codecs used to decode request bodies and encode
+// responses, as done by http.Body.Decode and http.Response.Negotiate.
+package media
+
+import (
+	"iter"
+	"strings"
+
+	"github.com/indigo-web/indigo/http/json"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/internal/strutil"
+)
+
+// Codec encodes and decodes values of a single media type. It's the same interface as the JSON
+// codec has, so any JSON codec can be registered for other media types as well.
+type Codec = json.Codec
+
+// Registry holds codecs keyed by media types. The order of registration is the order of
+// the server preference, used when the client accepts multiple media types equally.
+//
+// JSON is never served by the registry, as its codec is set via config.Config.JSON.
+type Registry struct {
+	mimes  []mime.MIME
+	codecs []Codec
+}
+
+func NewRegistry() *Registry {
+	return new(Registry)
+}
+
+// Default returns the registry of XML, CBOR and MessagePack codecs.
+func Default() *Registry {
+	xml := XML()
+
+	return NewRegistry().
+		Register(mime.XML, xml).
+		Register(mime.ApplicationXML, xml).
+		Register(mime.CBOR, CBOR()).
+		Register(mime.MsgPack, MsgPack())
+}
+
+// Register adds the codec for the media type, replacing the previous one if any. Registering
+// JSON results in panic, as it's configured via config.Config.JSON instead.
+func (r *Registry) Register(m mime.MIME, codec Codec) *Registry {
+	if strutil.CmpFoldSafe(m, mime.JSON) {
+		panic("media: JSON codec is set via config.Config.JSON")
+	}
+
+	if i := r.find(m); i != -1 {
+		r.codecs[i] = codec
+		return r
+	}
+
+	r.mimes = append(r.mimes, m)
+	r.codecs = append(r.codecs, codec)
+
+	return r
+}
+
+// Lookup returns the codec for the media type, which may also carry parameters. If none
+// is registered exactly, types with a structured syntax suffix (e.g. application/soap+xml)
+// are looked up by it. Nil is returned if nothing is found.
+func (r *Registry) Lookup(contentType string) Codec {
+	if r == nil {
+		return nil
+	}
+
+	m, _ := strutil.CutHeader(contentType)
+	m = strings.TrimSpace(m)
+	if i := r.find(m); i != -1 {
+		return r.codecs[i]
+	}
+
+	if plus := strings.LastIndexByte(m, '+'); plus != -1 {
+		if i := r.find("application/" + m[plus+1:]); i != -1 {
+			return r.codecs[i]
+		}
+	}
+
+	return nil
+}
+
+// All iterates over the registered media types and their codecs in order of registration.
+func (r *Registry) All() iter.Seq2[mime.MIME, Codec] {
+	return func(yield func(mime.MIME, Codec) bool) {
+		if r == nil {
+			return
+		}
+
+		for i, m := range r.mimes {
+			if !yield(m, r.codecs[i]) {
+				return
+			}
+		}
+	}
+}
+
+func (r *Registry) find(m mime.MIME) int {
+	for i, entry := range r.mimes {
+		if strutil.CmpFoldSafe(entry, m) {
+			return i
+		}
+	}
+
+	return -1
+}
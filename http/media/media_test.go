@@ -0,0 +1,133 @@
+package media
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/stretchr/testify/require"
+)
+
+type model struct {
+	Name string `json:"name" xml:"name"`
+	Age  int    `json:"age" xml:"age"`
+}
+
+type nopCodec struct{}
+
+func (nopCodec) Encode(io.Writer, any) error { return nil }
+func (nopCodec) Decode(io.Reader, any) error { return nil }
+
+func TestCodecs(t *testing.T) {
+	for name, codec := range map[string]Codec{
+		"XML":     XML(),
+		"CBOR":    CBOR(),
+		"MsgPack": MsgPack(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buff bytes.Buffer
+			require.NoError(t, codec.Encode(&buff, model{Name: "Pavlo", Age: 20}))
+
+			var m model
+			require.NoError(t, codec.Decode(&buff, &m))
+			require.Equal(t, model{Name: "Pavlo", Age: 20}, m)
+		})
+	}
+
+	t.Run("msgpack tags", func(t *testing.T) {
+		var buff bytes.Buffer
+		require.NoError(t, MsgPack().Encode(&buff, model{Name: "Pavlo"}))
+
+		var m map[string]any
+		require.NoError(t, MsgPack().Decode(&buff, &m))
+		require.Equal(t, "Pavlo", m["name"])
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("lookup", func(t *testing.T) {
+		r := Default()
+		require.NotNil(t, r.Lookup(mime.XML))
+		require.NotNil(t, r.Lookup("Application/CBOR; charset=utf-8"))
+		require.NotNil(t, r.Lookup("application/soap+xml"))
+		require.Nil(t, r.Lookup("application/x-protobuf"))
+		require.Nil(t, r.Lookup(mime.JSON))
+	})
+
+	t.Run("register", func(t *testing.T) {
+		r := NewRegistry().Register("application/x-protobuf", nopCodec{})
+		require.Equal(t, nopCodec{}, r.Lookup("application/x-protobuf"))
+
+		r.Register("application/x-protobuf", XML())
+		require.Equal(t, XML(), r.Lookup("application/x-protobuf"))
+
+		var mimes []string
+		for m := range r.All() {
+			mimes = append(mimes, m)
+		}
+
+		require.Equal(t, []string{"application/x-protobuf"}, mimes)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		require.Panics(t, func() {
+			NewRegistry().Register(mime.JSON, XML())
+		})
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var r *Registry
+		require.Nil(t, r.Lookup(mime.XML))
+		for range r.All() {
+			require.Fail(t, "nil registry yields entries")
+		}
+	})
+}
+
+func TestQuality(t *testing.T) {
+	accept := slices.Values([]string{"text/*;q=0.5, application/xml;q=0.8", "*/*;q=0.1"})
+
+	require.Equal(t, 800, Quality(accept, "application/xml"))
+	require.Equal(t, 500, Quality(accept, "text/xml"))
+	require.Equal(t, 100, Quality(accept, "application/cbor"))
+	require.Equal(t, -1, Quality(slices.Values([]string{"text/html"}), "application/cbor"))
+	require.Equal(t, 1000, Quality(slices.Values([]string{"APPLICATION/CBOR"}), "application/cbor"))
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := func(yield func(mime.MIME, Codec) bool) {
+		for _, m := range []mime.MIME{mime.JSON, mime.XML, mime.CBOR} {
+			if !yield(m, nopCodec{}) {
+				return
+			}
+		}
+	}
+
+	negotiate := func(accept ...string) (mime.MIME, bool) {
+		m, _, ok := Negotiate(slices.Values(accept), offers)
+		return m, ok
+	}
+
+	for _, tc := range []struct {
+		Accept []string
+		Want   mime.MIME
+	}{
+		{nil, mime.JSON},
+		{[]string{"*/*"}, mime.JSON},
+		{[]string{"text/xml"}, mime.XML},
+		{[]string{"application/json;q=0.5, application/cbor"}, mime.CBOR},
+		{[]string{"application/*;q=0.9, text/xml;q=0.9"}, mime.JSON},
+		{[]string{"application/json;q=0, */*"}, mime.XML},
+	} {
+		m, ok := negotiate(tc.Accept...)
+		require.True(t, ok, tc.Accept)
+		require.Equal(t, tc.Want, m, tc.Accept)
+	}
+
+	_, ok := negotiate("text/html")
+	require.False(t, ok)
+	_, ok = negotiate("*/*;q=0")
+	require.False(t, ok)
+}
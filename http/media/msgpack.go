@@ -0,0 +1,35 @@
+package media
+
+import (
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type msgpackCodec struct{}
+
+// MsgPack returns the MessagePack codec. Struct fields are named by the msgpack tags, falling
+// back to the json ones.
+func MsgPack() Codec {
+	return msgpackCodec{}
+}
+
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	enc := msgpack.GetEncoder()
+	enc.Reset(w)
+	enc.SetCustomStructTag("json")
+	err := enc.Encode(v)
+	msgpack.PutEncoder(enc)
+
+	return err
+}
+
+func (msgpackCodec) Decode(r io.Reader, v any) error {
+	dec := msgpack.GetDecoder()
+	dec.Reset(r)
+	dec.SetCustomStructTag("json")
+	err := dec.Decode(v)
+	msgpack.PutDecoder(dec)
+
+	return err
+}
@@ -0,0 +1,71 @@
+package media
+
+import (
+	"iter"
+	"strings"
+
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/internal/strutil"
+)
+
+// Quality returns the quality of the media type in thousandths, as it's listed in the Accept
+// values. The most specific matching media range is used, so type/subtype overrides type/*,
+// which overrides */* (RFC 9110, 12.5.1). If no media range matches, -1 is returned.
+func Quality(accept iter.Seq[string], m mime.MIME) int {
+	typ, _, _ := strings.Cut(m, "/")
+	quality, specificity := -1, -1
+
+	for value := range accept {
+		for _, mediaRange := range strings.Split(value, ",") {
+			rng, params := strutil.CutHeader(mediaRange)
+			rng = strings.TrimSpace(rng)
+
+			var s int
+			switch {
+			case rng == "*/*":
+				s = 0
+			case strings.HasSuffix(rng, "/*") && strutil.CmpFoldSafe(rng[:len(rng)-2], typ):
+				s = 1
+			case strutil.CmpFoldSafe(rng, m):
+				s = 2
+			default:
+				continue
+			}
+
+			if s > specificity {
+				quality, specificity = strutil.ParseQuality(params), s
+			}
+		}
+	}
+
+	return quality
+}
+
+// Negotiate chooses the media type among the offers, as preferred by the client. Ties are
+// resolved by the order of the offers. No Accept values mean anything is acceptable, so the
+// first offer is chosen. If nothing is acceptable, false is returned.
+func Negotiate(accept iter.Seq[string], offers iter.Seq2[mime.MIME, Codec]) (mime.MIME, Codec, bool) {
+	empty := true
+	for range accept {
+		empty = false
+		break
+	}
+
+	var (
+		best        mime.MIME
+		bestCodec   Codec
+		bestQuality int
+	)
+
+	for m, codec := range offers {
+		if empty {
+			return m, codec, true
+		}
+
+		if q := Quality(accept, m); q > bestQuality {
+			best, bestCodec, bestQuality = m, codec, q
+		}
+	}
+
+	return best, bestCodec, bestCodec != nil
+}
@@ -0,0 +1,21 @@
+package media
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+type xmlCodec struct{}
+
+// XML returns the codec backed by the encoding/xml package of the standard library.
+func XML() Codec {
+	return xmlCodec{}
+}
+
+func (xmlCodec) Encode(w io.Writer, v any) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (xmlCodec) Decode(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
@@ -17,7 +17,10 @@ const (
 	Plain          MIME = "text/plain"
 	HTML           MIME = "text/html"
 	XML            MIME = "text/xml"
+	ApplicationXML MIME = "application/xml"
 	JSON           MIME = "application/json"
+	CBOR           MIME = "application/cbor"
+	MsgPack        MIME = "application/msgpack"
 	YAML           MIME = "application/yaml"
 	PDF            MIME = "application/pdf"
 	FormUrlencoded MIME = "application/x-www-form-urlencoded"
@@ -40,6 +43,7 @@ const (
 	TZIF           MIME = "application/tzif"
 	XFDF           MIME = "application/xfdf"
 	HTTP           MIME = "message/http"
+	EventStream    MIME = "text/event-stream"
 )
 
 // Complies returns whether two MIMEs are compatible. Empty MIME is considered
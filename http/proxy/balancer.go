@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/indigo-web/indigo/http"
+)
+
+// Balancer chooses an upstream for the request. Only upstreams, for which the available returns
+// true, may be chosen. Nil is returned if there are none.
+type Balancer interface {
+	Pick(request *http.Request, upstreams []*Upstream, available func(*Upstream) bool) *Upstream
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+// RoundRobin picks available upstreams one by one.
+func RoundRobin() Balancer {
+	return new(roundRobin)
+}
+
+func (r *roundRobin) Pick(_ *http.Request, upstreams []*Upstream, available func(*Upstream) bool) *Upstream {
+	start := r.next.Add(1) - 1
+
+	for i := range uint64(len(upstreams)) {
+		upstream := upstreams[(start+i)%uint64(len(upstreams))]
+		if available(upstream) {
+			return upstream
+		}
+	}
+
+	return nil
+}
+
+type leastConn struct{}
+
+// LeastConn picks the available upstream with the fewest requests in flight. Ties are resolved
+// in favor of the upstream declared first.
+func LeastConn() Balancer {
+	return leastConn{}
+}
+
+func (leastConn) Pick(_ *http.Request, upstreams []*Upstream, available func(*Upstream) bool) *Upstream {
+	var least *Upstream
+
+	for _, upstream := range upstreams {
+		if available(upstream) && (least == nil || upstream.Active() < least.Active()) {
+			least = upstream
+		}
+	}
+
+	return least
+}
+
+// replicas is the number of points every upstream occupies on the hash ring. More points result
+// in more even distribution.
+const replicas = 128
+
+type point struct {
+	hash     uint64
+	upstream *Upstream
+}
+
+type consistentHash struct {
+	key  func(*http.Request) string
+	once sync.Once
+	ring []point
+}
+
+// ConsistentHash maps requests with the same key to the same upstream, as long as it's available.
+// When an upstream goes away, only the keys mapped to it are redistributed. Nil key defaults to
+// the client IP address.
+func ConsistentHash(key func(*http.Request) string) Balancer {
+	if key == nil {
+		key = clientIP
+	}
+
+	return &consistentHash{key: key}
+}
+
+func (c *consistentHash) Pick(request *http.Request, upstreams []*Upstream, available func(*Upstream) bool) *Upstream {
+	// the set of upstreams is fixed for the lifetime of the proxy, so the ring is built once.
+	c.once.Do(func() {
+		c.ring = make([]point, 0, len(upstreams)*replicas)
+		for _, upstream := range upstreams {
+			for i := range replicas {
+				c.ring = append(c.ring, point{
+					hash:     hash(upstream.URL.String() + "#" + strconv.Itoa(i)),
+					upstream: upstream,
+				})
+			}
+		}
+
+		sort.Slice(c.ring, func(i, j int) bool {
+			return c.ring[i].hash < c.ring[j].hash
+		})
+	})
+
+	if len(c.ring) == 0 {
+		return nil
+	}
+
+	h := hash(c.key(request))
+	start := sort.Search(len(c.ring), func(i int) bool {
+		return c.ring[i].hash >= h
+	})
+
+	for i := range c.ring {
+		upstream := c.ring[(start+i)%len(c.ring)].upstream
+		if available(upstream) {
+			return upstream
+		}
+	}
+
+	return nil
+}
+
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	x := h.Sum64()
+
+	// FNV alone distributes similar keys poorly, so the bits are additionally mixed.
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
+
+func clientIP(request *http.Request) string {
+	addr := request.ClientAddr()
+	if addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
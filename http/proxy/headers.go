@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/internal/strutil"
+)
+
+// hopByHopHeaders are meaningful only for a single connection, therefore aren't forwarded
+// (RFC 9110, 7.6.1).
+var hopByHopHeaders = []string{
+	"connection", "keep-alive", "proxy-connection", "proxy-authenticate", "proxy-authorization",
+	"te", "trailer", "transfer-encoding", "upgrade",
+}
+
+// copyHeaders copies all the end-to-end headers, except content-length and the skipped ones.
+func copyHeaders(dst, src http.Headers, skip ...string) {
+	hop := connectionTokens(src)
+
+	for key, value := range src.Pairs() {
+		if hopByHop(key, hop) || strutil.CmpFoldSafe(key, "content-length") || containsFold(skip, key) {
+			continue
+		}
+
+		dst.Add(key, value)
+	}
+}
+
+// connectionTokens returns the headers listed in the Connection header.
+func connectionTokens(headers http.Headers) (tokens []string) {
+	for value := range headers.Values("connection") {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); len(token) > 0 {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+
+	return tokens
+}
+
+func hopByHop(key string, listed []string) bool {
+	return containsFold(hopByHopHeaders, key) || containsFold(listed, key)
+}
+
+func containsFold(list []string, key string) bool {
+	return slices.ContainsFunc(list, func(elem string) bool {
+		return strutil.CmpFoldSafe(elem, key)
+	})
+}
+
+// isUpgrade tells whether the client asks to switch protocols, e.g. to the WebSocket.
+func isUpgrade(request *http.Request) bool {
+	return request.Headers.Has("upgrade") &&
+		containsFold(connectionTokens(request.Headers), "upgrade")
+}
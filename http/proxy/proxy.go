@@ -0,0 +1,447 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indigo-web/indigo/client"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/netutil"
+	"github.com/indigo-web/indigo/internal/strutil"
+	"github.com/indigo-web/indigo/kv"
+	"github.com/indigo-web/indigo/router/inbuilt"
+)
+
+// HealthCheck configures active health checks. Every upstream is periodically requested with GET
+// at the path, and is considered healthy as long as it responds with a 2xx or 3xx code.
+type HealthCheck struct {
+	// Path defaults to "/".
+	Path string
+	// Interval is the period between two consecutive checks.
+	Interval time.Duration
+	// Timeout limits every check. Defaults to the interval.
+	Timeout time.Duration
+}
+
+// Proxy is a reverse proxy forwarding requests to a set of upstreams. Both request and response
+// bodies are streamed as they arrive, without being buffered in whole.
+type Proxy struct {
+	upstreams []*Upstream
+	balancer  Balancer
+	client    *client.Client
+	retries   int
+	maxFails  int
+	cooldown  time.Duration
+	check     HealthCheck
+	mu        sync.Mutex
+	started   bool
+	stop      context.CancelFunc
+}
+
+// New returns a new Proxy balancing between the upstreams in the round-robin manner. Upstreams
+// are URLs with the http or https scheme, optionally containing a path prefix prepended to the
+// paths of forwarded requests. Failed idempotent requests are retried once on another upstream.
+// It panics if any of the URLs is malformed.
+func New(upstreams ...string) *Proxy {
+	p := &Proxy{
+		balancer: RoundRobin(),
+		client:   client.New().Redirects(client.NoRedirects),
+		retries:  1,
+	}
+
+	for _, upstream := range upstreams {
+		u, err := url.Parse(upstream)
+		if err != nil {
+			panic(fmt.Errorf("bad upstream: %w", err))
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" || len(u.Host) == 0 {
+			panic(fmt.Errorf("bad upstream: %s", upstream))
+		}
+
+		p.upstreams = append(p.upstreams, &Upstream{URL: u})
+	}
+
+	return p
+}
+
+// Balancer sets the balancing strategy.
+func (p *Proxy) Balancer(balancer Balancer) *Proxy {
+	p.balancer = balancer
+	return p
+}
+
+// Client replaces the client used to send requests to the upstreams. It must neither follow
+// redirects nor decompress responses, i.e. have no codecs.
+func (p *Proxy) Client(c *client.Client) *Proxy {
+	p.client = c
+	return p
+}
+
+// Retries sets how many times a failed request is retried on other upstreams. Only requests
+// with idempotent methods and no body are retried.
+func (p *Proxy) Retries(n int) *Proxy {
+	p.retries = n
+	return p
+}
+
+// PassiveHealth excludes upstreams failing maxFails requests in a row for the cooldown period.
+// Zero maxFails disables the exclusion, which is the default.
+func (p *Proxy) PassiveHealth(maxFails int, cooldown time.Duration) *Proxy {
+	p.maxFails, p.cooldown = maxFails, cooldown
+	return p
+}
+
+// HealthCheck enables active health checks. Upstreams failing them are excluded until they
+// pass again. They're started along with the first Handler call and stopped by Close.
+func (p *Proxy) HealthCheck(check HealthCheck) *Proxy {
+	if len(check.Path) == 0 {
+		check.Path = "/"
+	}
+
+	if check.Timeout <= 0 {
+		check.Timeout = check.Interval
+	}
+
+	p.check = check
+	return p
+}
+
+// Upstreams returns all the upstreams in order of declaration.
+func (p *Proxy) Upstreams() []*Upstream {
+	return p.upstreams
+}
+
+// Handler returns a handler forwarding requests to the upstreams.
+func (p *Proxy) Handler() inbuilt.Handler {
+	p.mu.Lock()
+	if !p.started && p.check.Interval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.stop = cancel
+		go p.watch(ctx)
+	}
+
+	p.started = true
+	p.mu.Unlock()
+
+	return p.serve
+}
+
+// Close stops the health checks and closes idle connections to the upstreams.
+func (p *Proxy) Close() {
+	p.mu.Lock()
+	if p.stop != nil {
+		p.stop()
+		p.stop = nil
+	}
+	p.mu.Unlock()
+
+	p.client.CloseIdle()
+}
+
+func (p *Proxy) serve(request *http.Request) *http.Response {
+	if isUpgrade(request) {
+		return p.tunnel(request)
+	}
+
+	var (
+		tried []*Upstream
+		err   error
+	)
+
+	for {
+		upstream := p.pick(request, tried)
+		if upstream == nil {
+			if err != nil {
+				// all the upstreams were tried.
+				return http.Error(request, upstreamError(err))
+			}
+
+			return http.Error(request, status.ErrServiceUnavailable)
+		}
+
+		var resp *client.Response
+
+		resp, err = p.forward(request, upstream, false)
+		if err == nil {
+			return p.respond(request, upstream, resp)
+		}
+
+		if request.Ctx.Err() == nil {
+			// the client going away isn't a fault of the upstream.
+			upstream.fail(p.maxFails, p.cooldown)
+		}
+
+		tried = append(tried, upstream)
+		if len(tried) > p.retries || !retriable(request) || request.Ctx.Err() != nil {
+			return http.Error(request, upstreamError(err))
+		}
+	}
+}
+
+// pick chooses an available upstream, which wasn't tried yet.
+func (p *Proxy) pick(request *http.Request, tried []*Upstream) *Upstream {
+	now := time.Now()
+
+	return p.balancer.Pick(request, p.upstreams, func(upstream *Upstream) bool {
+		return upstream.available(now) && !slices.Contains(tried, upstream)
+	})
+}
+
+// forward sends the request to the upstream. The upstream is considered active until the
+// response is closed.
+func (p *Proxy) forward(request *http.Request, upstream *Upstream, upgrade bool) (*client.Response, error) {
+	out := &client.Request{
+		Method:  request.Method,
+		URL:     target(upstream.URL, request),
+		Headers: kv.New(),
+		Ctx:     request.Ctx,
+	}
+
+	copyHeaders(out.Headers, request.Headers, "host")
+	if upgrade {
+		out.Headers.Add("Connection", "Upgrade")
+		for value := range request.Headers.Values("upgrade") {
+			out.Headers.Add("Upgrade", value)
+		}
+	}
+
+	setForwarded(out.Headers, request)
+
+	if request.Chunked || request.ContentLength > 0 {
+		length := int64(request.ContentLength)
+		if request.Chunked || len(request.ContentEncoding) > 0 {
+			// the body was decoded by the server, so its final length isn't known.
+			length = -1
+		}
+
+		out.Headers.Delete("content-encoding")
+		out.Stream(request.Body, length)
+	}
+
+	upstream.active.Add(1)
+	resp, err := p.client.Do(out)
+	if err != nil {
+		upstream.active.Add(-1)
+		return nil, err
+	}
+
+	upstream.succeed()
+	return resp, nil
+}
+
+// respond streams the upstream response back to the client.
+func (p *Proxy) respond(request *http.Request, upstream *Upstream, resp *client.Response) *http.Response {
+	response := request.Respond().
+		Code(resp.Code).
+		Buffered(false)
+
+	if len(resp.Status) > 0 {
+		response.Status(resp.Status)
+	}
+
+	hop := connectionTokens(resp.Headers)
+	for key, value := range resp.Headers.Pairs() {
+		if !hopByHop(key, hop) && !strutil.CmpFoldSafe(key, "content-length") {
+			response.Header(key, value)
+		}
+	}
+
+	body := &upstreamBody{resp: resp, upstream: upstream}
+	if resp.ContentLength == 0 {
+		// empty streams aren't consumed, therefore neither closed by the serializer.
+		_ = body.Close()
+		return response
+	}
+
+	return response.Stream(body, resp.ContentLength)
+}
+
+// upstreamBody releases the upstream connection as soon as the response is written.
+type upstreamBody struct {
+	resp     *client.Response
+	upstream *Upstream
+	closed   bool
+}
+
+func (u *upstreamBody) Read(b []byte) (int, error) {
+	return u.resp.Body.Read(b)
+}
+
+func (u *upstreamBody) Close() error {
+	if u.closed {
+		return nil
+	}
+
+	u.closed = true
+	u.upstream.active.Add(-1)
+	return u.resp.Close()
+}
+
+func (p *Proxy) watch(ctx context.Context) {
+	ticker := time.NewTicker(p.check.Interval)
+	defer ticker.Stop()
+
+	for {
+		var wg sync.WaitGroup
+		for _, upstream := range p.upstreams {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				healthy := p.probe(ctx, upstream)
+				if ctx.Err() == nil {
+					// the verdict is unreliable if the checks are stopped in the meantime.
+					upstream.down.Store(!healthy)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Proxy) probe(ctx context.Context, upstream *Upstream) bool {
+	ctx, cancel := context.WithTimeout(ctx, p.check.Timeout)
+	defer cancel()
+
+	u := *upstream.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(p.check.Path, "/")
+	u.RawPath = ""
+
+	resp, err := p.client.Do(&client.Request{
+		Method:  method.GET,
+		URL:     &u,
+		Headers: kv.New(),
+		Ctx:     ctx,
+	})
+	if err != nil {
+		return false
+	}
+
+	_ = resp.Body.Discard()
+	_ = resp.Close()
+
+	return resp.Code >= 200 && resp.Code < 400
+}
+
+// target builds the upstream URL for the request.
+func target(base *url.URL, request *http.Request) *url.URL {
+	u := *base
+	u.RawPath = strings.TrimSuffix(base.EscapedPath(), "/") + escapePath(request.Path)
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	u.RawQuery = encodeParams(request.Params)
+	u.Fragment = ""
+
+	return &u
+}
+
+// escapePath escapes the decoded request path. URL-unsafe characters are left escaped by the
+// parser, so the existing escape sequences are kept as is.
+func escapePath(path string) string {
+	var b strings.Builder
+	b.Grow(len(path))
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case isPathChar(c):
+			b.WriteByte(c)
+		case c == '%' && i+2 < len(path) && isHex(path[i+1]) && isHex(path[i+2]):
+			b.WriteByte(c)
+		default:
+			const hex = "0123456789ABCDEF"
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+		}
+	}
+
+	return b.String()
+}
+
+// isPathChar tells whether the character is allowed in a path unescaped (RFC 3986, 3.3).
+func isPathChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+
+	return strings.IndexByte("-._~!$&'()*+,;=:@/", c) != -1
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func encodeParams(params http.Params) string {
+	var b strings.Builder
+
+	for key, value := range params.Pairs() {
+		if b.Len() > 0 {
+			b.WriteByte('&')
+		}
+
+		b.WriteString(url.QueryEscape(key))
+		if len(value) > 0 {
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(value))
+		}
+	}
+
+	return b.String()
+}
+
+// retriable tells whether the request can be safely sent once more.
+func retriable(request *http.Request) bool {
+	if request.Chunked || request.ContentLength > 0 {
+		// the body was already consumed.
+		return false
+	}
+
+	switch request.Method {
+	case method.GET, method.HEAD, method.OPTIONS, method.TRACE, method.PUT, method.DELETE:
+		return true
+	default:
+		return false
+	}
+}
+
+// upstreamError converts errors occurred while communicating with an upstream into responses.
+func upstreamError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return status.ErrGatewayTimeout
+	}
+
+	return status.ErrBadGateway
+}
+
+// setForwarded appends the peer address to the X-Forwarded-For and sets X-Forwarded-Proto
+// and X-Forwarded-Host.
+func setForwarded(headers http.Headers, request *http.Request) {
+	if addr, ok := netutil.AddrOf(request.Remote); ok {
+		chain := slices.Collect(headers.Values("x-forwarded-for"))
+		chain = append(chain, addr.String())
+		headers.Set("X-Forwarded-For", strings.Join(chain, ", "))
+	}
+
+	headers.Set("X-Forwarded-Proto", request.Scheme())
+	if host := request.Host(); len(host) > 0 {
+		headers.Set("X-Forwarded-Host", host)
+	}
+}
@@ -0,0 +1,402 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/indigo-web/indigo"
+	"github.com/indigo-web/indigo/client"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/kv"
+	"github.com/indigo-web/indigo/router"
+	"github.com/indigo-web/indigo/router/inbuilt"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	upstreamA = "localhost:16300"
+	upstreamB = "localhost:16301"
+	proxyAddr = "localhost:16302"
+	// deadAddr is never listened on.
+	deadAddr = "localhost:16399"
+)
+
+func serve(t *testing.T, addr string, r router.Builder) {
+	app := indigo.New(addr)
+	started, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		_ = app.OnStart(func() {
+			close(started)
+		}).Serve(r)
+		close(stopped)
+	}()
+
+	<-started
+	t.Cleanup(func() {
+		require.NoError(t, app.Shutdown(context.Background()))
+		<-stopped
+	})
+
+	for i := 0; ; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			require.NoError(t, conn.Close())
+			break
+		}
+
+		require.Less(t, i, 40, err)
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func upstream(name string, healthy *atomic.Bool) router.Builder {
+	info := func(request *http.Request) *http.Response {
+		var params []string
+		for key, value := range request.Params.Pairs() {
+			params = append(params, key+"="+value)
+		}
+
+		return http.String(request, request.Path).
+			Header("X-Upstream", name).
+			Header("X-Params", strings.Join(params, "&")).
+			Header("X-Got-For", request.Headers.Value("x-forwarded-for")).
+			Header("X-Got-Proto", request.Headers.Value("x-forwarded-proto")).
+			Header("X-Got-Host", request.Headers.Value("x-forwarded-host")).
+			Header("X-Got-Secret", request.Headers.Value("x-secret")).
+			Header("X-Got-Custom", request.Headers.Value("x-custom"))
+	}
+
+	return inbuilt.New().
+		Get("/health", func(request *http.Request) *http.Response {
+			if !healthy.Load() {
+				return http.Code(request, status.ServiceUnavailable)
+			}
+
+			return http.Code(request, status.OK)
+		}).
+		Get("/hop", func(request *http.Request) *http.Response {
+			return http.String(request, "hop").
+				Header("Connection", "X-Secret").
+				Header("X-Secret", "leaked").
+				Header("Keep-Alive", "timeout=5").
+				Header("X-Public", "visible")
+		}).
+		Get("/empty", func(request *http.Request) *http.Response {
+			return http.Code(request, status.NoContent).Header("X-Upstream", name)
+		}).
+		Get("/tunnel", func(request *http.Request) *http.Response {
+			conn, err := request.Hijack()
+			if err != nil {
+				return http.Error(request, err)
+			}
+
+			_, _ = io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+				"Connection: Upgrade\r\nUpgrade: echo\r\nX-Upstream: "+name+"\r\n\r\n")
+			pipe(conn, conn)
+			return nil
+		}).
+		Get("/:path...", info).
+		Post("/echo", func(request *http.Request) *http.Response {
+			return request.Respond().
+				Header("X-Upstream", name).
+				Stream(request.Body, -1)
+		})
+}
+
+func get(t *testing.T, c *client.Client, path string, headers ...string) *client.Response {
+	request, err := client.NewRequest(method.GET, "http://"+proxyAddr+path)
+	require.NoError(t, err)
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header(headers[i], headers[i+1])
+	}
+
+	resp, err := c.Do(request)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = resp.Close()
+	})
+
+	return resp
+}
+
+func TestProxy(t *testing.T) {
+	var healthyA, healthyB atomic.Bool
+	healthyA.Store(true)
+	healthyB.Store(true)
+	serve(t, upstreamA, upstream("a", &healthyA))
+	serve(t, upstreamB, upstream("b", &healthyB))
+
+	urls := []string{"http://" + upstreamA, "http://" + upstreamB}
+	rr := New(urls...)
+	hash := New(urls...).Balancer(ConsistentHash(func(request *http.Request) string {
+		return request.Headers.Value("x-key")
+	}))
+	failover := New("http://"+deadAddr, "http://"+upstreamA).PassiveHealth(1, time.Minute)
+	dead := New("http://" + deadAddr)
+	checked := New(urls...).HealthCheck(HealthCheck{
+		Path:     "/health",
+		Interval: 20 * time.Millisecond,
+	})
+
+	r := inbuilt.New()
+	for _, p := range []*Proxy{rr, hash, failover, dead, checked} {
+		t.Cleanup(p.Close)
+	}
+
+	r.Get("/:path...", rr.Handler()).
+		Post("/echo", rr.Handler())
+	r.Group("/sticky").Get("/:path...", hash.Handler())
+	r.Group("/failover").Get("/:path...", failover.Handler())
+	r.Group("/dead").
+		Get("/:path...", dead.Handler()).
+		Post("/:path...", dead.Handler())
+	r.Group("/checked").Get("/:path...", checked.Handler())
+
+	serve(t, proxyAddr, r)
+
+	c := client.New()
+	defer c.CloseIdle()
+
+	t.Run("forwarding", func(t *testing.T) {
+		resp := get(t, c, "/info/hello%20world?x=1&y=a+b",
+			"Connection", "X-Secret",
+			"X-Secret", "hop-by-hop",
+			"X-Custom", "end-to-end",
+			"X-Forwarded-For", "10.0.0.1",
+		)
+		require.Equal(t, status.OK, resp.Code)
+		body, err := resp.Body.String()
+		require.NoError(t, err)
+		require.Equal(t, "/info/hello world", body)
+		require.Equal(t, "x=1&y=a b", resp.Headers.Value("x-params"))
+		require.Equal(t, "10.0.0.1, 127.0.0.1", resp.Headers.Value("x-got-for"))
+		require.Equal(t, "http", resp.Headers.Value("x-got-proto"))
+		require.Equal(t, proxyAddr, resp.Headers.Value("x-got-host"))
+		require.Empty(t, resp.Headers.Value("x-got-secret"))
+		require.Equal(t, "end-to-end", resp.Headers.Value("x-got-custom"))
+	})
+
+	t.Run("hop-by-hop response headers", func(t *testing.T) {
+		resp := get(t, c, "/hop")
+		require.Equal(t, "visible", resp.Headers.Value("x-public"))
+		require.False(t, resp.Headers.Has("x-secret"))
+		require.False(t, resp.Headers.Has("keep-alive"))
+	})
+
+	t.Run("empty response", func(t *testing.T) {
+		for range 4 {
+			resp := get(t, c, "/empty")
+			require.Equal(t, status.NoContent, resp.Code)
+			require.NoError(t, resp.Close())
+		}
+
+		for _, u := range rr.Upstreams() {
+			require.Zero(t, u.Active())
+		}
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		payload := strings.Repeat("streamed body ", 100_000)
+		request, err := client.NewRequest(method.POST, "http://"+proxyAddr+"/echo")
+		require.NoError(t, err)
+
+		resp, err := c.Do(request.
+			ContentType(mime.Plain).
+			Stream(io.MultiReader(strings.NewReader(payload[:100]), strings.NewReader(payload[100:]))))
+		require.NoError(t, err)
+		defer resp.Close()
+
+		require.Equal(t, int64(-1), resp.ContentLength)
+		body, err := resp.Body.String()
+		require.NoError(t, err)
+		require.Equal(t, payload, body)
+	})
+
+	t.Run("round robin", func(t *testing.T) {
+		var names []string
+		for range 4 {
+			resp := get(t, c, "/info")
+			names = append(names, resp.Headers.Value("x-upstream"))
+			require.NoError(t, resp.Close())
+		}
+
+		require.NotEqual(t, names[0], names[1])
+		require.Equal(t, names[0], names[2])
+		require.Equal(t, names[1], names[3])
+	})
+
+	t.Run("consistent hash", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for i := range 32 {
+			key := "client-" + string(rune('a'+i))
+			first := get(t, c, "/sticky/info", "X-Key", key).Headers.Value("x-upstream")
+			for range 3 {
+				require.Equal(t, first, get(t, c, "/sticky/info", "X-Key", key).Headers.Value("x-upstream"))
+			}
+
+			seen[first] = true
+		}
+
+		require.Len(t, seen, 2)
+	})
+
+	t.Run("passive failover", func(t *testing.T) {
+		resp := get(t, c, "/failover/info")
+		require.Equal(t, status.OK, resp.Code)
+		require.Equal(t, "a", resp.Headers.Value("x-upstream"))
+		require.False(t, failover.Upstreams()[0].available(time.Now()))
+
+		resp = get(t, c, "/failover/info")
+		require.Equal(t, "a", resp.Headers.Value("x-upstream"))
+	})
+
+	t.Run("bad gateway", func(t *testing.T) {
+		require.Equal(t, status.BadGateway, get(t, c, "/dead/info").Code)
+
+		resp, err := c.Post("http://"+proxyAddr+"/dead/info", mime.Plain, []byte("body"))
+		require.NoError(t, err)
+		defer resp.Close()
+		require.Equal(t, status.BadGateway, resp.Code)
+	})
+
+	t.Run("active health check", func(t *testing.T) {
+		healthyB.Store(false)
+		require.Eventually(t, func() bool {
+			return !checked.Upstreams()[1].Healthy()
+		}, time.Second, 10*time.Millisecond)
+
+		for range 4 {
+			require.Equal(t, "a", get(t, c, "/checked/info").Headers.Value("x-upstream"))
+		}
+
+		healthyB.Store(true)
+		require.Eventually(t, func() bool {
+			return checked.Upstreams()[1].Healthy()
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("upgrade tunnel", func(t *testing.T) {
+		conn, err := net.Dial("tcp", proxyAddr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = io.WriteString(conn, "GET /tunnel HTTP/1.1\r\nHost: "+proxyAddr+"\r\n"+
+			"Connection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		require.NoError(t, err)
+
+		reader := bufio.NewReader(conn)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "HTTP/1.1 101 Switching Protocols\r\n", line)
+
+		var upgrade string
+		for {
+			line, err = reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\r\n" {
+				break
+			}
+
+			if key, value, _ := strings.Cut(strings.TrimSpace(line), ": "); strings.EqualFold(key, "upgrade") {
+				upgrade = value
+			}
+		}
+
+		require.Equal(t, "echo", upgrade)
+
+		for _, msg := range []string{"ping", "pong"} {
+			_, err = io.WriteString(conn, msg)
+			require.NoError(t, err)
+			echoed := make([]byte, len(msg))
+			_, err = io.ReadFull(reader, echoed)
+			require.NoError(t, err)
+			require.Equal(t, msg, string(echoed))
+		}
+	})
+}
+
+func TestBalancers(t *testing.T) {
+	upstreams := make([]*Upstream, 3)
+	for i := range upstreams {
+		upstreams[i] = &Upstream{URL: &url.URL{Scheme: "http", Host: "backend-" + string(rune('a'+i))}}
+	}
+
+	all := func(*Upstream) bool { return true }
+	except := func(excluded *Upstream) func(*Upstream) bool {
+		return func(u *Upstream) bool { return u != excluded }
+	}
+	none := func(*Upstream) bool { return false }
+
+	t.Run("round robin", func(t *testing.T) {
+		b := RoundRobin()
+		require.Equal(t, upstreams[0], b.Pick(nil, upstreams, all))
+		require.Equal(t, upstreams[1], b.Pick(nil, upstreams, all))
+		require.Equal(t, upstreams[0], b.Pick(nil, upstreams, except(upstreams[2])))
+		require.Nil(t, b.Pick(nil, upstreams, none))
+	})
+
+	t.Run("least connections", func(t *testing.T) {
+		upstreams[0].active.Store(2)
+		upstreams[1].active.Store(1)
+		upstreams[2].active.Store(1)
+		defer func() {
+			for _, u := range upstreams {
+				u.active.Store(0)
+			}
+		}()
+
+		b := LeastConn()
+		require.Equal(t, upstreams[1], b.Pick(nil, upstreams, all))
+		require.Equal(t, upstreams[2], b.Pick(nil, upstreams, except(upstreams[1])))
+		require.Nil(t, b.Pick(nil, upstreams, none))
+	})
+
+	t.Run("consistent hash", func(t *testing.T) {
+		request := &http.Request{Headers: kv.New()}
+		b := ConsistentHash(func(request *http.Request) string {
+			return request.Headers.Value("x-key")
+		})
+
+		moved := 0
+		for i := range 300 {
+			request.Headers.Set("x-key", "key-"+string(rune(i)))
+			chosen := b.Pick(request, upstreams, all)
+			require.Equal(t, chosen, b.Pick(request, upstreams, all))
+
+			fallback := b.Pick(request, upstreams, except(upstreams[0]))
+			require.NotEqual(t, upstreams[0], fallback)
+			if chosen != upstreams[0] {
+				// keys of remaining upstreams must stay in place.
+				require.Equal(t, chosen, fallback)
+			} else {
+				moved++
+			}
+		}
+
+		require.Greater(t, moved, 0)
+		require.Nil(t, b.Pick(request, upstreams, none))
+	})
+}
+
+func TestTarget(t *testing.T) {
+	base, err := url.Parse("http://backend:8080/api/")
+	require.NoError(t, err)
+
+	request := &http.Request{Path: "/a b/%2f/ü?", Params: kv.New()}
+	request.Params.Add("q", "a&b").Add("flag", "")
+
+	u := target(base, request)
+	require.Equal(t, "/api/a%20b/%2f/%C3%BC%3F?q=a%26b&flag", u.RequestURI())
+	require.Equal(t, "backend:8080", u.Host)
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"io"
+	"time"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/proto"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/transport"
+)
+
+// tunnel forwards the upgrade request and, if the upstream agrees to switch protocols, relays
+// the raw data in both directions until either side closes the connection. Otherwise, the
+// upstream response is forwarded as usual.
+func (p *Proxy) tunnel(request *http.Request) *http.Response {
+	if request.Protocol == proto.HTTP2 {
+		// HTTP/2 connections are multiplexed, therefore cannot be hijacked.
+		return http.Error(request, status.ErrNotImplemented)
+	}
+
+	upstream := p.pick(request, nil)
+	if upstream == nil {
+		return http.Error(request, status.ErrServiceUnavailable)
+	}
+
+	resp, err := p.forward(request, upstream, true)
+	if err != nil {
+		if request.Ctx.Err() == nil {
+			upstream.fail(p.maxFails, p.cooldown)
+		}
+
+		return http.Error(request, upstreamError(err))
+	}
+
+	if resp.Code != status.SwitchingProtocols {
+		return p.respond(request, upstream, resp)
+	}
+
+	defer upstream.active.Add(-1)
+
+	backend, err := resp.Hijack()
+	if err != nil {
+		return http.Error(request, status.ErrBadGateway)
+	}
+
+	defer backend.Close()
+
+	client, err := request.Hijack()
+	if err != nil {
+		return http.Error(request, err)
+	}
+
+	reason := resp.Status
+	if len(reason) == 0 {
+		reason = status.String(status.SwitchingProtocols)
+	}
+
+	head := make([]byte, 0, 256)
+	head = append(head, "HTTP/1.1 101 "...)
+	head = append(head, reason...)
+	head = append(head, "\r\n"...)
+	for key, value := range resp.Headers.Pairs() {
+		head = append(head, key...)
+		head = append(head, ": "...)
+		head = append(head, value...)
+		head = append(head, "\r\n"...)
+	}
+
+	head = append(head, "\r\n"...)
+	if _, err = client.Write(head); err != nil {
+		return nil
+	}
+
+	relay(client, backend)
+	return nil
+}
+
+// relay copies the data between two connections in both directions. As soon as either of the
+// directions is done, both connections are closed.
+func relay(a, b transport.Client) {
+	done := make(chan struct{})
+
+	go func() {
+		pipe(a, b)
+		_ = a.Close()
+		_ = b.Close()
+		close(done)
+	}()
+
+	pipe(b, a)
+	_ = a.Close()
+	_ = b.Close()
+	<-done
+}
+
+type pending interface {
+	Pending() []byte
+}
+
+// pipe copies the data from src to dst, starting with the data already read from src, but not
+// consumed yet.
+func pipe(dst, src transport.Client) {
+	if p, ok := src.(pending); ok {
+		if data := p.Pending(); len(data) > 0 {
+			if _, err := dst.Write(data); err != nil {
+				return
+			}
+		}
+	}
+
+	conn := src.Conn()
+	// tunnels are long-living and might stay idle for a while, so they aren't limited by
+	// the read timeouts.
+	_ = conn.SetReadDeadline(time.Time{})
+	_, _ = io.Copy(dst.Conn(), conn)
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Upstream is a backend server the requests are forwarded to.
+type Upstream struct {
+	URL *url.URL
+	// active is the number of requests currently in flight.
+	active atomic.Int64
+	// down is set by the active health check.
+	down atomic.Bool
+	mu   sync.Mutex
+	// fails is the number of consecutive failures observed while forwarding requests.
+	fails int
+	// until is the moment the upstream is considered available again after exceeding the
+	// failures limit.
+	until time.Time
+}
+
+// Active returns the number of requests being currently served by the upstream.
+func (u *Upstream) Active() int64 {
+	return u.active.Load()
+}
+
+// Healthy tells whether the upstream passed the last active health check. It's always true if
+// health checks are disabled.
+func (u *Upstream) Healthy() bool {
+	return !u.down.Load()
+}
+
+// available tells whether the upstream can be picked. Upstreams failed passively are given a
+// chance again after the cooldown.
+func (u *Upstream) available(now time.Time) bool {
+	if u.down.Load() {
+		return false
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.until.IsZero() || !now.Before(u.until)
+}
+
+// fail records a failure. Exceeding maxFails in a row makes the upstream unavailable for the
+// cooldown period.
+func (u *Upstream) fail(maxFails int, cooldown time.Duration) {
+	if maxFails <= 0 {
+		return
+	}
+
+	u.mu.Lock()
+	u.fails++
+	if u.fails >= maxFails {
+		u.fails = 0
+		u.until = time.Now().Add(cooldown)
+	}
+	u.mu.Unlock()
+}
+
+// succeed resets the failures counter.
+func (u *Upstream) succeed() {
+	u.mu.Lock()
+	u.fails = 0
+	u.until = time.Time{}
+	u.mu.Unlock()
+}
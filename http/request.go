@@ -3,11 +3,14 @@ package http
 import (
 	"context"
 	"net"
+	"time"
 
 	"github.com/indigo-web/indigo/config"
 	"github.com/indigo-web/indigo/http/cookie"
 	"github.com/indigo-web/indigo/http/method"
 	"github.com/indigo-web/indigo/http/proto"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/response"
 	"github.com/indigo-web/indigo/internal/strutil"
 	"github.com/indigo-web/indigo/kv"
 	"github.com/indigo-web/indigo/transport"
@@ -41,8 +44,9 @@ type Request struct {
 	// Remote holds the remote address. Please note that this is generally not a good parameter to identify
 	// a user, because there might be proxies in the middle.
 	Remote net.Addr
-	// Ctx is user-managed context which lives as long as the connection does and is never automatically
-	// cleared.
+	// Ctx is the request context. It is cancelled as soon as the client disconnects, the server is
+	// stopping or the request deadline (config.NET.RequestTimeout) is exceeded, and must not be used
+	// after the handler returns. It can be replaced, however the replacement must be derived from it.
 	Ctx context.Context
 	// Env contains a fixed set of contextual values which are useful in specific cases. They aren't
 	// passed via the Ctx due to performance considerations.
@@ -62,7 +66,7 @@ func NewRequest(
 	client transport.Client,
 	headers, params, vars *kv.Storage,
 ) *Request {
-	return &Request{
+	request := &Request{
 		Protocol: proto.HTTP11,
 		Params:   params,
 		Vars:     vars,
@@ -73,6 +77,18 @@ func NewRequest(
 		response: response,
 		cfg:      cfg,
 	}
+
+	if response != nil {
+		response.request = request
+	}
+
+	if unix, ok := request.Remote.(*transport.UnixAddr); ok {
+		request.Env.Cred = unix.Cred
+	}
+
+	request.Env.Proxy = transport.ProxyHeaderOf(client.Conn())
+
+	return request
 }
 
 // Cookies returns a cookie jar with parsed cookies key-value pairs, and an error
@@ -106,7 +122,13 @@ func (r *Request) Respond() *Response {
 
 // Hijack hijacks an underlying connection. The request body is implicitly discarded before
 // exposing the transport. After the handler function terminates, the connection is closed automatically.
+//
+// HTTP/2 connections are shared among multiple requests, therefore cannot be hijacked.
 func (r *Request) Hijack() (transport.Client, error) {
+	if r.Protocol == proto.HTTP2 {
+		return nil, status.ErrNotImplemented
+	}
+
 	if err := r.Body.Discard(); err != nil {
 		return nil, err
 	}
@@ -116,19 +138,79 @@ func (r *Request) Hijack() (transport.Client, error) {
 	return r.client, nil
 }
 
+// ClientAddr returns the address of the client. If the request was forwarded by a trusted proxy,
+// the forwarded address is returned, otherwise it equals to the Remote.
+func (r *Request) ClientAddr() net.Addr {
+	if r.Env.Forwarded.Addr != nil {
+		return r.Env.Forwarded.Addr
+	}
+
+	return r.Remote
+}
+
+// Scheme returns the scheme used by the client, either "http" or "https". If the request was
+// forwarded by a trusted proxy, the forwarded scheme is preferred.
+func (r *Request) Scheme() string {
+	if len(r.Env.Forwarded.Scheme) > 0 {
+		return r.Env.Forwarded.Scheme
+	}
+
+	if r.Env.Encryption != 0 {
+		return "https"
+	}
+
+	return "http"
+}
+
+// Host returns the host requested by the client. If the request was forwarded by a trusted proxy,
+// the forwarded host is preferred over the Host header.
+func (r *Request) Host() string {
+	if len(r.Env.Forwarded.Host) > 0 {
+		return r.Env.Forwarded.Host
+	}
+
+	return r.Headers.Value("host")
+}
+
+// Precondition evaluates the conditional headers (If-Match, If-None-Match, If-Modified-Since and
+// If-Unmodified-Since) against the current validators of the target resource, following the
+// RFC 9110, 13.2.2. Empty etag or zero modified mean the resource lacks such a validator. It returns
+// either status.ErrNotModified or status.ErrPreconditionFailed if the request must not be performed,
+// which makes it suitable for optimistic locking on state-changing requests:
+//
+//	if err := request.Precondition(item.ETag, time.Time{}); err != nil {
+//		return http.Error(request, err)
+//	}
+func (r *Request) Precondition(etag string, modified time.Time) error {
+	if len(etag) > 0 {
+		etag = quoteETag(etag)
+	}
+
+	switch response.Preconditions(r.Method, r.Headers, etag, modified) {
+	case status.NotModified:
+		return status.ErrNotModified
+	case status.PreconditionFailed:
+		return status.ErrPreconditionFailed
+	default:
+		return nil
+	}
+}
+
 // Hijacked tells whether the connection was hijacked.
 func (r *Request) Hijacked() bool {
 	return r.hijacked
 }
 
 // Reset clears all the request fields to its zero values. This also includes resetting the context.
+// The encryption, peer credentials and PROXY protocol header are kept, as they are properties of
+// the connection.
 func (r *Request) Reset() {
 	r.Params.Clear()
 	r.Vars.Clear()
 	r.Headers.Clear()
 	r.commonHeaders = commonHeaders{}
 	r.Ctx = zeroContext
-	r.Env = Environment{}
+	r.Env = Environment{Encryption: r.Env.Encryption, Cred: r.Env.Cred, Proxy: r.Env.Proxy}
 }
 
 type Environment struct {
@@ -143,6 +225,29 @@ type Environment struct {
 	// AliasFrom contains the original request path, in case it was replaced via alias
 	// aka implicit redirect
 	AliasFrom string
+	// TrailingSlash tells whether the request path ended with a slash before it was trimmed by
+	// the router.
+	TrailingSlash bool
+	// Cred contains credentials of the peer process, if connected via a Unix socket on a platform
+	// supporting SO_PEERCRED (Linux). Otherwise nil.
+	Cred *transport.Cred
+	// Proxy contains the PROXY protocol header, if enabled for the transport. The Remote is
+	// already replaced by the original client address.
+	Proxy *transport.ProxyHeader
+	// Forwarded contains the values reported by trusted proxies via the Forwarded, X-Forwarded-*
+	// or X-Real-IP headers. It is filled by middleware.RealIP only.
+	Forwarded Forwarded
+}
+
+// Forwarded holds the client properties as they were seen by the outermost trusted proxy.
+// Empty fields mean the value wasn't reported.
+type Forwarded struct {
+	// Addr is the client address.
+	Addr net.Addr
+	// Scheme is either "http" or "https".
+	Scheme string
+	// Host is the originally requested host.
+	Host string
 }
 
 type commonHeaders struct {
@@ -169,6 +274,8 @@ type commonHeaders struct {
 }
 
 // PreferredEncoding chooses a preferred encoding from AcceptEncoding, respecting quality markers.
+// Please note that the codecs available on the server aren't taken into account. Automatic
+// compression negotiates against them instead.
 func (c *commonHeaders) PreferredEncoding() string {
 	if len(c.AcceptEncoding) == 0 {
 		return "identity"
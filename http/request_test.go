@@ -2,9 +2,12 @@ package http
 
 import (
 	"testing"
+	"time"
 
 	"github.com/indigo-web/indigo/config"
 	"github.com/indigo-web/indigo/http/cookie"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/status"
 	"github.com/indigo-web/indigo/kv"
 	"github.com/indigo-web/indigo/transport/dummy"
 	"github.com/stretchr/testify/require"
@@ -70,4 +73,36 @@ func TestRequest(t *testing.T) {
 		))
 		t.Run("invalid qualifier", testPreferredEncoding("gzip", "gzip", "zstd;q=0.05"))
 	})
+
+	t.Run("precondition", func(t *testing.T) {
+		modified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		test := func(m method.Method, key, value, etag string, modified time.Time) error {
+			request := getRequest()
+			request.Method = m
+			request.Headers.Add(key, value)
+			return request.Precondition(etag, modified)
+		}
+
+		require.NoError(t, getRequest().Precondition("v1", modified))
+
+		require.NoError(t, test(method.PUT, "If-Match", `"v1"`, "v1", time.Time{}))
+		require.NoError(t, test(method.PUT, "If-Match", `"v0", "v1"`, "v1", time.Time{}))
+		require.NoError(t, test(method.PUT, "If-Match", "*", "v1", time.Time{}))
+		require.ErrorIs(t, test(method.PUT, "If-Match", `"v0"`, "v1", time.Time{}), status.ErrPreconditionFailed)
+		require.ErrorIs(t, test(method.PUT, "If-Match", `W/"v1"`, "v1", time.Time{}), status.ErrPreconditionFailed)
+		require.ErrorIs(t, test(method.PUT, "If-Match", `"v1"`, "", time.Time{}), status.ErrPreconditionFailed)
+
+		require.ErrorIs(t, test(method.GET, "If-None-Match", `W/"v1"`, "v1", time.Time{}), status.ErrNotModified)
+		require.ErrorIs(t, test(method.PUT, "If-None-Match", "*", "v1", time.Time{}), status.ErrPreconditionFailed)
+		require.NoError(t, test(method.GET, "If-None-Match", `"v0"`, "v1", time.Time{}))
+
+		date := modified.Format(time.RFC1123)
+		require.ErrorIs(t, test(method.GET, "If-Modified-Since", date, "", modified), status.ErrNotModified)
+		require.NoError(t, test(method.GET, "If-Modified-Since", date, "", modified.Add(time.Hour)))
+		require.NoError(t, test(method.POST, "If-Modified-Since", date, "", modified))
+		require.NoError(t, test(method.PUT, "If-Unmodified-Since", date, "", modified))
+		require.ErrorIs(t, test(method.PUT, "If-Unmodified-Since", date, "", modified.Add(time.Hour)),
+			status.ErrPreconditionFailed)
+		require.NoError(t, test(method.PUT, "If-Unmodified-Since", "malformed", "", modified.Add(time.Hour)))
+	})
 }
@@ -1,16 +1,24 @@
 package http
 
 import (
+	"errors"
 	"io"
+	"io/fs"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/flrdv/uf"
 	"github.com/indigo-web/indigo/http/cookie"
+	"github.com/indigo-web/indigo/http/json"
+	"github.com/indigo-web/indigo/http/media"
 	"github.com/indigo-web/indigo/http/mime"
 	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/http/validate"
 	"github.com/indigo-web/indigo/internal/response"
+	"github.com/indigo-web/indigo/internal/strutil"
 	"github.com/indigo-web/indigo/kv"
-	json "github.com/json-iterator/go"
 )
 
 const (
@@ -19,9 +27,13 @@ const (
 	preallocateResponseHeaders = 7
 )
 
+// defaultJSON is used by responses built outside of requests, e.g. via NewResponse.
+var defaultJSON = json.Iterator()
+
 type Response struct {
-	body   sliceReader
-	fields response.Fields
+	body    sliceReader
+	fields  response.Fields
+	request *Request
 }
 
 // NewResponse returns a new instance of the Response object with status code set to 200 OK,
@@ -67,7 +79,9 @@ func (r *Response) ContentType(value mime.MIME, charset ...mime.Charset) *Respon
 	return r.Header("Content-Type", value)
 }
 
-// Compress chooses and sets the best suiting compression based on client preferences.
+// Compress chooses and sets the best suiting compression based on client preferences. Small bodies
+// and content types excluded by the compression policy of the App aren't compressed. Otherwise, the
+// Vary: Accept-Encoding is added automatically, unless already present.
 func (r *Response) Compress() *Response {
 	r.fields.AutoCompress = true
 	r.fields.ContentEncoding = "" // to avoid conflicts, wins the last method applied.
@@ -82,6 +96,13 @@ func (r *Response) Compression(token string) *Response {
 	return r
 }
 
+// Precompressed marks the stream as already compressed by the token, so it's sent as is with the
+// Content-Encoding set accordingly. Neither Compress nor Compression apply to such responses.
+func (r *Response) Precompressed(token string) *Response {
+	r.fields.Precompressed = token
+	return r
+}
+
 // Header appends a key-values pair into the list of headers to be sent in the response. Passing
 // Content-Encoding isn't equivalent to calling Compress() and ultimately results in no encodings
 // being automatically applied. Can be used in order to use own compressors.
@@ -125,7 +146,7 @@ func (r *Response) Write(b []byte) (n int, err error) {
 }
 
 // TryFile tries to open a file by the path for reading and sets it as an upload stream if succeeded.
-// Otherwise, the error is returned.
+// Otherwise, the error is returned. Range requests to the file are handled automatically.
 func (r *Response) TryFile(path string) (*Response, error) {
 	fd, err := os.Open(path)
 	if err != nil {
@@ -143,6 +164,7 @@ func (r *Response) TryFile(path string) (*Response, error) {
 
 	return r.
 		ContentType(mime.Guess(path, mime.HTML)).
+		Validators(stat).
 		Stream(fd, stat.Size()), nil
 }
 
@@ -156,7 +178,8 @@ func (r *Response) File(path string) *Response {
 // Stream sets a reader to be the source of the response's body. If no size is provided AND the reader
 // doesn't have the Len() int method, the stream is considered unsized and therefore will be streamed
 // using chunked transfer encoding. Otherwise, plain transfer is used, unless a compression is applied.
-// Specifying the size of -1 forces the stream to be considered unsized.
+// Specifying the size of -1 forces the stream to be considered unsized. Sized streams implementing either
+// io.ReadSeeker or io.ReaderAt additionally support Range requests, which are handled automatically.
 func (r *Response) Stream(reader io.Reader, size ...int64) *Response {
 	type Len interface {
 		Len() int
@@ -173,22 +196,109 @@ func (r *Response) Stream(reader io.Reader, size ...int64) *Response {
 	return r
 }
 
+// ETag sets the entity tag of the response. Unquoted tags are quoted automatically, weak tags must be
+// passed in their complete form, e.g. W/"tag". Conditional GET and HEAD requests are evaluated against
+// it automatically.
+func (r *Response) ETag(tag string) *Response {
+	return r.set("ETag", quoteETag(tag))
+}
+
+// LastModified sets the modification time of the response. Conditional GET and HEAD requests are
+// evaluated against it automatically.
+func (r *Response) LastModified(t time.Time) *Response {
+	return r.set("Last-Modified", response.FormatDate(t))
+}
+
+// Validators sets both the entity tag and the modification time derived from the file info.
+func (r *Response) Validators(info fs.FileInfo) *Response {
+	return r.
+		set("ETag", response.FileETag(info.ModTime(), info.Size())).
+		LastModified(info.ModTime())
+}
+
+// HashETag derives the entity tag from the body, unless set explicitly. Only bodies set via Bytes,
+// String, Write and alike are eligible, as streams cannot be hashed without being consumed.
+func (r *Response) HashETag() *Response {
+	r.fields.HashETag = true
+	return r
+}
+
 // Cookie adds cookies. They'll be later rendered as a set of Set-Cookie headers
 func (r *Response) Cookie(cookies ...cookie.Cookie) *Response {
 	r.fields.Cookies = append(r.fields.Cookies, cookies...)
 	return r
 }
 
-// TryJSON tries to serialize the model into JSON.
+// TryJSON tries to serialize the model into JSON by the codec set in config.Config.JSON.
 func (r *Response) TryJSON(model any) (*Response, error) {
-	stream := json.ConfigDefault.BorrowStream(r)
-	stream.WriteVal(model)
-	err := stream.Flush()
-	json.ConfigDefault.ReturnStream(stream)
-
+	err := r.jsonCodec().Encode(r, model)
 	return r.ContentType(mime.JSON), err
 }
 
+// TryNegotiate tries to serialize the model into the media type preferred by the client, as
+// listed in the Accept header. JSON is offered first, followed by the media types registered
+// in config.Config.Media. If none is acceptable, status.ErrNotAcceptable is returned.
+func (r *Response) TryNegotiate(model any) (*Response, error) {
+	if !response.Varies(r.fields.Headers, "Accept") {
+		r.Header("Vary", "Accept")
+	}
+
+	if r.request == nil {
+		return r.TryJSON(model)
+	}
+
+	m, codec, ok := media.Negotiate(r.request.Headers.Values("accept"), r.offers)
+	if !ok {
+		return r, status.ErrNotAcceptable
+	}
+
+	err := codec.Encode(r, model)
+	return r.ContentType(m), err
+}
+
+// Negotiate serializes the model into the media type preferred by the client. Otherwise,
+// the error is silently written instead.
+func (r *Response) Negotiate(model any) *Response {
+	resp, err := r.TryNegotiate(model)
+	return resp.Error(err)
+}
+
+// MediaTypes returns the media types the models are negotiated among by Negotiate, in order of
+// the server preference. JSON always goes first.
+func (r *Response) MediaTypes() []mime.MIME {
+	if r.request == nil {
+		return []mime.MIME{mime.JSON}
+	}
+
+	var mediaTypes []mime.MIME
+	for m := range r.offers {
+		mediaTypes = append(mediaTypes, m)
+	}
+
+	return mediaTypes
+}
+
+// offers yields the media types available for negotiation.
+func (r *Response) offers(yield func(mime.MIME, media.Codec) bool) {
+	if !yield(mime.JSON, r.jsonCodec()) {
+		return
+	}
+
+	for m, codec := range r.request.cfg.Media.All() {
+		if !yield(m, codec) {
+			return
+		}
+	}
+}
+
+func (r *Response) jsonCodec() json.Codec {
+	if r.request == nil || r.request.cfg.JSON == nil {
+		return defaultJSON
+	}
+
+	return r.request.cfg.JSON
+}
+
 // JSON serializes the model into JSON and sets the Content-Type to application/json if succeeded.
 // Otherwise, the error is silently written instead.
 func (r *Response) JSON(model any) *Response {
@@ -198,6 +308,10 @@ func (r *Response) JSON(model any) *Response {
 
 // Error returns the response builder with an error set. The nil value for error is a no-op.
 // If the error is an instance of status.HTTPError, its status code is used instead the default one.
+// Errors wrapping status.HTTPError use its code, too, but their message is written as well.
+// Validation errors (validate.Errors) result in status.UnprocessableEntity with the JSON-encoded
+// list of violations, e.g. {"errors": [{"field": "age", "rule": "min", "message": "..."}]}.
+// Such responses are passed through the router's error handlers, if supported.
 // The default code is status.ErrInternalServerError, which can be overridden if at least one code is
 // specified (all others are ignored).
 func (r *Response) Error(err error, code ...status.Code) *Response {
@@ -209,6 +323,24 @@ func (r *Response) Error(err error, code ...status.Code) *Response {
 		return r.Code(http.Code)
 	}
 
+	var violations validate.Errors
+	if errors.As(err, &violations) {
+		// the error is kept, so the router can pass it through its error handlers.
+		r.fields.Error = err
+		return r.
+			Code(status.UnprocessableEntity).
+			JSON(validationErrors{Errors: violations})
+	}
+
+	var http status.HTTPError
+	if errors.As(err, &http) {
+		// the error wraps the HTTP one, so its code is used, however its message is still
+		// more descriptive.
+		return r.
+			Code(http.Code).
+			String(err.Error())
+	}
+
 	c := status.InternalServerError
 	if len(code) > 0 {
 		c = code[0]
@@ -229,6 +361,23 @@ func (r *Response) Buffered(flag bool) *Response {
 	return r
 }
 
+func quoteETag(tag string) string {
+	if strings.HasPrefix(tag, `"`) || strings.HasPrefix(tag, `W/"`) {
+		return tag
+	}
+
+	return `"` + tag + `"`
+}
+
+// set replaces all the values of the header by the value.
+func (r *Response) set(key, value string) *Response {
+	r.fields.Headers = slices.DeleteFunc(r.fields.Headers, func(header kv.Pair) bool {
+		return strutil.CmpFoldSafe(header.Key, key)
+	})
+
+	return r.Header(key, value)
+}
+
 // Expose gives direct access to internal builder fields.
 func (r *Response) Expose() *response.Fields {
 	return &r.fields
@@ -290,6 +439,12 @@ func JSON(request *Request, model any) *Response {
 	return request.Respond().JSON(model)
 }
 
+// Negotiate serializes the model into the media type preferred by the client. Otherwise,
+// the error is silently written instead.
+func Negotiate(request *Request, model any) *Response {
+	return request.Respond().Negotiate(model)
+}
+
 // Error returns the response builder with an error set. The nil value for error is a no-op.
 // If the error is an instance of status.HTTPError, its status code is used instead the default one.
 // The default code is status.ErrInternalServerError, which can be overridden if at least one code is
@@ -298,6 +453,11 @@ func Error(request *Request, err error, code ...status.Code) *Response {
 	return request.Respond().Error(err, code...)
 }
 
+// validationErrors is the body of responses to validation errors.
+type validationErrors struct {
+	Errors validate.Errors `json:"errors"`
+}
+
 type sliceReader struct {
 	data []byte
 }
@@ -312,6 +472,11 @@ func (s *sliceReader) Read(b []byte) (n int, err error) {
 	return n, err
 }
 
+// Bytes returns the unread data.
+func (s *sliceReader) Bytes() []byte {
+	return s.data
+}
+
 func (s *sliceReader) Reset(data []byte) *sliceReader {
 	s.data = data
 	return s
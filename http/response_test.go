@@ -4,7 +4,12 @@ import (
 	"io"
 	"testing"
 
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http/json"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
 	"github.com/indigo-web/indigo/kv"
+	"github.com/indigo-web/indigo/transport/dummy"
 	"github.com/stretchr/testify/require"
 )
 
@@ -18,6 +23,54 @@ func TestResponse(t *testing.T) {
 		contentType := kv.NewFromPairs(resp.fields.Headers).Value("Content-Type")
 		require.Equal(t, "application/json", contentType)
 	})
+
+	t.Run("JSON with custom codec", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.JSON = json.Std()
+		request := NewRequest(cfg, NewResponse(), dummy.NewNopClient(), kv.New(), kv.New(), kv.New())
+		resp, err := request.Respond().TryJSON(map[string]string{"a": "<b>"})
+		require.NoError(t, err)
+		require.Equal(t, "{\"a\":\"<b>\"}\n", string(resp.fields.Buffer))
+	})
+
+	t.Run("Negotiate", func(t *testing.T) {
+		negotiate := func(accept ...string) (*Response, error) {
+			headers := kv.New()
+			for _, value := range accept {
+				headers.Add("Accept", value)
+			}
+
+			request := NewRequest(config.Default(), NewResponse(), dummy.NewNopClient(), headers, kv.New(), kv.New())
+			return request.Respond().TryNegotiate(map[string]int{"a": 1})
+		}
+
+		resp, err := negotiate()
+		require.NoError(t, err)
+		require.Equal(t, `{"a":1}`, string(resp.fields.Buffer))
+		headers := kv.NewFromPairs(resp.fields.Headers)
+		require.Equal(t, "application/json", headers.Value("Content-Type"))
+		require.Equal(t, "Accept", headers.Value("Vary"))
+
+		resp, err = negotiate("application/json;q=0.5, application/cbor")
+		require.NoError(t, err)
+		require.Equal(t, []byte{0xa1, 0x61, 'a', 0x01}, resp.fields.Buffer)
+		require.Equal(t, "application/cbor", kv.NewFromPairs(resp.fields.Headers).Value("Content-Type"))
+
+		_, err = negotiate("text/html")
+		require.Equal(t, status.ErrNotAcceptable, err)
+		resp = NewRequest(config.Default(), NewResponse(), dummy.NewNopClient(), kv.New().Add("Accept", "text/html"), kv.New(), kv.New()).
+			Respond().Negotiate(1)
+		require.Equal(t, status.NotAcceptable, resp.fields.Code)
+	})
+
+	t.Run("MediaTypes", func(t *testing.T) {
+		request := NewRequest(config.Default(), NewResponse(), dummy.NewNopClient(), kv.New(), kv.New(), kv.New())
+		require.Equal(t,
+			[]mime.MIME{mime.JSON, mime.XML, mime.ApplicationXML, mime.CBOR, mime.MsgPack},
+			request.Respond().MediaTypes(),
+		)
+		require.Equal(t, []mime.MIME{mime.JSON}, NewResponse().MediaTypes())
+	})
 }
 
 func TestSliceReader(t *testing.T) {
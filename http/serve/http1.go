@@ -1,21 +1,31 @@
 package serve
 
 import (
+	"context"
 	"net"
 
 	"github.com/indigo-web/indigo/config"
 	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/proto"
+	"github.com/indigo-web/indigo/http/status"
 	"github.com/indigo-web/indigo/internal/codecutil"
 	"github.com/indigo-web/indigo/internal/construct"
 	"github.com/indigo-web/indigo/internal/protocol/http1"
+	"github.com/indigo-web/indigo/internal/protocol/http2"
 	"github.com/indigo-web/indigo/router"
+	"github.com/indigo-web/indigo/transport"
 )
 
 // HTTP1 setups and serves an HTTP/1.1 server until it stops. Note that the connection isn't
-// automatically closed on server stop
+// automatically closed on server stop, however request contexts derived from the ctx are cancelled.
+// The tracker, if not nil, lets the graceful shutdown wrap the connection up.
+// Clients starting with the HTTP/2 connection preface (prior knowledge) and upgrading via h2c are
+// served as HTTP/2 ones.
 func HTTP1(
+	ctx context.Context,
 	cfg *config.Config,
 	conn net.Conn,
+	tracker *transport.Tracker,
 	enc uint16,
 	r router.Router,
 	codecs codecutil.Cache,
@@ -23,7 +33,21 @@ func HTTP1(
 	client := construct.Client(cfg.NET, conn)
 	request := construct.Request(cfg, client)
 	request.Env.Encryption = enc
-	suit := http1.New(cfg, r, client, request, codecs)
+
+	priorKnowledge, err := http2.PriorKnowledge(client)
+	if err != nil {
+		r.OnError(request, status.ErrCloseConnection)
+		return
+	}
+
+	if priorKnowledge {
+		http2.New(ctx, cfg, r, client, tracker, enc, codecs).Serve()
+		return
+	}
+
+	suit := http1.New(ctx, cfg, r, client, tracker, request, codecs)
 	request.Body = http.NewBody(suit)
-	suit.Serve()
+	if suit.Serve() == proto.HTTP2 {
+		http2.New(ctx, cfg, r, client, tracker, enc, codecs).ServeUpgrade(request, request.Headers.Value("HTTP2-Settings"))
+	}
 }
@@ -0,0 +1,28 @@
+package serve
+
+import (
+	"context"
+	"net"
+
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/internal/codecutil"
+	"github.com/indigo-web/indigo/internal/construct"
+	"github.com/indigo-web/indigo/internal/protocol/http2"
+	"github.com/indigo-web/indigo/router"
+	"github.com/indigo-web/indigo/transport"
+)
+
+// HTTP2 serves the connection, which is known to speak HTTP/2 (e.g. negotiated via ALPN),
+// until it is closed. The tracker, if not nil, lets the graceful shutdown wrap the connection up.
+func HTTP2(
+	ctx context.Context,
+	cfg *config.Config,
+	conn net.Conn,
+	tracker *transport.Tracker,
+	enc uint16,
+	r router.Router,
+	codecs codecutil.Cache,
+) {
+	client := construct.Client(cfg.NET, conn)
+	http2.New(ctx, cfg, r, client, tracker, enc, codecs).Serve()
+}
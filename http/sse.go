@@ -0,0 +1,270 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indigo-web/indigo/http/mime"
+)
+
+var (
+	ErrBadEvent     = errors.New("event id and name must not contain line breaks")
+	ErrDisconnected = errors.New("client disconnected")
+)
+
+// Event is a single server-sent event. Empty fields are omitted.
+type Event struct {
+	// ID sets the last event ID, which is sent back by reconnecting clients via the
+	// Last-Event-ID header.
+	ID string
+	// Event is the event name. Clients default it to "message".
+	Event string
+	// Data is the event payload. Multiline data is split into multiple data fields.
+	Data string
+	// Retry tells the client, how long it should wait before reconnecting.
+	Retry time.Duration
+}
+
+// EventWriter sends server-sent events. Every event is flushed immediately.
+type EventWriter interface {
+	// Send writes the event.
+	Send(event Event) error
+	// Data is a shorthand for Send(Event{Data: data}).
+	Data(data string) error
+	// Comment writes a comment, which is ignored by clients.
+	Comment(text string) error
+	// Heartbeat starts sending empty comments periodically, keeping the connection alive and
+	// detecting disconnected clients. Repeated calls change the interval. The heartbeat
+	// stops after the callback returns.
+	Heartbeat(interval time.Duration)
+	// LastEventID returns the ID of the last event received by the client, if it reconnects.
+	LastEventID() string
+	// Done returns a channel, which is closed once the client is gone, the request context
+	// is canceled (e.g. the server is shutting down) or the response is terminated otherwise.
+	Done() <-chan struct{}
+}
+
+// SSE returns a response streaming server-sent events. The callback is called once the response
+// headers are being sent, and all writes return ErrDisconnected after the client has gone. Returning
+// non-nil error from the callback terminates the connection.
+func SSE(request *Request, cb func(events EventWriter) error) *Response {
+	return request.Respond().
+		ContentType(mime.EventStream).
+		Header("Cache-Control", "no-cache").
+		Buffered(false).
+		Stream(newEventStream(request.Ctx, request.Headers.Value("Last-Event-ID"), cb), -1)
+}
+
+var _ EventWriter = new(eventStream)
+
+// eventStream is the response body stream, fed by the callback running in a separate goroutine.
+type eventStream struct {
+	ctx         context.Context
+	cb          func(EventWriter) error
+	lastEventID string
+	start       sync.Once
+	pr          *io.PipeReader
+	pw          *io.PipeWriter
+	finished    chan struct{}
+	mu          sync.Mutex
+	buff        []byte
+	heartbeat   *time.Ticker
+	done        chan struct{}
+	doneOnce    sync.Once
+	closeOnce   sync.Once
+}
+
+func newEventStream(ctx context.Context, lastEventID string, cb func(EventWriter) error) *eventStream {
+	return &eventStream{
+		ctx:         ctx,
+		cb:          cb,
+		lastEventID: lastEventID,
+		done:        make(chan struct{}),
+	}
+}
+
+func (e *eventStream) Read(b []byte) (int, error) {
+	e.start.Do(e.run)
+	return e.pr.Read(b)
+}
+
+func (e *eventStream) run() {
+	e.pr, e.pw = io.Pipe()
+	e.finished = make(chan struct{})
+
+	go func() {
+		defer close(e.finished)
+
+		// the empty comment pushes the response headers out immediately, so the client
+		// doesn't need to wait for the first event.
+		err := e.Comment("")
+		if err == nil {
+			err = e.cb(e)
+		}
+
+		e.mu.Lock()
+		if e.heartbeat != nil {
+			e.heartbeat.Stop()
+		}
+		e.mu.Unlock()
+
+		_ = e.pw.CloseWithError(err)
+	}()
+
+	go func() {
+		select {
+		case <-e.ctx.Done():
+			// the serializer is blocked reading the stream, so the pipe must be closed in order
+			// to release it.
+			e.finish()
+			_ = e.pw.CloseWithError(ErrDisconnected)
+		case <-e.finished:
+		}
+	}()
+}
+
+func (e *eventStream) finish() {
+	e.doneOnce.Do(func() {
+		close(e.done)
+	})
+}
+
+// Close is called by the serializer after the response is written or the writing failed. It
+// waits for the callback to return.
+func (e *eventStream) Close() error {
+	e.closeOnce.Do(func() {
+		e.finish()
+
+		if e.pr != nil {
+			_ = e.pr.CloseWithError(ErrDisconnected)
+			<-e.finished
+		}
+	})
+
+	return nil
+}
+
+func (e *eventStream) Send(event Event) error {
+	if strings.ContainsAny(event.ID, "\r\n\x00") || strings.ContainsAny(event.Event, "\r\n") {
+		return ErrBadEvent
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	buff := e.buff[:0]
+
+	if len(event.ID) > 0 {
+		buff = append(buff, "id: "...)
+		buff = append(buff, event.ID...)
+		buff = append(buff, '\n')
+	}
+
+	if len(event.Event) > 0 {
+		buff = append(buff, "event: "...)
+		buff = append(buff, event.Event...)
+		buff = append(buff, '\n')
+	}
+
+	if event.Retry > 0 {
+		buff = append(buff, "retry: "...)
+		buff = strconv.AppendInt(buff, event.Retry.Milliseconds(), 10)
+		buff = append(buff, '\n')
+	}
+
+	if len(event.Data) > 0 {
+		buff = appendLines(buff, "data: ", event.Data)
+	}
+
+	buff = append(buff, '\n')
+
+	return e.write(buff)
+}
+
+func (e *eventStream) Data(data string) error {
+	return e.Send(Event{Data: data})
+}
+
+func (e *eventStream) Comment(text string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var buff []byte
+	if len(text) == 0 {
+		buff = append(e.buff[:0], ":\n"...)
+	} else {
+		buff = appendLines(e.buff[:0], ": ", text)
+	}
+
+	return e.write(buff)
+}
+
+func (e *eventStream) Heartbeat(interval time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.heartbeat != nil {
+		e.heartbeat.Reset(interval)
+		return
+	}
+
+	e.heartbeat = time.NewTicker(interval)
+	go func(ticker *time.Ticker) {
+		for {
+			select {
+			case <-ticker.C:
+				if e.Comment("") != nil {
+					return
+				}
+			case <-e.finished:
+				return
+			}
+		}
+	}(e.heartbeat)
+}
+
+func (e *eventStream) LastEventID() string {
+	return e.lastEventID
+}
+
+func (e *eventStream) Done() <-chan struct{} {
+	return e.done
+}
+
+// write must be called with the lock held.
+func (e *eventStream) write(buff []byte) error {
+	e.buff = buff
+
+	if _, err := e.pw.Write(buff); err != nil {
+		return ErrDisconnected
+	}
+
+	return nil
+}
+
+// appendLines appends every line of the text with the prefix, normalizing line breaks.
+func appendLines(buff []byte, prefix, text string) []byte {
+	for {
+		end := strings.IndexAny(text, "\r\n")
+		if end == -1 {
+			buff = append(buff, prefix...)
+			buff = append(buff, text...)
+			return append(buff, '\n')
+		}
+
+		buff = append(buff, prefix...)
+		buff = append(buff, text[:end]...)
+		buff = append(buff, '\n')
+
+		if strings.HasPrefix(text[end:], "\r\n") {
+			end++
+		}
+
+		text = text[end+1:]
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSE(t *testing.T) {
+	t.Run("events", func(t *testing.T) {
+		stream := newEventStream(context.Background(), "42", func(events EventWriter) error {
+			require.Equal(t, "42", events.LastEventID())
+			require.NoError(t, events.Data("hello"))
+			require.NoError(t, events.Send(Event{
+				ID:    "43",
+				Event: "update",
+				Data:  "first\nsecond\r\nthird\r",
+				Retry: 3 * time.Second,
+			}))
+			require.NoError(t, events.Comment("just a comment"))
+			require.ErrorIs(t, events.Send(Event{ID: "4\n4"}), ErrBadEvent)
+			return nil
+		})
+
+		data, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		require.NoError(t, stream.Close())
+
+		want := ":\n" +
+			"data: hello\n\n" +
+			"id: 43\nevent: update\nretry: 3000\ndata: first\ndata: second\ndata: third\ndata: \n\n" +
+			": just a comment\n"
+		require.Equal(t, want, string(data))
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		stream := newEventStream(context.Background(), "", func(events EventWriter) error {
+			events.Heartbeat(10 * time.Millisecond)
+			<-events.Done()
+			return nil
+		})
+
+		buff := make([]byte, 64)
+		for range 3 {
+			n, err := stream.Read(buff)
+			require.NoError(t, err)
+			require.Equal(t, ":\n", string(buff[:n]))
+		}
+
+		require.NoError(t, stream.Close())
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		result := make(chan error, 1)
+		stream := newEventStream(context.Background(), "", func(events EventWriter) error {
+			<-events.Done()
+			result <- events.Data("are you there?")
+			return nil
+		})
+
+		buff := make([]byte, 64)
+		_, err := stream.Read(buff)
+		require.NoError(t, err)
+		require.NoError(t, stream.Close())
+		require.ErrorIs(t, <-result, ErrDisconnected)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := newEventStream(ctx, "", func(events EventWriter) error {
+			<-events.Done()
+			return nil
+		})
+
+		buff := make([]byte, 64)
+		_, err := stream.Read(buff)
+		require.NoError(t, err)
+
+		cancel()
+		_, err = stream.Read(buff)
+		require.ErrorIs(t, err, ErrDisconnected)
+		require.NoError(t, stream.Close())
+	})
+
+	t.Run("never read", func(t *testing.T) {
+		stream := newEventStream(context.Background(), "", func(events EventWriter) error {
+			require.Fail(t, "callback must not be called")
+			return nil
+		})
+
+		require.NoError(t, stream.Close())
+	})
+}
@@ -18,6 +18,7 @@ func (h HTTPError) Error() string {
 
 var (
 	ErrCloseConnection = NewError(CloseConnection, "actively closing the connection")
+	ErrNotModified     = NewError(NotModified, "not modified")
 
 	ErrBadRequest                    = NewError(BadRequest, "bad request")
 	ErrTooLongRequestLine            = NewError(BadRequest, "request line is too long")
@@ -0,0 +1,195 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// builtin returns the built-in rule by its name. Parameters are parsed in advance, so malformed
+// ones are reported once the struct type is compiled.
+func builtin(name, param string) (Func, error) {
+	switch name {
+	case "min":
+		bound, err := parseBound(name, param)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(value reflect.Value, _ string) error {
+			if n, ok := number(value); ok {
+				if n < bound {
+					return fmt.Errorf("must be at least %s", param)
+				}
+
+				return nil
+			}
+
+			if length(value) < int(bound) {
+				return lengthError("at least", param, value)
+			}
+
+			return nil
+		}, nil
+	case "max":
+		bound, err := parseBound(name, param)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(value reflect.Value, _ string) error {
+			if n, ok := number(value); ok {
+				if n > bound {
+					return fmt.Errorf("must be at most %s", param)
+				}
+
+				return nil
+			}
+
+			if length(value) > int(bound) {
+				return lengthError("at most", param, value)
+			}
+
+			return nil
+		}, nil
+	case "len":
+		bound, err := parseBound(name, param)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(value reflect.Value, _ string) error {
+			if length(value) != int(bound) {
+				return lengthError("exactly", param, value)
+			}
+
+			return nil
+		}, nil
+	case "regex":
+		expr, err := regexp.Compile(param)
+		if err != nil {
+			return nil, fmt.Errorf("bad regex rule: %w", err)
+		}
+
+		return func(value reflect.Value, _ string) error {
+			if !expr.MatchString(text(value)) {
+				return fmt.Errorf("must match %s", param)
+			}
+
+			return nil
+		}, nil
+	case "enum":
+		options := strings.Split(param, "|")
+		message := "must be one of " + strings.Join(options, ", ")
+		return func(value reflect.Value, _ string) error {
+			str := fmt.Sprint(value.Interface())
+			for _, option := range options {
+				if str == option {
+					return nil
+				}
+			}
+
+			return errors.New(message)
+		}, nil
+	case "email":
+		return func(value reflect.Value, _ string) error {
+			str := text(value)
+			addr, err := mail.ParseAddress(str)
+			if err != nil || addr.Address != str {
+				return errors.New("must be a valid email address")
+			}
+
+			return nil
+		}, nil
+	case "uuid":
+		return func(value reflect.Value, _ string) error {
+			if !isUUID(text(value)) {
+				return errors.New("must be a valid UUID")
+			}
+
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown rule %q", name)
+	}
+}
+
+func parseBound(name, param string) (float64, error) {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s rule requires a numeric parameter, got %q", name, param)
+	}
+
+	return bound, nil
+}
+
+func number(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+func length(value reflect.Value) int {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String())
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return value.Len()
+	default:
+		return 0
+	}
+}
+
+func lengthError(bound, param string, value reflect.Value) error {
+	if value.Kind() == reflect.String {
+		return fmt.Errorf("must be %s %s characters long", bound, param)
+	}
+
+	return fmt.Errorf("must contain %s %s items", bound, param)
+}
+
+func text(value reflect.Value) string {
+	if value.Kind() == reflect.String {
+		return value.String()
+	}
+
+	return fmt.Sprint(value.Interface())
+}
+
+// isUUID tells whether the string is a UUID in its canonical 8-4-4-4-12 form.
+func isUUID(str string) bool {
+	if len(str) != 36 {
+		return false
+	}
+
+	for i := range len(str) {
+		switch i {
+		case 8, 13, 18, 23:
+			if str[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(str[i]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
@@ -0,0 +1,351 @@
+// Package validate checks models against the rules declared by the validate struct tag, as
+// done by http.Bind, http.Body.JSON and http.Body.Decode.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/indigo-web/indigo/http/status"
+)
+
+// Func checks the value against the rule parameter, which is empty if none is passed. The
+// returned error's message is reported to the client, so it should be short and descriptive,
+// e.g. "must be even". The value is never a pointer, as those are dereferenced beforehand.
+type Func func(value reflect.Value, param string) error
+
+// FieldError describes a single field failing a rule. The Field is a dot-separated path of the
+// field, named by its json tag if any, e.g. "items[1].name".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (f FieldError) Error() string {
+	return f.Field + ": " + f.Message
+}
+
+// Errors are all the rule violations of a model. It wraps status.ErrUnprocessableEntity.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e Errors) Unwrap() error {
+	return status.ErrUnprocessableEntity
+}
+
+// Validator validates structs by their validate tags. The tag is a comma-separated list of
+// rules, each optionally followed by a parameter after the equality sign:
+//   - required: the value must not be zero,
+//   - omitempty: zero value skips all the other rules,
+//   - min=n and max=n: bounds of a number, or the length of a string (in runes), slice or map,
+//   - len=n: the exact length of a string (in runes), slice or map,
+//   - regex=expr: the string must match the regular expression. It must not contain commas,
+//   - enum=a|b|c: the value must be one of the listed ones,
+//   - email: the string must be a valid email address,
+//   - uuid: the string must be a UUID in its canonical form.
+//
+// Rules apply to zero values as well, so e.g. an empty string fails the email rule. Optional
+// fields are either pointers, whose nil values skip all the rules but required, or marked by
+// the omitempty rule. Nested structs, pointers to them and slices of them are validated as well.
+//
+// Unknown rules and malformed parameters are reported once the struct type is compiled, which
+// happens either in advance by Compile, or by the first Struct call with the type.
+type Validator struct {
+	funcs map[string]Func
+	types sync.Map
+}
+
+// New returns a validator supporting the built-in rules.
+func New() *Validator {
+	return &Validator{funcs: make(map[string]Func)}
+}
+
+// Register adds a custom rule, overriding any built-in one of the same name. Rules must be
+// registered before the validator is used.
+func (v *Validator) Register(name string, fn Func) *Validator {
+	if fn == nil {
+		panic("validate: nil rule function")
+	}
+
+	v.funcs[name] = fn
+	v.types.Clear()
+
+	return v
+}
+
+// Compile compiles the validation rules of the models' types, as well as of the nested ones, in
+// advance. Unknown rules and malformed parameters result in panic, so they're reported at the
+// startup instead of by the first request carrying the model.
+func (v *Validator) Compile(models ...any) *Validator {
+	for _, model := range models {
+		typ := indirectType(reflect.TypeOf(model))
+		if typ.Kind() != reflect.Struct {
+			panic(fmt.Sprintf("validate: %s is not a struct", typ))
+		}
+
+		if err := v.compileAll(typ, make(map[reflect.Type]bool)); err != nil {
+			panic(err)
+		}
+	}
+
+	return v
+}
+
+func (v *Validator) compileAll(typ reflect.Type, seen map[reflect.Type]bool) error {
+	if seen[typ] {
+		return nil
+	}
+
+	seen[typ] = true
+	fields, err := v.typeOf(typ)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		nested := indirectType(typ.Field(f.index).Type)
+		if nested.Kind() == reflect.Slice || nested.Kind() == reflect.Array {
+			nested = indirectType(nested.Elem())
+		}
+
+		if nested.Kind() == reflect.Struct {
+			if err = v.compileAll(nested, seen); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Struct validates the model, which is usually a pointer to a struct. Models of other kinds
+// are never violating any rules. The returned error, if any, is of the Errors type, unless
+// the struct type has invalid validate tags. Nil validator validates nothing.
+func (v *Validator) Struct(model any) error {
+	if v == nil {
+		return nil
+	}
+
+	value := indirect(reflect.ValueOf(model))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	if err := v.validateStruct(&errs, value, ""); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (v *Validator) validateStruct(errs *Errors, value reflect.Value, prefix string) error {
+	fields, err := v.typeOf(value.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		field := indirect(value.Field(f.index))
+		path := join(prefix, f.name)
+
+		if f.embedded {
+			if field.Kind() == reflect.Struct {
+				if err = v.validateStruct(errs, field, prefix); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if !field.IsValid() || field.IsZero() {
+			if f.required {
+				*errs = append(*errs, FieldError{Field: path, Rule: "required", Message: "is required"})
+				continue
+			}
+
+			if !field.IsValid() || f.omitempty {
+				continue
+			}
+		}
+
+		failed := false
+		for _, r := range f.rules {
+			if err := r.check(field, r.param); err != nil {
+				*errs = append(*errs, FieldError{Field: path, Rule: r.name, Message: err.Error()})
+				failed = true
+			}
+		}
+
+		if !failed {
+			if err = v.validateNested(errs, field, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *Validator) validateNested(errs *Errors, value reflect.Value, path string) error {
+	switch value.Kind() {
+	case reflect.Struct:
+		return v.validateStruct(errs, value, path)
+	case reflect.Slice, reflect.Array:
+		for i := range value.Len() {
+			elem := indirect(value.Index(i))
+			if elem.Kind() == reflect.Struct {
+				if err := v.validateStruct(errs, elem, path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+type rule struct {
+	name  string
+	param string
+	check Func
+}
+
+type field struct {
+	index     int
+	name      string
+	embedded  bool
+	required  bool
+	omitempty bool
+	rules     []rule
+}
+
+// compiled is the cached result of the struct type compilation.
+type compiled struct {
+	fields []field
+	err    error
+}
+
+// typeOf returns the validated fields of the struct type, caching the result.
+func (v *Validator) typeOf(typ reflect.Type) ([]field, error) {
+	if cached, ok := v.types.Load(typ); ok {
+		c := cached.(compiled)
+		return c.fields, c.err
+	}
+
+	fields, err := v.compile(typ)
+	if err != nil {
+		err = fmt.Errorf("validate: %s.%w", typ, err)
+	}
+
+	v.types.Store(typ, compiled{fields: fields, err: err})
+
+	return fields, err
+}
+
+func (v *Validator) compile(typ reflect.Type) (fields []field, err error) {
+	for i := range typ.NumField() {
+		sf := typ.Field(i)
+		if sf.Anonymous && indirectType(sf.Type).Kind() == reflect.Struct {
+			fields = append(fields, field{index: i, embedded: true})
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		f := field{index: i, name: fieldName(sf)}
+		for _, token := range strings.Split(sf.Tag.Get("validate"), ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(token), "=")
+			switch name {
+			case "":
+			case "required":
+				f.required = true
+			case "omitempty":
+				f.omitempty = true
+			default:
+				check, err := v.lookup(name, param)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", sf.Name, err)
+				}
+
+				f.rules = append(f.rules, rule{name: name, param: param, check: check})
+			}
+		}
+
+		if f.required || len(f.rules) > 0 || isNested(sf.Type) {
+			fields = append(fields, f)
+		}
+	}
+
+	return fields, nil
+}
+
+func (v *Validator) lookup(name, param string) (Func, error) {
+	if fn, found := v.funcs[name]; found {
+		return fn, nil
+	}
+
+	return builtin(name, param)
+}
+
+// fieldName returns the name of the field as seen by the client, i.e. by its json tag.
+func fieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if len(name) == 0 || name == "-" {
+		return sf.Name
+	}
+
+	return name
+}
+
+func isNested(typ reflect.Type) bool {
+	typ = indirectType(typ)
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = indirectType(typ.Elem())
+	}
+
+	return typ.Kind() == reflect.Struct
+}
+
+func join(prefix, name string) string {
+	if len(prefix) == 0 {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+
+	return value
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	return typ
+}
@@ -0,0 +1,172 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/stretchr/testify/require"
+)
+
+type Address struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"len=5"`
+}
+
+type Item struct {
+	SKU      string `json:"sku" validate:"required,regex=^[A-Z]{3}-[0-9]+$"`
+	Quantity int    `json:"quantity" validate:"min=1,max=10"`
+}
+
+type Base struct {
+	ID string `json:"id" validate:"omitempty,uuid"`
+}
+
+type User struct {
+	Base
+	Name    string   `json:"name" validate:"required,min=2,max=8"`
+	Email   string   `json:"email" validate:"omitempty,email"`
+	Role    string   `json:"role" validate:"omitempty,enum=admin|user"`
+	Age     *int     `json:"age" validate:"min=18"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Address *Address `json:"address"`
+	Items   []Item   `json:"items"`
+	Nick    string   `validate:"even"`
+	secret  string
+}
+
+func violations(t *testing.T, err error) Errors {
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	return errs
+}
+
+func TestValidator(t *testing.T) {
+	v := New().Register("even", func(value reflect.Value, _ string) error {
+		if value.Len()%2 != 0 {
+			return errors.New("must be of even length")
+		}
+
+		return nil
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		age := 20
+		user := User{
+			Base:    Base{ID: "123e4567-e89b-12d3-a456-426614174000"},
+			Name:    "Pavlo",
+			Email:   "pavlo@example.com",
+			Role:    "admin",
+			Age:     &age,
+			Address: &Address{City: "Kyiv", Zip: "01001"},
+			Items:   []Item{{SKU: "ABC-1", Quantity: 3}},
+			Nick:    "ab",
+		}
+		require.NoError(t, v.Struct(&user))
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		require.NoError(t, v.Struct(User{Name: "Pavlo"}))
+	})
+
+	t.Run("violations", func(t *testing.T) {
+		age := 16
+		user := User{
+			Base:    Base{ID: "not-a-uuid"},
+			Email:   "pavlo",
+			Role:    "root",
+			Age:     &age,
+			Tags:    []string{"a", "b", "c"},
+			Address: &Address{Zip: "123"},
+			Items:   []Item{{SKU: "ABC-1", Quantity: 1}, {SKU: "abc", Quantity: 11}},
+			Nick:    "abc",
+		}
+
+		err := v.Struct(&user)
+		require.True(t, errors.Is(err, status.ErrUnprocessableEntity))
+		require.Equal(t, Errors{
+			{Field: "id", Rule: "uuid", Message: "must be a valid UUID"},
+			{Field: "name", Rule: "required", Message: "is required"},
+			{Field: "email", Rule: "email", Message: "must be a valid email address"},
+			{Field: "role", Rule: "enum", Message: "must be one of admin, user"},
+			{Field: "age", Rule: "min", Message: "must be at least 18"},
+			{Field: "tags", Rule: "max", Message: "must contain at most 2 items"},
+			{Field: "address.city", Rule: "required", Message: "is required"},
+			{Field: "address.zip", Rule: "len", Message: "must be exactly 5 characters long"},
+			{Field: "items[1].sku", Rule: "regex", Message: "must match ^[A-Z]{3}-[0-9]+$"},
+			{Field: "items[1].quantity", Rule: "max", Message: "must be at most 10"},
+			{Field: "Nick", Rule: "even", Message: "must be of even length"},
+		}, violations(t, err))
+	})
+
+	t.Run("zero values", func(t *testing.T) {
+		type model struct {
+			Count int     `json:"count" validate:"min=1"`
+			Code  string  `json:"code" validate:"len=3"`
+			Limit *int    `json:"limit" validate:"min=1"`
+			Note  *string `json:"note" validate:"required"`
+		}
+
+		require.Equal(t, Errors{
+			{Field: "count", Rule: "min", Message: "must be at least 1"},
+			{Field: "code", Rule: "len", Message: "must be exactly 3 characters long"},
+			{Field: "note", Rule: "required", Message: "is required"},
+		}, violations(t, v.Struct(model{})))
+
+		zero, empty := 0, ""
+		require.Equal(t, Errors{
+			{Field: "count", Rule: "min", Message: "must be at least 1"},
+			{Field: "code", Rule: "len", Message: "must be exactly 3 characters long"},
+			{Field: "limit", Rule: "min", Message: "must be at least 1"},
+			{Field: "note", Rule: "required", Message: "is required"},
+		}, violations(t, v.Struct(model{Limit: &zero, Note: &empty})))
+	})
+
+	t.Run("string length in runes", func(t *testing.T) {
+		type model struct {
+			Name string `validate:"max=3"`
+		}
+
+		require.NoError(t, v.Struct(model{Name: "Їжа"}))
+		errs := violations(t, v.Struct(model{Name: "Їжак"}))
+		require.Equal(t, "Name: must be at most 3 characters long", errs.Error())
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		require.NoError(t, v.Struct(map[string]any{"a": 1}))
+		require.NoError(t, v.Struct(nil))
+	})
+
+	t.Run("nil validator", func(t *testing.T) {
+		var v *Validator
+		require.NoError(t, v.Struct(User{}))
+	})
+
+	t.Run("bad tags", func(t *testing.T) {
+		type unknown struct {
+			A string `validate:"required,gte=1"`
+		}
+		err := New().Struct(unknown{})
+		require.EqualError(t, err, `validate: validate.unknown.A: unknown rule "gte"`)
+		require.False(t, errors.Is(err, status.ErrUnprocessableEntity))
+
+		type badParam struct {
+			A string `validate:"min=many"`
+		}
+		require.Error(t, New().Struct(badParam{}))
+	})
+
+	t.Run("compile", func(t *testing.T) {
+		require.NotPanics(t, func() { v.Compile(User{}) })
+		require.Panics(t, func() { New().Compile(User{}) }, "the even rule isn't registered")
+
+		type nested struct {
+			Items []struct {
+				A string `validate:"unknown"`
+			}
+		}
+		require.Panics(t, func() { New().Compile(&nested{}) })
+		require.Panics(t, func() { New().Compile(42) })
+	})
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"unicode/utf8"
+)
+
+// CloseCode is a status code sent in the close frame (RFC 6455, 7.4).
+type CloseCode uint16
+
+const (
+	CloseNormal             CloseCode = 1000
+	CloseGoingAway          CloseCode = 1001
+	CloseProtocolError      CloseCode = 1002
+	CloseUnsupportedData    CloseCode = 1003
+	CloseNoStatus           CloseCode = 1005
+	CloseAbnormal           CloseCode = 1006
+	CloseInvalidPayload     CloseCode = 1007
+	ClosePolicyViolation    CloseCode = 1008
+	CloseMessageTooBig      CloseCode = 1009
+	CloseMandatoryExtension CloseCode = 1010
+	CloseInternalError      CloseCode = 1011
+)
+
+var (
+	ErrProtocol        = &CloseError{Code: CloseProtocolError, Reason: "protocol error"}
+	ErrInvalidPayload  = &CloseError{Code: CloseInvalidPayload, Reason: "invalid payload"}
+	ErrMessageTooLarge = &CloseError{Code: CloseMessageTooBig, Reason: "message is too large"}
+	// ErrClosed is returned when the connection is used after being closed.
+	ErrClosed = errors.New("websocket: connection is closed")
+)
+
+// CloseError is returned when the connection is closed, either by the peer or due to a
+// protocol violation.
+type CloseError struct {
+	Code   CloseCode
+	Reason string
+}
+
+func (c *CloseError) Error() string {
+	err := "websocket: close " + strconv.Itoa(int(c.Code))
+	if len(c.Reason) > 0 {
+		err += ": " + c.Reason
+	}
+
+	return err
+}
+
+// validSendCode tells whether the code may be sent in a close frame.
+func validSendCode(code CloseCode) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	default:
+		// 3000-3999 are registered by IANA, 4000-4999 are for private use.
+		return code >= 3000 && code <= 4999
+	}
+}
+
+func parseClosePayload(payload []byte) (*CloseError, error) {
+	switch {
+	case len(payload) == 0:
+		return &CloseError{Code: CloseNoStatus}, nil
+	case len(payload) == 1:
+		return nil, ErrProtocol
+	}
+
+	code := CloseCode(binary.BigEndian.Uint16(payload))
+	if !validSendCode(code) {
+		return nil, ErrProtocol
+	}
+
+	reason := payload[2:]
+	if !utf8.Valid(reason) {
+		return nil, ErrInvalidPayload
+	}
+
+	return &CloseError{Code: code, Reason: string(reason)}, nil
+}
+
+func appendClosePayload(b []byte, code CloseCode, reason string) []byte {
+	if code == CloseNoStatus {
+		return b
+	}
+
+	b = binary.BigEndian.AppendUint16(b, uint16(code))
+	// the reason is truncated, as control frames are limited in size.
+	return append(b, reason[:min(len(reason), maxControlPayload-2)]...)
+}
@@ -0,0 +1,611 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/transport"
+)
+
+// MessageType is the type of data message.
+type MessageType uint8
+
+const (
+	TextMessage   = MessageType(opText)
+	BinaryMessage = MessageType(opBinary)
+)
+
+var (
+	ErrBadMessageType   = errors.New("websocket: unknown message type")
+	ErrControlTooLarge  = errors.New("websocket: control frame payload is too large")
+	ErrWriterClosed     = errors.New("websocket: message writer is closed")
+	errCorruptedMessage = &CloseError{Code: CloseInvalidPayload, Reason: "corrupted compressed message"}
+)
+
+// deflateTail is stripped from every compressed message by the sender, therefore must be
+// appended back before decompressing (RFC 7692, 7.2.2).
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// serverHeaderLen is the largest possible header of an unmasked frame.
+const serverHeaderLen = maxHeaderLen - 4
+
+type pending interface {
+	Pending() []byte
+}
+
+// Conn is a WebSocket connection. At most one goroutine may read messages and at most one may
+// write them at the same time. However, Ping and Close are safe to be called concurrently with
+// all other methods.
+//
+// Read errors are permanent: once one occurred, the connection is closed and all the following
+// reads return the same error.
+type Conn struct {
+	client       transport.Client
+	conn         net.Conn
+	br           *bufio.Reader
+	subprotocol  string
+	deflate      codec.Instance
+	maxSize      int64
+	readBuffSize int
+	onPong       func([]byte)
+
+	hdrBuff   [maxHeaderLen]byte
+	ctrlBuff  [maxControlPayload]byte
+	frame     frameHeader
+	remaining uint64
+	maskPos   int
+	msgSize   int64
+	reading   bool
+	readErr   error
+	raw       rawReader
+	fetcher   frameFetcher
+	inflater  inflater
+	message   []byte
+
+	wmu       sync.Mutex
+	closeSent bool
+	closed    bool
+	ctrlWBuff [serverHeaderLen + maxControlPayload]byte
+	writer    messageWriter
+}
+
+func newConn(
+	client transport.Client, subprotocol string, deflate codec.Instance, maxSize int64, readBuff, writeBuff int,
+) *Conn {
+	conn := client.Conn()
+	// the transport might have left its own deadline, which isn't relevant anymore.
+	_ = conn.SetReadDeadline(time.Time{})
+
+	var src io.Reader = conn
+	if p, ok := client.(pending); ok && len(p.Pending()) > 0 {
+		src = io.MultiReader(bytes.NewReader(p.Pending()), conn)
+		client.Pushback(nil)
+	}
+
+	c := &Conn{
+		client:       client,
+		conn:         conn,
+		br:           bufio.NewReaderSize(src, readBuff),
+		subprotocol:  subprotocol,
+		deflate:      deflate,
+		maxSize:      maxSize,
+		readBuffSize: readBuff,
+	}
+
+	// in order to avoid an empty frame in the end, the buffer is flushed only when there's
+	// more data to be written. Therefore, there's always a spare byte for the trailing zero.
+	c.writer = messageWriter{c: c, buff: make([]byte, serverHeaderLen, serverHeaderLen+writeBuff)}
+	c.raw = rawReader{c}
+
+	if deflate != nil {
+		c.fetcher = frameFetcher{c: c, buff: make([]byte, readBuff+len(deflateTail))}
+		c.inflater = inflater{c: c}
+	}
+
+	return c
+}
+
+// Subprotocol returns the negotiated subprotocol, if any.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Compressed tells whether the permessage-deflate extension was negotiated.
+func (c *Conn) Compressed() bool {
+	return c.deflate != nil
+}
+
+// Remote returns the remote address of the connection.
+func (c *Conn) Remote() net.Addr {
+	return c.client.Remote()
+}
+
+// SetReadDeadline sets the deadline for reading messages. There's no deadline by default.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for writing messages and control frames.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// OnPong sets the callback invoked on every received pong. The data must not be retained after
+// the callback returns. Pings are always responded automatically.
+func (c *Conn) OnPong(cb func(data []byte)) {
+	c.onPong = cb
+}
+
+// NextReader returns the reader of the next data message. Control frames are processed
+// implicitly. The reader is valid until the next call, and if it isn't exhausted by then,
+// the rest of the message is discarded.
+func (c *Conn) NextReader() (MessageType, io.Reader, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+
+	if c.reading {
+		if _, err := io.Copy(io.Discard, &c.raw); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	c.msgSize = 0
+	if err := c.nextFrame(false); err != nil {
+		return 0, nil, c.fail(err)
+	}
+
+	c.reading = true
+	typ := MessageType(c.frame.Opcode)
+
+	if !c.frame.RSV1 {
+		return typ, &c.raw, nil
+	}
+
+	c.fetcher.tail = false
+	c.inflater.Reset()
+	if err := c.deflate.ResetDecompressor(&c.fetcher, c.readBuffSize); err != nil {
+		return 0, nil, c.fail(err)
+	}
+
+	return typ, &c.inflater, nil
+}
+
+// ReadMessage reads the whole next data message. The returned data is valid until the next read.
+// Text messages are validated to be correct UTF-8.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	typ, r, err := c.NextReader()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	c.message = c.message[:0]
+
+	for {
+		if len(c.message) == cap(c.message) {
+			c.message = slices.Grow(c.message, c.readBuffSize)
+		}
+
+		n, err := r.Read(c.message[len(c.message):cap(c.message)])
+		c.message = c.message[:len(c.message)+n]
+
+		switch err {
+		case nil:
+		case io.EOF:
+			if typ == TextMessage && !utf8.Valid(c.message) {
+				return 0, nil, c.fail(ErrInvalidPayload)
+			}
+
+			return typ, c.message, nil
+		default:
+			return 0, nil, err
+		}
+	}
+}
+
+// nextFrame reads frame headers until a data frame occurs, handling control frames in between.
+func (c *Conn) nextFrame(continuation bool) error {
+	for {
+		hdr, err := readFrameHeader(c.br, c.hdrBuff[:])
+		if err != nil {
+			return err
+		}
+
+		if !hdr.Masked {
+			// client frames must always be masked (RFC 6455, 5.1)
+			return ErrProtocol
+		}
+
+		if hdr.Opcode.IsControl() {
+			if hdr.RSV1 {
+				return ErrProtocol
+			}
+
+			if err = c.handleControl(hdr); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		switch {
+		case continuation != (hdr.Opcode == opContinuation):
+			return ErrProtocol
+		case hdr.Opcode > opBinary:
+			return ErrProtocol
+		case hdr.RSV1 && (continuation || c.deflate == nil):
+			return ErrProtocol
+		case c.maxSize > 0 && hdr.Length > uint64(c.maxSize-c.msgSize):
+			return ErrMessageTooLarge
+		}
+
+		c.msgSize += int64(hdr.Length)
+		c.frame, c.remaining, c.maskPos = hdr, hdr.Length, 0
+
+		return nil
+	}
+}
+
+func (c *Conn) handleControl(hdr frameHeader) error {
+	payload := c.ctrlBuff[:hdr.Length]
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return err
+	}
+
+	maskBytes(hdr.Mask, 0, payload)
+
+	switch hdr.Opcode {
+	case opPing:
+		if err := c.writeControl(opPong, payload); err != nil && err != ErrClosed {
+			return err
+		}
+	case opPong:
+		if c.onPong != nil {
+			c.onPong(payload)
+		}
+	case opClose:
+		closeErr, err := parseClosePayload(payload)
+		if err != nil {
+			return err
+		}
+
+		return closeErr
+	default:
+		return ErrProtocol
+	}
+
+	return nil
+}
+
+// fail makes the error permanent and closes the connection. CloseError is also sent to the peer,
+// which in case of the peer's close frame means just echoing it back.
+func (c *Conn) fail(err error) error {
+	if c.readErr != nil {
+		return c.readErr
+	}
+
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	c.readErr = err
+	c.reading = false
+
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		_ = c.Close(closeErr.Code, closeErr.Reason)
+	} else {
+		_ = c.Close(CloseAbnormal, "")
+	}
+
+	return err
+}
+
+// NextWriter returns a writer of a new message. If the previous writer wasn't closed, it's
+// closed implicitly. Written data is buffered and sent in fragments, when the buffer is full.
+// The message is finished by closing the writer.
+func (c *Conn) NextWriter(typ MessageType) (io.WriteCloser, error) {
+	if typ != TextMessage && typ != BinaryMessage {
+		return nil, ErrBadMessageType
+	}
+
+	w := &c.writer
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	w.active = true
+	w.op = opcode(typ)
+	w.compress = c.deflate != nil
+	w.rsv1 = w.compress
+
+	if w.compress {
+		c.deflate.ResetCompressor(frameSink{w})
+	}
+
+	return w, nil
+}
+
+// WriteMessage writes the data as a single message.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	w, err := c.NextWriter(typ)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// Ping sends a ping frame. The payload must not exceed 125 bytes.
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return ErrControlTooLarge
+	}
+
+	return c.writeControl(opPing, data)
+}
+
+// Close sends the close frame, unless it was already sent, and closes the underlying connection.
+// Codes which aren't allowed to be sent result in the close frame without a status.
+func (c *Conn) Close(code CloseCode, reason string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+
+	var err error
+	if !c.closeSent {
+		if !validSendCode(code) {
+			code = CloseNoStatus
+		}
+
+		var buff [maxControlPayload]byte
+		payload := appendClosePayload(buff[:0], code, reason)
+		err = c.writeFrame(opClose, payload)
+		c.closeSent = true
+	}
+
+	if cerr := c.client.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (c *Conn) writeControl(op opcode, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	return c.writeFrame(op, payload)
+}
+
+// writeFrame writes a single control frame. Must be called with the write lock held.
+func (c *Conn) writeFrame(op opcode, payload []byte) error {
+	buff := appendFrameHeader(c.ctrlWBuff[:0], true, false, op, len(payload))
+	buff = append(buff, payload...)
+	_, err := c.client.Write(buff)
+
+	return err
+}
+
+// rawReader reads the payload of the current message, unmasking it and following continuation
+// frames.
+type rawReader struct {
+	c *Conn
+}
+
+func (r *rawReader) Read(p []byte) (n int, err error) {
+	c := r.c
+	if c.readErr != nil {
+		return 0, c.readErr
+	}
+
+	for c.remaining == 0 {
+		if c.frame.Fin {
+			c.reading = false
+			return 0, io.EOF
+		}
+
+		if err = c.nextFrame(true); err != nil {
+			return 0, c.fail(err)
+		}
+	}
+
+	if uint64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+
+	n, err = c.br.Read(p)
+	c.remaining -= uint64(n)
+	c.maskPos = maskBytes(c.frame.Mask, c.maskPos, p[:n])
+	if err != nil {
+		return n, c.fail(err)
+	}
+
+	return n, nil
+}
+
+// frameFetcher feeds the decompressor with the raw message, appending the deflate tail in the end.
+type frameFetcher struct {
+	c    *Conn
+	buff []byte
+	tail bool
+}
+
+func (f *frameFetcher) Fetch() ([]byte, error) {
+	if f.tail {
+		return nil, io.EOF
+	}
+
+	n, err := f.c.raw.Read(f.buff[:len(f.buff)-len(deflateTail)])
+	if err == io.EOF {
+		f.tail = true
+		return append(f.buff[:n], deflateTail...), nil
+	}
+
+	return f.buff[:n], err
+}
+
+// inflater reads the decompressed message.
+type inflater struct {
+	c    *Conn
+	data []byte
+	err  error
+	size int64
+}
+
+func (i *inflater) Reset() {
+	*i = inflater{c: i.c}
+}
+
+func (i *inflater) Read(p []byte) (n int, err error) {
+	c := i.c
+
+	for len(i.data) == 0 {
+		if i.err != nil {
+			return 0, i.err
+		}
+
+		i.data, i.err = c.deflate.Fetch()
+		switch {
+		case i.err == nil:
+		case i.err == io.EOF, i.err == io.ErrUnexpectedEOF && c.fetcher.tail:
+			// the compressed data might've been terminated by a final block before the tail.
+			i.err = io.EOF
+			if c.reading {
+				if _, err = io.Copy(io.Discard, &c.raw); err != nil {
+					i.err = err
+				}
+			}
+		case c.readErr != nil:
+			i.err = c.readErr
+		default:
+			i.err = c.fail(errCorruptedMessage)
+		}
+
+		if i.size += int64(len(i.data)); c.maxSize > 0 && i.size > c.maxSize {
+			i.data, i.err = nil, c.fail(ErrMessageTooLarge)
+		}
+	}
+
+	n = copy(p, i.data)
+	i.data = i.data[n:]
+
+	return n, nil
+}
+
+// messageWriter fragments the message into frames of the buffer size. The beginning of the buffer
+// is reserved for the frame header.
+type messageWriter struct {
+	c        *Conn
+	buff     []byte
+	hdr      [serverHeaderLen]byte
+	op       opcode
+	rsv1     bool
+	compress bool
+	active   bool
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	if !w.active {
+		return 0, ErrWriterClosed
+	}
+
+	if w.compress {
+		return w.c.deflate.Write(p)
+	}
+
+	return w.write(p)
+}
+
+func (w *messageWriter) write(p []byte) (n int, err error) {
+	for n < len(p) {
+		if len(w.buff) == cap(w.buff) {
+			if err = w.flush(false); err != nil {
+				return n, err
+			}
+		}
+
+		copied := copy(w.buff[len(w.buff):cap(w.buff)], p[n:])
+		w.buff = w.buff[:len(w.buff)+copied]
+		n += copied
+	}
+
+	return n, nil
+}
+
+// Close finishes the message. Closing an already closed writer is a no-op.
+func (w *messageWriter) Close() error {
+	if !w.active {
+		return nil
+	}
+
+	w.active = false
+
+	if w.compress {
+		// the compressor terminates the data with a final block, so the stripped tail
+		// isn't there. Instead, an empty non-final block header must be padded with
+		// zeroes to a byte (RFC 7692, 7.2.3.3).
+		if err := w.c.deflate.Close(); err != nil {
+			return err
+		}
+
+		if _, err := w.write([]byte{0x00}); err != nil {
+			return err
+		}
+	}
+
+	return w.flush(true)
+}
+
+func (w *messageWriter) flush(fin bool) error {
+	c := w.c
+	payload := w.buff[serverHeaderLen:]
+	hdr := appendFrameHeader(w.hdr[:0], fin, w.rsv1, w.op, len(payload))
+	start := serverHeaderLen - len(hdr)
+	copy(w.buff[start:], hdr)
+
+	w.op, w.rsv1 = opContinuation, false
+	frame := w.buff[start:]
+	w.buff = w.buff[:serverHeaderLen]
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	_, err := c.client.Write(frame)
+	return err
+}
+
+// frameSink is the compressor's destination. It must not implement io.Closer, as otherwise
+// the compressor would close the message writer before the trailing byte is written.
+type frameSink struct {
+	w *messageWriter
+}
+
+func (f frameSink) Write(p []byte) (int, error) {
+	return f.w.write(p)
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+type opcode uint8
+
+const (
+	opContinuation opcode = 0x0
+	opText         opcode = 0x1
+	opBinary       opcode = 0x2
+	opClose        opcode = 0x8
+	opPing         opcode = 0x9
+	opPong         opcode = 0xA
+)
+
+func (o opcode) IsControl() bool {
+	return o&0x8 != 0
+}
+
+const (
+	finBit  = 0x80
+	rsv1Bit = 0x40
+	rsvBits = 0x70
+	maskBit = 0x80
+
+	// maxControlPayload is the largest payload a control frame may carry (RFC 6455, 5.5).
+	maxControlPayload = 125
+	// maxHeaderLen is the length of the largest possible frame header: 2 bytes of flags, opcode
+	// and length, 8 bytes of extended length and 4 bytes of mask.
+	maxHeaderLen = 14
+)
+
+type frameHeader struct {
+	Fin    bool
+	RSV1   bool
+	Opcode opcode
+	Masked bool
+	Mask   [4]byte
+	Length uint64
+}
+
+// readFrameHeader reads and validates a single frame header.
+func readFrameHeader(r io.Reader, buff []byte) (hdr frameHeader, err error) {
+	buff = buff[:2]
+	if _, err = io.ReadFull(r, buff); err != nil {
+		return hdr, err
+	}
+
+	if buff[0]&rsvBits&^rsv1Bit != 0 {
+		return hdr, ErrProtocol
+	}
+
+	hdr = frameHeader{
+		Fin:    buff[0]&finBit != 0,
+		RSV1:   buff[0]&rsv1Bit != 0,
+		Opcode: opcode(buff[0] & 0x0F),
+		Masked: buff[1]&maskBit != 0,
+		Length: uint64(buff[1] &^ maskBit),
+	}
+
+	switch hdr.Length {
+	case 126:
+		buff = buff[:2]
+		if _, err = io.ReadFull(r, buff); err != nil {
+			return hdr, err
+		}
+
+		hdr.Length = uint64(binary.BigEndian.Uint16(buff))
+	case 127:
+		buff = buff[:8]
+		if _, err = io.ReadFull(r, buff); err != nil {
+			return hdr, err
+		}
+
+		if hdr.Length = binary.BigEndian.Uint64(buff); hdr.Length>>63 != 0 {
+			return hdr, ErrProtocol
+		}
+	}
+
+	if hdr.Masked {
+		if _, err = io.ReadFull(r, hdr.Mask[:]); err != nil {
+			return hdr, err
+		}
+	}
+
+	if hdr.Opcode.IsControl() && (!hdr.Fin || hdr.Length > maxControlPayload) {
+		return hdr, ErrProtocol
+	}
+
+	return hdr, nil
+}
+
+// appendFrameHeader appends an unmasked frame header, as server frames are never masked.
+func appendFrameHeader(b []byte, fin, rsv1 bool, op opcode, length int) []byte {
+	first := byte(op)
+	if fin {
+		first |= finBit
+	}
+
+	if rsv1 {
+		first |= rsv1Bit
+	}
+
+	switch {
+	case length < 126:
+		return append(b, first, byte(length))
+	case length <= 0xFFFF:
+		return binary.BigEndian.AppendUint16(append(b, first, 126), uint16(length))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, first, 127), uint64(length))
+	}
+}
+
+// maskBytes applies the masking key to the data in place. The pos is the offset of the data
+// in the frame payload, the returned value is the offset after the data.
+func maskBytes(key [4]byte, pos int, data []byte) int {
+	for i := range data {
+		data[i] ^= key[(pos+i)&3]
+	}
+
+	return (pos + len(data)) & 3
+}
@@ -0,0 +1,286 @@
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/strutil"
+	"github.com/indigo-web/indigo/router/inbuilt"
+)
+
+const (
+	// Version is the only supported version of the protocol (RFC 6455).
+	Version = "13"
+	// magicGUID is concatenated with the client key in order to produce the accept key.
+	magicGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// keyLength is the length of the decoded Sec-WebSocket-Key value.
+	keyLength = 16
+
+	permessageDeflate = "permessage-deflate"
+)
+
+var (
+	ErrNotWebSocket       = status.NewError(status.UpgradeRequired, "websocket upgrade required")
+	ErrUnsupportedVersion = status.NewError(status.UpgradeRequired, "unsupported websocket version")
+	ErrBadKey             = status.NewError(status.BadRequest, "invalid Sec-WebSocket-Key")
+	ErrOriginNotAllowed   = status.NewError(status.Forbidden, "origin not allowed")
+)
+
+// Callback is a WebSocket endpoint handler. The connection is closed automatically after it
+// returns: normally if no error is returned, and with the 1011 Internal Error code otherwise.
+type Callback func(conn *Conn) error
+
+// Upgrader validates WebSocket handshakes and upgrades the connections.
+type Upgrader struct {
+	subprotocols   []string
+	checkOrigin    func(*http.Request) bool
+	compression    codec.Codec
+	maxMessageSize int64
+	readBuffSize   int
+	writeBuffSize  int
+}
+
+// New returns a new Upgrader with default settings: no subprotocols, no compression, any
+// origins allowed and messages limited to 16MiB.
+func New() *Upgrader {
+	return &Upgrader{
+		maxMessageSize: 16 * 1024 * 1024,
+		readBuffSize:   4096,
+		writeBuffSize:  4096,
+	}
+}
+
+// Subprotocols sets supported subprotocols. The first one offered by the client and supported
+// by the server is chosen.
+func (u *Upgrader) Subprotocols(protocols ...string) *Upgrader {
+	u.subprotocols = append(u.subprotocols, protocols...)
+	return u
+}
+
+// CheckOrigin sets the origin validator. Connections whose origins aren't allowed are rejected
+// with 403 Forbidden.
+func (u *Upgrader) CheckOrigin(check func(*http.Request) bool) *Upgrader {
+	u.checkOrigin = check
+	return u
+}
+
+// Compression enables the permessage-deflate extension (RFC 7692), if the client supports it.
+// The context takeover is disabled in both directions, therefore every message is compressed
+// independently.
+func (u *Upgrader) Compression() *Upgrader {
+	u.compression = codec.NewDeflate()
+	return u
+}
+
+// MaxMessageSize limits the size of incoming messages. Exceeding it results in the connection
+// being closed with the 1009 Message Too Big code.
+func (u *Upgrader) MaxMessageSize(size int64) *Upgrader {
+	u.maxMessageSize = size
+	return u
+}
+
+// BufferSize sets sizes of read and write buffers. Written messages are fragmented by the size
+// of the write buffer.
+func (u *Upgrader) BufferSize(read, write int) *Upgrader {
+	u.readBuffSize, u.writeBuffSize = read, write
+	return u
+}
+
+// Upgrade validates the handshake, hijacks the connection and responds with 101 Switching Protocols.
+// Returned errors are instances of status.HTTPError, suitable to be responded with.
+func (u *Upgrader) Upgrade(request *http.Request) (*Conn, error) {
+	if request.Method != method.GET {
+		return nil, status.ErrMethodNotAllowed
+	}
+
+	if !containsToken(request.Headers.Values("Upgrade"), "websocket") ||
+		!containsToken(request.Headers.Values("Connection"), "upgrade") {
+		return nil, ErrNotWebSocket
+	}
+
+	if request.Headers.Value("Sec-WebSocket-Version") != Version {
+		return nil, ErrUnsupportedVersion
+	}
+
+	key := request.Headers.Value("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != keyLength {
+		return nil, ErrBadKey
+	}
+
+	if u.checkOrigin != nil && !u.checkOrigin(request) {
+		return nil, ErrOriginNotAllowed
+	}
+
+	subprotocol := u.chooseSubprotocol(request)
+	compress := u.compression != nil && acceptsDeflate(request.Headers.Values("Sec-WebSocket-Extensions"))
+
+	client, err := request.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, 0, 256)
+	resp = append(resp, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"...)
+	resp = append(resp, "Sec-WebSocket-Accept: "...)
+	resp = append(resp, acceptKey(key)...)
+	resp = append(resp, "\r\n"...)
+
+	if len(subprotocol) > 0 {
+		resp = append(resp, "Sec-WebSocket-Protocol: "...)
+		resp = append(resp, subprotocol...)
+		resp = append(resp, "\r\n"...)
+	}
+
+	if compress {
+		resp = append(resp, "Sec-WebSocket-Extensions: "+permessageDeflate+
+			"; server_no_context_takeover; client_no_context_takeover\r\n"...)
+	}
+
+	resp = append(resp, "\r\n"...)
+	if _, err = client.Write(resp); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	var instance codec.Instance
+	if compress {
+		instance = u.compression.New()
+	}
+
+	return newConn(client, subprotocol, instance, u.maxMessageSize, u.readBuffSize, u.writeBuffSize), nil
+}
+
+// Handler returns a handler upgrading incoming requests and passing the connections to the callback.
+func (u *Upgrader) Handler(cb Callback) inbuilt.Handler {
+	return func(request *http.Request) *http.Response {
+		conn, err := u.Upgrade(request)
+		if err != nil {
+			if request.Hijacked() {
+				return nil
+			}
+
+			resp := http.Error(request, err)
+			if errors.Is(err, ErrNotWebSocket) || errors.Is(err, ErrUnsupportedVersion) {
+				resp.Header("Sec-WebSocket-Version", Version)
+			}
+
+			return resp
+		}
+
+		if err = cb(conn); err != nil {
+			var closeErr *CloseError
+			if !errors.As(err, &closeErr) {
+				closeErr = &CloseError{Code: CloseInternalError}
+			}
+
+			_ = conn.Close(closeErr.Code, closeErr.Reason)
+			return nil
+		}
+
+		_ = conn.Close(CloseNormal, "")
+		return nil
+	}
+}
+
+// Handler is a shorthand for New().Handler(cb).
+func Handler(cb Callback) inbuilt.Handler {
+	return New().Handler(cb)
+}
+
+func (u *Upgrader) chooseSubprotocol(request *http.Request) string {
+	if len(u.subprotocols) == 0 {
+		return ""
+	}
+
+	for value := range request.Headers.Values("Sec-WebSocket-Protocol") {
+		for offered := range splitList(value) {
+			if slices.Contains(u.subprotocols, offered) {
+				return offered
+			}
+		}
+	}
+
+	return ""
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value (RFC 6455, 4.2.2).
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + magicGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// acceptsDeflate tells whether any of permessage-deflate offers can be accepted. As neither
+// of directions uses the context takeover, the only offers rejected are those having unknown
+// parameters or limiting the server's window, which isn't configurable.
+func acceptsDeflate(values iter.Seq[string]) bool {
+	for value := range values {
+	offers:
+		for offer := range splitList(value) {
+			name, params := strutil.CutHeader(offer)
+			if strings.TrimSpace(name) != permessageDeflate {
+				continue
+			}
+
+			for param := range split(params, ';') {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				switch strings.TrimSpace(key) {
+				case "", "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+				case "server_max_window_bits":
+					if strutil.Unquote(strings.TrimSpace(value)) != "15" {
+						continue offers
+					}
+				default:
+					continue offers
+				}
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsToken(values iter.Seq[string], token string) bool {
+	for value := range values {
+		for elem := range splitList(value) {
+			if strutil.CmpFoldSafe(elem, token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// splitList iterates over non-empty comma-separated elements with whitespaces trimmed.
+func splitList(value string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for elem := range split(value, ',') {
+			if elem = strings.TrimSpace(elem); len(elem) > 0 && !yield(elem) {
+				return
+			}
+		}
+	}
+}
+
+func split(value string, sep byte) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for len(value) > 0 {
+			elem, rest, _ := strings.Cut(value, string(sep))
+			if !yield(elem) {
+				return
+			}
+
+			value = rest
+		}
+	}
+}
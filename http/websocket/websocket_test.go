@@ -0,0 +1,487 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	stdhttp "net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/indigo-web/indigo"
+	"github.com/indigo-web/indigo/router/inbuilt"
+	"github.com/klauspost/compress/flate"
+	"github.com/stretchr/testify/require"
+)
+
+const addr = "localhost:16200"
+
+func TestFrame(t *testing.T) {
+	t.Run("header round trip", func(t *testing.T) {
+		for _, length := range []int{0, 1, 125, 126, 0xFFFF, 0x10000, 1 << 20} {
+			for _, op := range []opcode{opText, opBinary, opContinuation} {
+				hdr := appendFrameHeader(nil, length%2 == 0, op == opText, op, length)
+				parsed, err := readFrameHeader(bytes.NewReader(hdr), make([]byte, maxHeaderLen))
+				require.NoError(t, err)
+				require.Equal(t, frameHeader{
+					Fin:    length%2 == 0,
+					RSV1:   op == opText,
+					Opcode: op,
+					Length: uint64(length),
+				}, parsed)
+			}
+		}
+	})
+
+	t.Run("masking", func(t *testing.T) {
+		key := [4]byte{1, 2, 3, 4}
+		data := []byte("Hello, world! Lorem ipsum")
+		masked := slices.Clone(data)
+		pos := maskBytes(key, 0, masked[:5])
+		pos = maskBytes(key, pos, masked[5:])
+		require.NotEqual(t, data, masked)
+		maskBytes(key, 0, masked)
+		require.Equal(t, data, masked)
+	})
+
+	t.Run("reserved bits", func(t *testing.T) {
+		_, err := readFrameHeader(bytes.NewReader([]byte{0x81 | 0x20, 0}), make([]byte, maxHeaderLen))
+		require.ErrorIs(t, err, ErrProtocol)
+	})
+
+	t.Run("fragmented control frame", func(t *testing.T) {
+		_, err := readFrameHeader(bytes.NewReader([]byte{byte(opPing), 0}), make([]byte, maxHeaderLen))
+		require.ErrorIs(t, err, ErrProtocol)
+	})
+
+	t.Run("too large control frame", func(t *testing.T) {
+		hdr := appendFrameHeader(nil, true, false, opPing, 126)
+		_, err := readFrameHeader(bytes.NewReader(hdr), make([]byte, maxHeaderLen))
+		require.ErrorIs(t, err, ErrProtocol)
+	})
+}
+
+func TestHandshake(t *testing.T) {
+	t.Run("accept key", func(t *testing.T) {
+		// the sample from RFC 6455, 1.3
+		require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+	})
+
+	t.Run("permessage-deflate offers", func(t *testing.T) {
+		for _, tc := range []struct {
+			Offer  string
+			Accept bool
+		}{
+			{"permessage-deflate", true},
+			{"permessage-deflate; client_max_window_bits", true},
+			{"permessage-deflate; server_no_context_takeover; client_no_context_takeover", true},
+			{"permessage-deflate; server_max_window_bits=10", false},
+			{"permessage-deflate; server_max_window_bits=10, permessage-deflate", true},
+			{"permessage-deflate; server_max_window_bits=\"15\"", true},
+			{"permessage-deflate; unknown", false},
+			{"x-webkit-deflate-frame", false},
+			{"", false},
+		} {
+			require.Equal(t, tc.Accept, acceptsDeflate(slices.Values([]string{tc.Offer})), tc.Offer)
+		}
+	})
+}
+
+func getRouter() *inbuilt.Router {
+	r := inbuilt.New()
+
+	echo := func(conn *Conn) error {
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return err
+			}
+
+			if err = conn.WriteMessage(typ, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.Get("/echo", Handler(echo))
+	r.Get("/compressed", New().Compression().MaxMessageSize(1024).Handler(echo))
+	r.Get("/limited", New().MaxMessageSize(16).Handler(echo))
+	r.Get("/chat", New().Subprotocols("chat.v2", "chat").Handler(func(conn *Conn) error {
+		return conn.WriteMessage(TextMessage, []byte(conn.Subprotocol()))
+	}))
+	r.Get("/fail", Handler(func(conn *Conn) error {
+		return errors.New("something went wrong")
+	}))
+	r.Get("/stream", New().BufferSize(4096, 16).Handler(func(conn *Conn) error {
+		w, err := conn.NextWriter(BinaryMessage)
+		if err != nil {
+			return err
+		}
+
+		for i := range 4 {
+			if _, err = w.Write(bytes.Repeat([]byte{byte('a' + i)}, 10)); err != nil {
+				return err
+			}
+		}
+
+		return w.Close()
+	}))
+
+	return r
+}
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+type testFrame struct {
+	Fin     bool
+	RSV1    bool
+	Opcode  opcode
+	Payload []byte
+}
+
+func dial(t *testing.T, path string, headers ...string) (*testClient, *stdhttp.Response) {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	request := "GET " + path + " HTTP/1.1\r\nHost: " + addr + "\r\n" + strings.Join(headers, "\r\n")
+	if len(headers) > 0 {
+		request += "\r\n"
+	}
+
+	_, err = conn.Write([]byte(request + "\r\n"))
+	require.NoError(t, err)
+
+	client := &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+	resp, err := stdhttp.ReadResponse(client.r, nil)
+	require.NoError(t, err)
+
+	return client, resp
+}
+
+func upgrade(t *testing.T, path string, headers ...string) (*testClient, *stdhttp.Response) {
+	headers = append([]string{
+		"Upgrade: websocket",
+		"Connection: keep-alive, Upgrade",
+		"Sec-WebSocket-Version: 13",
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==",
+	}, headers...)
+
+	client, resp := dial(t, path, headers...)
+	require.Equal(t, stdhttp.StatusSwitchingProtocols, resp.StatusCode)
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	require.Equal(t, "websocket", resp.Header.Get("Upgrade"))
+
+	return client, resp
+}
+
+func (c *testClient) Write(fin, rsv1 bool, op opcode, payload []byte) {
+	frame := appendFrameHeader(nil, fin, rsv1, op, len(payload))
+	frame[1] |= maskBit
+
+	var key [4]byte
+	_, _ = rand.Read(key[:])
+	frame = append(frame, key[:]...)
+
+	masked := slices.Clone(payload)
+	maskBytes(key, 0, masked)
+
+	_, err := c.conn.Write(append(frame, masked...))
+	require.NoError(c.t, err)
+}
+
+func (c *testClient) Read() testFrame {
+	hdr, err := readFrameHeader(c.r, make([]byte, maxHeaderLen))
+	require.NoError(c.t, err)
+	require.False(c.t, hdr.Masked, "server frames must not be masked")
+
+	payload := make([]byte, hdr.Length)
+	_, err = io.ReadFull(c.r, payload)
+	require.NoError(c.t, err)
+
+	return testFrame{Fin: hdr.Fin, RSV1: hdr.RSV1, Opcode: hdr.Opcode, Payload: payload}
+}
+
+func (c *testClient) ExpectClose(code CloseCode) {
+	frame := c.Read()
+	require.Equal(c.t, opClose, frame.Opcode)
+	require.GreaterOrEqual(c.t, len(frame.Payload), 2)
+	require.Equal(c.t, code, CloseCode(binary.BigEndian.Uint16(frame.Payload)))
+	_, err := c.r.ReadByte()
+	require.ErrorIs(c.t, err, io.EOF)
+}
+
+func (c *testClient) Close() {
+	_ = c.conn.Close()
+}
+
+func TestWebSocket(t *testing.T) {
+	app := indigo.New(addr)
+	started := make(chan struct{})
+	go func() {
+		require.NoError(t, app.
+			OnStart(func() {
+				close(started)
+			}).
+			Serve(getRouter()),
+		)
+	}()
+	defer app.Stop()
+	<-started
+	waitForAvailability(t)
+
+	t.Run("echo", func(t *testing.T) {
+		client, _ := upgrade(t, "/echo")
+		defer client.Close()
+
+		client.Write(true, false, opText, []byte("Hello, world!"))
+		require.Equal(t, testFrame{Fin: true, Opcode: opText, Payload: []byte("Hello, world!")}, client.Read())
+
+		large := bytes.Repeat([]byte("0123456789abcdef"), 8192)
+		client.Write(true, false, opBinary, large)
+		var received []byte
+		for {
+			frame := client.Read()
+			received = append(received, frame.Payload...)
+			if frame.Fin {
+				break
+			}
+		}
+		require.Equal(t, large, received)
+	})
+
+	t.Run("fragmented with ping", func(t *testing.T) {
+		client, _ := upgrade(t, "/echo")
+		defer client.Close()
+
+		client.Write(false, false, opText, []byte("Hello, "))
+		client.Write(true, false, opPing, []byte("are you there?"))
+		client.Write(false, false, opContinuation, []byte("world"))
+		client.Write(true, false, opContinuation, []byte("!"))
+
+		require.Equal(t, testFrame{Fin: true, Opcode: opPong, Payload: []byte("are you there?")}, client.Read())
+		require.Equal(t, testFrame{Fin: true, Opcode: opText, Payload: []byte("Hello, world!")}, client.Read())
+	})
+
+	t.Run("close handshake", func(t *testing.T) {
+		client, _ := upgrade(t, "/echo")
+		defer client.Close()
+
+		client.Write(true, false, opClose, appendClosePayload(nil, CloseGoingAway, "bye"))
+		client.ExpectClose(CloseGoingAway)
+	})
+
+	t.Run("close without status", func(t *testing.T) {
+		client, _ := upgrade(t, "/echo")
+		defer client.Close()
+
+		client.Write(true, false, opClose, nil)
+		frame := client.Read()
+		require.Equal(t, opClose, frame.Opcode)
+		require.Empty(t, frame.Payload)
+	})
+
+	t.Run("unmasked frame", func(t *testing.T) {
+		client, _ := upgrade(t, "/echo")
+		defer client.Close()
+
+		_, err := client.conn.Write(append(appendFrameHeader(nil, true, false, opText, 2), "hi"...))
+		require.NoError(t, err)
+		client.ExpectClose(CloseProtocolError)
+	})
+
+	t.Run("unexpected continuation", func(t *testing.T) {
+		client, _ := upgrade(t, "/echo")
+		defer client.Close()
+
+		client.Write(true, false, opContinuation, []byte("hi"))
+		client.ExpectClose(CloseProtocolError)
+	})
+
+	t.Run("compression not negotiated", func(t *testing.T) {
+		client, _ := upgrade(t, "/echo")
+		defer client.Close()
+
+		client.Write(true, true, opText, []byte("hi"))
+		client.ExpectClose(CloseProtocolError)
+	})
+
+	t.Run("invalid utf-8", func(t *testing.T) {
+		client, _ := upgrade(t, "/echo")
+		defer client.Close()
+
+		client.Write(true, false, opText, []byte{0xff, 0xfe})
+		client.ExpectClose(CloseInvalidPayload)
+	})
+
+	t.Run("message too big", func(t *testing.T) {
+		client, _ := upgrade(t, "/limited")
+		defer client.Close()
+
+		client.Write(false, false, opBinary, []byte("0123456789"))
+		client.Write(true, false, opContinuation, []byte("0123456789"))
+		client.ExpectClose(CloseMessageTooBig)
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		client, _ := upgrade(t, "/fail")
+		defer client.Close()
+
+		client.ExpectClose(CloseInternalError)
+	})
+
+	t.Run("fragmented write", func(t *testing.T) {
+		client, _ := upgrade(t, "/stream")
+		defer client.Close()
+
+		var frames []testFrame
+		for {
+			frame := client.Read()
+			frames = append(frames, frame)
+			if frame.Fin {
+				break
+			}
+		}
+
+		require.Equal(t, []testFrame{
+			{Opcode: opBinary, Payload: []byte("aaaaaaaaaabbbbbb")},
+			{Opcode: opContinuation, Payload: []byte("bbbbccccccccccdd")},
+			{Fin: true, Opcode: opContinuation, Payload: []byte("dddddddd")},
+		}, frames)
+		client.ExpectClose(CloseNormal)
+	})
+
+	t.Run("subprotocol", func(t *testing.T) {
+		client, resp := upgrade(t, "/chat", "Sec-WebSocket-Protocol: chat, chat.v2")
+		defer client.Close()
+
+		require.Equal(t, "chat", resp.Header.Get("Sec-WebSocket-Protocol"))
+		require.Equal(t, testFrame{Fin: true, Opcode: opText, Payload: []byte("chat")}, client.Read())
+	})
+
+	t.Run("no subprotocol", func(t *testing.T) {
+		client, resp := upgrade(t, "/chat", "Sec-WebSocket-Protocol: superchat")
+		defer client.Close()
+
+		require.Empty(t, resp.Header.Values("Sec-WebSocket-Protocol"))
+		require.Equal(t, testFrame{Fin: true, Opcode: opText, Payload: []byte{}}, client.Read())
+	})
+
+	t.Run("compression", func(t *testing.T) {
+		client, resp := upgrade(t, "/compressed", "Sec-WebSocket-Extensions: permessage-deflate; client_max_window_bits")
+		defer client.Close()
+
+		require.Equal(t,
+			"permessage-deflate; server_no_context_takeover; client_no_context_takeover",
+			resp.Header.Get("Sec-WebSocket-Extensions"),
+		)
+
+		for _, message := range []string{"Hello, world!", strings.Repeat("ab", 300), ""} {
+			compressed := deflate(t, []byte(message))
+			half := len(compressed) / 2
+			client.Write(false, true, opText, compressed[:half])
+			client.Write(true, false, opContinuation, compressed[half:])
+
+			frame := client.Read()
+			require.True(t, frame.Fin)
+			require.True(t, frame.RSV1)
+			require.Equal(t, opText, frame.Opcode)
+			require.Equal(t, message, inflate(t, frame.Payload))
+		}
+
+		// the uncompressed message is also acceptable
+		client.Write(true, false, opText, []byte("plain"))
+		require.Equal(t, "plain", inflate(t, client.Read().Payload))
+
+		// the decompressed size must be limited, too
+		client.Write(true, true, opBinary, deflate(t, bytes.Repeat([]byte{'a'}, 2048)))
+		client.ExpectClose(CloseMessageTooBig)
+	})
+
+	t.Run("compression declined", func(t *testing.T) {
+		client, resp := upgrade(t, "/echo", "Sec-WebSocket-Extensions: permessage-deflate")
+		defer client.Close()
+
+		require.Empty(t, resp.Header.Values("Sec-WebSocket-Extensions"))
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		client, resp := dial(t, "/echo",
+			"Upgrade: websocket",
+			"Connection: Upgrade",
+			"Sec-WebSocket-Version: 8",
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==",
+		)
+		defer client.Close()
+
+		require.Equal(t, stdhttp.StatusUpgradeRequired, resp.StatusCode)
+		require.Equal(t, "13", resp.Header.Get("Sec-WebSocket-Version"))
+	})
+
+	t.Run("not websocket", func(t *testing.T) {
+		client, resp := dial(t, "/echo")
+		defer client.Close()
+
+		require.Equal(t, stdhttp.StatusUpgradeRequired, resp.StatusCode)
+	})
+
+	t.Run("bad key", func(t *testing.T) {
+		client, resp := dial(t, "/echo",
+			"Upgrade: websocket",
+			"Connection: Upgrade",
+			"Sec-WebSocket-Version: 13",
+			"Sec-WebSocket-Key: c2hvcnQ=",
+		)
+		defer client.Close()
+
+		require.Equal(t, stdhttp.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func waitForAvailability(t *testing.T) {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = conn.Close()
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start listening on %s in time: %v", addr, err)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	buff := new(bytes.Buffer)
+	w, err := flate.NewWriter(buff, flate.BestSpeed)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	return bytes.TrimSuffix(buff.Bytes(), deflateTail)
+}
+
+func inflate(t *testing.T, data []byte) string {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail)))
+	result, err := io.ReadAll(r)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		require.NoError(t, err)
+	}
+
+	return string(result)
+}
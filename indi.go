@@ -1,10 +1,12 @@
 package indigo
 
 import (
+	"context"
 	"crypto/tls"
 
 	"github.com/indigo-web/indigo/config"
 	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/internal/codecutil"
 	"github.com/indigo-web/indigo/internal/strutil"
 	"github.com/indigo-web/indigo/router"
 	"github.com/indigo-web/indigo/router/inbuilt"
@@ -18,11 +20,14 @@ const Version = "0.17.3"
 type App struct {
 	cfg   *config.Config
 	hooks struct {
-		OnStart func()
-		OnBind  func(addr string)
-		OnStop  func()
+		OnStart    func()
+		OnBind     func(addr string)
+		OnStop     func()
+		OnShutdown func(phase ShutdownPhase, connections int)
 	}
 	codecs     []codec.Codec
+	policy     *codec.Policy
+	metrics    *codec.Metrics
 	transports []Transport
 	supervisor transport.Supervisor
 }
@@ -31,6 +36,7 @@ type App struct {
 func New(addr string) *App {
 	return (&App{
 		cfg:        config.Default(),
+		policy:     codec.NewPolicy(),
 		supervisor: transport.NewSupervisor(),
 	}).Listen(addr, TCP())
 }
@@ -62,12 +68,45 @@ func (a *App) OnStop(cb func()) *App {
 	return a
 }
 
+// ShutdownPhase describes the progress of the graceful shutdown.
+type ShutdownPhase = transport.ShutdownPhase
+
+const (
+	// ShutdownDraining means the server doesn't accept new connections anymore and idle ones
+	// are closed. The remaining connections are closed after serving their current requests.
+	ShutdownDraining = transport.ShutdownDraining
+	// ShutdownForcing means the deadline was exceeded and the remaining connections are
+	// closed forcibly.
+	ShutdownForcing = transport.ShutdownForcing
+)
+
+// OnShutdown calls the callback as the graceful shutdown progresses, passing the number of
+// connections still open at the moment. The OnStop is called afterward as usual.
+func (a *App) OnShutdown(cb func(phase ShutdownPhase, connections int)) *App {
+	a.hooks.OnShutdown = cb
+	return a
+}
+
 // Codec appends a new codec into the list of supported.
 func (a *App) Codec(codecs ...codec.Codec) *App {
 	a.codecs = append(a.codecs, codecs...)
 	return a
 }
 
+// CompressionPolicy replaces the policy deciding which responses are compressed automatically.
+// By default, codec.Incompressible content types are skipped. Nil policy compresses everything.
+func (a *App) CompressionPolicy(policy *codec.Policy) *App {
+	a.policy = policy
+	return a
+}
+
+// DecompressionMetrics sets the metrics counting request bodies rejected due to the
+// decompression limits, which are set in config.Body.Decompression.
+func (a *App) DecompressionMetrics(metrics *codec.Metrics) *App {
+	a.metrics = metrics
+	return a
+}
+
 func (a *App) Listen(addr string, ts ...Transport) *App {
 	if len(addr) == 0 {
 		// empty addr is considered a no-op Bind operation. Main use-case is omitting
@@ -113,8 +152,12 @@ func (a *App) run(r router.Router) error {
 		a.hooks.OnStart()
 	}
 
+	codecs := codecutil.NewCache(a.codecs, codecutil.AcceptEncoding(a.codecs)).
+		WithPolicy(a.policy).
+		WithMetrics(a.metrics)
+
 	for _, t := range a.transports {
-		if err := a.supervisor.Add(t.addr, t.inner, t.spawnCallback(a.cfg, r, a.codecs)); err != nil {
+		if err := a.supervisor.Add(t.addr, t.inner, t.spawnCallback(a.supervisor.Context(), a.cfg, r, codecs)); err != nil {
 			return err
 		}
 
@@ -135,3 +178,11 @@ func (a *App) run(r router.Router) error {
 func (a *App) Stop() {
 	a.supervisor.Stop()
 }
+
+// Shutdown gracefully stops the application. New connections aren't accepted anymore, idle
+// ones are closed immediately and the rest are closed after their current requests are served.
+// When the ctx is done, the remaining connections are closed forcibly, request contexts are
+// cancelled and the ctx error is returned. Serve returns as soon as all the connections are done.
+func (a *App) Shutdown(ctx context.Context) error {
+	return a.supervisor.Shutdown(ctx, a.hooks.OnShutdown)
+}
@@ -1,6 +1,7 @@
 package indigo
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
@@ -12,12 +13,15 @@ import (
 	stdhttp "net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
 	"slices"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/indigo-web/indigo/config"
 	"github.com/indigo-web/indigo/http"
 	"github.com/indigo-web/indigo/http/codec"
@@ -34,6 +38,8 @@ import (
 	"github.com/klauspost/compress/gzip"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
 )
 
 const (
@@ -59,6 +65,12 @@ func respond(request *http.Request) *http.Response {
 	return request.Respond().String(str)
 }
 
+// sseReceived is closed by the client once the first compressed event is received.
+var sseReceived = make(chan struct{})
+
+// ctxCancelled receives the request context error after the client has disconnected.
+var ctxCancelled = make(chan error, 1)
+
 func getInbuiltRouter() *inbuilt.Router {
 	ctx := context.WithValue(context.Background(), "easter", "egg")
 
@@ -98,6 +110,38 @@ func getInbuiltRouter() *inbuilt.Router {
 		return http.Bytes(request, buff)
 	})
 
+	r.Get("/sse", func(request *http.Request) *http.Response {
+		return http.SSE(request, func(events http.EventWriter) error {
+			last, _ := strconv.Atoi(events.LastEventID())
+
+			for i := last + 1; i <= last+3; i++ {
+				err := events.Send(http.Event{ID: strconv.Itoa(i), Data: "event " + strconv.Itoa(i)})
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	r.Get("/sse-compressed", func(request *http.Request) *http.Response {
+		return http.SSE(request, func(events http.EventWriter) error {
+			if err := events.Data("first"); err != nil {
+				return err
+			}
+
+			// the second event is sent only after the client has received the first one, which
+			// wouldn't happen if the compressor held it back.
+			select {
+			case <-sseReceived:
+			case <-time.After(5 * time.Second):
+			}
+
+			return events.Data("second")
+		}).Compression("gzip")
+	})
+
 	r.Get("/hijack", func(request *http.Request) *http.Response {
 		client, err := request.Hijack()
 		if err != nil {
@@ -108,6 +152,17 @@ func getInbuiltRouter() *inbuilt.Router {
 		return nil
 	})
 
+	r.Get("/ctx-cancel", func(request *http.Request) *http.Response {
+		select {
+		case <-request.Ctx.Done():
+			ctxCancelled <- request.Ctx.Err()
+		case <-time.After(5 * time.Second):
+			ctxCancelled <- nil
+		}
+
+		return nil
+	})
+
 	r.Get("/ctx-value", func(request *http.Request) *http.Response {
 		return http.String(request, request.Ctx.Value("easter").(string))
 	})
@@ -331,6 +386,30 @@ func TestFirstPhase(t *testing.T) {
 		require.Equal(t, "Hello, world!", body)
 	})
 
+	t.Run("server-sent events", func(t *testing.T) {
+		request, err := stdhttp.NewRequest(stdhttp.MethodGet, appURL+"/sse", nil)
+		require.NoError(t, err)
+		request.Header.Set("Last-Event-ID", "5")
+
+		resp, err := stdhttp.DefaultClient.Do(request)
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		require.Equal(t, stdhttp.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		require.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+		require.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t,
+			":\nid: 6\ndata: event 6\n\nid: 7\ndata: event 7\n\nid: 8\ndata: event 8\n\n",
+			string(body),
+		)
+	})
+
 	t.Run("hijacking", func(t *testing.T) {
 		conn, err := sendSimpleRequest(addr, "/hijack")
 		require.NoError(t, err)
@@ -343,6 +422,13 @@ func TestFirstPhase(t *testing.T) {
 		require.Equal(t, "j", string(data))
 	})
 
+	t.Run("ctx cancelled on disconnect", func(t *testing.T) {
+		conn, err := sendSimpleRequest(addr, "/ctx-cancel")
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+		require.ErrorIs(t, <-ctxCancelled, context.Canceled)
+	})
+
 	t.Run("request existing file", func(t *testing.T) {
 		actualContent, err := os.ReadFile("./tests/index.html")
 		require.NoError(t, err)
@@ -402,6 +488,38 @@ func TestFirstPhase(t *testing.T) {
 		testStatic(t, "pics.vfs", mime.Unset)
 	})
 
+	t.Run("request static range", func(t *testing.T) {
+		actualContent, err := os.ReadFile("./tests/index.html")
+		require.NoError(t, err)
+
+		request, err := stdhttp.NewRequest(stdhttp.MethodGet, appURL+"/static/index.html", nil)
+		require.NoError(t, err)
+		request.Header.Set("Range", "bytes=5-14")
+		resp, err := stdhttp.DefaultClient.Do(request)
+		require.NoError(t, err)
+		require.Equal(t, stdhttp.StatusPartialContent, resp.StatusCode)
+		require.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+		require.Equal(t, fmt.Sprintf("bytes 5-14/%d", len(actualContent)), resp.Header.Get("Content-Range"))
+		require.Equal(t, string(actualContent[5:15]), readFullBody(t, resp))
+	})
+
+	t.Run("request static conditional", func(t *testing.T) {
+		resp, err := stdhttp.Get(appURL + "/static/index.html")
+		require.NoError(t, err)
+		_ = readFullBody(t, resp)
+		etag := resp.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+		require.NotEmpty(t, resp.Header.Get("Last-Modified"))
+
+		request, err := stdhttp.NewRequest(stdhttp.MethodGet, appURL+"/static/index.html", nil)
+		require.NoError(t, err)
+		request.Header.Set("If-None-Match", etag)
+		resp, err = stdhttp.DefaultClient.Do(request)
+		require.NoError(t, err)
+		require.Equal(t, stdhttp.StatusNotModified, resp.StatusCode)
+		require.Empty(t, readFullBody(t, resp))
+	})
+
 	t.Run("TRACE", func(t *testing.T) {
 		request := &stdhttp.Request{
 			Method: stdhttp.MethodTrace,
@@ -496,6 +614,93 @@ func TestFirstPhase(t *testing.T) {
 		testCtxValue(t, "http://"+altAddr)
 	})
 
+	testHTTP2 := func(t *testing.T, client *stdhttp.Client, url string) {
+		resp, err := client.Get(url + "/ctx-value")
+		require.NoError(t, err)
+		require.Equal(t, 2, resp.ProtoMajor)
+		require.Equal(t, stdhttp.StatusOK, resp.StatusCode)
+		require.Equal(t, "egg", readFullBody(t, resp))
+
+		// the body exceeds default flow-control windows
+		body := strings.Repeat("abcdefgh", 256*1024)
+		resp, err = client.Post(url+"/body-reader", "text/plain", strings.NewReader(body))
+		require.NoError(t, err)
+		require.Equal(t, stdhttp.StatusOK, resp.StatusCode)
+		require.Equal(t, body, readFullBody(t, resp))
+	}
+
+	t.Run("HTTP/2 prior knowledge", func(t *testing.T) {
+		client := &stdhttp.Client{
+			Transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					return new(net.Dialer).DialContext(ctx, network, addr)
+				},
+			},
+		}
+		testHTTP2(t, client, appURL)
+	})
+
+	t.Run("HTTP/2 over TLS", func(t *testing.T) {
+		client := &stdhttp.Client{
+			Transport: &http2.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+		testHTTP2(t, client, "https://"+httpsAddr)
+	})
+
+	t.Run("h2c upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// HTTP2-Settings contains SETTINGS_MAX_CONCURRENT_STREAMS=100
+		_, err = conn.Write([]byte(
+			"GET /ctx-value HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade, HTTP2-Settings\r\n" +
+				"Upgrade: h2c\r\nHTTP2-Settings: AAMAAABk\r\n\r\n",
+		))
+		require.NoError(t, err)
+
+		reader := bufio.NewReader(conn)
+		resp, err := stdhttp.ReadResponse(reader, nil)
+		require.NoError(t, err)
+		require.Equal(t, stdhttp.StatusSwitchingProtocols, resp.StatusCode)
+		require.Equal(t, "h2c", resp.Header.Get("Upgrade"))
+
+		_, err = conn.Write([]byte(http2.ClientPreface))
+		require.NoError(t, err)
+		framer := http2.NewFramer(conn, reader)
+		framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+		require.NoError(t, framer.WriteSettings())
+
+		var (
+			code string
+			body []byte
+		)
+
+		for {
+			frame, err := framer.ReadFrame()
+			require.NoError(t, err)
+
+			switch f := frame.(type) {
+			case *http2.MetaHeadersFrame:
+				require.Equal(t, uint32(1), f.StreamID)
+				code = f.PseudoValue("status")
+			case *http2.DataFrame:
+				require.Equal(t, uint32(1), f.StreamID)
+				body = append(body, f.Data()...)
+			}
+
+			if frame.Header().Flags.Has(http2.FlagDataEndStream) && frame.Header().StreamID == 1 {
+				break
+			}
+		}
+
+		require.Equal(t, "200", code)
+		require.Equal(t, "egg", string(body))
+	})
+
 	requireField := func(t *testing.T, m map[string]any, key, value string) {
 		actual, found := m[key]
 		require.Truef(t, found, "json doesn't contain the key %s", key)
@@ -689,13 +894,16 @@ func TestSecondPhase(t *testing.T) {
 
 	ch := make(chan struct{})
 	app := New(addr)
+	metrics := new(codec.Metrics)
 	go func(app *App) {
 		r := getInbuiltRouter()
 		s := config.Default()
 		s.NET.ReadTimeout = 1 * time.Second
+		s.Body.Decompression.MaxSize = 64 * 1024
 		_ = app.
 			Tune(s).
 			Codec(codec.Suit()...).
+			DecompressionMetrics(metrics).
 			OnStart(func() {
 				ch <- struct{}{}
 			}).
@@ -734,10 +942,36 @@ func TestSecondPhase(t *testing.T) {
 		resp, err := stdhttp.DefaultClient.Head(appURL + "/")
 		require.NoError(t, err)
 		require.Equal(t, stdhttp.StatusOK, resp.StatusCode)
-		require.Equal(t, []string{"gzip, deflate, zstd"}, resp.Header["Accept-Encoding"])
+		require.Equal(t, []string{"gzip, deflate, zstd, br"}, resp.Header["Accept-Encoding"])
 		require.Empty(t, readFullBody(t, resp))
 	})
 
+	t.Run("compressed server-sent events", func(t *testing.T) {
+		start := time.Now()
+		resp, err := stdhttp.DefaultClient.Get(appURL + "/sse-compressed")
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		require.True(t, resp.Uncompressed, "the response must have been gzip-compressed")
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "data: first\n" {
+				break
+			}
+		}
+
+		require.Less(t, time.Since(start), 3*time.Second, "the event was held back")
+		close(sseReceived)
+
+		rest, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, "\ndata: second\n\n", string(rest))
+	})
+
 	t.Run("gzip compressed request", func(t *testing.T) {
 		const data = "Hello, world!"
 		buff := bytes.NewBuffer(nil)
@@ -768,6 +1002,39 @@ func TestSecondPhase(t *testing.T) {
 		require.Equal(t, data, readFullBody(t, resp))
 	})
 
+	t.Run("brotli compressed request", func(t *testing.T) {
+		const data = "Hello, world!"
+		buff := bytes.NewBuffer(nil)
+		c := brotli.NewWriter(buff)
+		_, err := c.Write([]byte(data))
+		require.NoError(t, err)
+		require.NoError(t, c.Close())
+
+		request, err := stdhttp.NewRequest(stdhttp.MethodPost, appURL+"/body-reader", buff)
+		require.NoError(t, err)
+		request.Header.Set("Content-Encoding", "br")
+		resp, err := stdhttp.DefaultClient.Do(request)
+		require.NoError(t, err)
+		require.Equal(t, data, readFullBody(t, resp))
+	})
+
+	t.Run("decompression bomb", func(t *testing.T) {
+		buff := bytes.NewBuffer(nil)
+		c := gzip.NewWriter(buff)
+		_, err := c.Write(make([]byte, 1024*1024))
+		require.NoError(t, err)
+		require.NoError(t, c.Close())
+
+		request, err := stdhttp.NewRequest(stdhttp.MethodPost, appURL+"/body-reader", buff)
+		require.NoError(t, err)
+		request.Header.Set("Content-Encoding", "gzip")
+		resp, err := stdhttp.DefaultClient.Do(request)
+		require.NoError(t, err)
+		require.Equal(t, stdhttp.StatusRequestEntityTooLarge, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, uint64(1), metrics.Size.Load())
+	})
+
 	t.Run("idle disconnect", func(t *testing.T) {
 		conn, err := net.Dial("tcp4", addr)
 		require.NoError(t, err)
@@ -832,6 +1099,322 @@ func TestSecondPhase(t *testing.T) {
 	})
 }
 
+func TestShutdown(t *testing.T) {
+	type progress struct {
+		phase       ShutdownPhase
+		connections int
+	}
+
+	run := func(t *testing.T, handler inbuilt.Handler) (*App, <-chan progress, <-chan error) {
+		app := New(addr)
+		progressCh := make(chan progress, 2)
+		started, stopped := make(chan struct{}), make(chan error, 1)
+		go func() {
+			stopped <- app.
+				OnStart(func() {
+					close(started)
+				}).
+				OnShutdown(func(phase ShutdownPhase, connections int) {
+					progressCh <- progress{phase, connections}
+				}).
+				Serve(inbuilt.New().Get("/", handler))
+		}()
+
+		<-started
+		waitForAvailability(t, addr)
+
+		return app, progressCh, stopped
+	}
+
+	readResponse := func(t *testing.T, conn net.Conn) *stdhttp.Response {
+		resp, err := stdhttp.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		_, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp
+	}
+
+	requireClosed := func(t *testing.T, conn net.Conn) {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		_, err := conn.Read(make([]byte, 1))
+		require.ErrorIs(t, err, io.EOF)
+	}
+
+	t.Run("graceful", func(t *testing.T) {
+		release := make(chan struct{})
+		app, progressCh, stopped := run(t, func(request *http.Request) *http.Response {
+			if request.Params.Has("slow") {
+				<-release
+			}
+
+			return http.String(request, "done")
+		})
+
+		idle, err := sendSimpleRequest(addr, "/")
+		require.NoError(t, err)
+		defer func() {
+			_ = idle.Close()
+		}()
+		require.Equal(t, stdhttp.StatusOK, readResponse(t, idle).StatusCode)
+
+		h2, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer func() {
+			_ = h2.Close()
+		}()
+		_, err = io.WriteString(h2, http2.ClientPreface)
+		require.NoError(t, err)
+		framer := http2.NewFramer(h2, h2)
+		require.NoError(t, framer.WriteSettings())
+		_, err = framer.ReadFrame()
+		require.NoError(t, err)
+
+		busy, err := sendSimpleRequest(addr, "/?slow")
+		require.NoError(t, err)
+		defer func() {
+			_ = busy.Close()
+		}()
+
+		// connections which haven't sent anything yet are idle, too.
+		fresh, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer func() {
+			_ = fresh.Close()
+		}()
+		// let the request reach the handler
+		time.Sleep(100 * time.Millisecond)
+
+		shutdown := make(chan error, 1)
+		go func() {
+			shutdown <- app.Shutdown(context.Background())
+		}()
+
+		require.Equal(t, progress{ShutdownDraining, 1}, <-progressCh)
+		requireClosed(t, idle)
+		requireClosed(t, fresh)
+
+		for {
+			frame, err := framer.ReadFrame()
+			require.NoError(t, err)
+			if goAway, ok := frame.(*http2.GoAwayFrame); ok {
+				require.Equal(t, http2.ErrCodeNo, goAway.ErrCode)
+				break
+			}
+		}
+		requireClosed(t, h2)
+
+		close(release)
+		resp := readResponse(t, busy)
+		require.True(t, resp.Close)
+		requireClosed(t, busy)
+
+		require.NoError(t, <-shutdown)
+		require.NoError(t, <-stopped)
+
+		_, err = net.Dial("tcp", addr)
+		require.Error(t, err)
+	})
+
+	t.Run("forced", func(t *testing.T) {
+		cancelled := make(chan error, 1)
+		app, progressCh, stopped := run(t, func(request *http.Request) *http.Response {
+			<-request.Ctx.Done()
+			cancelled <- request.Ctx.Err()
+			return nil
+		})
+
+		conn, err := sendSimpleRequest(addr, "/")
+		require.NoError(t, err)
+		defer func() {
+			_ = conn.Close()
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, app.Shutdown(ctx), context.DeadlineExceeded)
+		require.Equal(t, progress{ShutdownDraining, 1}, <-progressCh)
+		require.Equal(t, progress{ShutdownForcing, 1}, <-progressCh)
+		require.ErrorIs(t, <-cancelled, context.Canceled)
+		requireClosed(t, conn)
+		require.NoError(t, <-stopped)
+	})
+}
+
+func TestUnix(t *testing.T) {
+	run := func(t *testing.T, path string) *App {
+		app := New("").Listen(path, Unix(0o600))
+		started, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			r := inbuilt.New().
+				Get("/", func(request *http.Request) *http.Response {
+					cred := request.Env.Cred
+					if cred == nil {
+						return http.String(request, request.Remote.String())
+					}
+
+					return http.String(request, fmt.Sprintf(
+						"%s %d %d", request.Remote.String(), cred.UID, cred.PID,
+					))
+				})
+
+			_ = app.
+				OnStart(func() {
+					close(started)
+				}).
+				Serve(r)
+			close(stopped)
+		}()
+
+		<-started
+		t.Cleanup(func() {
+			require.NoError(t, app.Shutdown(context.Background()))
+			<-stopped
+		})
+
+		return app
+	}
+
+	get := func(t *testing.T, path string) string {
+		client := &stdhttp.Client{
+			Transport: &stdhttp.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return new(net.Dialer).DialContext(ctx, "unix", path)
+				},
+			},
+		}
+
+		var (
+			resp *stdhttp.Response
+			err  error
+		)
+		for range 40 {
+			if resp, err = client.Get("http://unix/"); err == nil {
+				break
+			}
+
+			time.Sleep(50 * time.Millisecond)
+		}
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		return readFullBody(t, resp)
+	}
+
+	t.Run("filesystem", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "indigo.sock")
+		// leave a stale socket file, as if the previous run has crashed.
+		l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+		require.NoError(t, err)
+		l.SetUnlinkOnClose(false)
+		require.NoError(t, l.Close())
+
+		run(t, path)
+		body := get(t, path)
+
+		stat, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), stat.Mode().Perm())
+
+		want := "unix:" + path
+		if runtime.GOOS == "linux" {
+			want = fmt.Sprintf("unix:%s[pid=%d,uid=%d,gid=%d] %d %d",
+				path, os.Getpid(), os.Getuid(), os.Getgid(), os.Getuid(), os.Getpid(),
+			)
+		}
+		require.Equal(t, want, body)
+	})
+
+	t.Run("not a socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "indigo.sock")
+		require.NoError(t, os.WriteFile(path, []byte("precious data"), 0o600))
+		err := New("").Listen(path, Unix()).Serve(nil)
+		require.Error(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "precious data", string(data))
+	})
+
+	t.Run("abstract", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("abstract sockets are Linux-only")
+		}
+
+		path := "@indigo-test-" + strconv.Itoa(os.Getpid())
+		run(t, path)
+		require.True(t, strings.HasPrefix(get(t, path), "unix:"+path))
+	})
+}
+
+func TestProxyProtocol(t *testing.T) {
+	app := New("").Listen(addr, TCP().ProxyProtocol("127.0.0.0/8", "::1"))
+	started, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		r := inbuilt.New().
+			Get("/", func(request *http.Request) *http.Response {
+				return http.String(request, request.Remote.String())
+			})
+
+		_ = app.
+			OnStart(func() {
+				close(started)
+			}).
+			Serve(r)
+		close(stopped)
+	}()
+
+	<-started
+	defer func() {
+		require.NoError(t, app.Shutdown(context.Background()))
+		<-stopped
+	}()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	for range 40 {
+		if conn, err = net.Dial("tcp4", addr); err == nil {
+			break
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_, err = io.WriteString(conn,
+		"PROXY TCP4 203.0.113.7 10.0.0.1 56324 80\r\n"+
+			"GET / HTTP/1.1\r\nConnection: close\r\n\r\n",
+	)
+	require.NoError(t, err)
+	resp, err := stdhttp.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	require.Equal(t, "203.0.113.7:56324", readFullBody(t, resp))
+}
+
+func TestProxyProtocolTrust(t *testing.T) {
+	require.Panics(t, func() {
+		TCP().ProxyProtocol()
+	}, "trusting everyone must be explicit")
+
+	require.NotPanics(t, func() {
+		TCP().ProxyProtocol("0.0.0.0/0", "::/0")
+	})
+}
+
+func TestTLSConfig(t *testing.T) {
+	cfg := &tls.Config{}
+	_ = newTLSTransport(cfg)
+	require.Empty(t, cfg.NextProtos, "the config of the caller must not be modified")
+}
+
 func TestEscaping(t *testing.T) {
 	runTest := func(dynamic bool) func(t *testing.T) {
 		return func(t *testing.T) {
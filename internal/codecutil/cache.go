@@ -3,6 +3,7 @@ package codecutil
 import (
 	"strings"
 
+	"github.com/indigo-web/indigo/config"
 	"github.com/indigo-web/indigo/http/codec"
 )
 
@@ -10,6 +11,8 @@ type Cache struct {
 	accept    string
 	codecs    []codec.Codec
 	instances []codec.Instance
+	policy    *codec.Policy
+	metrics   *codec.Metrics
 }
 
 func NewCache(codecs []codec.Codec, acceptString string) Cache {
@@ -45,6 +48,34 @@ func (c Cache) Get(token string) codec.Instance {
 	return inst
 }
 
+// Clone returns a cache over the same codecs, yet with its own instances. This allows
+// using the caches concurrently.
+func (c Cache) Clone() Cache {
+	return NewCache(c.codecs, c.accept).WithPolicy(c.policy).WithMetrics(c.metrics)
+}
+
+// WithPolicy returns the cache deciding the compressibility of responses by the policy.
+func (c Cache) WithPolicy(policy *codec.Policy) Cache {
+	c.policy = policy
+	return c
+}
+
+// WithMetrics returns the cache, whose limiters count rejected bodies into the metrics.
+func (c Cache) WithMetrics(metrics *codec.Metrics) Cache {
+	c.metrics = metrics
+	return c
+}
+
+// Limiter returns a new limiter enforcing the limits over the decompressors of the cache.
+func (c Cache) Limiter(limits config.BodyDecompression) *Limiter {
+	return NewLimiter(limits, c.metrics)
+}
+
+// Compressible tells whether the response of the content type may be compressed automatically.
+func (c Cache) Compressible(contentType string) bool {
+	return c.policy.Compressible(contentType)
+}
+
 func (c Cache) AcceptEncoding() string {
 	return c.accept
 }
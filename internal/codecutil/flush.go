@@ -0,0 +1,32 @@
+package codecutil
+
+import (
+	"io"
+
+	"github.com/indigo-web/indigo/http/codec"
+)
+
+// Flushing returns the compressor flushing after every write, so the data written so far
+// reaches the client immediately. Compressors not implementing codec.Flusher are returned
+// as is.
+func Flushing(c codec.Compressor) io.WriteCloser {
+	f, ok := c.(codec.Flusher)
+	if !ok {
+		return c
+	}
+
+	return flushingWriter{c, f}
+}
+
+type flushingWriter struct {
+	io.WriteCloser
+	flusher codec.Flusher
+}
+
+func (f flushingWriter) Write(b []byte) (n int, err error) {
+	if n, err = f.WriteCloser.Write(b); err != nil {
+		return n, err
+	}
+
+	return n, f.flusher.Flush()
+}
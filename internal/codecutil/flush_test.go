@@ -0,0 +1,29 @@
+package codecutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushing(t *testing.T) {
+	var buff bytes.Buffer
+	compressor := codec.NewGZIP().New()
+	compressor.ResetCompressor(&buff)
+	w := Flushing(compressor)
+
+	_, err := w.Write([]byte("Hello, world!"))
+	require.NoError(t, err)
+
+	r, err := gzip.NewReader(bytes.NewReader(buff.Bytes()))
+	require.NoError(t, err)
+	data := make([]byte, 13)
+	_, err = io.ReadFull(r, data)
+	require.NoError(t, err)
+	require.Equal(t, "Hello, world!", string(data))
+	require.NoError(t, w.Close())
+}
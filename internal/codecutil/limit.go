@@ -0,0 +1,98 @@
+package codecutil
+
+import (
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/http/status"
+)
+
+// Limiter enforces the decompression limits over a chain of decompressors. The compressed
+// data is counted at the very bottom of the chain, the decompressed one at its top. This way,
+// the limits hold regardless of how many decompressors are composed.
+type Limiter struct {
+	limits       config.BodyDecompression
+	metrics      *codec.Metrics
+	source       counter
+	chain        http.Fetcher
+	decompressed uint64
+	err          error
+}
+
+func NewLimiter(limits config.BodyDecompression, metrics *codec.Metrics) *Limiter {
+	return &Limiter{
+		limits:  limits,
+		metrics: metrics,
+	}
+}
+
+// Reset prepares the limiter for a new body.
+func (l *Limiter) Reset() {
+	l.source = counter{}
+	l.chain = nil
+	l.decompressed = 0
+	l.err = nil
+}
+
+// Source returns the fetcher counting the compressed data. It must be passed to the
+// innermost decompressor.
+func (l *Limiter) Source(src http.Fetcher) http.Fetcher {
+	l.source = counter{src: src}
+	return &l.source
+}
+
+// Limit returns the limiter fetching from the outermost decompressor.
+func (l *Limiter) Limit(chain http.Fetcher) http.Fetcher {
+	l.chain = chain
+	return l
+}
+
+// Err returns status.ErrRequestEntityTooLarge if the body violated the limits.
+func (l *Limiter) Err() error {
+	return l.err
+}
+
+func (l *Limiter) Fetch() ([]byte, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	data, err := l.chain.Fetch()
+	l.decompressed += uint64(len(data))
+
+	if l.decompressed > l.limits.MaxSize {
+		if l.metrics != nil {
+			l.metrics.Size.Add(1)
+		}
+
+		return nil, l.fail()
+	}
+
+	if l.limits.MaxRatio > 0 && l.decompressed > l.limits.RatioThreshold &&
+		l.decompressed/max(l.source.n, 1) > l.limits.MaxRatio {
+		if l.metrics != nil {
+			l.metrics.Ratio.Add(1)
+		}
+
+		return nil, l.fail()
+	}
+
+	return data, err
+}
+
+func (l *Limiter) fail() error {
+	l.err = status.ErrRequestEntityTooLarge
+	return l.err
+}
+
+// counter counts the data fetched from the source.
+type counter struct {
+	src http.Fetcher
+	n   uint64
+}
+
+func (c *counter) Fetch() ([]byte, error) {
+	data, err := c.src.Fetch()
+	c.n += uint64(len(data))
+	return data, err
+}
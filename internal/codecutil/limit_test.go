@@ -0,0 +1,106 @@
+package codecutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/transport/dummy"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	var buff bytes.Buffer
+	w := gzip.NewWriter(&buff)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buff.Bytes()
+}
+
+func limited(t *testing.T, limiter *Limiter, compressed []byte) http.Fetcher {
+	limiter.Reset()
+	decompressor := codec.NewGZIP().New()
+	source := limiter.Source(dummy.NewMockClient(compressed))
+	require.NoError(t, decompressor.ResetDecompressor(source, 512))
+
+	return limiter.Limit(decompressor)
+}
+
+func drain(f http.Fetcher) (n int, err error) {
+	for {
+		data, err := f.Fetch()
+		n += len(data)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+
+			return n, err
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	limits := config.BodyDecompression{
+		MaxSize:        64 * 1024,
+		MaxRatio:       10,
+		RatioThreshold: 1024,
+	}
+
+	t.Run("within limits", func(t *testing.T) {
+		metrics := new(codec.Metrics)
+		limiter := NewLimiter(config.BodyDecompression{MaxSize: 64 * 1024}, metrics)
+		n, err := drain(limited(t, limiter, gzipped(t, make([]byte, 32*1024))))
+		require.NoError(t, err)
+		require.Equal(t, 32*1024, n)
+		require.NoError(t, limiter.Err())
+		require.Zero(t, metrics.Rejected())
+	})
+
+	t.Run("size", func(t *testing.T) {
+		metrics := new(codec.Metrics)
+		limiter := NewLimiter(config.BodyDecompression{MaxSize: 64 * 1024}, metrics)
+		n, err := drain(limited(t, limiter, gzipped(t, make([]byte, 128*1024))))
+		require.Equal(t, status.ErrRequestEntityTooLarge, err)
+		require.LessOrEqual(t, n, 64*1024)
+		require.Equal(t, status.ErrRequestEntityTooLarge, limiter.Err())
+		require.Equal(t, uint64(1), metrics.Size.Load())
+		require.Zero(t, metrics.Ratio.Load())
+
+		// the error is sticky
+		_, err = limiter.Fetch()
+		require.Equal(t, status.ErrRequestEntityTooLarge, err)
+		require.Equal(t, uint64(1), metrics.Size.Load())
+	})
+
+	t.Run("ratio", func(t *testing.T) {
+		metrics := new(codec.Metrics)
+		limiter := NewLimiter(limits, metrics)
+		_, err := drain(limited(t, limiter, gzipped(t, make([]byte, 32*1024))))
+		require.Equal(t, status.ErrRequestEntityTooLarge, err)
+		require.Equal(t, uint64(1), metrics.Ratio.Load())
+		require.Zero(t, metrics.Size.Load())
+	})
+
+	t.Run("below threshold", func(t *testing.T) {
+		limiter := NewLimiter(limits, nil)
+		n, err := drain(limited(t, limiter, gzipped(t, make([]byte, 1024))))
+		require.NoError(t, err)
+		require.Equal(t, 1024, n)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		limiter := NewLimiter(limits, nil)
+		_, err := drain(limited(t, limiter, gzipped(t, make([]byte, 32*1024))))
+		require.Error(t, err)
+		limiter.Reset()
+		require.NoError(t, limiter.Err())
+	})
+}
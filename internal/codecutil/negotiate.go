@@ -0,0 +1,93 @@
+package codecutil
+
+import (
+	"strings"
+
+	"github.com/indigo-web/indigo/internal/response"
+	"github.com/indigo-web/indigo/internal/strutil"
+)
+
+// Quality returns the quality of the coding in thousandths, as it's listed in the Accept-Encoding.
+// Codings not listed explicitly inherit the quality of the asterisk. If neither is listed, -1 is
+// returned.
+func Quality(accept []string, coding string) int {
+	quality, wildcard := -1, -1
+
+	for _, value := range accept {
+		token, params := strutil.CutHeader(value)
+		token = strings.TrimSpace(token)
+
+		switch {
+		case strutil.CmpFoldSafe(token, coding):
+			quality = strutil.ParseQuality(params)
+		case token == "*":
+			wildcard = strutil.ParseQuality(params)
+		}
+	}
+
+	if quality == -1 {
+		return wildcard
+	}
+
+	return quality
+}
+
+// Negotiate chooses the content coding among the available codecs and the identity, as preferred
+// by the client (RFC 9110, 12.5.3). Ties are resolved by the order of the codecs, the identity
+// being the least preferred. The identity is acceptable, unless explicitly refused either directly
+// or by the asterisk. If nothing is acceptable, false is returned.
+func (c Cache) Negotiate(accept []string) (token string, ok bool) {
+	if len(accept) == 0 {
+		return "identity", true
+	}
+
+	best, bestQuality := "", 0
+	for _, entry := range c.codecs {
+		if q := Quality(accept, entry.Token()); q > bestQuality {
+			best, bestQuality = entry.Token(), q
+		}
+	}
+
+	identity := Quality(accept, "identity")
+	if len(best) > 0 && bestQuality >= identity {
+		return best, true
+	}
+
+	if identity != 0 {
+		return "identity", true
+	}
+
+	return "", false
+}
+
+// Compression decides the content coding of the response. The coding enforced explicitly wins.
+// Otherwise, if the automatic compression is enabled, the coding is negotiated, unless the body
+// is either too small or incompressible by the policy, and the client accepts the identity. The
+// vary reports whether the coding depends on Accept-Encoding. False acceptable means the client
+// accepts none of the available codings, which is reported for successful responses with a body
+// only. Others fall back to the identity, as they aren't worth replacing with 406.
+func (c Cache) Compression(f *response.Fields, accept []string, smallBody int64) (
+	token string, vary, acceptable bool,
+) {
+	if len(f.Precompressed) > 0 {
+		return "", false, true
+	}
+
+	if !f.AutoCompress {
+		return f.ContentEncoding, false, true
+	}
+
+	// small bodies aren't worth compressing. The gain is negligible, yet the zero-copy transfer
+	// becomes impossible.
+	small := f.StreamSize != -1 && f.StreamSize < smallBody
+	if (small || !c.Compressible(response.ContentType(f.Headers))) && Quality(accept, "identity") != 0 {
+		return "identity", false, true
+	}
+
+	token, acceptable = c.Negotiate(accept)
+	if !acceptable && (f.Code < 200 || f.Code >= 300 || f.StreamSize == 0) {
+		return "identity", true, true
+	}
+
+	return token, true, acceptable
+}
@@ -0,0 +1,113 @@
+package codecutil
+
+import (
+	"testing"
+
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/response"
+	"github.com/indigo-web/indigo/kv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuality(t *testing.T) {
+	require.Equal(t, 1000, Quality([]string{"gzip"}, "gzip"))
+	require.Equal(t, 1000, Quality([]string{"GZIP;q=1"}, "gzip"))
+	require.Equal(t, 500, Quality([]string{"deflate", "gzip;q=0.5"}, "gzip"))
+	require.Equal(t, 0, Quality([]string{"gzip;q=0"}, "gzip"))
+	require.Equal(t, 0, Quality([]string{"gzip;q=nope"}, "gzip"))
+	require.Equal(t, 100, Quality([]string{"*;q=0.1"}, "gzip"))
+	require.Equal(t, 1000, Quality([]string{"*;q=0", "gzip"}, "gzip"))
+	require.Equal(t, -1, Quality([]string{"deflate"}, "gzip"))
+}
+
+func TestNegotiate(t *testing.T) {
+	codecs := []codec.Codec{codec.NewZSTD(), codec.NewGZIP()}
+	cache := NewCache(codecs, AcceptEncoding(codecs))
+
+	test := func(want string, accept ...string) func(t *testing.T) {
+		return func(t *testing.T) {
+			token, ok := cache.Negotiate(accept)
+			require.True(t, ok)
+			require.Equal(t, want, token)
+		}
+	}
+
+	t.Run("no header", test("identity"))
+	t.Run("server preference on ties", test("zstd", "gzip", "zstd"))
+	t.Run("client preference", test("gzip", "gzip", "zstd;q=0.5"))
+	t.Run("unavailable codec", test("gzip", "br", "gzip;q=0.1"))
+	t.Run("refused", test("gzip", "*", "zstd;q=0"))
+	t.Run("wildcard", test("zstd", "*"))
+	t.Run("identity preferred", test("identity", "identity", "gzip;q=0.5"))
+	t.Run("nothing available", test("identity", "br"))
+
+	t.Run("not acceptable", func(t *testing.T) {
+		for _, accept := range [][]string{
+			{"br", "identity;q=0"},
+			{"br", "*;q=0"},
+			{"gzip;q=0", "zstd;q=0", "identity;q=0"},
+		} {
+			_, ok := cache.Negotiate(accept)
+			require.False(t, ok, accept)
+		}
+	})
+}
+
+func TestCompression(t *testing.T) {
+	codecs := []codec.Codec{codec.NewGZIP()}
+	cache := NewCache(codecs, AcceptEncoding(codecs)).WithPolicy(codec.NewPolicy())
+
+	fields := func(size int64, contentType string) *response.Fields {
+		f := &response.Fields{Code: status.OK, AutoCompress: true, StreamSize: size}
+		f.Headers = append(f.Headers, kv.Pair{Key: "Content-Type", Value: contentType})
+		return f
+	}
+
+	t.Run("negotiated", func(t *testing.T) {
+		token, vary, ok := cache.Compression(fields(-1, "text/html"), []string{"gzip"}, 1024)
+		require.Equal(t, "gzip", token)
+		require.True(t, vary)
+		require.True(t, ok)
+	})
+
+	t.Run("small", func(t *testing.T) {
+		token, vary, ok := cache.Compression(fields(10, "text/html"), []string{"gzip"}, 1024)
+		require.Equal(t, "identity", token)
+		require.False(t, vary)
+		require.True(t, ok)
+	})
+
+	t.Run("small with identity refused", func(t *testing.T) {
+		token, _, ok := cache.Compression(fields(10, "text/html"), []string{"gzip", "identity;q=0"}, 1024)
+		require.Equal(t, "gzip", token)
+		require.True(t, ok)
+	})
+
+	t.Run("incompressible", func(t *testing.T) {
+		token, vary, ok := cache.Compression(fields(-1, "image/png"), []string{"gzip"}, 1024)
+		require.Equal(t, "identity", token)
+		require.False(t, vary)
+		require.True(t, ok)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		_, _, ok := cache.Compression(fields(-1, "text/html"), []string{"br", "identity;q=0"}, 1024)
+		require.False(t, ok)
+	})
+
+	t.Run("not acceptable error", func(t *testing.T) {
+		f := fields(-1, "text/html")
+		f.Code = status.NotFound
+		token, _, ok := cache.Compression(f, []string{"br", "identity;q=0"}, 1024)
+		require.Equal(t, "identity", token)
+		require.True(t, ok)
+	})
+
+	t.Run("enforced", func(t *testing.T) {
+		token, vary, ok := cache.Compression(&response.Fields{ContentEncoding: "gzip"}, nil, 1024)
+		require.Equal(t, "gzip", token)
+		require.False(t, vary)
+		require.True(t, ok)
+	})
+}
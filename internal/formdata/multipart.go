@@ -63,6 +63,10 @@ func ParseMultipart(cfg *config.Config, into form.Form, data, buff []byte, b str
 			continue
 		}
 
+		if len(hdr.File) == 0 && int64(len(value)) > cfg.Body.Form.MaxFieldSize {
+			return nil, status.ErrRequestEntityTooLarge
+		}
+
 		if len(hdr.Charset) == 0 {
 			hdr.Charset = charset
 		}
@@ -126,3 +126,13 @@ func TestMultipartNegative(t *testing.T) {
 			"Test case %d: wanted status.ErrBadRequest, got instead %s", i+1, err)
 	}
 }
+
+func TestMultipartFieldSize(t *testing.T) {
+	cfg := config.Default()
+	cfg.Body.Form.MaxFieldSize = 4
+	data := "--boundary\r\nContent-Disposition: form-data; name=file; filename=a.txt\r\n\r\nlong file\r\n" +
+		"--boundary\r\nContent-Disposition: form-data; name=username\r\n\r\nAlice\r\n--boundary--\r\n"
+
+	_, err := ParseMultipart(cfg, nil, []byte(data), nil, "boundary")
+	require.EqualError(t, err, status.ErrRequestEntityTooLarge.Error())
+}
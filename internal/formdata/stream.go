@@ -0,0 +1,280 @@
+package formdata
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/flrdv/uf"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/kv"
+)
+
+// Header describes a part of the multipart/form-data body.
+type Header struct {
+	Name, File, ContentType, Charset string
+	// Raw is the whole headers block of the part.
+	Raw string
+}
+
+// Fields parses the raw headers block into the storage.
+func (h Header) Fields(into *kv.Storage) *kv.Storage {
+	for _, line := range strings.Split(h.Raw, "\n") {
+		key, value, found := strings.Cut(strings.TrimSuffix(line, "\r"), ":")
+		if found {
+			into.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+
+	return into
+}
+
+// Reader parses the multipart/form-data body as a stream. Parts are read directly from the
+// source, so at most a single buffer of data is held in memory at once.
+type Reader struct {
+	src        io.Reader
+	delim      string
+	buff       []byte
+	head, tail int
+	err        error
+	// gen is incremented every time the next part is requested, so readers of the previous
+	// parts become exhausted.
+	gen     uint64
+	done    bool
+	headers []byte
+	decoded []byte
+}
+
+// NewReader returns the reader of the multipart body. The size limits the length of the headers
+// block of a single part.
+func NewReader(size int) *Reader {
+	return &Reader{buff: make([]byte, size)}
+}
+
+// Reset prepares the reader to parse a new body.
+func (r *Reader) Reset(src io.Reader, boundary string) {
+	r.src = src
+	// the preamble is treated as if it was a part preceding the first delimiter, which is why
+	// the very first delimiter might lack the leading CRLF.
+	r.delim = "\r\n--" + boundary
+	if len(r.buff) < 2*len(r.delim) {
+		r.buff = make([]byte, 2*len(r.delim))
+	}
+
+	r.head, r.tail = 0, copy(r.buff, "\r\n")
+	r.err = nil
+	r.gen++
+	r.done = false
+}
+
+// Next skips the rest of the current part and parses the headers of the next one. If there
+// are no more parts, io.EOF is returned. The returned header is valid until the next call.
+func (r *Reader) Next() (Header, io.Reader, error) {
+	if r.done {
+		return Header{}, nil, io.EOF
+	}
+
+	r.gen++
+
+	if err := r.skip(); err != nil {
+		return Header{}, nil, err
+	}
+
+	for r.tail-r.head < 2 {
+		if err := r.fill(); err != nil {
+			return Header{}, nil, err
+		}
+	}
+
+	if string(r.buff[r.head:r.head+2]) == "--" {
+		// the closing delimiter. Whatever follows it is the epilogue, which is ignored.
+		r.done = true
+		return Header{}, nil, io.EOF
+	}
+
+	line, err := r.line()
+	if err != nil {
+		return Header{}, nil, err
+	}
+
+	if len(strings.TrimSpace(line)) > 0 {
+		// only the transport padding is allowed after the boundary (RFC 2046, 5.1.1).
+		return Header{}, nil, status.ErrBadRequest
+	}
+
+	hdr, err := r.header()
+	if err != nil {
+		return Header{}, nil, err
+	}
+
+	return hdr, &partReader{r: r, gen: r.gen}, nil
+}
+
+// skip discards everything up to and including the next delimiter.
+func (r *Reader) skip() error {
+	for {
+		data := r.buff[r.head:r.tail]
+		if i := bytes.Index(data, uf.S2B(r.delim)); i != -1 {
+			r.head += i + len(r.delim)
+			return nil
+		}
+
+		// the tail might be the beginning of the delimiter.
+		if keep := len(r.delim) - 1; len(data) > keep {
+			r.head = r.tail - keep
+		}
+
+		if err := r.fill(); err != nil {
+			return err
+		}
+	}
+}
+
+// line returns the rest of the line, consuming it together with the line feed.
+func (r *Reader) line() (string, error) {
+	for {
+		data := r.buff[r.head:r.tail]
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			r.head += i + 1
+			return string(data[:i]), nil
+		}
+
+		if err := r.fill(); err != nil {
+			if err == errBufferFull {
+				err = status.ErrBadRequest
+			}
+
+			return "", err
+		}
+	}
+}
+
+// header reads the headers block of the part and parses it.
+func (r *Reader) header() (Header, error) {
+	for {
+		data := r.buff[r.head:r.tail]
+		end := -1
+		if bytes.HasPrefix(data, []byte("\r\n")) {
+			end = 2
+		} else if i := bytes.Index(data, []byte("\r\n\r\n")); i != -1 {
+			end = i + 4
+		}
+
+		if end != -1 {
+			r.headers = append(r.headers[:0], data[:end]...)
+			r.head += end
+			break
+		}
+
+		if err := r.fill(); err != nil {
+			if err == errBufferFull {
+				err = status.ErrHeaderFieldsTooLarge
+			}
+
+			return Header{}, err
+		}
+	}
+
+	s := stream(uf.B2S(r.headers))
+	hdr := parseHeaders(&s)
+	if len(hdr.Name) == 0 {
+		return Header{}, status.ErrBadRequest
+	}
+
+	var ok bool
+	r.decoded = r.decoded[:0]
+	if hdr.Name, r.decoded, ok = urldecode(hdr.Name, r.decoded); !ok {
+		return Header{}, status.ErrBadEncoding
+	}
+
+	if hdr.File, r.decoded, ok = urldecode(hdr.File, r.decoded); !ok {
+		return Header{}, status.ErrBadEncoding
+	}
+
+	return Header{
+		Name:        hdr.Name,
+		File:        hdr.File,
+		ContentType: hdr.ContentType,
+		Charset:     hdr.Charset,
+		Raw:         uf.B2S(r.headers[:len(r.headers)-2]),
+	}, nil
+}
+
+var errBufferFull = errors.New("multipart buffer is full")
+
+// fill reads more data from the source, moving the unread data to the beginning of the buffer.
+// Reaching the end of the source is unexpected, as the closing delimiter must come first.
+func (r *Reader) fill() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	if r.head > 0 {
+		r.tail = copy(r.buff, r.buff[r.head:r.tail])
+		r.head = 0
+	}
+
+	if r.tail == len(r.buff) {
+		return errBufferFull
+	}
+
+	n, err := r.src.Read(r.buff[r.tail:])
+	r.tail += n
+	if err == io.EOF {
+		err = status.ErrBadRequest
+	}
+
+	if err != nil {
+		r.err = err
+		if n == 0 {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// read reads the content of the current part.
+func (r *Reader) read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	for {
+		data := r.buff[r.head:r.tail]
+		if i := bytes.Index(data, uf.S2B(r.delim)); i != -1 {
+			if i == 0 {
+				return 0, io.EOF
+			}
+
+			n := copy(b, data[:i])
+			r.head += n
+			return n, nil
+		}
+
+		// the tail might be the beginning of the delimiter, so it's held back.
+		if safe := len(data) - (len(r.delim) - 1); safe > 0 {
+			n := copy(b, data[:safe])
+			r.head += n
+			return n, nil
+		}
+
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+type partReader struct {
+	r   *Reader
+	gen uint64
+}
+
+func (p *partReader) Read(b []byte) (int, error) {
+	if p.gen != p.r.gen {
+		return 0, io.EOF
+	}
+
+	return p.r.read(b)
+}
@@ -0,0 +1,130 @@
+package formdata
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/kv"
+	"github.com/stretchr/testify/require"
+)
+
+type part struct {
+	Header
+	Value string
+}
+
+func readParts(t *testing.T, r *Reader) (parts []part, err error) {
+	for {
+		hdr, content, err := r.Next()
+		if err == io.EOF {
+			return parts, nil
+		}
+
+		if err != nil {
+			return parts, err
+		}
+
+		value, err := io.ReadAll(content)
+		if err != nil {
+			return parts, err
+		}
+
+		hdr.Raw = strings.Clone(hdr.Raw)
+		hdr.Name, hdr.File = strings.Clone(hdr.Name), strings.Clone(hdr.File)
+		parts = append(parts, part{Header: hdr, Value: string(value)})
+	}
+}
+
+func TestReader(t *testing.T) {
+	const sample = "preamble\r\n" +
+		"--boundary\r\n" +
+		"Content-Disposition: form-data; name=\"username\"\r\n" +
+		"\r\n" +
+		"Alice\r\n" +
+		"--boundary  \r\n" +
+		"Content-Disposition: form-data; name=\"profile%20pic\"; filename=\"profile.png\"\r\n" +
+		"Content-Type: image/png\r\n" +
+		"\r\n" +
+		"\r\n--boundar\r\n\r\n" +
+		"--boundary--\r\n" +
+		"epilogue"
+
+	test := func(t *testing.T, src io.Reader, size int) {
+		r := NewReader(size)
+		r.Reset(src, "boundary")
+		parts, err := readParts(t, r)
+		require.NoError(t, err)
+		require.Len(t, parts, 2)
+
+		require.Equal(t, "username", parts[0].Name)
+		require.Empty(t, parts[0].File)
+		require.Equal(t, "Alice", parts[0].Value)
+
+		require.Equal(t, "profile pic", parts[1].Name)
+		require.Equal(t, "profile.png", parts[1].File)
+		require.Equal(t, "image/png", parts[1].ContentType)
+		require.Equal(t, "\r\n--boundar\r\n", parts[1].Value)
+		fields := parts[1].Fields(kv.New())
+		require.Equal(t, "image/png", fields.Value("content-type"))
+	}
+
+	t.Run("whole", func(t *testing.T) {
+		test(t, strings.NewReader(sample), 4096)
+	})
+
+	t.Run("byte by byte", func(t *testing.T) {
+		test(t, iotest.OneByteReader(strings.NewReader(sample)), 4096)
+	})
+
+	t.Run("small buffer", func(t *testing.T) {
+		test(t, iotest.HalfReader(strings.NewReader(sample)), 128)
+	})
+
+	t.Run("no preamble", func(t *testing.T) {
+		r := NewReader(4096)
+		r.Reset(strings.NewReader("--b\r\nContent-Disposition: form-data; name=a\r\n\r\n1\r\n--b--"), "b")
+		parts, err := readParts(t, r)
+		require.NoError(t, err)
+		require.Len(t, parts, 1)
+		require.Equal(t, "1", parts[0].Value)
+	})
+
+	t.Run("skip unread", func(t *testing.T) {
+		r := NewReader(4096)
+		r.Reset(strings.NewReader(sample), "boundary")
+		_, first, err := r.Next()
+		require.NoError(t, err)
+		hdr, _, err := r.Next()
+		require.NoError(t, err)
+		require.Equal(t, "profile pic", hdr.Name)
+
+		// the reader of the previous part is exhausted.
+		n, err := first.Read(make([]byte, 10))
+		require.Zero(t, n)
+		require.Equal(t, io.EOF, err)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		r := NewReader(4096)
+		r.Reset(strings.NewReader("--b\r\nContent-Disposition: form-data; name=a\r\n\r\nvalue"), "b")
+		_, err := readParts(t, r)
+		require.Equal(t, status.ErrBadRequest, err)
+	})
+
+	t.Run("too large headers", func(t *testing.T) {
+		r := NewReader(128)
+		r.Reset(strings.NewReader("--b\r\nX-Foo: "+strings.Repeat("a", 256)+"\r\n\r\n--b--"), "b")
+		_, err := readParts(t, r)
+		require.Equal(t, status.ErrHeaderFieldsTooLarge, err)
+	})
+
+	t.Run("no name", func(t *testing.T) {
+		r := NewReader(4096)
+		r.Reset(strings.NewReader("--b\r\nContent-Type: text/plain\r\n\r\nvalue\r\n--b--"), "b")
+		_, err := readParts(t, r)
+		require.Equal(t, status.ErrBadRequest, err)
+	})
+}
@@ -1,6 +1,8 @@
 package parse
 
 import (
+	"context"
+
 	"github.com/indigo-web/indigo/config"
 	"github.com/indigo-web/indigo/http"
 	"github.com/indigo-web/indigo/internal/codecutil"
@@ -12,7 +14,7 @@ import (
 func HTTP11Request(data string) (*http.Request, error) {
 	client := dummy.NewMockClient([]byte(data))
 	request := construct.Request(config.Default(), client)
-	suit := http1.New(config.Default(), nil, client, request, codecutil.NewCache(nil, "identity"))
+	suit := http1.New(context.Background(), config.Default(), nil, client, nil, request, codecutil.NewCache(nil, "identity"))
 	request.Body = http.NewBody(suit)
 
 	for {
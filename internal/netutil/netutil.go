@@ -0,0 +1,67 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// ParseNetworks parses CIDRs. Single addresses are converted into prefixes containing only them.
+func ParseNetworks(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+
+	for _, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(network)
+			if aerr != nil {
+				return nil, fmt.Errorf("bad network: %s", network)
+			}
+
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// MustParseNetworks is like ParseNetworks, but panics if any of the networks is invalid.
+func MustParseNetworks(networks []string) []netip.Prefix {
+	prefixes, err := ParseNetworks(networks)
+	if err != nil {
+		panic(err)
+	}
+
+	return prefixes
+}
+
+// Contains tells whether the address belongs to any of the networks.
+func Contains(networks []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range networks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddrOf returns the IP address of TCP and UDP addresses.
+func AddrOf(addr net.Addr) (netip.Addr, bool) {
+	var ip net.IP
+
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		return netip.Addr{}, false
+	}
+
+	parsed, ok := netip.AddrFromSlice(ip)
+	return parsed.Unmap(), ok
+}
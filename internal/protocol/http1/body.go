@@ -16,6 +16,8 @@ type body struct {
 	reader        func(*body) ([]byte, error)
 	chunkedParser chunkedParser
 	client        transport.Client
+	// done reports whether the body is completely consumed.
+	done bool
 }
 
 func newBody(client transport.Client, s config.Body) *body {
@@ -46,6 +48,7 @@ func (b *body) Reset(request *http.Request) {
 
 func (b *body) initPlain(totalLen uint64) {
 	b.counter = totalLen
+	b.done = totalLen == 0
 }
 
 func (b *body) readPlain() (body []byte, err error) {
@@ -66,6 +69,7 @@ func (b *body) readPlain() (body []byte, err error) {
 		body, data = data[:b.counter], data[b.counter:]
 		b.client.Pushback(data)
 		b.counter = 0
+		b.done = true
 		err = io.EOF
 	} else {
 		b.counter -= uint64(len(data))
@@ -77,6 +81,8 @@ func (b *body) readPlain() (body []byte, err error) {
 
 func (b *body) initEOFReader() {
 	b.counter = 0
+	// the body lasts till the connection is closed, so it can never be done.
+	b.done = false
 }
 
 func (b *body) readTillEOF() ([]byte, error) {
@@ -92,6 +98,7 @@ func (b *body) readTillEOF() ([]byte, error) {
 
 func (b *body) initChunked() {
 	b.counter = 0
+	b.done = false
 }
 
 func (b *body) readChunked() (body []byte, err error) {
@@ -112,6 +119,7 @@ func (b *body) readChunked() (body []byte, err error) {
 	}
 
 	b.counter += uint64(len(chunk))
+	b.done = err == io.EOF
 	b.client.Pushback(extra)
 
 	return chunk, err
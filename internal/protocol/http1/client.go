@@ -0,0 +1,275 @@
+package http1
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/proto"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/codecutil"
+	"github.com/indigo-web/indigo/internal/construct"
+	"github.com/indigo-web/indigo/internal/response"
+	"github.com/indigo-web/indigo/kv"
+	"github.com/indigo-web/indigo/transport"
+)
+
+// ErrNoResponse is returned if the connection was closed by the peer before any byte of
+// the response was received. This mostly happens to idle connections closed by the server,
+// in which case the request is safe to be retried.
+var ErrNoResponse = errors.New("connection closed before receiving a response")
+
+// ResponseHead is a response head received by the ClientConn. All the values are valid until
+// the next response is read.
+type ResponseHead struct {
+	Protocol proto.Protocol
+	Code     status.Code
+	Status   string
+	Headers  *kv.Storage
+	// ContentLength is -1 if the length isn't known in advance, e.g. the body is chunked
+	// or decompressed.
+	ContentLength int64
+	ContentType   string
+	Body          *http.Body
+}
+
+// ClientConn is the client side of an HTTP/1.1 connection. Requests are written by the serializer,
+// responses are read by the parser running in the response mode.
+type ClientConn struct {
+	cfg        *config.Config
+	client     transport.Client
+	parser     *Parser
+	body       *body
+	serializer *serializer
+	fields     response.Fields
+	// carrier stores the response headers and the body, as the parser and the body are
+	// designed to work with requests.
+	carrier *http.Request
+	codecs  codecutil.Cache
+	closing bool
+}
+
+func NewClientConn(cfg *config.Config, client transport.Client, codecs codecutil.Cache) *ClientConn {
+	statusBuff, headersBuff := construct.Buffers(cfg)
+	carrier := construct.Request(cfg, client)
+	b := newBody(client, cfg.Body)
+	carrier.Body = http.NewBody(b)
+
+	return &ClientConn{
+		cfg:    cfg,
+		client: client,
+		parser: NewResponseParser(cfg, carrier, statusBuff, headersBuff),
+		body:   b,
+		serializer: &serializer{
+			cfg:            cfg,
+			client:         client,
+			codecs:         codecs,
+			buff:           make([]byte, 0, cfg.NET.WriteBufferSize.Default),
+			defaultHeaders: newDefaultHeaders(pairsFromMap(nil, codecs.AcceptEncoding())),
+		},
+		carrier: carrier,
+		codecs:  codecs,
+	}
+}
+
+// WriteRequest writes the request and its body, if any. Unless overridden, the Accept-Encoding
+// header lists all the available codecs. The body is sent chunked if its length is -1.
+func (c *ClientConn) WriteRequest(
+	m method.Method, target string, headers []kv.Pair, body io.Reader, length int64,
+) (err error) {
+	s := c.serializer
+	s.buff = append(s.buff, m.String()...)
+	s.sp()
+	s.buff = append(s.buff, target...)
+	s.sp()
+	s.buff = append(s.buff, proto.HTTP11.String()...)
+	s.crlf()
+
+	for _, header := range headers {
+		s.defaultHeaders.Exclude(header.Key)
+		s.appendHeader(header)
+		s.crlf()
+	}
+
+	s.appendDefaultHeaders()
+
+	var encoder io.WriteCloser
+
+	switch {
+	case body == nil:
+		if m == method.POST || m == method.PUT || m == method.PATCH {
+			s.appendKnownHeader("Content-Length", "0")
+		}
+	case length == -1:
+		s.appendKnownHeader("Transfer-Encoding", "chunked")
+		encoder = chunkedWriter{s}
+	default:
+		s.appendContentLength(length)
+		encoder = identityWriter{s}
+	}
+
+	s.crlf()
+
+	if encoder == nil {
+		return stale(s.flush())
+	}
+
+	c.fields = response.Fields{Buffered: true, StreamSize: length}
+	s.response = &c.fields
+	if length > 0 {
+		s.growToContain(int(length))
+	}
+
+	defer func() {
+		if cerr := encoder.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	return stale(s.pipe(encoder, body))
+}
+
+// ReadResponse reads the response head to the request of method m. Informational responses
+// are skipped. The body must be consumed before the next request is written.
+func (c *ClientConn) ReadResponse(m method.Method) (head ResponseHead, err error) {
+	carrier := c.carrier
+
+	for {
+		carrier.Reset()
+		if err = c.readHead(); err != nil {
+			c.closing = true
+			return head, err
+		}
+
+		code, _ := c.parser.Status()
+		if code >= 200 || code == status.SwitchingProtocols {
+			break
+		}
+	}
+
+	code, reason := c.parser.Status()
+	head = ResponseHead{
+		Protocol:      carrier.Protocol,
+		Code:          code,
+		Status:        reason,
+		Headers:       carrier.Headers,
+		ContentLength: int64(carrier.ContentLength),
+		ContentType:   carrier.ContentType,
+		Body:          carrier.Body,
+	}
+
+	c.closing = !isKeepAlive(carrier.Protocol, carrier) || code == status.SwitchingProtocols
+	carrier.Body.Fetcher = c.body
+
+	switch {
+	case m == method.HEAD || code < 200 || code == status.NoContent || code == status.NotModified:
+		c.body.initPlain(0)
+		c.body.reader = (*body).readPlain
+		head.ContentLength = 0
+		carrier.ContentLength, carrier.Chunked = 0, false
+	case carrier.Chunked:
+		c.body.initChunked()
+		c.body.reader = (*body).readChunked
+		head.ContentLength = -1
+	case carrier.Headers.Has("content-length"):
+		c.body.initPlain(uint64(carrier.ContentLength))
+		c.body.reader = (*body).readPlain
+	default:
+		// the body lasts till the connection is closed.
+		c.body.initEOFReader()
+		c.body.reader = (*body).readTillEOF
+		c.closing = true
+		head.ContentLength = -1
+		carrier.Chunked = true
+	}
+
+	carrier.Body.Reset(carrier)
+
+	if c.decodable(carrier.ContentEncoding) {
+		err = applyDecoders(carrier.Body, c.codecs, carrier.ContentEncoding, c.cfg.NET.ReadBufferSize)
+		if err != nil {
+			c.closing = true
+			return head, err
+		}
+
+		carrier.Headers.Delete("content-encoding").Delete("content-length")
+		head.ContentLength = -1
+		carrier.Chunked = true
+	}
+
+	return head, nil
+}
+
+// readHead reads and parses the response head.
+func (c *ClientConn) readHead() error {
+	received := false
+
+	for {
+		data, err := c.client.Read()
+		if err != nil {
+			if !received {
+				return stale(err)
+			}
+
+			return err
+		}
+
+		received = received || len(data) > 0
+
+		done, extra, err := c.parser.Parse(data)
+		if err != nil {
+			return fmt.Errorf("malformed response: %w", err)
+		}
+
+		if done {
+			c.client.Pushback(extra)
+			return nil
+		}
+	}
+}
+
+// decodable tells whether all the content codings are supported.
+func (c *ClientConn) decodable(tokens []string) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+
+	for _, token := range tokens {
+		if c.codecs.Get(token) == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Reusable tells whether the connection can carry another request. This is the case only if
+// the last response was completely consumed and the server didn't ask to close the connection.
+func (c *ClientConn) Reusable() bool {
+	return !c.closing && c.body.done
+}
+
+// Hijack hands the underlying connection over, e.g. after 101 Switching Protocols. The ClientConn
+// must not be used afterward.
+func (c *ClientConn) Hijack() transport.Client {
+	c.closing = true
+	return c.client
+}
+
+// Close closes the underlying connection.
+func (c *ClientConn) Close() error {
+	return c.client.Close()
+}
+
+// stale wraps errors caused by the connection closed by the peer into ErrNoResponse.
+func stale(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return fmt.Errorf("%w: %w", ErrNoResponse, err)
+	}
+
+	return err
+}
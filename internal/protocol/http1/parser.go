@@ -34,11 +34,13 @@ const (
 	eContentLengthCR
 	eHeaderValue
 	eHeaderValueCRLFCR
+	eStatusLine
 )
 
 type Parser struct {
 	urlEncodedChar      uint8
 	state               parserState
+	initial             parserState
 	metTransferEncoding bool
 	headersNumber       int
 	contentLength       int64
@@ -49,12 +51,15 @@ type Parser struct {
 	key                 string
 	acceptEncodings     []string
 	encodings           []string
+	code                status.Code
+	reason              string
 }
 
 func NewParser(cfg *config.Config, request *http.Request, statusBuff, headers *buffer.Buffer) *Parser {
 	return &Parser{
 		cfg:             cfg,
 		state:           eMethod,
+		initial:         eMethod,
 		request:         request,
 		acceptEncodings: make([]string, 0, cfg.Headers.MaxAcceptEncodingTokens),
 		encodings:       make([]string, 0, cfg.Headers.MaxEncodingTokens),
@@ -63,6 +68,21 @@ func NewParser(cfg *config.Config, request *http.Request, statusBuff, headers *b
 	}
 }
 
+// NewResponseParser returns a parser of response heads, as they're received by a client. The request
+// serves merely as a storage for the headers, whereas the status is available via Status.
+func NewResponseParser(cfg *config.Config, request *http.Request, statusBuff, headers *buffer.Buffer) *Parser {
+	p := NewParser(cfg, request, statusBuff, headers)
+	p.state, p.initial = eStatusLine, eStatusLine
+
+	return p
+}
+
+// Status returns the code and the reason phrase of the last parsed response. The reason phrase
+// is valid until the next call to Parse.
+func (p *Parser) Status() (status.Code, string) {
+	return p.code, p.reason
+}
+
 func (p *Parser) Parse(data []byte) (done bool, extra []byte, err error) {
 	_ = *p.request
 	request := p.request
@@ -103,6 +123,8 @@ func (p *Parser) Parse(data []byte) (done bool, extra []byte, err error) {
 		goto headerValue
 	case eHeaderValueCRLFCR:
 		goto headerValueCRLFCR
+	case eStatusLine:
+		goto statusLine
 	default:
 		panic("unreachable code")
 	}
@@ -627,6 +649,66 @@ contentLengthCR:
 
 	data = data[1:]
 	goto headerKey
+
+statusLine:
+	{
+		lf := bytes.IndexByte(data, '\n')
+		if lf == -1 {
+			if !requestLine.Append(data) {
+				return true, nil, status.ErrTooLongRequestLine
+			}
+
+			p.state = eStatusLine
+			return false, nil, nil
+		}
+
+		if !requestLine.Append(data[:lf]) {
+			return true, nil, status.ErrTooLongRequestLine
+		}
+
+		if err = p.parseStatusLine(stripCR(requestLine.Finish())); err != nil {
+			return true, nil, err
+		}
+
+		data = data[lf+1:]
+		goto headerKey
+	}
+}
+
+// parseStatusLine parses the status line in form of `HTTP/1.1 200 OK`. The reason phrase
+// is optional.
+func (p *Parser) parseStatusLine(line []byte) error {
+	const minLength = len("HTTP/x.x 200")
+
+	if len(line) < minLength || line[8] != ' ' || len(line) > minLength && line[minLength] != ' ' {
+		return status.ErrBadRequest
+	}
+
+	p.request.Protocol = proto.FromBytes(line[:8])
+	if p.request.Protocol == proto.Unknown {
+		return status.ErrHTTPVersionNotSupported
+	}
+
+	var code status.Code
+	for _, char := range line[9:minLength] {
+		if char < '0' || char > '9' {
+			return status.ErrBadRequest
+		}
+
+		code = code*10 + status.Code(char-'0')
+	}
+
+	if code < 100 {
+		return status.ErrBadRequest
+	}
+
+	p.code = code
+	p.reason = ""
+	if len(line) > minLength {
+		p.reason = uf.B2S(line[minLength+1:])
+	}
+
+	return nil
 }
 
 func (p *Parser) cleanup() {
@@ -634,7 +716,10 @@ func (p *Parser) cleanup() {
 	p.headers.Clear()
 
 	*p = Parser{
-		state:           eMethod,
+		state:           p.initial,
+		initial:         p.initial,
+		code:            p.code,
+		reason:          p.reason,
 		cfg:             p.cfg,
 		request:         p.request,
 		requestLine:     p.requestLine,
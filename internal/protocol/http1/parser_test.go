@@ -751,3 +751,62 @@ func genHeaders(n int) (out []string) {
 func genHeader() string {
 	return fmt.Sprintf("%[1]s: %[1]s", uniuri.NewLen(16))
 }
+
+func TestResponseParser(t *testing.T) {
+	cfg := config.Default()
+	request := construct.Request(cfg, dummy.NewNopClient())
+	statusLine, headers := construct.Buffers(cfg)
+	parser := NewResponseParser(cfg, request, statusLine, headers)
+
+	parse := func(raw string) (status.Code, string, error) {
+		request.Reset()
+
+		// feed byte by byte in order to verify, the state is preserved among calls.
+		for i := range raw {
+			done, _, err := parser.Parse([]byte{raw[i]})
+			if err != nil {
+				return 0, "", err
+			}
+
+			if done {
+				code, reason := parser.Status()
+				return code, reason, nil
+			}
+		}
+
+		return 0, "", errors.New("incomplete")
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		code, reason, err := parse("HTTP/1.1 404 Not Found\r\nContent-Length: 13\r\nContent-Type: text/plain\r\n\r\n")
+		require.NoError(t, err)
+		require.Equal(t, status.NotFound, code)
+		require.Equal(t, "Not Found", reason)
+		require.Equal(t, proto.HTTP11, request.Protocol)
+		require.Equal(t, 13, request.ContentLength)
+		require.Equal(t, "text/plain", request.ContentType)
+	})
+
+	t.Run("no reason phrase", func(t *testing.T) {
+		code, reason, err := parse("HTTP/1.0 200\n\n")
+		require.NoError(t, err)
+		require.Equal(t, status.OK, code)
+		require.Empty(t, reason)
+		require.Equal(t, proto.HTTP10, request.Protocol)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, tc := range []string{
+			"HTTP/1.1 20 OK\r\n\r\n",
+			"HTTP/1.1 2000 OK\r\n\r\n",
+			"HTTP/1.1 0x1 OK\r\n\r\n",
+			"HTTP/1.1 099 OK\r\n\r\n",
+			"HTTP/1.1200 OK\r\n\r\n",
+			"HTTP/4.2 200 OK\r\n\r\n",
+		} {
+			parser = NewResponseParser(cfg, request, statusLine, headers)
+			_, _, err := parse(tc)
+			require.Error(t, err, tc)
+		}
+	})
+}
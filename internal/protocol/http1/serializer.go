@@ -6,7 +6,6 @@ import (
 	"slices"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/indigo-web/indigo/config"
 	"github.com/indigo-web/indigo/http"
@@ -33,6 +32,8 @@ type serializer struct {
 	streamReadBuff []byte
 	defaultHeaders defaultHeaders
 	codecs         codecutil.Cache
+	// closing adds the Connection: close header to the response.
+	closing bool
 }
 
 func newSerializer(
@@ -65,8 +66,29 @@ func (s *serializer) Upgrade() {
 	s.crlf()
 }
 
-func (s *serializer) Write(protocol proto.Protocol, response *http.Response) error {
-	resp := response.Expose()
+// UpgradeH2C writes and flushes an informational response 101 Switching Protocols to h2c.
+func (s *serializer) UpgradeH2C() error {
+	s.appendProtocol(proto.HTTP11)
+	s.buff = append(s.buff, "101 Switching Protocols\r\n"...)
+
+	s.appendKnownHeader("Connection", "Upgrade")
+	s.appendKnownHeader("Upgrade", "h2c")
+
+	s.crlf()
+
+	return s.flush()
+}
+
+func (s *serializer) Write(protocol proto.Protocol, r *http.Response) error {
+	resp := r.Expose()
+	request := s.request
+	response.Conditional(resp, request.Method, request.Headers)
+	response.Ranges(resp, request.Method, request.Headers.Value("range"), request.Headers.Value("if-range"))
+
+	compression, vary, acceptable := s.codecs.Compression(resp, request.AcceptEncoding, s.cfg.NET.SmallBody)
+	if !acceptable {
+		response.Reject(resp, status.NotAcceptable)
+	}
 
 	s.appendProtocol(protocol)
 	s.appendStatus(resp)
@@ -76,7 +98,11 @@ func (s *serializer) Write(protocol proto.Protocol, response *http.Response) err
 		s.appendCookie(c)
 	}
 
-	err := s.writeStream(resp)
+	if s.closing {
+		s.appendKnownHeader("Connection", "close")
+	}
+
+	err := s.writeStream(resp, compression, vary)
 	if err != nil {
 		return err
 	}
@@ -84,12 +110,16 @@ func (s *serializer) Write(protocol proto.Protocol, response *http.Response) err
 	return s.flush()
 }
 
-func (s *serializer) writeStream(resp *response.Fields) (err error) {
+func (s *serializer) writeStream(resp *response.Fields, compression string, vary bool) (err error) {
 	s.response = resp
 	stream, length := resp.Stream, resp.StreamSize
 	unsized := length == -1
 	if length == 0 {
-		s.appendKnownHeader("Content-Length", "0")
+		if resp.Code != status.NotModified {
+			// 304 responses would otherwise advertise the length of the omitted representation.
+			s.appendKnownHeader("Content-Length", "0")
+		}
+
 		s.crlf()
 		return nil
 	}
@@ -115,12 +145,13 @@ func (s *serializer) writeStream(resp *response.Fields) (err error) {
 
 	var encoder io.WriteCloser
 
-	compression := resp.ContentEncoding
-	if resp.AutoCompress && (unsized || length >= s.cfg.NET.SmallBody) {
-		// if the stream is sized and the size is below limit (i.e. is considered a small one),
-		// do not compress it. It won't give much gain anyway, yet the performance is impacted,
-		// especially if we otherwise could use a zero-copy mechanism
-		compression = s.request.PreferredEncoding()
+	if vary && !response.Varies(resp.Headers, "accept-encoding") {
+		s.appendKnownHeader("Vary", "Accept-Encoding")
+	}
+
+	if len(resp.Precompressed) > 0 {
+		// the stream is already compressed, so it can be transferred as is.
+		s.appendKnownHeader("Content-Encoding", resp.Precompressed)
 	}
 
 	compressor := s.getCompressor(compression)
@@ -135,7 +166,9 @@ func (s *serializer) writeStream(resp *response.Fields) (err error) {
 			s.appendKnownHeader("Transfer-Encoding", "chunked")
 		} else {
 			encoder = identityWriter{s}
-			s.appendKnownHeader("Connection", "close")
+			if !s.closing {
+				s.appendKnownHeader("Connection", "close")
+			}
 			closeConnection = true
 		}
 	} else {
@@ -169,6 +202,9 @@ func (s *serializer) writeStream(resp *response.Fields) (err error) {
 	if compressor != nil {
 		compressor.ResetCompressor(encoder)
 		encoder = compressor
+		if !resp.Buffered {
+			encoder = codecutil.Flushing(compressor)
+		}
 	}
 
 	defer func() {
@@ -177,13 +213,18 @@ func (s *serializer) writeStream(resp *response.Fields) (err error) {
 		}
 	}()
 
+	return s.pipe(encoder, stream)
+}
+
+// pipe copies the stream into the encoder, engaging io.ReaderFrom or io.WriterTo if possible.
+func (s *serializer) pipe(encoder io.Writer, stream io.Reader) error {
 	if rf, ok := encoder.(io.ReaderFrom); ok {
-		_, err = rf.ReadFrom(stream)
+		_, err := rf.ReadFrom(stream)
 		return err
 	}
 
 	if wt, ok := stream.(io.WriterTo); ok {
-		_, err = wt.WriteTo(encoder)
+		_, err := wt.WriteTo(encoder)
 		return err
 	}
 
@@ -307,6 +348,12 @@ func (s *serializer) appendHeaders(fields *response.Fields) {
 		s.crlf()
 	}
 
+	s.appendDefaultHeaders()
+}
+
+// appendDefaultHeaders writes all the default headers, which weren't excluded, and resets
+// the exclusions.
+func (s *serializer) appendDefaultHeaders() {
 	for i, header := range s.defaultHeaders {
 		if header.Excluded {
 			s.defaultHeaders[i].Excluded = false
@@ -334,61 +381,9 @@ func (s *serializer) appendKnownHeader(key, value string) {
 	s.crlf()
 }
 
-var zoneGMT = time.FixedZone("GMT", 0)
-
 func (s *serializer) appendCookie(c cookie.Cookie) {
 	s.buff = append(s.buff, "Set-Cookie: "...)
-	s.buff = append(s.buff, c.Name...)
-	s.buff = append(s.buff, '=')
-	s.buff = append(s.buff, c.Value...)
-	s.buff = append(s.buff, ';', ' ')
-
-	if len(c.Path) > 0 {
-		s.buff = append(s.buff, "Path="...)
-		s.buff = append(s.buff, c.Path...)
-		s.buff = append(s.buff, ';', ' ')
-	}
-
-	if len(c.Domain) > 0 {
-		s.buff = append(s.buff, "Domain="...)
-		s.buff = append(s.buff, c.Domain...)
-		s.buff = append(s.buff, ';', ' ')
-	}
-
-	if !c.Expires.IsZero() {
-		s.buff = append(s.buff, "Expires="...)
-		// TODO: this _may_ be slow. We could write it manually instead
-		s.buff = c.Expires.In(zoneGMT).AppendFormat(s.buff, time.RFC1123)
-		s.buff = append(s.buff, ';', ' ')
-	}
-
-	if c.MaxAge != 0 {
-		maxage := "0"
-		if c.MaxAge > 0 {
-			maxage = strconv.Itoa(c.MaxAge)
-		}
-
-		s.buff = append(s.buff, "MaxAge="...)
-		s.buff = append(s.buff, maxage...)
-		s.buff = append(s.buff, ';', ' ')
-	}
-
-	if len(c.SameSite) > 0 {
-		s.buff = append(s.buff, "SameSite="...)
-		s.buff = append(s.buff, c.SameSite...)
-		s.buff = append(s.buff, ';', ' ')
-	}
-
-	if c.Secure {
-		s.buff = append(s.buff, "Secure; "...)
-	}
-
-	if c.HttpOnly {
-		s.buff = append(s.buff, "HttpOnly; "...)
-	}
-
-	// strip last 2 bytes, which are always a semicolon and a space
-	s.buff = s.buff[:len(s.buff)-2]
+	s.buff = response.AppendCookie(s.buff, c)
 	s.crlf()
 }
 
@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	stdmime "mime"
+	"mime/multipart"
 	stdhttp "net/http"
 	"slices"
 	"strings"
@@ -18,6 +20,7 @@ import (
 	"github.com/indigo-web/indigo/http/method"
 	"github.com/indigo-web/indigo/http/mime"
 	"github.com/indigo-web/indigo/http/proto"
+	"github.com/indigo-web/indigo/http/status"
 	"github.com/indigo-web/indigo/internal/codecutil"
 	"github.com/indigo-web/indigo/internal/construct"
 	respfields "github.com/indigo-web/indigo/internal/response"
@@ -478,6 +481,20 @@ func TestSerializer(t *testing.T) {
 			testSized(t, "GET", len(helloworld), helloworld)
 		})
 
+		t.Run("precompressed", func(t *testing.T) {
+			w.Reset()
+			request.Method = method.GET
+			request.AcceptEncoding = []string{"gzip"}
+			compressed := string(encodeGZIP(helloworld))
+			resp := http.NewResponse().
+				Stream(strings.NewReader(compressed)).
+				Precompressed("gzip").
+				Compress()
+			require.NoError(t, s.Write(proto.HTTP11, resp))
+
+			testSized(t, "GET", len(compressed), compressed, "gzip")
+		})
+
 		t.Run("sized buffer growth", func(t *testing.T) {
 			writeResp := func(t *testing.T, resp *http.Response, buffsize int, cfg *config.Config) (*serializer, string) {
 				s, w := getSerializer(nil, newRequest(method.GET), noCodecs)
@@ -673,6 +690,304 @@ func TestSerializer(t *testing.T) {
 			testMIME(t, http.NewResponse().ContentType(mime.Unset, mime.Unset), mime.Unset)
 		})
 	})
+
+	t.Run("ranges", func(t *testing.T) {
+		const content = "Hello, world! Lorem ipsum dolor sit amet"
+		request := newRequest(method.GET)
+		s, w := getSerializer(nil, request, noCodecs)
+
+		write := func(t *testing.T, resp *http.Response, headers ...string) *stdhttp.Response {
+			w.Reset()
+			request.Headers.Clear()
+			for i := 0; i+1 < len(headers); i += 2 {
+				request.Headers.Add(headers[i], headers[i+1])
+			}
+
+			require.NoError(t, s.Write(proto.HTTP11, resp))
+			r, err := parseHTTP11Response(request.Method.String(), w.Written())
+			require.NoError(t, err)
+
+			return r
+		}
+
+		file := func() *http.Response {
+			return http.NewResponse().
+				ContentType(mime.Plain).
+				Header("ETag", `"v1"`).
+				Stream(strings.NewReader(content))
+		}
+
+		readBody := func(t *testing.T, r *stdhttp.Response) string {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			return string(body)
+		}
+
+		t.Run("no range", func(t *testing.T) {
+			r := write(t, file())
+			require.Equal(t, 200, r.StatusCode)
+			require.Equal(t, "bytes", r.Header.Get("Accept-Ranges"))
+			require.Equal(t, content, readBody(t, r))
+		})
+
+		t.Run("not seekable", func(t *testing.T) {
+			r := write(t, http.NewResponse().Stream(io.LimitReader(strings.NewReader(content), 100), int64(len(content))),
+				"Range", "bytes=0-4")
+			require.Equal(t, 200, r.StatusCode)
+			require.Empty(t, r.Header.Get("Accept-Ranges"))
+			require.Equal(t, content, readBody(t, r))
+		})
+
+		t.Run("single", func(t *testing.T) {
+			for _, tc := range []struct {
+				Range, ContentRange, Body string
+			}{
+				{"bytes=0-4", "bytes 0-4/40", "Hello"},
+				{"bytes=7-", "bytes 7-39/40", content[7:]},
+				{"bytes=-5", "bytes 35-39/40", " amet"},
+				{"bytes=35-1000", "bytes 35-39/40", " amet"},
+			} {
+				r := write(t, file(), "Range", tc.Range)
+				require.Equal(t, 206, r.StatusCode, tc.Range)
+				require.Equal(t, tc.ContentRange, r.Header.Get("Content-Range"))
+				require.Equal(t, tc.Body, readBody(t, r))
+			}
+		})
+
+		t.Run("multiple", func(t *testing.T) {
+			r := write(t, file(), "Range", "bytes=0-4, 7-11")
+			require.Equal(t, 206, r.StatusCode)
+			mediaType, params, err := stdmime.ParseMediaType(r.Header.Get("Content-Type"))
+			require.NoError(t, err)
+			require.Equal(t, "multipart/byteranges", mediaType)
+
+			reader := multipart.NewReader(r.Body, params["boundary"])
+			for _, want := range []struct{ ContentRange, Body string }{
+				{"bytes 0-4/40", "Hello"},
+				{"bytes 7-11/40", "world"},
+			} {
+				p, err := reader.NextPart()
+				require.NoError(t, err)
+				require.Equal(t, "text/plain", p.Header.Get("Content-Type"))
+				require.Equal(t, want.ContentRange, p.Header.Get("Content-Range"))
+				body, err := io.ReadAll(p)
+				require.NoError(t, err)
+				require.Equal(t, want.Body, string(body))
+			}
+
+			_, err = reader.NextPart()
+			require.ErrorIs(t, err, io.EOF)
+		})
+
+		t.Run("unsatisfiable", func(t *testing.T) {
+			r := write(t, file(), "Range", "bytes=40-")
+			require.Equal(t, 416, r.StatusCode)
+			require.Equal(t, "bytes */40", r.Header.Get("Content-Range"))
+			require.Empty(t, readBody(t, r))
+		})
+
+		t.Run("malformed", func(t *testing.T) {
+			for _, value := range []string{"bytes=5-1", "lines=0-4", "bytes=a-b", "bytes=0-4" + strings.Repeat(",0-1", 32)} {
+				r := write(t, file(), "Range", value)
+				require.Equal(t, 200, r.StatusCode, value)
+				require.Equal(t, content, readBody(t, r))
+			}
+		})
+
+		t.Run("If-Range", func(t *testing.T) {
+			r := write(t, file(), "Range", "bytes=0-4", "If-Range", `"v1"`)
+			require.Equal(t, 206, r.StatusCode)
+			require.Equal(t, "Hello", readBody(t, r))
+
+			r = write(t, file(), "Range", "bytes=0-4", "If-Range", `"v2"`)
+			require.Equal(t, 200, r.StatusCode)
+			require.Equal(t, content, readBody(t, r))
+
+			modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC1123)
+			resp := http.NewResponse().Header("Last-Modified", modified).Stream(strings.NewReader(content))
+			r = write(t, resp, "Range", "bytes=0-4", "If-Range", modified)
+			require.Equal(t, 206, r.StatusCode)
+		})
+
+		t.Run("HEAD", func(t *testing.T) {
+			request.Method = method.HEAD
+			defer func() {
+				request.Method = method.GET
+			}()
+
+			r := write(t, file(), "Range", "bytes=0-4")
+			require.Equal(t, 200, r.StatusCode)
+			require.Equal(t, "bytes", r.Header.Get("Accept-Ranges"))
+			require.Equal(t, int64(len(content)), r.ContentLength)
+		})
+	})
+
+	t.Run("compression policy", func(t *testing.T) {
+		request := newRequest(method.GET)
+		request.AcceptEncoding = []string{"gzip"}
+		codecs := codecutil.NewCache([]codec.Codec{codec.NewGZIP()}, "gzip").WithPolicy(codec.NewPolicy())
+		s, w := getSerializer(nil, request, codecs)
+		body := strings.Repeat("a", int(config.Default().NET.SmallBody))
+
+		write := func(t *testing.T, resp *http.Response) *stdhttp.Response {
+			w.Reset()
+			require.NoError(t, s.Write(proto.HTTP11, resp))
+			r, err := parseHTTP11Response("GET", w.Written())
+			require.NoError(t, err)
+			_, err = io.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			return r
+		}
+
+		t.Run("compressible", func(t *testing.T) {
+			r := write(t, http.NewResponse().ContentType(mime.HTML).String(body).Compress())
+			require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+			require.Equal(t, []string{"Accept-Encoding"}, r.Header["Vary"])
+		})
+
+		t.Run("incompressible", func(t *testing.T) {
+			r := write(t, http.NewResponse().ContentType(mime.PNG).String(body).Compress())
+			require.Empty(t, r.Header.Get("Content-Encoding"))
+			require.Empty(t, r.Header["Vary"])
+		})
+
+		t.Run("small", func(t *testing.T) {
+			r := write(t, http.NewResponse().ContentType(mime.HTML).String("small").Compress())
+			require.Empty(t, r.Header.Get("Content-Encoding"))
+			require.Empty(t, r.Header["Vary"])
+		})
+
+		t.Run("not acceptable", func(t *testing.T) {
+			request.AcceptEncoding = []string{"br", "identity;q=0"}
+			defer func() {
+				request.AcceptEncoding = []string{"gzip"}
+			}()
+
+			r := write(t, http.NewResponse().ContentType(mime.HTML).String(body).Compress())
+			require.Equal(t, 406, r.StatusCode)
+			require.Empty(t, r.Header.Get("Content-Encoding"))
+		})
+
+		t.Run("not acceptable error", func(t *testing.T) {
+			request.AcceptEncoding = []string{"br", "identity;q=0"}
+			defer func() {
+				request.AcceptEncoding = []string{"gzip"}
+			}()
+
+			resp := http.NewResponse().Code(status.NotFound).ContentType(mime.HTML).String(body).Compress()
+			r := write(t, resp)
+			require.Equal(t, 404, r.StatusCode)
+			require.Empty(t, r.Header.Get("Content-Encoding"))
+			require.Equal(t, int64(len(body)), r.ContentLength)
+		})
+
+		t.Run("not acceptable without body", func(t *testing.T) {
+			request.AcceptEncoding = []string{"br", "identity;q=0"}
+			defer func() {
+				request.AcceptEncoding = []string{"gzip"}
+			}()
+
+			r := write(t, http.NewResponse().Code(status.NoContent).Compress())
+			require.Equal(t, 204, r.StatusCode)
+			require.Empty(t, r.Header.Get("Content-Encoding"))
+		})
+
+		t.Run("unavailable codec", func(t *testing.T) {
+			request.AcceptEncoding = []string{"br", "gzip;q=0.5"}
+			defer func() {
+				request.AcceptEncoding = []string{"gzip"}
+			}()
+
+			r := write(t, http.NewResponse().ContentType(mime.HTML).String(body).Compress())
+			require.Equal(t, 200, r.StatusCode)
+			require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		})
+
+		t.Run("already varies", func(t *testing.T) {
+			resp := http.NewResponse().
+				ContentType(mime.HTML).
+				Header("Vary", "Origin, accept-encoding").
+				String(body).
+				Compress()
+			r := write(t, resp)
+			require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+			require.Equal(t, []string{"Origin, accept-encoding"}, r.Header["Vary"])
+		})
+	})
+
+	t.Run("conditional", func(t *testing.T) {
+		request := newRequest(method.GET)
+		s, w := getSerializer(nil, request, noCodecs)
+		modified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		write := func(t *testing.T, resp *http.Response, key, value string) (*stdhttp.Response, string) {
+			w.Reset()
+			request.Headers.Clear()
+			request.Headers.Add(key, value)
+			require.NoError(t, s.Write(proto.HTTP11, resp))
+
+			r, err := parseHTTP11Response(request.Method.String(), w.Written())
+			require.NoError(t, err)
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			return r, string(body)
+		}
+
+		t.Run("If-None-Match", func(t *testing.T) {
+			r, body := write(t, http.NewResponse().ETag("v1").String("hello"), "If-None-Match", `"v1"`)
+			require.Equal(t, 304, r.StatusCode)
+			require.Equal(t, `"v1"`, r.Header.Get("ETag"))
+			require.Empty(t, r.Header["Content-Length"])
+			require.Empty(t, body)
+
+			r, body = write(t, http.NewResponse().ETag("v2").String("hello"), "If-None-Match", `"v1"`)
+			require.Equal(t, 200, r.StatusCode)
+			require.Equal(t, "hello", body)
+		})
+
+		t.Run("custom status", func(t *testing.T) {
+			resp := http.NewResponse().Status("Fine").ETag("v1").String("hello")
+			r, _ := write(t, resp, "If-None-Match", `"v1"`)
+			require.Equal(t, "304 Not Modified", r.Status)
+		})
+
+		t.Run("If-Modified-Since", func(t *testing.T) {
+			date := modified.Format(time.RFC1123)
+			r, _ := write(t, http.NewResponse().LastModified(modified).String("hello"), "If-Modified-Since", date)
+			require.Equal(t, 304, r.StatusCode)
+			require.Equal(t, "Mon, 01 Jan 2024 12:00:00 GMT", r.Header.Get("Last-Modified"))
+
+			r, body := write(t, http.NewResponse().LastModified(modified.Add(time.Second)).String("hello"),
+				"If-Modified-Since", date)
+			require.Equal(t, 200, r.StatusCode)
+			require.Equal(t, "hello", body)
+		})
+
+		t.Run("If-Match", func(t *testing.T) {
+			r, body := write(t, http.NewResponse().ETag("v1").String("hello"), "If-Match", `"v0"`)
+			require.Equal(t, 412, r.StatusCode)
+			require.Empty(t, body)
+		})
+
+		t.Run("hashed", func(t *testing.T) {
+			r, _ := write(t, http.NewResponse().HashETag().String("hello"), "X-Nothing", "")
+			etag := r.Header.Get("ETag")
+			require.NotEmpty(t, etag)
+
+			r, _ = write(t, http.NewResponse().HashETag().String("hello"), "If-None-Match", etag)
+			require.Equal(t, 304, r.StatusCode)
+
+			r, _ = write(t, http.NewResponse().HashETag().String("world"), "If-None-Match", etag)
+			require.Equal(t, 200, r.StatusCode)
+		})
+
+		t.Run("non-2xx", func(t *testing.T) {
+			r, _ := write(t, http.NewResponse().Code(404).ETag("v1"), "If-None-Match", `"v1"`)
+			require.Equal(t, 404, r.StatusCode)
+		})
+	})
 }
 
 func parseHTTP11Response(method string, data []byte) (*stdhttp.Response, error) {
@@ -1,6 +1,8 @@
 package http1
 
 import (
+	"context"
+
 	"github.com/indigo-web/indigo/config"
 	"github.com/indigo-web/indigo/http"
 	"github.com/indigo-web/indigo/http/proto"
@@ -8,6 +10,7 @@ import (
 	"github.com/indigo-web/indigo/internal/buffer"
 	"github.com/indigo-web/indigo/internal/codecutil"
 	"github.com/indigo-web/indigo/internal/construct"
+	"github.com/indigo-web/indigo/internal/reqctx"
 	"github.com/indigo-web/indigo/internal/strutil"
 	"github.com/indigo-web/indigo/router"
 	"github.com/indigo-web/indigo/transport"
@@ -17,36 +20,56 @@ type Suit struct {
 	*Parser
 	*body
 	*serializer
-	router router.Router
-	client transport.Client
-	codecs codecutil.Cache
+	router  router.Router
+	client  transport.Client
+	tracker *transport.Tracker
+	codecs  codecutil.Cache
+	limiter *codecutil.Limiter
+	// base is cancelled once the server is stopping.
+	base context.Context
+	ctx  *reqctx.Context
+	// upgrade is the protocol the connection was switched to, which is served by a
+	// different suit.
+	upgrade proto.Protocol
 }
 
 func newSuit(
+	ctx context.Context,
 	cfg *config.Config,
 	r router.Router,
 	request *http.Request,
 	client transport.Client,
+	tracker *transport.Tracker,
 	body *body,
 	codecs codecutil.Cache,
 	statusBuff, headersBuff *buffer.Buffer,
 	respBuff []byte,
 ) *Suit {
-	return &Suit{
+	s := &Suit{
 		Parser:     NewParser(cfg, request, statusBuff, headersBuff),
 		body:       body,
 		serializer: newSerializer(cfg, request, client, codecs, respBuff),
 		router:     r,
 		client:     client,
+		tracker:    tracker,
 		codecs:     codecs,
+		limiter:    codecs.Limiter(cfg.Body.Decompression),
+		base:       ctx,
+		ctx:        reqctx.New(ctx),
 	}
+	s.ctx.OnWatch(s.watch)
+
+	return s
 }
 
-// New instantiates an HTTP/1 protocol suit.
+// New instantiates an HTTP/1 protocol suit. Request contexts are derived from the ctx. The tracker
+// may be nil.
 func New(
+	ctx context.Context,
 	cfg *config.Config,
 	r router.Router,
 	client transport.Client,
+	tracker *transport.Tracker,
 	request *http.Request,
 	codecs codecutil.Cache,
 ) *Suit {
@@ -54,20 +77,54 @@ func New(
 	respBuff := make([]byte, 0, cfg.NET.WriteBufferSize.Default)
 	b := newBody(client, cfg.Body)
 
-	return newSuit(cfg, r, request, client, b, codecs, statusBuff, headersBuff, respBuff)
+	return newSuit(ctx, cfg, r, request, client, tracker, b, codecs, statusBuff, headersBuff, respBuff)
 }
 
 func (s *Suit) ServeOnce() (ok bool) {
 	return s.serve(true)
 }
 
-func (s *Suit) Serve() {
+// Serve serves the connection until it is closed or upgraded to a protocol, which must be served
+// by a different suit. In the latter case, the protocol is returned, otherwise proto.Unknown.
+func (s *Suit) Serve() proto.Protocol {
+	stop := context.AfterFunc(s.base, func() {
+		s.ctx.Close(context.Canceled)
+	})
 	s.serve(false)
+	stop()
+
+	if s.upgrade == proto.Unknown {
+		s.ctx.Close(context.Canceled)
+	}
+
+	return s.upgrade
+}
+
+// watch starts watching the connection for the peer going away. It is possible only if there's
+// no more data expected from the client, as otherwise it'd be consumed.
+func (s *Suit) watch() {
+	if !s.body.done {
+		return
+	}
+
+	if w, ok := s.client.(watcher); ok {
+		w.Watch(s.disconnected)
+	}
+}
+
+func (s *Suit) disconnected() {
+	s.ctx.Cancel(context.Canceled)
+}
+
+type watcher interface {
+	Watch(cb func()) bool
 }
 
 func (s *Suit) serve(once bool) (ok bool) {
 	client := s.client
 	request := s.Parser.request
+	// the connection is considered idle until the first request arrives.
+	idle := true
 
 	for {
 		data, err := client.Read()
@@ -78,6 +135,11 @@ func (s *Suit) serve(once bool) (ok bool) {
 			return false
 		}
 
+		if idle {
+			s.tracker.Active()
+			idle = false
+		}
+
 		done, extra, err := s.Parse(data)
 		if err != nil {
 			resp := respond(request, s.router.OnError(request, err))
@@ -95,7 +157,22 @@ func (s *Suit) serve(once bool) (ok bool) {
 
 		client.Pushback(extra)
 		request.Body.Reset(request)
+		// the decoders of the previous request, if any, must not be stacked up.
+		request.Body.Fetcher = s.body
 		s.body.Reset(request)
+		s.ctx.Reset(s.Parser.cfg.NET.RequestTimeout)
+		request.Ctx = s.ctx
+
+		if isH2CUpgrade(request) {
+			if err = s.UpgradeH2C(); err != nil {
+				s.router.OnError(request, status.ErrCloseConnection)
+				return false
+			}
+
+			// the request is going to be served by the HTTP/2 suit as the stream 1.
+			s.upgrade = proto.HTTP2
+			return false
+		}
 
 		transferEncoding := request.TransferEncoding
 		if !validateTransferEncodingTokens(transferEncoding) {
@@ -104,18 +181,9 @@ func (s *Suit) serve(once bool) (ok bool) {
 			return false
 		}
 
-		if len(transferEncoding) > 0 {
-			// get rid of the trailing chunked encoding as it is already built-in.
-			if err = s.applyDecoders(transferEncoding[:len(transferEncoding)-1]); err != nil {
-				// even if the connection is going to be upgraded in advance, the error happened with the
-				// request prior to upgrade.
-				resp := respond(request, s.router.OnError(request, err))
-				_ = s.Write(request.Protocol, resp)
-				return false
-			}
-		}
-
-		if err = s.applyDecoders(request.ContentEncoding); err != nil {
+		if err = s.applyDecoders(request); err != nil {
+			// even if the connection is going to be upgraded in advance, the error happened with the
+			// request prior to upgrade.
 			resp := respond(request, s.router.OnError(request, err))
 			_ = s.Write(request.Protocol, resp)
 			return false
@@ -131,10 +199,23 @@ func (s *Suit) serve(once bool) (ok bool) {
 
 		if request.Hijacked() {
 			// in case the connection was hijacked, we must not intrude after, so fail fast
+			request.Body.Cleanup()
 			return false
 		}
 
-		if err = s.Write(version, resp); err != nil {
+		if err = s.limiter.Err(); err != nil {
+			// the body turned out to be a decompression bomb, so whatever the handler responded,
+			// the error goes through the error handler.
+			resp = respond(request, s.router.OnError(request, err))
+		}
+
+		// the server is shutting down, so let the client know the connection is going
+		// to be closed.
+		s.serializer.closing = s.tracker.Draining()
+
+		err = s.Write(version, resp)
+		request.Body.Cleanup()
+		if err != nil {
 			// considering any write errors could occur due to broken connection, it makes
 			// thereby no sense to try to write any error back. Moreover, there could be an
 			// already sent data, which would overlay and result in a complete mess at the
@@ -149,7 +230,7 @@ func (s *Suit) serve(once bool) (ok bool) {
 			return false
 		}
 
-		if !isKeepAlive(version, request) {
+		if s.serializer.closing || !isKeepAlive(version, request) {
 			s.router.OnError(request, status.ErrCloseConnection)
 			return true
 		}
@@ -159,6 +240,11 @@ func (s *Suit) serve(once bool) (ok bool) {
 		}
 
 		request.Reset()
+
+		if idle = s.tracker.Idle(); !idle {
+			s.router.OnError(request, status.ErrCloseConnection)
+			return false
+		}
 	}
 }
 
@@ -176,6 +262,15 @@ func isKeepAlive(protocol proto.Protocol, req *http.Request) bool {
 	}
 }
 
+// isH2CUpgrade tells whether the request asks for an upgrade to the cleartext HTTP/2. Requests
+// carrying a body aren't upgraded, as it would have to be read beforehand.
+func isH2CUpgrade(request *http.Request) bool {
+	return request.Upgrade == proto.HTTP2 &&
+		request.Env.Encryption == 0 &&
+		request.ContentLength == 0 && !request.Chunked &&
+		request.Headers.Has("HTTP2-Settings")
+}
+
 func validateTransferEncodingTokens(tokens []string) bool {
 	if len(tokens) == 0 {
 		return true
@@ -190,21 +285,50 @@ func validateTransferEncodingTokens(tokens []string) bool {
 	return tokens[len(tokens)-1] == "chunked"
 }
 
-func (s *Suit) applyDecoders(tokens []string) error {
-	request := s.Parser.request
-	bufferSize := s.Parser.cfg.NET.ReadBufferSize
+// applyDecoders wraps the request body into the decompressors of the transfer and content
+// codings, limited by the decompression limits.
+func (s *Suit) applyDecoders(request *http.Request) error {
+	s.limiter.Reset()
+
+	transferEncoding := request.TransferEncoding
+	if len(transferEncoding) > 0 {
+		// get rid of the trailing chunked encoding as it is already built-in.
+		transferEncoding = transferEncoding[:len(transferEncoding)-1]
+	}
+
+	if len(transferEncoding) == 0 && len(request.ContentEncoding) == 0 {
+		return nil
+	}
+
+	body, bufferSize := request.Body, s.Parser.cfg.NET.ReadBufferSize
+	body.Fetcher = s.limiter.Source(body.Fetcher)
+
+	// transfer codings are applied on top of the content ones, so they're stripped first.
+	if err := applyDecoders(body, s.codecs, transferEncoding, bufferSize); err != nil {
+		return err
+	}
+
+	if err := applyDecoders(body, s.codecs, request.ContentEncoding, bufferSize); err != nil {
+		return err
+	}
+
+	body.Fetcher = s.limiter.Limit(body.Fetcher)
+	return nil
+}
 
+// applyDecoders wraps the body fetcher into decompressors in reverse order of the tokens.
+func applyDecoders(body *http.Body, codecs codecutil.Cache, tokens []string, bufferSize int) error {
 	for i := len(tokens); i > 0; i-- {
-		c := s.codecs.Get(tokens[i-1])
+		c := codecs.Get(tokens[i-1])
 		if c == nil {
 			return status.ErrUnsupportedEncoding
 		}
 
-		if err := c.ResetDecompressor(request.Body.Fetcher, bufferSize); err != nil {
+		if err := c.ResetDecompressor(body.Fetcher, bufferSize); err != nil {
 			return status.ErrInternalServerError
 		}
 
-		request.Body.Fetcher = c
+		body.Fetcher = c
 	}
 
 	return nil
@@ -2,6 +2,7 @@ package http1
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http/httputil"
@@ -164,7 +165,7 @@ func getSuit(client transport.Client, codecs ...codec.Codec) (*Suit, *http.Reque
 	cfg := config.Default()
 	r := getInbuiltRouter()
 	req := construct.Request(cfg, client)
-	suit := New(cfg, r, client, req, codecutil.NewCache(codecs, codecutil.AcceptEncoding(codecs)))
+	suit := New(context.Background(), cfg, r, client, nil, req, codecutil.NewCache(codecs, codecutil.AcceptEncoding(codecs)))
 	req.Body = http.NewBody(suit)
 
 	return suit, req
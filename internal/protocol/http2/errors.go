@@ -0,0 +1,69 @@
+package http2
+
+import "strconv"
+
+type errCode uint32
+
+const (
+	errNo errCode = iota
+	errProtocol
+	errInternal
+	errFlowControl
+	errSettingsTimeout
+	errStreamClosed
+	errFrameSize
+	errRefusedStream
+	errCancel
+	errCompression
+	errConnect
+	errEnhanceYourCalm
+	errInadequateSecurity
+	errHTTP11Required
+)
+
+func (e errCode) String() string {
+	lut := [...]string{
+		errNo:                 "NO_ERROR",
+		errProtocol:           "PROTOCOL_ERROR",
+		errInternal:           "INTERNAL_ERROR",
+		errFlowControl:        "FLOW_CONTROL_ERROR",
+		errSettingsTimeout:    "SETTINGS_TIMEOUT",
+		errStreamClosed:       "STREAM_CLOSED",
+		errFrameSize:          "FRAME_SIZE_ERROR",
+		errRefusedStream:      "REFUSED_STREAM",
+		errCancel:             "CANCEL",
+		errCompression:        "COMPRESSION_ERROR",
+		errConnect:            "CONNECT_ERROR",
+		errEnhanceYourCalm:    "ENHANCE_YOUR_CALM",
+		errInadequateSecurity: "INADEQUATE_SECURITY",
+		errHTTP11Required:     "HTTP_1_1_REQUIRED",
+	}
+
+	if int(e) >= len(lut) {
+		return "UNKNOWN_ERROR(" + strconv.FormatUint(uint64(e), 10) + ")"
+	}
+
+	return lut[e]
+}
+
+// connError is a connection error. It results in a GOAWAY frame being sent and the
+// connection being closed.
+type connError struct {
+	Code   errCode
+	Reason string
+}
+
+func (c connError) Error() string {
+	return "http2: connection error: " + c.Code.String() + ": " + c.Reason
+}
+
+// streamError is a stream error. It affects a single stream only, which is reset
+// via a RST_STREAM frame.
+type streamError struct {
+	StreamID uint32
+	Code     errCode
+}
+
+func (s streamError) Error() string {
+	return "http2: stream " + strconv.FormatUint(uint64(s.StreamID), 10) + " error: " + s.Code.String()
+}
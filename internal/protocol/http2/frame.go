@@ -0,0 +1,87 @@
+package http2
+
+import (
+	"encoding/binary"
+)
+
+// Preface is the client connection preface, which must be the very first data sent by a client.
+const Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+type frameType uint8
+
+const (
+	frameData frameType = iota
+	frameHeaders
+	framePriority
+	frameRSTStream
+	frameSettings
+	framePushPromise
+	framePing
+	frameGoAway
+	frameWindowUpdate
+	frameContinuation
+)
+
+const (
+	flagEndStream  uint8 = 0x1
+	flagAck        uint8 = 0x1
+	flagEndHeaders uint8 = 0x4
+	flagPadded     uint8 = 0x8
+	flagPriority   uint8 = 0x20
+)
+
+const (
+	frameHeaderLen = 9
+	// priorityLen is the length of the stream dependency and weight fields which might
+	// appear in HEADERS frames.
+	priorityLen = 5
+	// streamIDMask strips the reserved bit of stream identifiers.
+	streamIDMask = 1<<31 - 1
+)
+
+type frameHeader struct {
+	Length   uint32
+	Type     frameType
+	Flags    uint8
+	StreamID uint32
+}
+
+func (f frameHeader) Has(flag uint8) bool {
+	return f.Flags&flag != 0
+}
+
+func parseFrameHeader(b []byte) frameHeader {
+	_ = b[frameHeaderLen-1]
+
+	return frameHeader{
+		Length:   uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]),
+		Type:     frameType(b[3]),
+		Flags:    b[4],
+		StreamID: binary.BigEndian.Uint32(b[5:]) & streamIDMask,
+	}
+}
+
+func appendFrameHeader(b []byte, hdr frameHeader) []byte {
+	b = append(b, byte(hdr.Length>>16), byte(hdr.Length>>8), byte(hdr.Length), byte(hdr.Type), hdr.Flags)
+	return binary.BigEndian.AppendUint32(b, hdr.StreamID&streamIDMask)
+}
+
+// stripPadding removes the padding from payloads of frames with the PADDED flag set. Note that
+// the padding is still accounted by the flow control, so the frame length must be used for it.
+func stripPadding(hdr frameHeader, payload []byte) (data []byte, err error) {
+	if !hdr.Has(flagPadded) {
+		return payload, nil
+	}
+
+	if len(payload) == 0 {
+		return nil, connError{errProtocol, "missing padding length"}
+	}
+
+	padding := int(payload[0])
+	payload = payload[1:]
+	if padding > len(payload) {
+		return nil, connError{errProtocol, "padding exceeds the frame payload"}
+	}
+
+	return payload[:len(payload)-padding], nil
+}
@@ -0,0 +1,90 @@
+package http2
+
+import (
+	"github.com/indigo-web/indigo/transport"
+)
+
+// framer splits the incoming data stream into frames. Frames, which are entirely contained in
+// a single read, are returned without copying. Otherwise, they are accumulated in the internal
+// buffer. In both cases the returned payload is valid only until the next call.
+type framer struct {
+	client       transport.Client
+	buff         []byte
+	maxFrameSize uint32
+	// partial tells whether the buffer holds an incomplete frame, left from an interrupted read.
+	partial bool
+}
+
+func newFramer(client transport.Client, maxFrameSize uint32) *framer {
+	return &framer{
+		client:       client,
+		maxFrameSize: maxFrameSize,
+	}
+}
+
+// Preface consumes the client connection preface.
+func (f *framer) Preface() error {
+	var received int
+
+	for received < len(Preface) {
+		data, err := f.client.Read()
+		if err != nil {
+			return err
+		}
+
+		n := min(len(data), len(Preface)-received)
+		if string(data[:n]) != Preface[received:received+n] {
+			return connError{errProtocol, "invalid connection preface"}
+		}
+
+		received += n
+		f.client.Pushback(data[n:])
+	}
+
+	return nil
+}
+
+// Next returns the next frame. If reading is interrupted by an error, e.g. a timeout, the
+// incomplete frame is kept, so the call can be safely retried.
+func (f *framer) Next() (hdr frameHeader, payload []byte, err error) {
+	if !f.partial {
+		f.buff = f.buff[:0]
+	}
+
+	for {
+		data, err := f.client.Read()
+		if err != nil {
+			f.partial = len(f.buff) > 0
+			return hdr, nil, err
+		}
+
+		if len(f.buff) == 0 && len(data) >= frameHeaderLen {
+			// fast path: the frame is probably entirely in the chunk.
+			hdr = parseFrameHeader(data)
+			if hdr.Length > f.maxFrameSize {
+				return hdr, nil, connError{errFrameSize, "frame exceeds SETTINGS_MAX_FRAME_SIZE"}
+			}
+
+			if end := frameHeaderLen + int(hdr.Length); len(data) >= end {
+				f.client.Pushback(data[end:])
+				return hdr, data[frameHeaderLen:end], nil
+			}
+		}
+
+		f.buff = append(f.buff, data...)
+		if len(f.buff) < frameHeaderLen {
+			continue
+		}
+
+		hdr = parseFrameHeader(f.buff)
+		if hdr.Length > f.maxFrameSize {
+			return hdr, nil, connError{errFrameSize, "frame exceeds SETTINGS_MAX_FRAME_SIZE"}
+		}
+
+		if end := frameHeaderLen + int(hdr.Length); len(f.buff) >= end {
+			f.client.Pushback(f.buff[end:])
+			f.partial = false
+			return hdr, f.buff[frameHeaderLen:end], nil
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package http2
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/flrdv/uf"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/hexconv"
+	"github.com/indigo-web/indigo/internal/strutil"
+	"golang.org/x/net/http2/hpack"
+)
+
+// errMalformed signalizes a malformed request, which must be treated as a stream error of
+// type PROTOCOL_ERROR (RFC 9113, 8.1.1).
+var errMalformed = streamError{Code: errProtocol}
+
+// buildRequest fills the request from the decoded header block. Malformed requests result in
+// errMalformed, whereas semantically invalid ones, which should be processed by an error
+// handler, are returned as regular errors.
+func (st *stream) buildRequest(fields []hpack.HeaderField) error {
+	var (
+		request   = st.request
+		headersNo int
+		scheme    bool
+		path      string
+		authority string
+		regular   bool
+		err       error
+	)
+
+	request.Method = method.Unknown
+	request.ContentLength = 0
+	st.expectLength = -1
+
+	for _, field := range fields {
+		name, value := field.Name, field.Value
+
+		if len(name) > 0 && name[0] == ':' {
+			if regular {
+				// all pseudo-headers must precede regular header fields
+				return errMalformed
+			}
+
+			switch name {
+			case ":method":
+				if request.Method != method.Unknown || len(value) == 0 {
+					return errMalformed
+				}
+
+				if request.Method = method.Parse(value); request.Method == method.Unknown && err == nil {
+					err = status.ErrMethodNotImplemented
+				}
+			case ":scheme":
+				if scheme {
+					return errMalformed
+				}
+
+				scheme = true
+			case ":path":
+				if len(path) > 0 || len(value) == 0 {
+					return errMalformed
+				}
+
+				path = value
+			case ":authority":
+				if len(authority) > 0 {
+					return errMalformed
+				}
+
+				authority = value
+			default:
+				return errMalformed
+			}
+
+			continue
+		}
+
+		regular = true
+		if !validFieldName(name) {
+			return errMalformed
+		}
+
+		switch name {
+		case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+			return errMalformed
+		case "te":
+			if value != "trailers" {
+				return errMalformed
+			}
+		}
+
+		if headersNo++; headersNo > st.suit.cfg.Headers.Number.Maximal && err == nil {
+			err = status.ErrTooManyHeaders
+		}
+
+		request.Headers.Add(name, value)
+
+		switch name {
+		case "content-length":
+			length, perr := strconv.ParseUint(value, 10, 63)
+			if perr != nil || st.expectLength != -1 && st.expectLength != int64(length) {
+				return errMalformed
+			}
+
+			st.expectLength = int64(length)
+			request.ContentLength = int(length)
+		case "content-type":
+			request.ContentType = value
+		case "accept-encoding":
+			var terr error
+			st.acceptEncodings, request.AcceptEncoding, terr = splitTokens(st.acceptEncodings, value)
+			if terr != nil && err == nil {
+				err = terr
+			}
+		case "content-encoding":
+			var terr error
+			st.encodings, request.ContentEncoding, terr = splitTokens(st.encodings, value)
+			if terr != nil && err == nil {
+				err = terr
+			}
+		}
+	}
+
+	if request.Method == method.Unknown && err == nil {
+		// the method pseudo-header was never met.
+		return errMalformed
+	}
+
+	if request.Method == method.CONNECT {
+		// the CONNECT request must consist only of :method and :authority pseudo-headers.
+		if scheme || len(path) > 0 || len(authority) == 0 {
+			return errMalformed
+		}
+
+		request.Path = authority
+	} else {
+		if !scheme || len(path) == 0 {
+			return errMalformed
+		}
+
+		if perr := parsePath(request, path); perr != nil && err == nil {
+			err = perr
+		}
+	}
+
+	if len(authority) > 0 && !request.Headers.Has("host") {
+		request.Headers.Add("host", authority)
+	}
+
+	// the length of the body is unknown in advance unless content-length is provided. The
+	// stream can be also already closed, meaning there's no body at all.
+	request.Chunked = st.expectLength == -1 && !st.remoteClosed
+
+	return err
+}
+
+// parsePath splits the path and query parts, decoding both of them.
+func parsePath(request *http.Request, raw string) error {
+	if raw == "*" {
+		if request.Method != method.OPTIONS {
+			return status.ErrBadRequest
+		}
+
+		request.Path = raw
+		return nil
+	}
+
+	if raw[0] != '/' {
+		return status.ErrBadRequest
+	}
+
+	path, query, _ := strings.Cut(raw, "?")
+	for i := 0; i < len(path); i++ {
+		if path[i] == '#' || strutil.IsASCIINonprintable(path[i]) {
+			return status.ErrBadRequest
+		}
+	}
+
+	decoded, ok := strutil.URLDecode(path)
+	if !ok {
+		return status.ErrURLDecoding
+	}
+
+	request.Path = decoded
+
+	for len(query) > 0 {
+		var pair string
+		pair, query, _ = strings.Cut(query, "&")
+		if len(pair) == 0 {
+			continue
+		}
+
+		key, value, _ := strings.Cut(pair, "=")
+		if key, ok = unescapeQuery(key); !ok || len(key) == 0 {
+			return status.ErrBadParams
+		}
+
+		if value, ok = unescapeQuery(value); !ok {
+			return status.ErrBadParams
+		}
+
+		request.Params.Add(key, value)
+	}
+
+	return nil
+}
+
+// unescapeQuery decodes a query component. The string is returned as is, if there's nothing
+// to decode.
+func unescapeQuery(s string) (string, bool) {
+	if strings.IndexByte(s, '%') == -1 && strings.IndexByte(s, '+') == -1 {
+		return s, !containsNonprintable(s)
+	}
+
+	buff := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '+':
+			buff = append(buff, ' ')
+		case '%':
+			if i+2 >= len(s) {
+				return "", false
+			}
+
+			x, y := hexconv.Halfbyte[s[i+1]], hexconv.Halfbyte[s[i+2]]
+			if x|y == 0xFF {
+				return "", false
+			}
+
+			buff = append(buff, x<<4|y)
+			i += 2
+		default:
+			if strutil.IsASCIINonprintable(c) {
+				return "", false
+			}
+
+			buff = append(buff, c)
+		}
+	}
+
+	return uf.B2S(buff), true
+}
+
+func containsNonprintable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if strutil.IsASCIINonprintable(s[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validFieldName reports whether the field name is a non-empty lowercase token.
+func validFieldName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c >= 'A' && c <= 'Z' || c <= ' ' || c >= 0x7f || c == ':' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitTokens splits a comma-separated list of coding tokens, dropping qualifiers and
+// identity tokens. The tokens are appended to buff, which capacity is never exceeded.
+func splitTokens(buff []string, value string) (alteredBuff, toks []string, err error) {
+	offset := len(buff)
+
+	for len(value) > 0 {
+		var token string
+		token, value, _ = strings.Cut(value, ",")
+		token, _, _ = strings.Cut(token, ";")
+		token = strings.TrimSpace(token)
+		if len(token) == 0 {
+			return buff, nil, status.ErrUnsupportedEncoding
+		}
+
+		if len(buff) >= cap(buff) {
+			return buff, nil, status.ErrTooManyEncodingTokens
+		}
+
+		if strutil.CmpFoldFast(token, "identity") {
+			continue
+		}
+
+		buff = append(buff, token)
+	}
+
+	return buff, buff[offset:], nil
+}
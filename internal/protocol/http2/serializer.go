@@ -0,0 +1,290 @@
+package http2
+
+import (
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/codecutil"
+	"github.com/indigo-web/indigo/internal/response"
+	"github.com/indigo-web/indigo/internal/strutil"
+	"github.com/indigo-web/indigo/kv"
+	"golang.org/x/net/http2/hpack"
+)
+
+// write sends the response as a HEADERS frame, followed by DATA frames, if there's a body.
+func (st *stream) write(resp *http.Response) (err error) {
+	var (
+		s       = st.suit
+		request = st.request
+		fields  = resp.Expose()
+	)
+
+	response.Conditional(fields, request.Method, request.Headers)
+	response.Ranges(fields, request.Method, request.Headers.Value("range"), request.Headers.Value("if-range"))
+
+	compression, vary, acceptable := st.codecs.Compression(fields, request.AcceptEncoding, s.cfg.NET.SmallBody)
+	if !acceptable {
+		response.Reject(fields, status.NotAcceptable)
+	}
+
+	stream, length := fields.Stream, fields.StreamSize
+
+	if length != 0 && stream == nil {
+		return status.ErrInternalServerError
+	}
+
+	if c, ok := stream.(io.Closer); ok {
+		defer func() {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+	}
+
+	var compressor codec.Compressor
+	if compression != "" && compression != "identity" {
+		compressor = st.codecs.Get(compression)
+	}
+
+	st.fields = st.appendHeaders(st.fields[:0], fields)
+	if vary && !response.Varies(fields.Headers, "accept-encoding") {
+		st.fields = append(st.fields, hpack.HeaderField{Name: "vary", Value: "Accept-Encoding"})
+	}
+
+	if len(fields.Precompressed) > 0 && length != 0 {
+		st.fields = append(st.fields, hpack.HeaderField{Name: "content-encoding", Value: fields.Precompressed})
+	}
+
+	if compressor != nil {
+		st.fields = append(st.fields, hpack.HeaderField{Name: "content-encoding", Value: compression})
+	} else if length >= 0 && fields.Code != status.NotModified {
+		st.fields = append(st.fields, hpack.HeaderField{
+			Name:  "content-length",
+			Value: strconv.FormatInt(length, 10),
+		})
+	}
+
+	for _, c := range fields.Cookies {
+		st.cookieBuff = response.AppendCookie(st.cookieBuff[:0], c)
+		st.fields = append(st.fields, hpack.HeaderField{Name: "set-cookie", Value: string(st.cookieBuff)})
+	}
+
+	endStream := length == 0 || request.Method == method.HEAD
+	if err = s.writer.Headers(st.id, st.fields, endStream, endStream || !fields.Buffered); err != nil {
+		return err
+	}
+
+	if endStream {
+		return nil
+	}
+
+	st.data.Reset(!fields.Buffered)
+	var encoder io.WriteCloser = &st.data
+	if compressor != nil {
+		compressor.ResetCompressor(encoder)
+		encoder = compressor
+		if !fields.Buffered {
+			encoder = codecutil.Flushing(compressor)
+		}
+	}
+
+	src := stream
+	if length > 0 && compressor == nil {
+		// don't let the stream send more data than promised in the content-length.
+		st.limited = io.LimitedReader{R: stream, N: length}
+		src = &st.limited
+	}
+
+	if st.readBuff == nil {
+		st.readBuff = make([]byte, s.cfg.NET.WriteBufferSize.Default)
+	}
+
+	n, err := io.CopyBuffer(encoder, src, st.readBuff)
+	if err != nil {
+		return err
+	}
+
+	if length > 0 && compressor == nil && n < length {
+		// the stream is exhausted before it must have been.
+		return status.ErrInternalServerError
+	}
+
+	return encoder.Close()
+}
+
+func (st *stream) appendHeaders(buff []hpack.HeaderField, fields *response.Fields) []hpack.HeaderField {
+	code := status.StringCode(fields.Code)
+	if len(code) == 0 {
+		code = strconv.Itoa(int(fields.Code))
+	}
+
+	buff = append(buff, hpack.HeaderField{Name: ":status", Value: code})
+
+	for _, header := range fields.Headers {
+		name := strings.ToLower(header.Key)
+		if connectionSpecific(name) || name == "content-length" {
+			continue
+		}
+
+		value := header.Value
+		if name == "content-type" && fields.Charset != mime.Unset {
+			value += "; charset=" + string(fields.Charset)
+		}
+
+		buff = append(buff, hpack.HeaderField{Name: name, Value: value})
+	}
+
+	for _, header := range st.suit.defaults {
+		if !containsHeader(fields.Headers, header.Name) {
+			buff = append(buff, header)
+		}
+	}
+
+	return buff
+}
+
+// connectionSpecific tells whether the header is prohibited in HTTP/2 (RFC 9113, 8.2.2).
+func connectionSpecific(name string) bool {
+	switch name {
+	case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+		return true
+	default:
+		return false
+	}
+}
+
+func containsHeader(headers []kv.Pair, name string) bool {
+	for _, header := range headers {
+		if strutil.CmpFoldFast(header.Key, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// defaultHeaders prepares the default response headers, sorted by their names.
+func defaultHeaders(m map[string]string, acceptEncoding string) []hpack.HeaderField {
+	headers := make([]hpack.HeaderField, 0, len(m)+1)
+	headers = append(headers, hpack.HeaderField{Name: "accept-encoding", Value: acceptEncoding})
+
+	for key, value := range m {
+		name := strings.ToLower(key)
+		if connectionSpecific(name) {
+			continue
+		}
+
+		headers = append(headers, hpack.HeaderField{Name: name, Value: value})
+	}
+
+	slices.SortFunc(headers, func(a, b hpack.HeaderField) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return headers
+}
+
+// dataWriter splits the response body into DATA frames, respecting the flow control. Unless
+// unbuffered, frames are filled up as much as possible.
+type dataWriter struct {
+	st         *stream
+	buff       []byte
+	unbuffered bool
+}
+
+func (d *dataWriter) Reset(unbuffered bool) {
+	if d.buff == nil {
+		d.buff = make([]byte, 0, defaultMaxFrameSize)
+	}
+
+	d.buff = d.buff[:0]
+	d.unbuffered = unbuffered
+}
+
+func (d *dataWriter) ReadFrom(r io.Reader) (total int64, err error) {
+	for {
+		n, err := r.Read(d.buff[len(d.buff):cap(d.buff)])
+		d.buff = d.buff[:len(d.buff)+n]
+		total += int64(n)
+
+		if len(d.buff) == cap(d.buff) || d.unbuffered && n > 0 {
+			if ferr := d.flush(false); ferr != nil {
+				return total, ferr
+			}
+		}
+
+		switch err {
+		case nil:
+		case io.EOF:
+			return total, nil
+		default:
+			return total, err
+		}
+	}
+}
+
+func (d *dataWriter) Write(p []byte) (n int, err error) {
+	for n < len(p) {
+		copied := copy(d.buff[len(d.buff):cap(d.buff)], p[n:])
+		d.buff = d.buff[:len(d.buff)+copied]
+		n += copied
+
+		if len(d.buff) == cap(d.buff) {
+			if err = d.flush(false); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	if d.unbuffered && len(d.buff) > 0 {
+		err = d.flush(false)
+	}
+
+	return n, err
+}
+
+// Close sends the rest of the data, ending the stream.
+func (d *dataWriter) Close() error {
+	return d.flush(true)
+}
+
+func (d *dataWriter) flush(endStream bool) error {
+	var (
+		st     = d.st
+		writer = st.suit.writer
+		data   = d.buff
+	)
+
+	d.buff = d.buff[:0]
+
+	if len(data) == 0 {
+		if endStream {
+			return writer.Data(st.id, nil, true, true)
+		}
+
+		return nil
+	}
+
+	for len(data) > 0 {
+		n, err := st.suit.reserve(st, len(data))
+		if err != nil {
+			return err
+		}
+
+		last := endStream && n == len(data)
+		if err = writer.Data(st.id, data[:n], last, last || d.unbuffered); err != nil {
+			return err
+		}
+
+		data = data[n:]
+	}
+
+	return nil
+}
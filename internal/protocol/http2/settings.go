@@ -0,0 +1,68 @@
+package http2
+
+import (
+	"encoding/binary"
+)
+
+type settingID uint16
+
+const (
+	settingHeaderTableSize settingID = iota + 1
+	settingEnablePush
+	settingMaxConcurrentStreams
+	settingInitialWindowSize
+	settingMaxFrameSize
+	settingMaxHeaderListSize
+)
+
+const (
+	settingLen = 6
+
+	defaultWindowSize      = 65535
+	defaultMaxFrameSize    = 16384
+	defaultHeaderTableSize = 4096
+	maxFrameSizeLimit      = 1<<24 - 1
+	maxWindowSize          = 1<<31 - 1
+)
+
+type setting struct {
+	ID    settingID
+	Value uint32
+}
+
+// walkSettings iterates over the SETTINGS frame payload. The payload length is expected to be
+// already validated.
+func walkSettings(payload []byte, cb func(setting) error) error {
+	for ; len(payload) >= settingLen; payload = payload[settingLen:] {
+		err := cb(setting{
+			ID:    settingID(binary.BigEndian.Uint16(payload)),
+			Value: binary.BigEndian.Uint32(payload[2:]),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate checks whether the setting value is in the allowed range. Unknown settings are
+// always valid, as they must be ignored.
+func (s setting) validate() error {
+	switch s.ID {
+	case settingEnablePush:
+		if s.Value > 1 {
+			return connError{errProtocol, "invalid SETTINGS_ENABLE_PUSH value"}
+		}
+	case settingInitialWindowSize:
+		if s.Value > maxWindowSize {
+			return connError{errFlowControl, "SETTINGS_INITIAL_WINDOW_SIZE is too large"}
+		}
+	case settingMaxFrameSize:
+		if s.Value < defaultMaxFrameSize || s.Value > maxFrameSizeLimit {
+			return connError{errProtocol, "invalid SETTINGS_MAX_FRAME_SIZE value"}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package http2
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/proto"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/codecutil"
+	"github.com/indigo-web/indigo/internal/construct"
+	"github.com/indigo-web/indigo/internal/reqctx"
+	"golang.org/x/net/http2/hpack"
+)
+
+// stream is a single request-response exchange. Each stream owns its request object
+// and codec instances, as streams are served concurrently.
+type stream struct {
+	suit    *Suit
+	id      uint32
+	request *http.Request
+	codecs  codecutil.Cache
+	limiter *codecutil.Limiter
+	body    pipe
+	ctx     *reqctx.Context
+	// err is an error which occurred during the request processing. It is passed
+	// to the error handler instead of calling the router.
+	err error
+	// upgraded marks the stream opened via an HTTP/1.1 upgrade. Its request belongs to
+	// the HTTP/1.1 suit, therefore it must never be reused.
+	upgraded bool
+
+	// following fields are guarded by Suit.mu
+	sendWindow   int64
+	recvWindow   int64
+	unacked      uint32
+	remoteClosed bool
+	closed       bool
+	expectLength int64
+	received     uint64
+
+	acceptEncodings []string
+	encodings       []string
+	fields          []hpack.HeaderField
+	cookieBuff      []byte
+	readBuff        []byte
+	limited         io.LimitedReader
+	data            dataWriter
+}
+
+func newStream(s *Suit, request *http.Request) *stream {
+	codecs := s.codecs.Clone()
+	st := &stream{
+		suit:            s,
+		request:         request,
+		codecs:          codecs,
+		limiter:         codecs.Limiter(s.cfg.Body.Decompression),
+		ctx:             reqctx.New(s.base),
+		acceptEncodings: make([]string, 0, s.cfg.Headers.MaxAcceptEncodingTokens),
+		encodings:       make([]string, 0, s.cfg.Headers.MaxEncodingTokens),
+	}
+	st.body.init()
+	st.data.st = st
+
+	if request == nil {
+		st.request = construct.Request(s.cfg, s.client)
+		st.request.Body = http.NewBody(st)
+	}
+
+	return st
+}
+
+// init prepares the stream to serve a new request, built from the decoded header block.
+func (st *stream) init(fields []hpack.HeaderField, overflow bool) error {
+	request := st.request
+	request.Reset()
+	request.Protocol = proto.HTTP2
+	request.Env.Encryption = st.suit.enc
+	request.Body.Reset(request)
+	request.Body.Fetcher = st
+	st.acceptEncodings = st.acceptEncodings[:0]
+	st.encodings = st.encodings[:0]
+	st.received = 0
+	st.unacked = 0
+	st.closed = false
+	st.body.Reset()
+	st.resetContext()
+
+	st.err = st.buildRequest(fields)
+	if _, malformed := st.err.(streamError); malformed {
+		return streamError{st.id, errProtocol}
+	}
+
+	if overflow && st.err == nil {
+		st.err = status.ErrHeaderFieldsTooLarge
+	}
+
+	if st.remoteClosed {
+		if st.expectLength > 0 {
+			return streamError{st.id, errProtocol}
+		}
+
+		st.body.Close(io.EOF)
+	}
+
+	return nil
+}
+
+// resetContext prepares the request context. Streams opened after the server started stopping
+// are born cancelled.
+func (st *stream) resetContext() {
+	st.ctx.Reset(st.suit.cfg.NET.RequestTimeout)
+	if st.suit.base.Err() != nil {
+		st.ctx.Cancel(context.Canceled)
+	}
+
+	st.request.Ctx = st.ctx
+}
+
+// Fetch returns the next piece of the request body. Consumed data is acknowledged to the
+// client, allowing it to send more.
+func (st *stream) Fetch() ([]byte, error) {
+	data, err := st.body.Read()
+	if len(data) > 0 {
+		st.suit.consumed(st, len(data))
+	}
+
+	return data, err
+}
+
+// applyDecoders wraps the request body into the decompressors of the content codings, limited
+// by the decompression limits.
+func (st *stream) applyDecoders() error {
+	st.limiter.Reset()
+
+	request := st.request
+	tokens := request.ContentEncoding
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	request.Body.Fetcher = st.limiter.Source(request.Body.Fetcher)
+
+	for i := len(tokens); i > 0; i-- {
+		c := st.codecs.Get(tokens[i-1])
+		if c == nil {
+			return status.ErrUnsupportedEncoding
+		}
+
+		if err := c.ResetDecompressor(request.Body.Fetcher, st.suit.cfg.NET.ReadBufferSize); err != nil {
+			return status.ErrInternalServerError
+		}
+
+		request.Body.Fetcher = c
+	}
+
+	request.Body.Fetcher = st.limiter.Limit(request.Body.Fetcher)
+	return nil
+}
+
+// pipe passes the request body from the connection reader to the stream handler. Two
+// buffers are swapped on every read, so the returned data stays valid until the next read.
+type pipe struct {
+	mu    sync.Mutex
+	cond  sync.Cond
+	buff  []byte
+	spare []byte
+	err   error
+}
+
+func (p *pipe) init() {
+	p.cond.L = &p.mu
+}
+
+func (p *pipe) Reset() {
+	p.mu.Lock()
+	p.buff, p.spare = p.buff[:0], p.spare[:0]
+	p.err = nil
+	p.mu.Unlock()
+}
+
+func (p *pipe) Write(b []byte) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.buff = append(p.buff, b...)
+		p.cond.Signal()
+	}
+	p.mu.Unlock()
+}
+
+// Close terminates the pipe. Data left in the pipe is still readable if the error is io.EOF,
+// otherwise it is dropped. Only the first error is kept.
+func (p *pipe) Close(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+		if err != io.EOF {
+			p.buff = p.buff[:0]
+		}
+
+		p.cond.Signal()
+	}
+	p.mu.Unlock()
+}
+
+func (p *pipe) Read() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.buff) == 0 && p.err == nil {
+		p.cond.Wait()
+	}
+
+	if len(p.buff) == 0 {
+		return nil, p.err
+	}
+
+	data := p.buff
+	p.buff, p.spare = p.spare[:0], data
+
+	return data, nil
+}
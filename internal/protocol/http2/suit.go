@@ -0,0 +1,880 @@
+package http2
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/proto"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/codecutil"
+	"github.com/indigo-web/indigo/router"
+	"github.com/indigo-web/indigo/transport"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Suit serves a single HTTP/2 connection. Frames are read and processed by the calling goroutine,
+// whereas each stream is handled by its own one.
+type Suit struct {
+	// base is cancelled once the server is stopping.
+	base     context.Context
+	cfg      *config.Config
+	router   router.Router
+	client   transport.Client
+	tracker  *transport.Tracker
+	enc      uint16
+	codecs   codecutil.Cache
+	framer   *framer
+	writer   *writer
+	decoder  *hpack.Decoder
+	defaults []hpack.HeaderField
+
+	// fields are the header fields of the header block being decoded.
+	fields     []hpack.HeaderField
+	fieldsSize int
+	overflow   bool
+	// block accumulates a header block split into CONTINUATION frames.
+	block       []byte
+	blockStream uint32
+	blockEnd    bool
+
+	wg sync.WaitGroup
+	// mu guards the streams, flow-control windows and peer settings.
+	mu                sync.Mutex
+	cond              sync.Cond
+	streams           map[uint32]*stream
+	pool              []*stream
+	lastStreamID      uint32
+	sendWindow        int64
+	recvWindow        int64
+	unacked           uint32
+	peerInitialWindow int64
+	peerMaxFrameSize  uint32
+	recvInitialWindow int64
+	settingsAcked     bool
+	closed            bool
+	// draining means GOAWAY was sent due to the server shutdown, so no more streams are accepted
+	// and the connection is closed as soon as the last one is done.
+	draining bool
+}
+
+// New instantiates an HTTP/2 protocol suit. The enc is the value for Request.Env.Encryption.
+// Request contexts are derived from the ctx. The tracker may be nil.
+func New(
+	ctx context.Context,
+	cfg *config.Config,
+	r router.Router,
+	client transport.Client,
+	tracker *transport.Tracker,
+	enc uint16,
+	codecs codecutil.Cache,
+) *Suit {
+	s := &Suit{
+		base:              ctx,
+		cfg:               cfg,
+		router:            r,
+		client:            client,
+		tracker:           tracker,
+		enc:               enc,
+		codecs:            codecs,
+		framer:            newFramer(client, clampFrameSize(cfg.HTTP2.MaxFrameSize)),
+		writer:            newWriter(client, cfg.NET.WriteBufferSize.Maximal),
+		defaults:          defaultHeaders(cfg.Headers.Default, codecs.AcceptEncoding()),
+		streams:           make(map[uint32]*stream),
+		sendWindow:        defaultWindowSize,
+		recvWindow:        defaultWindowSize,
+		peerInitialWindow: defaultWindowSize,
+		peerMaxFrameSize:  defaultMaxFrameSize,
+		recvInitialWindow: defaultWindowSize,
+	}
+	s.cond.L = &s.mu
+	s.decoder = hpack.NewDecoder(max(cfg.HTTP2.HeaderTableSize, defaultHeaderTableSize), s.onField)
+	s.decoder.SetMaxStringLength(cfg.Headers.Space.Maximal)
+
+	return s
+}
+
+// PriorKnowledge tells whether the client starts the connection with the HTTP/2 preface. The
+// read data is always pushed back.
+func PriorKnowledge(client transport.Client) (bool, error) {
+	data, err := client.Read()
+	if err != nil {
+		return false, err
+	}
+
+	client.Pushback(data)
+	n := min(len(data), len(Preface))
+
+	return n > 0 && string(data[:n]) == Preface[:n], nil
+}
+
+// Serve serves the connection until an error occurs.
+func (s *Suit) Serve() {
+	s.serve(nil)
+}
+
+// ServeUpgrade serves the connection, upgraded from HTTP/1.1 via h2c. The request, which initiated
+// the upgrade, is served as the stream 1. The settings are the HTTP2-Settings header value.
+func (s *Suit) ServeUpgrade(request *http.Request, settings string) {
+	s.serve(func() error {
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(settings, "="))
+		if err != nil || len(payload)%settingLen != 0 {
+			return connError{errProtocol, "malformed HTTP2-Settings"}
+		}
+
+		if err = s.applySettings(payload); err != nil {
+			return err
+		}
+
+		st := newStream(s, request)
+		st.id = 1
+		st.upgraded = true
+		st.remoteClosed = true
+		st.expectLength = -1
+		request.Protocol = proto.HTTP2
+		request.Body.Reset(request)
+		request.Body.Fetcher = st
+		st.body.Close(io.EOF)
+		st.resetContext()
+
+		s.mu.Lock()
+		st.sendWindow = s.peerInitialWindow
+		st.recvWindow = s.recvInitialWindow
+		s.streams[st.id] = st
+		s.lastStreamID = st.id
+		s.mu.Unlock()
+
+		s.spawn(st)
+
+		return nil
+	})
+}
+
+func (s *Suit) serve(init func() error) {
+	stop := context.AfterFunc(s.base, s.cancelStreams)
+	err := s.run(init)
+	stop()
+
+	code := errNo
+	var cerr connError
+	if errors.As(err, &cerr) {
+		code = cerr.Code
+	}
+
+	s.mu.Lock()
+	lastStreamID := s.lastStreamID
+	s.mu.Unlock()
+
+	_ = s.writer.GoAway(lastStreamID, code)
+	s.shutdown()
+}
+
+func (s *Suit) run(init func() error) error {
+	cfg := s.cfg.HTTP2
+
+	err := s.writer.Settings(
+		setting{settingEnablePush, 0},
+		setting{settingMaxConcurrentStreams, cfg.MaxConcurrentStreams},
+		setting{settingInitialWindowSize, cfg.InitialWindowSize},
+		setting{settingMaxFrameSize, clampFrameSize(cfg.MaxFrameSize)},
+		setting{settingHeaderTableSize, cfg.HeaderTableSize},
+		setting{settingMaxHeaderListSize, uint32(s.cfg.Headers.Space.Maximal)},
+	)
+	if err != nil {
+		return err
+	}
+
+	if cfg.ConnWindowSize > defaultWindowSize {
+		increment := cfg.ConnWindowSize - defaultWindowSize
+		s.recvWindow += int64(increment)
+		if err = s.writer.WindowUpdate(0, increment); err != nil {
+			return err
+		}
+	}
+
+	if init != nil {
+		if err = init(); err != nil {
+			return err
+		}
+	}
+
+	if err = s.framer.Preface(); err != nil {
+		return err
+	}
+
+	s.tracker.OnDrain(s.drain)
+
+	for settings := false; ; settings = true {
+		hdr, payload, err := s.framer.Next()
+		for err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) || !s.busy() {
+				return err
+			}
+
+			// the client is waiting for responses, so it is not idle.
+			hdr, payload, err = s.framer.Next()
+		}
+
+		if !settings && (hdr.Type != frameSettings || hdr.Has(flagAck)) {
+			return connError{errProtocol, "the preface must be followed by SETTINGS"}
+		}
+
+		if err = s.dispatch(hdr, payload); err != nil {
+			var serr streamError
+			if !errors.As(err, &serr) {
+				return err
+			}
+
+			s.reset(serr.StreamID, serr.Code)
+		}
+	}
+}
+
+func (s *Suit) dispatch(hdr frameHeader, payload []byte) error {
+	if s.blockStream != 0 && (hdr.Type != frameContinuation || hdr.StreamID != s.blockStream) {
+		return connError{errProtocol, "header block is interrupted"}
+	}
+
+	switch hdr.Type {
+	case frameData:
+		return s.onData(hdr, payload)
+	case frameHeaders:
+		return s.onHeaders(hdr, payload)
+	case frameContinuation:
+		return s.onContinuation(hdr, payload)
+	case framePriority:
+		if hdr.StreamID == 0 {
+			return connError{errProtocol, "PRIORITY on the stream 0"}
+		}
+
+		if len(payload) != priorityLen {
+			return streamError{hdr.StreamID, errFrameSize}
+		}
+
+		// priorities are deprecated and therefore ignored.
+		return nil
+	case frameRSTStream:
+		return s.onRSTStream(hdr, payload)
+	case frameSettings:
+		return s.onSettings(hdr, payload)
+	case framePushPromise:
+		return connError{errProtocol, "clients must not push"}
+	case framePing:
+		if hdr.StreamID != 0 {
+			return connError{errProtocol, "PING on a non-zero stream"}
+		}
+
+		if len(payload) != 8 {
+			return connError{errFrameSize, "PING payload must be 8 bytes long"}
+		}
+
+		if hdr.Has(flagAck) {
+			return nil
+		}
+
+		return s.writer.PingAck(payload)
+	case frameGoAway:
+		if hdr.StreamID != 0 {
+			return connError{errProtocol, "GOAWAY on a non-zero stream"}
+		}
+
+		// the client won't open new streams anymore, but the existing ones are still served.
+		return nil
+	case frameWindowUpdate:
+		return s.onWindowUpdate(hdr, payload)
+	default:
+		// unknown frame types must be ignored.
+		return nil
+	}
+}
+
+func (s *Suit) onSettings(hdr frameHeader, payload []byte) error {
+	if hdr.StreamID != 0 {
+		return connError{errProtocol, "SETTINGS on a non-zero stream"}
+	}
+
+	if hdr.Has(flagAck) {
+		if len(payload) != 0 {
+			return connError{errFrameSize, "SETTINGS acknowledgement with payload"}
+		}
+
+		s.onSettingsAck()
+		return nil
+	}
+
+	if len(payload)%settingLen != 0 {
+		return connError{errFrameSize, "malformed SETTINGS payload"}
+	}
+
+	if err := s.applySettings(payload); err != nil {
+		return err
+	}
+
+	return s.writer.SettingsAck()
+}
+
+// onSettingsAck applies our own settings, as the client has acknowledged them.
+func (s *Suit) onSettingsAck() {
+	s.mu.Lock()
+	if !s.settingsAcked {
+		s.settingsAcked = true
+		window := int64(s.cfg.HTTP2.InitialWindowSize)
+		for _, st := range s.streams {
+			st.recvWindow += window - s.recvInitialWindow
+		}
+
+		s.recvInitialWindow = window
+	}
+	s.mu.Unlock()
+
+	s.decoder.SetAllowedMaxDynamicTableSize(s.cfg.HTTP2.HeaderTableSize)
+}
+
+func (s *Suit) applySettings(payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.cond.Broadcast()
+
+	return walkSettings(payload, func(set setting) error {
+		if err := set.validate(); err != nil {
+			return err
+		}
+
+		switch set.ID {
+		case settingHeaderTableSize:
+			s.writer.SetHeaderTableSize(set.Value)
+		case settingInitialWindowSize:
+			delta := int64(set.Value) - s.peerInitialWindow
+			s.peerInitialWindow = int64(set.Value)
+
+			for _, st := range s.streams {
+				if st.sendWindow += delta; st.sendWindow > maxWindowSize {
+					return connError{errFlowControl, "stream window overflow"}
+				}
+			}
+		case settingMaxFrameSize:
+			s.peerMaxFrameSize = set.Value
+			s.writer.SetMaxFrameSize(set.Value)
+		}
+
+		return nil
+	})
+}
+
+func (s *Suit) onWindowUpdate(hdr frameHeader, payload []byte) error {
+	if len(payload) != 4 {
+		return connError{errFrameSize, "WINDOW_UPDATE payload must be 4 bytes long"}
+	}
+
+	increment := int64(binary.BigEndian.Uint32(payload) & streamIDMask)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.cond.Broadcast()
+
+	if hdr.StreamID == 0 {
+		if increment == 0 {
+			return connError{errProtocol, "zero window increment"}
+		}
+
+		if s.sendWindow += increment; s.sendWindow > maxWindowSize {
+			return connError{errFlowControl, "connection window overflow"}
+		}
+
+		return nil
+	}
+
+	st, found := s.streams[hdr.StreamID]
+	if !found {
+		if hdr.StreamID > s.lastStreamID {
+			return connError{errProtocol, "WINDOW_UPDATE on an idle stream"}
+		}
+
+		return nil
+	}
+
+	if increment == 0 {
+		return streamError{hdr.StreamID, errProtocol}
+	}
+
+	if st.sendWindow += increment; st.sendWindow > maxWindowSize {
+		return streamError{hdr.StreamID, errFlowControl}
+	}
+
+	return nil
+}
+
+func (s *Suit) onRSTStream(hdr frameHeader, payload []byte) error {
+	if len(payload) != 4 {
+		return connError{errFrameSize, "RST_STREAM payload must be 4 bytes long"}
+	}
+
+	if hdr.StreamID == 0 {
+		return connError{errProtocol, "RST_STREAM on the stream 0"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, found := s.streams[hdr.StreamID]
+	if !found {
+		if hdr.StreamID > s.lastStreamID {
+			return connError{errProtocol, "RST_STREAM on an idle stream"}
+		}
+
+		return nil
+	}
+
+	s.closeStream(st)
+
+	return nil
+}
+
+func (s *Suit) onHeaders(hdr frameHeader, payload []byte) error {
+	if hdr.StreamID == 0 {
+		return connError{errProtocol, "HEADERS on the stream 0"}
+	}
+
+	block, err := stripPadding(hdr, payload)
+	if err != nil {
+		return err
+	}
+
+	if hdr.Has(flagPriority) {
+		if len(block) < priorityLen {
+			return connError{errFrameSize, "HEADERS frame is too short"}
+		}
+
+		block = block[priorityLen:]
+	}
+
+	if hdr.Has(flagEndHeaders) {
+		return s.onHeaderBlock(hdr.StreamID, hdr.Has(flagEndStream), block)
+	}
+
+	s.blockStream = hdr.StreamID
+	s.blockEnd = hdr.Has(flagEndStream)
+	s.block = append(s.block[:0], block...)
+
+	return nil
+}
+
+func (s *Suit) onContinuation(hdr frameHeader, payload []byte) error {
+	if s.blockStream == 0 {
+		return connError{errProtocol, "unexpected CONTINUATION"}
+	}
+
+	s.block = append(s.block, payload...)
+	if len(s.block) > 2*s.cfg.Headers.Space.Maximal {
+		return connError{errEnhanceYourCalm, "header block is too large"}
+	}
+
+	if !hdr.Has(flagEndHeaders) {
+		return nil
+	}
+
+	streamID := s.blockStream
+	s.blockStream = 0
+
+	return s.onHeaderBlock(streamID, s.blockEnd, s.block)
+}
+
+func (s *Suit) onField(field hpack.HeaderField) {
+	if s.overflow {
+		return
+	}
+
+	// the size is calculated as defined for SETTINGS_MAX_HEADER_LIST_SIZE.
+	if s.fieldsSize += int(field.Size()); s.fieldsSize > s.cfg.Headers.Space.Maximal {
+		s.overflow = true
+		return
+	}
+
+	s.fields = append(s.fields, field)
+}
+
+func (s *Suit) onHeaderBlock(streamID uint32, endStream bool, block []byte) error {
+	// the block is always decoded, as the decoder state must be kept in sync with the client's
+	// encoder, even if the stream is going to be rejected.
+	s.fields, s.fieldsSize, s.overflow = s.fields[:0], 0, false
+	if _, err := s.decoder.Write(block); err != nil {
+		return connError{errCompression, err.Error()}
+	}
+
+	if err := s.decoder.Close(); err != nil {
+		return connError{errCompression, err.Error()}
+	}
+
+	s.mu.Lock()
+
+	if st, found := s.streams[streamID]; found {
+		defer s.mu.Unlock()
+
+		// trailers. They're not exposed, however they terminate the stream.
+		if st.remoteClosed {
+			return streamError{streamID, errStreamClosed}
+		}
+
+		if !endStream {
+			return streamError{streamID, errProtocol}
+		}
+
+		return s.endStream(st)
+	}
+
+	if streamID <= s.lastStreamID {
+		// the stream is already closed. Frames might still be in flight if we've closed
+		// it first, so just ignore them.
+		s.mu.Unlock()
+		return nil
+	}
+
+	if streamID%2 == 0 {
+		s.mu.Unlock()
+		return connError{errProtocol, "clients must use odd stream identifiers"}
+	}
+
+	if s.draining {
+		// the stream was initiated after GOAWAY, therefore it's not going to be processed.
+		s.mu.Unlock()
+		return streamError{streamID, errRefusedStream}
+	}
+
+	s.lastStreamID = streamID
+	if uint32(len(s.streams)) >= s.cfg.HTTP2.MaxConcurrentStreams {
+		s.mu.Unlock()
+		return streamError{streamID, errRefusedStream}
+	}
+
+	st := s.acquire()
+	st.id = streamID
+	st.sendWindow = s.peerInitialWindow
+	st.recvWindow = s.recvInitialWindow
+	st.remoteClosed = endStream
+	s.mu.Unlock()
+
+	if err := st.init(s.fields, s.overflow); err != nil {
+		s.release(st)
+		return err
+	}
+
+	s.mu.Lock()
+	s.streams[streamID] = st
+	s.mu.Unlock()
+
+	s.spawn(st)
+
+	return nil
+}
+
+func (s *Suit) onData(hdr frameHeader, payload []byte) error {
+	if hdr.StreamID == 0 {
+		return connError{errProtocol, "DATA on the stream 0"}
+	}
+
+	increment, err := s.receive(hdr, payload)
+	if increment > 0 {
+		if werr := s.writer.WindowUpdate(0, increment); werr != nil {
+			return werr
+		}
+	}
+
+	return err
+}
+
+// receive accounts the DATA frame and passes its payload to the stream. The connection
+// window is replenished as soon as it gets half exhausted, the returned increment must
+// be then sent to the client.
+func (s *Suit) receive(hdr frameHeader, payload []byte) (increment uint32, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := int64(hdr.Length)
+	if s.recvWindow -= size; s.recvWindow < 0 {
+		return 0, connError{errFlowControl, "connection window exceeded"}
+	}
+
+	if s.unacked += hdr.Length; s.unacked >= s.cfg.HTTP2.ConnWindowSize/2 {
+		increment = s.unacked
+		s.recvWindow += int64(increment)
+		s.unacked = 0
+	}
+
+	st, found := s.streams[hdr.StreamID]
+	if !found {
+		if hdr.StreamID > s.lastStreamID {
+			return increment, connError{errProtocol, "DATA on an idle stream"}
+		}
+
+		return increment, nil
+	}
+
+	if st.closed {
+		return increment, nil
+	}
+
+	if st.remoteClosed {
+		return increment, streamError{hdr.StreamID, errStreamClosed}
+	}
+
+	if st.recvWindow -= size; st.recvWindow < 0 {
+		return increment, streamError{hdr.StreamID, errFlowControl}
+	}
+
+	data, err := stripPadding(hdr, payload)
+	if err != nil {
+		return increment, err
+	}
+
+	// padding is never consumed by the handler, so account it immediately.
+	st.unacked += hdr.Length - uint32(len(data))
+	st.received += uint64(len(data))
+	if st.expectLength != -1 && st.received > uint64(st.expectLength) {
+		return increment, streamError{hdr.StreamID, errProtocol}
+	}
+
+	if st.received > s.cfg.Body.MaxSize {
+		st.body.Close(status.ErrBodyTooLarge)
+	} else {
+		st.body.Write(data)
+	}
+
+	if hdr.Has(flagEndStream) {
+		return increment, s.endStream(st)
+	}
+
+	return increment, nil
+}
+
+// endStream half-closes the stream from the remote side. Must be called with s.mu held.
+func (s *Suit) endStream(st *stream) error {
+	st.remoteClosed = true
+	if st.expectLength != -1 && st.received != uint64(st.expectLength) {
+		return streamError{st.id, errProtocol}
+	}
+
+	st.body.Close(io.EOF)
+
+	return nil
+}
+
+// consumed replenishes the stream window as soon as it gets half exhausted.
+func (s *Suit) consumed(st *stream, n int) {
+	s.mu.Lock()
+	if st.closed || st.remoteClosed {
+		s.mu.Unlock()
+		return
+	}
+
+	if st.unacked += uint32(n); int64(st.unacked) < s.recvInitialWindow/2 {
+		s.mu.Unlock()
+		return
+	}
+
+	increment := st.unacked
+	st.recvWindow += int64(increment)
+	st.unacked = 0
+	s.mu.Unlock()
+
+	_ = s.writer.WindowUpdate(st.id, increment)
+}
+
+// reserve blocks until the data of at most n bytes can be sent on the stream, returning
+// the number of bytes actually allowed.
+func (s *Suit) reserve(st *stream, n int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if s.closed || st.closed {
+			return 0, status.ErrCloseConnection
+		}
+
+		allowed := min(int64(n), s.sendWindow, st.sendWindow, int64(s.peerMaxFrameSize))
+		if allowed > 0 {
+			s.sendWindow -= allowed
+			st.sendWindow -= allowed
+			return int(allowed), nil
+		}
+
+		// the client is unlikely to grant more window until it receives everything we've
+		// buffered so far.
+		s.mu.Unlock()
+		err := s.writer.Flush()
+		s.mu.Lock()
+		if err != nil {
+			return 0, err
+		}
+
+		if min(s.sendWindow, st.sendWindow) <= 0 && !s.closed && !st.closed {
+			s.cond.Wait()
+		}
+	}
+}
+
+// reset closes the stream and notifies the client via RST_STREAM.
+func (s *Suit) reset(streamID uint32, code errCode) {
+	s.mu.Lock()
+	if st, found := s.streams[streamID]; found {
+		if st.closed {
+			s.mu.Unlock()
+			return
+		}
+
+		s.closeStream(st)
+	}
+	s.mu.Unlock()
+
+	_ = s.writer.RSTStream(streamID, code)
+}
+
+// closeStream marks the stream as closed, waking up everyone waiting on it. Must be called
+// with s.mu held.
+func (s *Suit) closeStream(st *stream) {
+	st.closed = true
+	st.body.Close(status.ErrCloseConnection)
+	st.ctx.Cancel(context.Canceled)
+	s.cond.Broadcast()
+}
+
+// cancelStreams cancels contexts of all the active streams.
+func (s *Suit) cancelStreams() {
+	s.mu.Lock()
+	for _, st := range s.streams {
+		st.ctx.Cancel(context.Canceled)
+	}
+	s.mu.Unlock()
+}
+
+func (s *Suit) spawn(st *stream) {
+	s.wg.Add(1)
+	go s.handle(st)
+}
+
+func (s *Suit) handle(st *stream) {
+	defer s.wg.Done()
+
+	request := st.request
+	err := st.err
+	if err == nil {
+		err = st.applyDecoders()
+	}
+
+	var response *http.Response
+	if err != nil {
+		response = respond(request, s.router.OnError(request, err))
+	} else {
+		response = respond(request, s.router.OnRequest(request))
+		if err = st.limiter.Err(); err != nil {
+			// the limits were violated while the handler was reading the body.
+			response = respond(request, s.router.OnError(request, err))
+		}
+	}
+
+	err = st.write(response)
+	request.Body.Cleanup()
+	if err != nil {
+		s.reset(st.id, errInternal)
+	}
+
+	s.finish(st)
+}
+
+// finish retires the stream after its response is completely sent.
+func (s *Suit) finish(st *stream) {
+	s.mu.Lock()
+	delete(s.streams, st.id)
+	// the client might still be sending the request body, which won't be read anymore.
+	cancel := !st.closed && !st.remoteClosed
+	st.closed = true
+	done := s.draining && len(s.streams) == 0
+	s.mu.Unlock()
+
+	if cancel {
+		_ = s.writer.RSTStream(st.id, errNo)
+	}
+
+	if done {
+		_ = s.client.Close()
+	}
+
+	if !st.upgraded {
+		s.release(st)
+	}
+}
+
+func (s *Suit) acquire() *stream {
+	if len(s.pool) == 0 {
+		return newStream(s, nil)
+	}
+
+	st := s.pool[len(s.pool)-1]
+	s.pool = s.pool[:len(s.pool)-1]
+
+	return st
+}
+
+func (s *Suit) release(st *stream) {
+	s.mu.Lock()
+	s.pool = append(s.pool, st)
+	s.mu.Unlock()
+}
+
+func (s *Suit) busy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.streams) > 0
+}
+
+// drain is called once the server starts shutting down. The client is told via GOAWAY not to
+// open new streams, and the connection is closed as soon as the active ones are done.
+func (s *Suit) drain() (closed bool) {
+	s.mu.Lock()
+	s.draining = true
+	lastStreamID := s.lastStreamID
+	idle := len(s.streams) == 0
+	s.mu.Unlock()
+
+	_ = s.writer.GoAway(lastStreamID, errNo)
+
+	if idle {
+		_ = s.client.Close()
+	}
+
+	return idle
+}
+
+// shutdown terminates all the streams and waits until their handlers are done.
+func (s *Suit) shutdown() {
+	s.mu.Lock()
+	s.closed = true
+	for _, st := range s.streams {
+		st.body.Close(status.ErrCloseConnection)
+		st.ctx.Cancel(context.Canceled)
+	}
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func clampFrameSize(size uint32) uint32 {
+	return min(max(size, defaultMaxFrameSize), maxFrameSizeLimit)
+}
+
+// respond ensures the passed resp is not nil, otherwise http.Respond(req) is returned
+func respond(req *http.Request, resp *http.Response) *http.Response {
+	if resp != nil {
+		return resp
+	}
+
+	return http.Respond(req)
+}
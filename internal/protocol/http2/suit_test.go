@@ -0,0 +1,454 @@
+package http2
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/codec"
+	"github.com/indigo-web/indigo/internal/codecutil"
+	"github.com/indigo-web/indigo/internal/construct"
+	"github.com/indigo-web/indigo/router"
+	"github.com/indigo-web/indigo/router/inbuilt"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func getRouter() router.Router {
+	r := inbuilt.New().
+		Get("/", func(request *http.Request) *http.Response {
+			return http.String(request, "Hello, world!").Header("X-Path", request.Path)
+		}).
+		Get("/params", func(request *http.Request) *http.Response {
+			var buff []byte
+			for key, value := range request.Params.Pairs() {
+				buff = append(buff, key+"="+value+";"...)
+			}
+
+			return http.Bytes(request, buff)
+		}).
+		Get("/large", func(request *http.Request) *http.Response {
+			return http.String(request, strings.Repeat("a", 200_000)).Compress()
+		}).
+		Get("/hijack", func(request *http.Request) *http.Response {
+			_, err := request.Hijack()
+			return http.Error(request, err)
+		}).
+		Post("/echo", func(request *http.Request) *http.Response {
+			body, err := request.Body.Bytes()
+			if err != nil {
+				return http.Error(request, err)
+			}
+
+			return http.Bytes(request, body)
+		})
+
+	return r.Build()
+}
+
+type result struct {
+	Headers map[string]string
+	Body    string
+	Reset   http2.ErrCode
+}
+
+type session struct {
+	t       *testing.T
+	conn    net.Conn
+	framer  *http2.Framer
+	encBuff *bytes.Buffer
+	encoder *hpack.Encoder
+}
+
+func newSession(t *testing.T, cfg *config.Config) *session {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		client := construct.Client(cfg.NET, conn)
+		codecs := codecutil.NewCache(codec.Suit(), codecutil.AcceptEncoding(codec.Suit()))
+		New(context.Background(), cfg, getRouter(), client, nil, 0, codecs).Serve()
+		_ = conn.Close()
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = l.Close()
+		<-done
+	})
+
+	encBuff := new(bytes.Buffer)
+	s := &session{
+		t:       t,
+		conn:    conn,
+		framer:  http2.NewFramer(conn, conn),
+		encBuff: encBuff,
+		encoder: hpack.NewEncoder(encBuff),
+	}
+	s.framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	s.framer.MaxHeaderListSize = 1 << 20
+
+	_, err = io.WriteString(conn, Preface)
+	require.NoError(t, err)
+
+	return s
+}
+
+// handshake sends the client settings and waits for the server ones.
+func (s *session) handshake(settings ...http2.Setting) {
+	require.NoError(s.t, s.framer.WriteSettings(settings...))
+
+	for {
+		frame := s.next()
+		if sf, ok := frame.(*http2.SettingsFrame); ok && !sf.IsAck() {
+			require.NoError(s.t, s.framer.WriteSettingsAck())
+			return
+		}
+	}
+}
+
+func (s *session) next() http2.Frame {
+	require.NoError(s.t, s.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	frame, err := s.framer.ReadFrame()
+	require.NoError(s.t, err)
+
+	return frame
+}
+
+func (s *session) block(fields ...string) []byte {
+	s.encBuff.Reset()
+	for i := 0; i < len(fields); i += 2 {
+		require.NoError(s.t, s.encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]}))
+	}
+
+	return s.encBuff.Bytes()
+}
+
+func (s *session) request(streamID uint32, endStream bool, fields ...string) {
+	require.NoError(s.t, s.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: s.block(fields...),
+		EndStream:     endStream,
+		EndHeaders:    true,
+	}))
+}
+
+func (s *session) get(streamID uint32, path string, extra ...string) {
+	s.request(streamID, true, append([]string{
+		":method", "GET", ":scheme", "http", ":authority", "localhost", ":path", path,
+	}, extra...)...)
+}
+
+// response reads frames until the stream is either ended or reset.
+func (s *session) response(streamID uint32) (resp result) {
+	resp.Headers = make(map[string]string)
+	var body []byte
+
+	for {
+		switch frame := s.next().(type) {
+		case *http2.MetaHeadersFrame:
+			require.Equal(s.t, streamID, frame.StreamID)
+			for _, field := range frame.Fields {
+				resp.Headers[field.Name] = field.Value
+			}
+
+			if frame.StreamEnded() {
+				resp.Body = string(body)
+				return resp
+			}
+		case *http2.DataFrame:
+			require.Equal(s.t, streamID, frame.StreamID)
+			body = append(body, frame.Data()...)
+			if len(frame.Data()) > 0 {
+				require.NoError(s.t, s.framer.WriteWindowUpdate(0, uint32(len(frame.Data()))))
+				require.NoError(s.t, s.framer.WriteWindowUpdate(streamID, uint32(len(frame.Data()))))
+			}
+
+			if frame.StreamEnded() {
+				resp.Body = string(body)
+				return resp
+			}
+		case *http2.RSTStreamFrame:
+			require.Equal(s.t, streamID, frame.StreamID)
+			resp.Reset = frame.ErrCode
+			return resp
+		case *http2.GoAwayFrame:
+			s.t.Fatalf("unexpected GOAWAY: %s", frame.ErrCode)
+		}
+	}
+}
+
+func (s *session) goAway() http2.ErrCode {
+	for {
+		if frame, ok := s.next().(*http2.GoAwayFrame); ok {
+			return frame.ErrCode
+		}
+	}
+}
+
+func TestSuit(t *testing.T) {
+	t.Run("simple GET", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		s.get(1, "/")
+		resp := s.response(1)
+		require.Zero(t, resp.Reset)
+		require.Equal(t, "200", resp.Headers[":status"])
+		require.Equal(t, "/", resp.Headers["x-path"])
+		require.Equal(t, "13", resp.Headers["content-length"])
+		require.Equal(t, "Hello, world!", resp.Body)
+	})
+
+	t.Run("multiple streams", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+
+		for i := uint32(1); i < 10; i += 2 {
+			s.get(i, "/")
+			resp := s.response(i)
+			require.Equal(t, "Hello, world!", resp.Body)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		s.get(1, "/params?hello=world&foo=b%61r+baz&&empty")
+		resp := s.response(1)
+		require.Equal(t, "hello=world;foo=bar baz;empty=;", resp.Body)
+	})
+
+	t.Run("request body", func(t *testing.T) {
+		// the body must fit into the initial window, as the test client ignores WINDOW_UPDATEs.
+		body := strings.Repeat("abcdef", 40_000)
+		s := newSession(t, config.Default())
+		s.handshake()
+		s.request(1, false,
+			":method", "POST", ":scheme", "http", ":path", "/echo",
+			"content-length", strconv.Itoa(len(body)),
+		)
+
+		for data := body; len(data) > 0; {
+			n := min(len(data), 16384)
+			require.NoError(t, s.framer.WriteData(1, n == len(data), []byte(data[:n])))
+			data = data[n:]
+		}
+
+		resp := s.response(1)
+		require.Zero(t, resp.Reset)
+		require.Equal(t, len(body), len(resp.Body))
+		require.Equal(t, body, resp.Body)
+	})
+
+	t.Run("flow control", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake(http2.Setting{ID: http2.SettingInitialWindowSize, Val: 1000})
+		s.get(1, "/large")
+		resp := s.response(1)
+		require.Zero(t, resp.Reset)
+		require.Equal(t, strings.Repeat("a", 200_000), resp.Body)
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		s.request(1, true, ":method", "HEAD", ":scheme", "http", ":path", "/")
+		resp := s.response(1)
+		require.Equal(t, "200", resp.Headers[":status"])
+		require.Empty(t, resp.Body)
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		data := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+		require.NoError(t, s.framer.WritePing(false, data))
+
+		for {
+			if ping, ok := s.next().(*http2.PingFrame); ok {
+				require.True(t, ping.IsAck())
+				require.Equal(t, data, ping.Data)
+				return
+			}
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		s.get(1, "/nonexistent")
+		require.Equal(t, "404", s.response(1).Headers[":status"])
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		s.request(1, true, ":method", "BREW", ":scheme", "http", ":path", "/")
+		require.Equal(t, "501", s.response(1).Headers[":status"])
+	})
+
+	t.Run("hijack", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		s.get(1, "/hijack")
+		require.Equal(t, "501", s.response(1).Headers[":status"])
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		tcs := []struct {
+			Name   string
+			Fields []string
+		}{
+			{"no path", []string{":method", "GET", ":scheme", "http"}},
+			{"uppercase header", []string{":method", "GET", ":scheme", "http", ":path", "/", "Hello", "world"}},
+			{"connection-specific", []string{":method", "GET", ":scheme", "http", ":path", "/", "connection", "close"}},
+			{"pseudo after regular", []string{":method", "GET", ":scheme", "http", "hello", "world", ":path", "/"}},
+			{"unknown pseudo", []string{":method", "GET", ":scheme", "http", ":path", "/", ":hello", "world"}},
+			{"te", []string{":method", "GET", ":scheme", "http", ":path", "/", "te", "gzip"}},
+			{"content-length", []string{":method", "GET", ":scheme", "http", ":path", "/", "content-length", "5"}},
+		}
+
+		for _, tc := range tcs {
+			t.Run(tc.Name, func(t *testing.T) {
+				s := newSession(t, config.Default())
+				s.handshake()
+				s.request(1, true, tc.Fields...)
+				require.Equal(t, http2.ErrCodeProtocol, s.response(1).Reset)
+
+				// the connection must remain usable
+				s.get(3, "/")
+				require.Equal(t, "Hello, world!", s.response(3).Body)
+			})
+		}
+	})
+
+	t.Run("refused stream", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.HTTP2.MaxConcurrentStreams = 1
+		s := newSession(t, cfg)
+		s.handshake()
+		// the first stream stays open, as it never ends
+		s.request(1, false, ":method", "POST", ":scheme", "http", ":path", "/echo")
+		s.get(3, "/")
+		require.Equal(t, http2.ErrCodeRefusedStream, s.response(3).Reset)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Body.MaxSize = 10
+		s := newSession(t, cfg)
+		s.handshake()
+		s.request(1, false, ":method", "POST", ":scheme", "http", ":path", "/echo")
+		require.NoError(t, s.framer.WriteData(1, true, []byte("Hello, world!")))
+		require.Equal(t, "413", s.response(1).Headers[":status"])
+	})
+
+	t.Run("flow control violation", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.HTTP2.InitialWindowSize = defaultWindowSize
+		s := newSession(t, cfg)
+		s.handshake()
+		s.request(1, false, ":method", "POST", ":scheme", "http", ":path", "/echo")
+		chunk := make([]byte, 16384)
+		for range 5 {
+			require.NoError(t, s.framer.WriteData(1, false, chunk))
+		}
+
+		require.Equal(t, http2.ErrCodeFlowControl, s.response(1).Reset)
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		s.get(1, "/large", "accept-encoding", "gzip")
+		resp := s.response(1)
+		require.Equal(t, "gzip", resp.Headers["content-encoding"])
+		require.NotContains(t, resp.Headers, "content-length")
+		require.Less(t, len(resp.Body), 200_000)
+	})
+
+	t.Run("no settings", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.get(1, "/")
+		require.Equal(t, http2.ErrCodeProtocol, s.goAway())
+	})
+
+	t.Run("even stream", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		s.get(2, "/")
+		require.Equal(t, http2.ErrCodeProtocol, s.goAway())
+	})
+
+	t.Run("interrupted header block", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		require.NoError(t, s.framer.WriteHeaders(http2.HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: s.block(":method", "GET"),
+		}))
+		require.NoError(t, s.framer.WritePing(false, [8]byte{}))
+		require.Equal(t, http2.ErrCodeProtocol, s.goAway())
+	})
+
+	t.Run("continuation", func(t *testing.T) {
+		s := newSession(t, config.Default())
+		s.handshake()
+		block := s.block(":method", "GET", ":scheme", "http", ":path", "/", "hello", "world")
+		require.NoError(t, s.framer.WriteHeaders(http2.HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: block[:5],
+			EndStream:     true,
+		}))
+		require.NoError(t, s.framer.WriteContinuation(1, true, block[5:]))
+		require.Equal(t, "Hello, world!", s.response(1).Body)
+	})
+}
+
+func TestPriorKnowledge(t *testing.T) {
+	test := func(t *testing.T, data string, want bool) {
+		server, client := net.Pipe()
+		go func() {
+			_, _ = io.WriteString(client, data)
+		}()
+
+		c := construct.Client(config.Default().NET, server)
+		isHTTP2, err := PriorKnowledge(c)
+		require.NoError(t, err)
+		require.Equal(t, want, isHTTP2)
+
+		pending, err := c.Read()
+		require.NoError(t, err)
+		require.Equal(t, data, string(pending), "the data must be pushed back")
+	}
+
+	t.Run("preface", func(t *testing.T) {
+		test(t, Preface, true)
+	})
+
+	t.Run("partial preface", func(t *testing.T) {
+		test(t, Preface[:5], true)
+	})
+
+	t.Run("HTTP/1.1", func(t *testing.T) {
+		test(t, "GET / HTTP/1.1\r\n\r\n", false)
+	})
+}
@@ -0,0 +1,206 @@
+package http2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+
+	"github.com/indigo-web/indigo/transport"
+	"golang.org/x/net/http2/hpack"
+)
+
+// writer serializes frames into the connection. It is shared among all the streams, therefore
+// is safe for concurrent use. Header blocks are also encoded here, as HPACK encoder state must
+// be changed in exactly the same order the header blocks are transmitted.
+type writer struct {
+	mu           sync.Mutex
+	client       transport.Client
+	buff         []byte
+	flushAt      int
+	hpackBuff    *bytes.Buffer
+	encoder      *hpack.Encoder
+	maxFrameSize uint32
+	err          error
+}
+
+func newWriter(client transport.Client, buffSize int) *writer {
+	hpackBuff := new(bytes.Buffer)
+
+	return &writer{
+		client:       client,
+		buff:         make([]byte, 0, buffSize),
+		flushAt:      buffSize,
+		hpackBuff:    hpackBuff,
+		encoder:      hpack.NewEncoder(hpackBuff),
+		maxFrameSize: defaultMaxFrameSize,
+	}
+}
+
+// SetMaxFrameSize updates the largest frame payload the peer is willing to receive.
+func (w *writer) SetMaxFrameSize(size uint32) {
+	w.mu.Lock()
+	w.maxFrameSize = size
+	w.mu.Unlock()
+}
+
+// SetHeaderTableSize updates the HPACK dynamic table size limit requested by the peer.
+func (w *writer) SetHeaderTableSize(size uint32) {
+	w.mu.Lock()
+	w.encoder.SetMaxDynamicTableSizeLimit(size)
+	w.mu.Unlock()
+}
+
+// Headers encodes the header fields and writes them as a HEADERS frame, followed by as many
+// CONTINUATION frames as needed.
+func (w *writer) Headers(streamID uint32, fields []hpack.HeaderField, endStream, flush bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	w.hpackBuff.Reset()
+	for _, field := range fields {
+		if err := w.encoder.WriteField(field); err != nil {
+			return err
+		}
+	}
+
+	block := w.hpackBuff.Bytes()
+	typ, flags := frameHeaders, uint8(0)
+	if endStream {
+		flags = flagEndStream
+	}
+
+	for first := true; first || len(block) > 0; first = false {
+		chunk := block[:min(len(block), int(w.maxFrameSize))]
+		block = block[len(chunk):]
+
+		if len(block) == 0 {
+			flags |= flagEndHeaders
+		}
+
+		w.appendFrame(frameHeader{
+			Length:   uint32(len(chunk)),
+			Type:     typ,
+			Flags:    flags,
+			StreamID: streamID,
+		}, chunk)
+
+		typ, flags = frameContinuation, 0
+	}
+
+	return w.finish(flush)
+}
+
+// Data writes a DATA frame. The data length must not exceed the peer's max frame size.
+func (w *writer) Data(streamID uint32, data []byte, endStream, flush bool) error {
+	var flags uint8
+	if endStream {
+		flags = flagEndStream
+	}
+
+	return w.frame(frameHeader{
+		Length:   uint32(len(data)),
+		Type:     frameData,
+		Flags:    flags,
+		StreamID: streamID,
+	}, data, flush)
+}
+
+func (w *writer) Settings(settings ...setting) error {
+	payload := make([]byte, 0, len(settings)*settingLen)
+	for _, s := range settings {
+		payload = binary.BigEndian.AppendUint16(payload, uint16(s.ID))
+		payload = binary.BigEndian.AppendUint32(payload, s.Value)
+	}
+
+	return w.frame(frameHeader{
+		Length: uint32(len(payload)),
+		Type:   frameSettings,
+	}, payload, true)
+}
+
+func (w *writer) SettingsAck() error {
+	return w.frame(frameHeader{
+		Type:  frameSettings,
+		Flags: flagAck,
+	}, nil, true)
+}
+
+func (w *writer) PingAck(data []byte) error {
+	return w.frame(frameHeader{
+		Length: uint32(len(data)),
+		Type:   framePing,
+		Flags:  flagAck,
+	}, data, true)
+}
+
+func (w *writer) WindowUpdate(streamID, increment uint32) error {
+	return w.frame(frameHeader{
+		Length:   4,
+		Type:     frameWindowUpdate,
+		StreamID: streamID,
+	}, binary.BigEndian.AppendUint32(nil, increment), true)
+}
+
+func (w *writer) RSTStream(streamID uint32, code errCode) error {
+	return w.frame(frameHeader{
+		Length:   4,
+		Type:     frameRSTStream,
+		StreamID: streamID,
+	}, binary.BigEndian.AppendUint32(nil, uint32(code)), true)
+}
+
+func (w *writer) GoAway(lastStreamID uint32, code errCode) error {
+	payload := binary.BigEndian.AppendUint32(nil, lastStreamID&streamIDMask)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(code))
+
+	return w.frame(frameHeader{
+		Length: uint32(len(payload)),
+		Type:   frameGoAway,
+	}, payload, true)
+}
+
+// Flush writes all the buffered frames.
+func (w *writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	return w.finish(true)
+}
+
+func (w *writer) frame(hdr frameHeader, payload []byte, flush bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	w.appendFrame(hdr, payload)
+
+	return w.finish(flush)
+}
+
+func (w *writer) appendFrame(hdr frameHeader, payload []byte) {
+	w.buff = appendFrameHeader(w.buff, hdr)
+	w.buff = append(w.buff, payload...)
+}
+
+// finish flushes the buffer if requested to or the buffer is full enough.
+func (w *writer) finish(flush bool) error {
+	if len(w.buff) == 0 || !flush && len(w.buff) < w.flushAt {
+		return nil
+	}
+
+	_, w.err = w.client.Write(w.buff)
+	w.buff = w.buff[:0]
+
+	return w.err
+}
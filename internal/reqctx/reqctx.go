@@ -0,0 +1,160 @@
+package reqctx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var closedchan = make(chan struct{})
+
+func init() {
+	close(closedchan)
+}
+
+var _ context.Context = new(Context)
+
+// Context is the request context. A single instance is reused by all the requests served by
+// the same connection (or HTTP/2 stream), and the done channel is created lazily, therefore
+// requests never observing it don't allocate anything.
+type Context struct {
+	base     context.Context
+	mu       sync.Mutex
+	done     chan struct{}
+	err      error
+	closeErr error
+	deadline time.Time
+	timer    *time.Timer
+	watch    func()
+	observed bool
+}
+
+// New returns a new context. Values are looked up in the base context.
+func New(base context.Context) *Context {
+	return &Context{base: base}
+}
+
+// OnWatch sets the callback invoked on the first Done call of every request, unless it's already
+// cancelled. It is called with the lock held, therefore must not use the context.
+func (c *Context) OnWatch(cb func()) {
+	c.watch = cb
+}
+
+// Reset prepares the context for a new request. Non-zero timeout sets the request deadline.
+func (c *Context) Reset(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+
+	c.deadline = time.Time{}
+	if timeout > 0 {
+		c.deadline = time.Now().Add(timeout)
+	}
+
+	if c.err != nil {
+		// closed channels cannot be reused, but the ones which weren't can.
+		c.done = nil
+	}
+
+	c.err = c.closeErr
+	c.observed = false
+}
+
+// Cancel cancels the current request.
+func (c *Context) Cancel(err error) {
+	c.mu.Lock()
+	c.cancel(err)
+	c.mu.Unlock()
+}
+
+// Close cancels the current request as well as all the following ones.
+func (c *Context) Close(err error) {
+	c.mu.Lock()
+	c.closeErr = err
+	c.cancel(err)
+	c.mu.Unlock()
+}
+
+func (c *Context) cancel(err error) {
+	if c.err != nil {
+		return
+	}
+
+	c.err = err
+	if c.done != nil {
+		close(c.done)
+	}
+}
+
+func (c *Context) expire() {
+	c.mu.Lock()
+	// the timer might've fired right before being stopped for the next request, whose
+	// deadline isn't exceeded yet.
+	if !c.deadline.IsZero() && !time.Now().Before(c.deadline) {
+		c.cancel(context.DeadlineExceeded)
+	}
+	c.mu.Unlock()
+}
+
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.deadline, !c.deadline.IsZero()
+}
+
+func (c *Context) Done() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.observed {
+		c.observed = true
+
+		if c.err == nil {
+			c.observe()
+		}
+	}
+
+	if c.done == nil {
+		if c.err != nil {
+			return closedchan
+		}
+
+		c.done = make(chan struct{})
+	}
+
+	return c.done
+}
+
+// observe starts all the cancellation sources, which are needed only if the context is observed.
+func (c *Context) observe() {
+	if !c.deadline.IsZero() {
+		if timeout := time.Until(c.deadline); c.timer == nil {
+			c.timer = time.AfterFunc(timeout, c.expire)
+		} else {
+			c.timer.Reset(timeout)
+		}
+	}
+
+	if c.watch != nil {
+		c.watch()
+	}
+}
+
+func (c *Context) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil && !c.deadline.IsZero() && !time.Now().Before(c.deadline) {
+		c.cancel(context.DeadlineExceeded)
+	}
+
+	return c.err
+}
+
+func (c *Context) Value(key any) any {
+	return c.base.Value(key)
+}
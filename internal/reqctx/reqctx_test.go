@@ -0,0 +1,133 @@
+package reqctx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func isDone(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestContext(t *testing.T) {
+	t.Run("lazy done", func(t *testing.T) {
+		ctx := New(context.Background())
+		ctx.Reset(0)
+		require.Nil(t, ctx.done)
+		require.NoError(t, ctx.Err())
+		require.False(t, isDone(ctx))
+		require.NotNil(t, ctx.done)
+	})
+
+	t.Run("cancel and reuse", func(t *testing.T) {
+		ctx := New(context.Background())
+		ctx.Reset(0)
+		done := ctx.Done()
+		ctx.Cancel(context.Canceled)
+		require.True(t, isDone(ctx))
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+		<-done
+
+		ctx.Reset(0)
+		require.NoError(t, ctx.Err())
+		require.False(t, isDone(ctx))
+	})
+
+	t.Run("untouched channel is reused", func(t *testing.T) {
+		ctx := New(context.Background())
+		ctx.Reset(0)
+		done := ctx.Done()
+		ctx.Reset(0)
+		require.Equal(t, done, ctx.Done())
+	})
+
+	t.Run("close", func(t *testing.T) {
+		ctx := New(context.Background())
+		ctx.Reset(0)
+		ctx.Close(context.Canceled)
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+
+		ctx.Reset(0)
+		require.True(t, isDone(ctx))
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+	})
+
+	t.Run("first error wins", func(t *testing.T) {
+		ctx := New(context.Background())
+		ctx.Reset(0)
+		ctx.Cancel(context.Canceled)
+		ctx.Cancel(errors.New("something else"))
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx := New(context.Background())
+		ctx.Reset(10 * time.Millisecond)
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.False(t, deadline.IsZero())
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			require.Fail(t, "deadline wasn't exceeded")
+		}
+
+		require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+
+		ctx.Reset(0)
+		_, ok = ctx.Deadline()
+		require.False(t, ok)
+		require.NoError(t, ctx.Err())
+	})
+
+	t.Run("deadline without observing", func(t *testing.T) {
+		ctx := New(context.Background())
+		ctx.Reset(time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		var calls int
+		ctx := New(context.Background())
+		ctx.OnWatch(func() {
+			calls++
+		})
+
+		ctx.Reset(0)
+		require.NoError(t, ctx.Err())
+		require.Zero(t, calls)
+		ctx.Done()
+		ctx.Done()
+		require.Equal(t, 1, calls)
+
+		ctx.Reset(0)
+		ctx.Cancel(context.Canceled)
+		ctx.Done()
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("values", func(t *testing.T) {
+		ctx := New(context.WithValue(context.Background(), "key", "value"))
+		require.Equal(t, "value", ctx.Value("key"))
+	})
+}
+
+func BenchmarkContext(b *testing.B) {
+	ctx := New(context.Background())
+	b.ReportAllocs()
+
+	for range b.N {
+		ctx.Reset(0)
+	}
+}
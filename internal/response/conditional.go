@@ -0,0 +1,164 @@
+package response
+
+import (
+	"hash/fnv"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/kv"
+)
+
+// dateFormats are the formats of an HTTP-date. Only the first one is generated, the others
+// are obsolete, but must still be accepted (RFC 9110, 5.6.7).
+var dateFormats = []string{time.RFC1123, time.RFC850, time.ANSIC}
+
+// FormatDate formats the time as an HTTP-date.
+func FormatDate(t time.Time) string {
+	return t.In(zoneGMT).Format(time.RFC1123)
+}
+
+// ParseDate parses an HTTP-date.
+func ParseDate(value string) (time.Time, bool) {
+	for _, format := range dateFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// FileETag derives a strong entity tag from the modification time and the size of a file.
+func FileETag(modTime time.Time, size int64) string {
+	return `"` + strconv.FormatInt(modTime.UnixNano(), 16) + "-" + strconv.FormatInt(size, 16) + `"`
+}
+
+// Preconditions evaluates the conditional headers of the request against the validators of
+// the resource, which are the entity tag and the last modification time. Empty etag and zero
+// modified mean the resource has no such validator. The result is either 0 if the request may
+// be performed, status.NotModified or status.PreconditionFailed. The evaluation order follows
+// RFC 9110, 13.2.2.
+func Preconditions(m method.Method, headers *kv.Storage, etag string, modified time.Time) status.Code {
+	safe := m == method.GET || m == method.HEAD
+
+	if headers.Has("if-match") {
+		if !matchETag(headers.Values("if-match"), etag, true) {
+			return status.PreconditionFailed
+		}
+	} else if since, found := headers.Lookup("if-unmodified-since"); found && !modified.IsZero() {
+		if date, ok := ParseDate(since); ok && modified.Truncate(time.Second).After(date) {
+			return status.PreconditionFailed
+		}
+	}
+
+	if headers.Has("if-none-match") {
+		if matchETag(headers.Values("if-none-match"), etag, false) {
+			if safe {
+				return status.NotModified
+			}
+
+			return status.PreconditionFailed
+		}
+	} else if since, found := headers.Lookup("if-modified-since"); found && safe && !modified.IsZero() {
+		if date, ok := ParseDate(since); ok && !modified.Truncate(time.Second).After(date) {
+			return status.NotModified
+		}
+	}
+
+	return 0
+}
+
+// matchETag tells whether any of the entity tags listed in the values matches the etag. The
+// asterisk matches any existing resource.
+func matchETag(values iter.Seq[string], etag string, strong bool) bool {
+	for value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" {
+				// the resource is considered existing, as otherwise the response wouldn't be 2xx.
+				return true
+			}
+
+			if len(etag) == 0 {
+				continue
+			}
+
+			if strong {
+				if !weak(tag) && !weak(etag) && tag == etag {
+					return true
+				}
+			} else if opaque(tag) == opaque(etag) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func weak(etag string) bool {
+	return strings.HasPrefix(etag, "W/")
+}
+
+func opaque(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
+
+// Conditional evaluates conditional requests against the validators of the response, which are
+// taken from the ETag and Last-Modified headers. As the handler is already done at this point,
+// only GET and HEAD requests are considered, whereas state-changing requests must be evaluated
+// by the handlers themselves prior to being performed. Responses failing the preconditions
+// are replaced by empty 304 Not Modified or 412 Precondition Failed ones.
+func Conditional(f *Fields, m method.Method, headers *kv.Storage) {
+	if f.Code < 200 || f.Code >= 300 || (m != method.GET && m != method.HEAD) {
+		return
+	}
+
+	if f.HashETag {
+		hashETag(f)
+	}
+
+	etag, _ := lookup(f.Headers, "etag")
+	var modified time.Time
+	if value, found := lookup(f.Headers, "last-modified"); found {
+		modified, _ = ParseDate(value)
+	}
+
+	if code := Preconditions(m, headers, etag, modified); code != 0 {
+		Reject(f, code)
+	}
+}
+
+// Reject replaces the response by an empty one with the code, preserving the headers.
+func Reject(f *Fields, code status.Code) {
+	if c, ok := f.Stream.(io.Closer); ok {
+		_ = c.Close()
+	}
+
+	f.Code, f.Status = code, ""
+	f.Stream, f.StreamSize = nil, 0
+	f.AutoCompress, f.ContentEncoding, f.Precompressed = false, "", ""
+}
+
+// hashETag sets the entity tag derived from the body, unless it's already set. Only buffered
+// bodies are eligible, as streams cannot be hashed without being consumed.
+func hashETag(f *Fields) {
+	if _, found := lookup(f.Headers, "etag"); found {
+		return
+	}
+
+	body, ok := f.Stream.(interface{ Bytes() []byte })
+	if !ok || f.StreamSize < 0 {
+		return
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(body.Bytes())
+	etag := `"` + strconv.FormatUint(h.Sum64(), 16) + "-" + strconv.FormatInt(f.StreamSize, 16) + `"`
+	f.Headers = append(f.Headers, kv.Pair{Key: "ETag", Value: etag})
+}
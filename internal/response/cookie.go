@@ -0,0 +1,65 @@
+package response
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/indigo-web/indigo/http/cookie"
+)
+
+var zoneGMT = time.FixedZone("GMT", 0)
+
+// AppendCookie renders the cookie as a Set-Cookie header field value.
+func AppendCookie(buff []byte, c cookie.Cookie) []byte {
+	buff = append(buff, c.Name...)
+	buff = append(buff, '=')
+	buff = append(buff, c.Value...)
+	buff = append(buff, ';', ' ')
+
+	if len(c.Path) > 0 {
+		buff = append(buff, "Path="...)
+		buff = append(buff, c.Path...)
+		buff = append(buff, ';', ' ')
+	}
+
+	if len(c.Domain) > 0 {
+		buff = append(buff, "Domain="...)
+		buff = append(buff, c.Domain...)
+		buff = append(buff, ';', ' ')
+	}
+
+	if !c.Expires.IsZero() {
+		buff = append(buff, "Expires="...)
+		// TODO: this _may_ be slow. We could write it manually instead
+		buff = c.Expires.In(zoneGMT).AppendFormat(buff, time.RFC1123)
+		buff = append(buff, ';', ' ')
+	}
+
+	if c.MaxAge != 0 {
+		maxage := "0"
+		if c.MaxAge > 0 {
+			maxage = strconv.Itoa(c.MaxAge)
+		}
+
+		buff = append(buff, "MaxAge="...)
+		buff = append(buff, maxage...)
+		buff = append(buff, ';', ' ')
+	}
+
+	if len(c.SameSite) > 0 {
+		buff = append(buff, "SameSite="...)
+		buff = append(buff, c.SameSite...)
+		buff = append(buff, ';', ' ')
+	}
+
+	if c.Secure {
+		buff = append(buff, "Secure; "...)
+	}
+
+	if c.HttpOnly {
+		buff = append(buff, "HttpOnly; "...)
+	}
+
+	// strip last 2 bytes, which are always a semicolon and a space
+	return buff[:len(buff)-2]
+}
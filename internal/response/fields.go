@@ -14,13 +14,18 @@ type Fields struct {
 	Code            status.Code
 	Status          status.Status
 	AutoCompress    bool
+	HashETag        bool
 	ContentEncoding string
+	Precompressed   string
 	Charset         mime.Charset
 	Stream          io.Reader
 	StreamSize      int64
 	Buffer          []byte
 	Headers         []kv.Pair
 	Cookies         []cookie.Cookie
+	// Error is the error the response was built from, which is yet to be passed through the
+	// router's error handlers. Set only for validation errors.
+	Error error
 }
 
 func (f *Fields) Clear() {
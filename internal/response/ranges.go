@@ -0,0 +1,305 @@
+package response
+
+import (
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/strutil"
+	"github.com/indigo-web/indigo/kv"
+)
+
+// maxRanges limits the number of ranges in a single request. Requests exceeding it are served
+// the whole content instead, as such requests are most likely abusive.
+const maxRanges = 32
+
+type byteRange struct {
+	start, length int64
+}
+
+// Ranges serves the byte ranges requested by the Range header (RFC 9110, 14). Only successful
+// responses with sized seekable streams, i.e. implementing either io.ReadSeeker or io.ReaderAt,
+// are eligible. Such responses advertise the Accept-Ranges, however ranges are served to GET
+// requests only, and only if the If-Range condition, if any, holds. A single range is sent as
+// is, multiple ones are packed into a multipart/byteranges body. Unsatisfiable ranges result in
+// 416 Range Not Satisfiable.
+func Ranges(f *Fields, m method.Method, rangeHeader, ifRange string) {
+	if f.Code != status.OK || f.StreamSize <= 0 || !seekable(f.Stream) || len(f.ContentEncoding) > 0 {
+		return
+	}
+
+	if acceptRanges, found := lookup(f.Headers, "accept-ranges"); !found {
+		f.Headers = append(f.Headers, kv.Pair{Key: "Accept-Ranges", Value: "bytes"})
+	} else if !strutil.CmpFoldSafe(acceptRanges, "bytes") {
+		return
+	}
+
+	if m != method.GET || len(rangeHeader) == 0 || !ifRangeHolds(ifRange, f.Headers) {
+		return
+	}
+
+	if _, found := lookup(f.Headers, "content-range"); found {
+		return
+	}
+
+	size := f.StreamSize
+	ranges, ok := parseRange(rangeHeader, size)
+	if !ok {
+		return
+	}
+
+	// the content is served in identity coding, as ranges of a compressed representation are
+	// of no use unless it's compressed exactly the same way every time.
+	f.AutoCompress = false
+
+	switch len(ranges) {
+	case 0:
+		if c, ok := f.Stream.(io.Closer); ok {
+			_ = c.Close()
+		}
+
+		f.Code = status.RequestedRangeNotSatisfiable
+		f.Headers = append(f.Headers, kv.Pair{Key: "Content-Range", Value: "bytes */" + strconv.FormatInt(size, 10)})
+		f.Stream, f.StreamSize = nil, 0
+		f.Precompressed = ""
+	case 1:
+		stream, err := section(f.Stream, ranges[0])
+		if err != nil {
+			return
+		}
+
+		f.Code = status.PartialContent
+		f.Headers = append(f.Headers, kv.Pair{Key: "Content-Range", Value: contentRange(ranges[0], size)})
+		f.Stream, f.StreamSize = stream, ranges[0].length
+	default:
+		f.Code = status.PartialContent
+		f.Stream, f.StreamSize = byteranges(f, ranges, size)
+	}
+}
+
+// parseRange parses the Range header value against the content of the size. Malformed or
+// unsupported values result in false, meaning the header must be ignored. Otherwise, only
+// satisfiable ranges are returned.
+func parseRange(header string, size int64) (ranges []byteRange, ok bool) {
+	unit, set, found := strings.Cut(header, "=")
+	if !found || !strutil.CmpFoldSafe(strings.TrimSpace(unit), "bytes") {
+		return nil, false
+	}
+
+	specs := strings.Split(set, ",")
+	if len(specs) > maxRanges {
+		return nil, false
+	}
+
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if len(spec) == 0 {
+			continue
+		}
+
+		first, last, found := strings.Cut(spec, "-")
+		if !found {
+			return nil, false
+		}
+
+		if len(first) == 0 {
+			// the suffix range, i.e. the last N bytes.
+			suffix, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || suffix < 0 {
+				return nil, false
+			}
+
+			if suffix > 0 {
+				suffix = min(suffix, size)
+				ranges = append(ranges, byteRange{start: size - suffix, length: suffix})
+			}
+
+			continue
+		}
+
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, false
+		}
+
+		end := size - 1
+		if len(last) > 0 {
+			if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+				return nil, false
+			}
+
+			end = min(end, size-1)
+		}
+
+		if start < size {
+			ranges = append(ranges, byteRange{start: start, length: end - start + 1})
+		}
+	}
+
+	return ranges, true
+}
+
+// ifRangeHolds evaluates the If-Range condition (RFC 9110, 13.1.5). Entity tags are compared
+// strongly, dates must exactly match the Last-Modified.
+func ifRangeHolds(ifRange string, headers []kv.Pair) bool {
+	if len(ifRange) == 0 {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) {
+		etag, _ := lookup(headers, "etag")
+		return etag == ifRange
+	}
+
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+
+	lastModified, found := lookup(headers, "last-modified")
+	if !found {
+		return false
+	}
+
+	date, ok := ParseDate(ifRange)
+	if !ok {
+		return false
+	}
+
+	modified, ok := ParseDate(lastModified)
+	return ok && date.Equal(modified)
+}
+
+func contentRange(r byteRange, size int64) string {
+	return "bytes " + strconv.FormatInt(r.start, 10) + "-" + strconv.FormatInt(r.start+r.length-1, 10) +
+		"/" + strconv.FormatInt(size, 10)
+}
+
+// byteranges replaces the content type and returns the multipart/byteranges body with its length.
+func byteranges(f *Fields, ranges []byteRange, size int64) (io.Reader, int64) {
+	boundary := strconv.FormatUint(rand.Uint64(), 36) + strconv.FormatUint(rand.Uint64(), 36)
+
+	contentType, _ := lookup(f.Headers, "content-type")
+	if len(contentType) > 0 && f.Charset != mime.Unset {
+		contentType += "; charset=" + string(f.Charset)
+	}
+
+	f.Charset = mime.Unset
+	f.Headers = setHeader(f.Headers, "Content-Type", "multipart/byteranges; boundary="+boundary)
+
+	var (
+		parts  = make([]io.Reader, 0, len(ranges)*2+1)
+		length int64
+	)
+
+	for i, r := range ranges {
+		var head strings.Builder
+		if i > 0 {
+			head.WriteString("\r\n")
+		}
+
+		head.WriteString("--" + boundary + "\r\n")
+		if len(contentType) > 0 {
+			head.WriteString("Content-Type: " + contentType + "\r\n")
+		}
+
+		head.WriteString("Content-Range: " + contentRange(r, size) + "\r\n\r\n")
+		parts = append(parts, strings.NewReader(head.String()), &part{src: f.Stream, r: r})
+		length += int64(head.Len()) + r.length
+	}
+
+	tail := "\r\n--" + boundary + "--\r\n"
+	parts = append(parts, strings.NewReader(tail))
+	length += int64(len(tail))
+
+	return &rangeStream{Reader: io.MultiReader(parts...), src: f.Stream}, length
+}
+
+func seekable(stream io.Reader) bool {
+	switch stream.(type) {
+	case io.ReadSeeker, io.ReaderAt:
+		return true
+	default:
+		return false
+	}
+}
+
+// section returns the reader of the range. Seekers are preferred, as limited files are
+// still eligible for sendfile(2) on Linux.
+func section(src io.Reader, r byteRange) (io.Reader, error) {
+	if seeker, ok := src.(io.ReadSeeker); ok {
+		if _, err := seeker.Seek(r.start, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		return &rangeStream{Reader: &io.LimitedReader{R: seeker, N: r.length}, src: src}, nil
+	}
+
+	return &rangeStream{
+		Reader: io.NewSectionReader(src.(io.ReaderAt), r.start, r.length),
+		src:    src,
+	}, nil
+}
+
+// rangeStream reads a part of the stream, closing the whole stream afterward.
+type rangeStream struct {
+	io.Reader
+	src io.Reader
+}
+
+// WriteTo passes the reader as is, so the writer can engage its io.ReaderFrom.
+func (r *rangeStream) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, r.Reader)
+}
+
+func (r *rangeStream) Close() error {
+	if c, ok := r.src.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// part is a range of the multipart/byteranges body. As parts are read sequentially, the
+// stream is positioned lazily.
+type part struct {
+	src    io.Reader
+	r      byteRange
+	reader io.Reader
+}
+
+func (p *part) Read(b []byte) (int, error) {
+	if p.reader == nil {
+		reader, err := section(p.src, p.r)
+		if err != nil {
+			return 0, err
+		}
+
+		p.reader = reader.(*rangeStream).Reader
+	}
+
+	return p.reader.Read(b)
+}
+
+func lookup(headers []kv.Pair, key string) (string, bool) {
+	for _, header := range headers {
+		if strutil.CmpFoldSafe(header.Key, key) {
+			return header.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// setHeader replaces all the values of the header by the value.
+func setHeader(headers []kv.Pair, key, value string) []kv.Pair {
+	headers = slices.DeleteFunc(headers, func(header kv.Pair) bool {
+		return strutil.CmpFoldSafe(header.Key, key)
+	})
+
+	return append(headers, kv.Pair{Key: key, Value: value})
+}
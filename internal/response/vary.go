@@ -0,0 +1,32 @@
+package response
+
+import (
+	"strings"
+
+	"github.com/indigo-web/indigo/internal/strutil"
+	"github.com/indigo-web/indigo/kv"
+)
+
+// ContentType returns the Content-Type header value.
+func ContentType(headers []kv.Pair) string {
+	value, _ := lookup(headers, "content-type")
+	return value
+}
+
+// Varies tells whether the Vary header already lists the field or the asterisk.
+func Varies(headers []kv.Pair, field string) bool {
+	for _, header := range headers {
+		if !strutil.CmpFoldSafe(header.Key, "vary") {
+			continue
+		}
+
+		for _, token := range strings.Split(header.Value, ",") {
+			token = strings.TrimSpace(token)
+			if token == "*" || strutil.CmpFoldSafe(token, field) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,9 @@
 package strutil
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+)
 
 func LStripWS(str string) string {
 	for i, c := range str {
@@ -64,3 +67,23 @@ func Unquote(str string) string {
 func IsASCIINonprintable(c byte) bool {
 	return c < 0x20 || c > 0x7e
 }
+
+// ParseQuality returns the q parameter value in thousandths. Absent parameter means 1, whereas
+// malformed one means 0, i.e. not acceptable.
+func ParseQuality(params string) int {
+	for _, param := range strings.Split(params, ";") {
+		value, found := strings.CutPrefix(strings.TrimSpace(param), "q=")
+		if !found {
+			continue
+		}
+
+		q, err := strconv.ParseFloat(value, 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0
+		}
+
+		return int(q * 1000)
+	}
+
+	return 1000
+}
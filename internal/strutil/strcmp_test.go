@@ -49,6 +49,7 @@ func BenchmarkFoldFast(b *testing.B) {
 
 func TestFoldSafe(t *testing.T) {
 	require.True(t, CmpFoldSafe("HELLO", "hello"))
+	require.True(t, CmpFoldSafe("ABCDEFGHIJKLMNOPQRSTUVWXYZ", "abcdefghijklmnopqrstuvwxyz"))
 	require.True(t, CmpFoldSafe("\r\n\r\n", "\r\n\r\n"))
 	require.False(t, CmpFoldSafe("\v\t", "\r\t"))
 }
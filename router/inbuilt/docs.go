@@ -0,0 +1,57 @@
+package inbuilt
+
+import (
+	"reflect"
+
+	"github.com/indigo-web/indigo/http/method"
+)
+
+// Docs describe a route in the OpenAPI document.
+type Docs struct {
+	Summary     string
+	Description string
+	OperationID string
+	Tags        []string
+	// Params describe the path wildcards by their names.
+	Params map[string]string
+	// Request and Response are samples of the request and response models, e.g. User{}. They
+	// take precedence over the models attached by TypedRoute.
+	Request, Response any
+	Deprecated        bool
+	// Hidden excludes the route from the document.
+	Hidden bool
+}
+
+// routeInfo is a registered route, as seen by the OpenAPI document.
+type routeInfo struct {
+	method  method.Method
+	path    string
+	in, out reflect.Type
+	docs    Docs
+}
+
+// catalog lists all the registered routes in order. It's shared by the router and its groups.
+type catalog struct {
+	routes []*routeInfo
+}
+
+func newCatalog() *catalog {
+	return new(catalog)
+}
+
+func (c *catalog) add(m method.Method, path string) *routeInfo {
+	route := &routeInfo{method: m, path: path}
+	c.routes = append(c.routes, route)
+	return route
+}
+
+// Describe attaches the docs to the most recently registered route of the router. Calling it
+// before any route is registered results in panic.
+func (r *Router) Describe(docs Docs) *Router {
+	if r.last == nil {
+		panic("inbuilt: no route to describe")
+	}
+
+	r.last.docs = docs
+	return r
+}
@@ -0,0 +1,482 @@
+package inbuilt
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/codecutil"
+)
+
+// FileServer serves files from a file system, e.g. os.DirFS or embed.FS. Directories are served
+// by their index files, or optionally by HTML listings. The files are looked up by the path
+// dynamic routing segment.
+type FileServer struct {
+	fsys      fs.FS
+	index     string
+	spa       string
+	browse    bool
+	control   []cacheControl
+	encodings []encoding
+	ttl       time.Duration
+	mu        sync.Mutex
+	cache     map[string]*cachedFile
+}
+
+type cacheControl struct {
+	pattern, value string
+}
+
+type encoding struct {
+	token, ext string
+}
+
+// precompressedExts maps the content codings to the extensions of precompressed files.
+var precompressedExts = map[string]string{
+	"br":   ".br",
+	"zstd": ".zst",
+	"gzip": ".gz",
+}
+
+// NewFileServer returns a new file server of the file system. By default, directories are served
+// by their index.html.
+func NewFileServer(fsys fs.FS) *FileServer {
+	return &FileServer{
+		fsys:  fsys,
+		index: "index.html",
+		cache: make(map[string]*cachedFile),
+	}
+}
+
+// Index sets the name of the file, which is served for directories. Empty name disables it.
+func (f *FileServer) Index(name string) *FileServer {
+	f.index = name
+	return f
+}
+
+// SPA enables the single-page application mode, in which the document is served for all paths
+// not matching any existing file, so the client-side routing can take over. The document is
+// relative to the root of the file system.
+func (f *FileServer) SPA(document string) *FileServer {
+	f.spa = path.Clean(strings.TrimPrefix(document, "/"))
+	return f
+}
+
+// Browse enables HTML listings of directories without an index file.
+func (f *FileServer) Browse(flag bool) *FileServer {
+	f.browse = flag
+	return f
+}
+
+// CacheControl sets the Cache-Control header value for files matching the pattern. Patterns
+// without slashes are matched against the file name, others against the whole path relative to
+// the root. The syntax is the one of path.Match. The first matching pattern wins.
+func (f *FileServer) CacheControl(pattern, value string) *FileServer {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(err)
+	}
+
+	f.control = append(f.control, cacheControl{pattern: pattern, value: value})
+	return f
+}
+
+// Precompressed sets the content codings, whose precompressed siblings are looked up, e.g. app.js.gz
+// for app.js and gzip. The order defines the preference among codings equally acceptable by the
+// client. Supported codings are br (.br), zstd (.zst) and gzip (.gz). The lookup is disabled by
+// default, as it costs a filesystem lookup per coding for every file lacking some of the siblings.
+// Passing no codings disables it.
+func (f *FileServer) Precompressed(tokens ...string) *FileServer {
+	f.encodings = f.encodings[:0]
+	for _, token := range tokens {
+		ext, found := precompressedExts[token]
+		if !found {
+			panic(fmt.Sprintf("%s: unsupported precompressed coding", token))
+		}
+
+		f.encodings = append(f.encodings, encoding{token: token, ext: ext})
+	}
+
+	return f
+}
+
+// Cache enables caching of open file descriptors for the ttl. Expired descriptors are kept, unless
+// the size or the modification time of the file has changed. Only files implementing io.ReaderAt
+// (both os.DirFS and embed.FS do) are cached, as a cached descriptor is shared among concurrent
+// requests. Please note that cached files are read by offsets, so they don't benefit from
+// sendfile(2).
+func (f *FileServer) Cache(ttl time.Duration) *FileServer {
+	f.ttl = ttl
+	return f
+}
+
+// Invalidate drops all the cached descriptors, so the changes of the file system are picked up
+// immediately.
+func (f *FileServer) Invalidate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for name, file := range f.cache {
+		delete(f.cache, name)
+		file.release()
+	}
+}
+
+// Handler returns the handler serving the files.
+func (f *FileServer) Handler() Handler {
+	return f.serve
+}
+
+func (f *FileServer) serve(request *http.Request) *http.Response {
+	name := request.Vars.Value("path")
+	if !isSafe(name) {
+		return http.Error(request, status.ErrBadRequest)
+	}
+
+	name = path.Clean("/" + name)[1:]
+	if len(name) == 0 {
+		name = "."
+	}
+
+	stream, info, err := f.open(name)
+	switch {
+	case err == nil && info.IsDir():
+		_ = stream.Close()
+		return f.directory(request, name)
+	case err == nil:
+		return f.respond(request, name, stream, info)
+	case errors.Is(err, fs.ErrNotExist) && len(f.spa) > 0:
+		return f.file(request, f.spa)
+	default:
+		return fileError(request, err)
+	}
+}
+
+// directory serves the directory by its index or listing. As both may contain relative links,
+// they're served only by paths with the trailing slash, others are redirected to.
+func (f *FileServer) directory(request *http.Request, name string) *http.Response {
+	if len(f.index) > 0 {
+		index := path.Join(name, f.index)
+		if stream, info, err := f.open(index); err == nil {
+			if !info.IsDir() {
+				if !request.Env.TrailingSlash {
+					_ = stream.Close()
+					return redirectToDir(request)
+				}
+
+				return f.respond(request, index, stream, info)
+			}
+
+			_ = stream.Close()
+		}
+	}
+
+	switch {
+	case f.browse && !request.Env.TrailingSlash:
+		return redirectToDir(request)
+	case f.browse:
+		return f.listing(request, name)
+	case len(f.spa) > 0:
+		return f.file(request, f.spa)
+	default:
+		return http.Error(request, status.ErrNotFound)
+	}
+}
+
+// redirectToDir redirects to the path with the trailing slash, the same way net/http.FileServer
+// does. The location is relative, so it's correct regardless of the prefixes stripped by proxies.
+func redirectToDir(request *http.Request) *http.Response {
+	return request.Respond().
+		Code(status.MovedPermanently).
+		Header("Location", path.Base(request.Path)+"/")
+}
+
+func (f *FileServer) file(request *http.Request, name string) *http.Response {
+	stream, info, err := f.open(name)
+	if err != nil {
+		return fileError(request, err)
+	}
+
+	if info.IsDir() {
+		_ = stream.Close()
+		return http.Error(request, status.ErrNotFound)
+	}
+
+	return f.respond(request, name, stream, info)
+}
+
+func (f *FileServer) respond(request *http.Request, name string, stream io.ReadCloser, info fs.FileInfo) *http.Response {
+	response := request.Respond().ContentType(mime.Guess(name))
+
+	if value, found := f.cacheControl(name); found {
+		response.Header("Cache-Control", value)
+	}
+
+	token, sibling, siblingInfo, vary := f.precompressed(request, name)
+	if vary {
+		response.Header("Vary", "Accept-Encoding")
+	}
+
+	if sibling != nil {
+		_ = stream.Close()
+		stream, info = sibling, siblingInfo
+		response.Precompressed(token)
+	}
+
+	response.Validators(info)
+
+	if info.Size() == 0 {
+		// empty streams are never consumed, therefore never closed.
+		_ = stream.Close()
+		return response
+	}
+
+	return response.Stream(stream, info.Size())
+}
+
+// precompressed opens the most preferred by the client precompressed sibling of the file, if any.
+// The vary reports whether any sibling exists, so the representation depends on Accept-Encoding.
+func (f *FileServer) precompressed(request *http.Request, name string) (
+	token string, stream io.ReadCloser, info fs.FileInfo, vary bool,
+) {
+	if len(f.encodings) == 0 {
+		return "", nil, nil, false
+	}
+
+	type candidate struct {
+		encoding
+		quality int
+	}
+
+	candidates := make([]candidate, 0, len(f.encodings))
+	for _, enc := range f.encodings {
+		if quality := codecutil.Quality(request.AcceptEncoding, enc.token); quality > 0 {
+			candidates = append(candidates, candidate{enc, quality})
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return b.quality - a.quality
+	})
+
+	for _, c := range candidates {
+		stream, info, err := f.open(name + c.ext)
+		if err != nil {
+			continue
+		}
+
+		if info.IsDir() {
+			_ = stream.Close()
+			continue
+		}
+
+		return c.token, stream, info, true
+	}
+
+	for _, enc := range f.encodings {
+		if slices.ContainsFunc(candidates, func(c candidate) bool { return c.token == enc.token }) {
+			// already known to be missing.
+			continue
+		}
+
+		if _, err := fs.Stat(f.fsys, name+enc.ext); err == nil {
+			return "", nil, nil, true
+		}
+	}
+
+	return "", nil, nil, false
+}
+
+// open opens the file, preferring the cached descriptor if enabled.
+func (f *FileServer) open(name string) (io.ReadCloser, fs.FileInfo, error) {
+	if f.ttl > 0 {
+		if file := f.cached(name); file != nil {
+			return file.reader(), file.info, nil
+		}
+	}
+
+	fd, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := fd.Stat()
+	if err != nil {
+		_ = fd.Close()
+		return nil, nil, err
+	}
+
+	if f.ttl > 0 && !info.IsDir() {
+		if readerAt, ok := fd.(io.ReaderAt); ok {
+			return f.store(name, fd, readerAt, info).reader(), info, nil
+		}
+	}
+
+	return fd, info, nil
+}
+
+// cached returns the cached descriptor, revalidating it if expired. The returned descriptor
+// is already acquired.
+func (f *FileServer) cached(name string) *cachedFile {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, found := f.cache[name]
+	if !found {
+		return nil
+	}
+
+	now := time.Now()
+	if now.After(file.expires) {
+		info, err := fs.Stat(f.fsys, name)
+		if err != nil || info.Size() != file.info.Size() || !info.ModTime().Equal(file.info.ModTime()) {
+			delete(f.cache, name)
+			file.release()
+			return nil
+		}
+
+		file.expires = now.Add(f.ttl)
+	}
+
+	file.refs.Add(1)
+	return file
+}
+
+// store caches the descriptor and returns it acquired. If the file was already cached
+// concurrently, the descriptor is replaced.
+func (f *FileServer) store(name string, fd fs.File, readerAt io.ReaderAt, info fs.FileInfo) *cachedFile {
+	file := &cachedFile{
+		fd:       fd,
+		readerAt: readerAt,
+		info:     info,
+		expires:  time.Now().Add(f.ttl),
+	}
+	// one reference is held by the cache itself and another one by the caller.
+	file.refs.Store(2)
+
+	f.mu.Lock()
+	if prev, found := f.cache[name]; found {
+		prev.release()
+	}
+	f.cache[name] = file
+	f.mu.Unlock()
+
+	return file
+}
+
+func (f *FileServer) cacheControl(name string) (string, bool) {
+	for _, c := range f.control {
+		subject := name
+		if !strings.Contains(c.pattern, "/") {
+			subject = path.Base(name)
+		}
+
+		if matched, _ := path.Match(c.pattern, subject); matched {
+			return c.value, true
+		}
+	}
+
+	return "", false
+}
+
+// listing renders the HTML listing of the directory.
+func (f *FileServer) listing(request *http.Request, name string) *http.Response {
+	entries, err := fs.ReadDir(f.fsys, name)
+	if err != nil {
+		return fileError(request, err)
+	}
+
+	base := strings.TrimSuffix(request.Path, "/") + "/"
+	title := html.EscapeString(base)
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Index of ")
+	b.WriteString(title)
+	b.WriteString("</title>\n</head>\n<body>\n<h1>Index of ")
+	b.WriteString(title)
+	b.WriteString("</h1>\n<ul>\n")
+
+	if name != "." {
+		b.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+
+		href := (&url.URL{Path: base + entryName}).EscapedPath()
+		b.WriteString("<li><a href=\"")
+		b.WriteString(html.EscapeString(href))
+		b.WriteString("\">")
+		b.WriteString(html.EscapeString(entryName))
+		b.WriteString("</a></li>\n")
+	}
+
+	b.WriteString("</ul>\n</body>\n</html>\n")
+
+	return http.String(request, b.String()).ContentType(mime.HTML, mime.UTF8)
+}
+
+func fileError(request *http.Request, err error) *http.Response {
+	code := status.ErrInternalServerError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		code = status.ErrNotFound
+	case errors.Is(err, fs.ErrPermission):
+		code = status.ErrForbidden
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+
+	return http.Error(request, code).String(err.Error())
+}
+
+// cachedFile is a descriptor shared among concurrent requests. It's closed as soon as it's
+// evicted from the cache and no more requests are reading it.
+type cachedFile struct {
+	fd       fs.File
+	readerAt io.ReaderAt
+	info     fs.FileInfo
+	expires  time.Time
+	refs     atomic.Int64
+}
+
+func (c *cachedFile) reader() io.ReadCloser {
+	return &cachedReader{
+		SectionReader: io.NewSectionReader(c.readerAt, 0, c.info.Size()),
+		file:          c,
+	}
+}
+
+func (c *cachedFile) release() {
+	if c.refs.Add(-1) == 0 {
+		_ = c.fd.Close()
+	}
+}
+
+type cachedReader struct {
+	*io.SectionReader
+	file *cachedFile
+	once sync.Once
+}
+
+func (c *cachedReader) Close() error {
+	c.once.Do(c.file.release)
+	return nil
+}
@@ -1,6 +1,7 @@
 package inbuilt
 
 import (
+	"errors"
 	"path"
 
 	"github.com/indigo-web/indigo/http"
@@ -32,6 +33,9 @@ type Router struct {
 	children     []*Router
 	traceHandler Handler
 	errHandlers  errorHandlers
+	catalog      *catalog
+	// last is the most recently registered route, which is affected by the Describe.
+	last *routeInfo
 }
 
 // New constructs a new instance of inbuilt router
@@ -39,6 +43,7 @@ func New() *Router {
 	return &Router{
 		registrar:   newRegistrar(),
 		errHandlers: newErrorHandlers(),
+		catalog:     newCatalog(),
 	}
 }
 
@@ -53,6 +58,8 @@ func (r *Router) Route(method method.Method, path string, handler Handler, middl
 		panic(err)
 	}
 
+	r.last = r.catalog.add(method, uri.Normalize(r.prefix+path))
+
 	return r
 }
 
@@ -73,8 +80,11 @@ func (r *Router) Route(method method.Method, path string, handler Handler, middl
 //   - status.UnsupportedMediaType
 //   - status.NotImplemented
 //   - status.RequestTimeout
+//   - status.UnprocessableEntity
 //
-// Note: if handler returned one of error codes above, error handler WON'T be called.
+// Note: if handler returned one of error codes above, error handler WON'T be called. The only
+// exception are validation errors responded via http.Error, which are passed through the
+// status.UnprocessableEntity handler with the Request.Env.Error set to validate.Errors.
 // Also, global middlewares, applied to the root router, will also be used for error handlers.
 // However, global middlewares defined on groups won't be used.
 //
@@ -112,6 +122,7 @@ func (r *Router) Group(prefix string) *Router {
 		prefix:      r.prefix + prefix,
 		registrar:   newRegistrar(),
 		errHandlers: r.errHandlers,
+		catalog:     r.catalog,
 	}
 
 	r.children = append(r.children, subrouter)
@@ -212,6 +223,7 @@ func (r *Router) Build() router.Router {
 
 // OnRequest processes the request
 func (r *runtimeRouter) OnRequest(request *http.Request) *http.Response {
+	request.Env.TrailingSlash = len(request.Path) > 1 && request.Path[len(request.Path)-1] == '/'
 	request.Path = uri.Normalize(request.Path)
 	r.runMutators(request)
 
@@ -241,7 +253,14 @@ func (r *runtimeRouter) onRequest(request *http.Request) *http.Response {
 		return r.onError(request, status.ErrMethodNotAllowed)
 	}
 
-	return handler(request)
+	resp := handler(request)
+	if resp != nil && resp.Expose().Error != nil {
+		// the handler responded with an error, e.g. a validation one, which has to be formatted
+		// by the error handlers.
+		return r.onError(request, resp.Expose().Error)
+	}
+
+	return resp
 }
 
 // OnError uses a user-defined error handler, otherwise default http.Error
@@ -265,12 +284,17 @@ func (r *runtimeRouter) onError(request *http.Request, err error) *http.Response
 	}
 
 	httpErr, ok := err.(status.HTTPError)
-	if !ok {
+	if !ok && !errors.As(err, &httpErr) {
 		return http.Code(request, status.InternalServerError)
 	}
 
 	handler := r.retrieveErrorHandler(httpErr.Code)
 	if handler == nil {
+		if !ok {
+			// the error only wraps the HTTP one, so it's likely to be more descriptive.
+			return http.Error(request, err)
+		}
+
 		// not using http.Error(request, err) in performance purposes, as in this case
 		// it would try under the hood to unwrap the error again, however we did this already
 		return request.Respond().
@@ -11,6 +11,7 @@ import (
 	"github.com/indigo-web/indigo/http"
 	"github.com/indigo-web/indigo/http/method"
 	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/http/validate"
 	"github.com/indigo-web/indigo/internal/construct"
 	"github.com/indigo-web/indigo/kv"
 	"github.com/indigo-web/indigo/router"
@@ -453,6 +454,46 @@ func TestRouterErrors(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, sample, string(data))
 	})
+
+	t.Run("validation error", func(t *testing.T) {
+		violations := validate.Errors{{Field: "name", Rule: "required", Message: "is required"}}
+		handler := func(req *http.Request) *http.Response {
+			return http.Error(req, violations)
+		}
+
+		t.Run("default", func(t *testing.T) {
+			r := New().Post("/", handler).Build()
+			resp := r.OnRequest(getRequest(method.POST, "/"))
+			require.Equal(t, status.UnprocessableEntity, resp.Expose().Code)
+			require.JSONEq(t,
+				`{"errors":[{"field":"name","rule":"required","message":"is required"}]}`,
+				string(resp.Expose().Buffer),
+			)
+		})
+
+		t.Run("custom", func(t *testing.T) {
+			r := New().
+				Post("/", handler).
+				RouteError(func(req *http.Request) *http.Response {
+					var errs validate.Errors
+					require.True(t, errors.As(req.Env.Error, &errs))
+
+					return req.Respond().
+						Code(status.BadRequest).
+						String(errs[0].Field)
+				}, status.UnprocessableEntity).
+				Build()
+
+			resp := r.OnRequest(getRequest(method.POST, "/"))
+			require.Equal(t, status.BadRequest, resp.Expose().Code)
+			require.Equal(t, "name", readbody(t, resp.Expose().Stream))
+		})
+
+		t.Run("OnError", func(t *testing.T) {
+			resp := r.OnError(getRequest(method.GET, "/"), violations)
+			require.Equal(t, status.UnprocessableEntity, resp.Expose().Code)
+		})
+	})
 }
 
 func TestAliases(t *testing.T) {
@@ -7,10 +7,26 @@ import (
 	"github.com/indigo-web/indigo/router/inbuilt"
 )
 
+// CustomContext makes values of the ctx available via Request.Ctx. The request context still
+// controls the cancellation, so handlers are notified about disconnects and deadlines as usual.
 func CustomContext(ctx context.Context) inbuilt.Middleware {
 	return func(next inbuilt.Handler, request *http.Request) *http.Response {
-		request.Ctx = ctx
+		request.Ctx = valuesContext{Context: request.Ctx, values: ctx}
 
 		return next(request)
 	}
 }
+
+// valuesContext looks the values up in the custom context first.
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (v valuesContext) Value(key any) any {
+	if value := v.values.Value(key); value != nil {
+		return value
+	}
+
+	return v.Context.Value(key)
+}
@@ -18,19 +18,20 @@ type HTTPOnlyParams struct {
 }
 
 // HTTPSOnly redirects all http requests to https. In case no Host header is provided,
-// 400 Bad Request will be returned without calling the actual handler.
+// 400 Bad Request will be returned without calling the actual handler. Requests forwarded
+// by trusted proxies (see RealIP) are judged by the scheme and host the client used.
 func HTTPSOnly(optionalParams ...HTTPOnlyParams) inbuilt.Middleware {
 	params := optional(optionalParams, HTTPOnlyParams{})
 
 	return func(next inbuilt.Handler, request *http.Request) *http.Response {
-		if request.Env.Encryption != 0 {
+		if request.Scheme() == "https" {
 			return next(request)
 		}
 
 		host := params.RedirectTo
 
 		if len(host) == 0 {
-			host = removePort(request.Headers.Value("host"))
+			host = removePort(request.Host())
 			if len(host) == 0 {
 				return request.Respond().
 					Code(status.BadRequest).
@@ -52,7 +53,7 @@ func HTTPSOnly(optionalParams ...HTTPOnlyParams) inbuilt.Middleware {
 }
 
 func removePort(str string) string {
-	if colon := strings.IndexByte(str, ':'); colon != -1 {
+	if colon := strings.LastIndexByte(str, ':'); colon != -1 && colon > strings.LastIndexByte(str, ']') {
 		return str[:colon]
 	}
 
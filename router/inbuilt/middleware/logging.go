@@ -24,7 +24,10 @@ func LogRequests(loggers ...Logger) inbuilt.Middleware {
 		}
 
 		for _, logger := range loggers {
-			logger.Printf("%s %s %d", request.Method.String(), request.Path, response.Expose().Code)
+			logger.Printf(
+				"%s %s %s %d",
+				request.ClientAddr(), request.Method.String(), request.Path, response.Expose().Code,
+			)
 		}
 
 		return response
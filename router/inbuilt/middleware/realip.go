@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/internal/netutil"
+	"github.com/indigo-web/indigo/router/inbuilt"
+)
+
+// RealIP resolves the actual client address, scheme and host from headers set by trusted proxies
+// and stores them in request.Env.Forwarded. The headers are consulted only if the immediate peer
+// belongs to one of the trusted networks, which are either CIDRs or single addresses. Peers
+// connected via non-IP transports, e.g. Unix sockets, are always trusted.
+//
+// The Forwarded header (RFC 7239) takes precedence over X-Forwarded-For, which in turn takes
+// precedence over X-Real-IP. The hop chain is walked from right to left, skipping trusted proxies,
+// so the first untrusted hop is considered to be the client. Scheme and host are taken from
+// the same hop, falling back to X-Forwarded-Proto and X-Forwarded-Host respectively.
+//
+// Any middleware relying on the client address (e.g. HTTPSOnly or LogRequests) must be
+// registered after this one.
+func RealIP(trusted ...string) inbuilt.Middleware {
+	networks := netutil.MustParseNetworks(trusted)
+
+	return func(next inbuilt.Handler, request *http.Request) *http.Response {
+		if peer, ok := netutil.AddrOf(request.Remote); !ok || netutil.Contains(networks, peer) {
+			request.Env.Forwarded = resolveForwarded(networks, request.Headers)
+		}
+
+		return next(request)
+	}
+}
+
+type hop struct {
+	addr        netip.AddrPort
+	proto, host string
+}
+
+func resolveForwarded(trusted []netip.Prefix, headers http.Headers) (fwd http.Forwarded) {
+	var hops []hop
+
+	if headers.Has("forwarded") {
+		hops = parseForwarded(headers)
+	} else if headers.Has("x-forwarded-for") {
+		hops = parseXFF(headers)
+	} else if realIP, found := headers.Lookup("x-real-ip"); found {
+		hops = append(hops, hop{
+			addr:  parseNode(strings.TrimSpace(realIP)),
+			proto: headers.Value("x-forwarded-proto"),
+			host:  headers.Value("x-forwarded-host"),
+		})
+	}
+
+	if len(hops) == 0 {
+		return fwd
+	}
+
+	client := hops[0]
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !hops[i].addr.IsValid() || !netutil.Contains(trusted, hops[i].addr.Addr()) {
+			client = hops[i]
+			break
+		}
+	}
+
+	if client.addr.IsValid() {
+		fwd.Addr = net.TCPAddrFromAddrPort(client.addr)
+	}
+
+	switch proto := strings.ToLower(strings.TrimSpace(client.proto)); proto {
+	case "http", "https":
+		fwd.Scheme = proto
+	}
+
+	if host := strings.TrimSpace(client.host); isValidHost(host) {
+		fwd.Host = host
+	}
+
+	return fwd
+}
+
+// parseForwarded parses all the Forwarded header values. Malformed pairs are ignored.
+func parseForwarded(headers http.Headers) (hops []hop) {
+	for value := range headers.Values("forwarded") {
+		for len(value) > 0 {
+			var (
+				element string
+				h       hop
+			)
+
+			element, value = cutUnquoted(value, ',')
+			for len(element) > 0 {
+				var pair string
+				pair, element = cutUnquoted(element, ';')
+				key, val, found := strings.Cut(pair, "=")
+				if !found {
+					continue
+				}
+
+				val = unquote(strings.TrimSpace(val))
+
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "for":
+					h.addr = parseNode(val)
+				case "proto":
+					h.proto = val
+				case "host":
+					h.host = val
+				}
+			}
+
+			hops = append(hops, h)
+		}
+	}
+
+	return hops
+}
+
+// parseXFF parses the X-Forwarded-For values. X-Forwarded-Proto and X-Forwarded-Host are
+// matched to the hops by their index if their lengths are equal, otherwise the leftmost
+// value is used for every hop.
+func parseXFF(headers http.Headers) (hops []hop) {
+	for value := range headers.Values("x-forwarded-for") {
+		for _, node := range strings.Split(value, ",") {
+			hops = append(hops, hop{addr: parseNode(strings.TrimSpace(node))})
+		}
+	}
+
+	protos, hosts := listOf(headers, "x-forwarded-proto"), listOf(headers, "x-forwarded-host")
+	for i := range hops {
+		hops[i].proto = pick(protos, i, len(hops))
+		hops[i].host = pick(hosts, i, len(hops))
+	}
+
+	return hops
+}
+
+func listOf(headers http.Headers, key string) (list []string) {
+	for value := range headers.Values(key) {
+		list = append(list, strings.Split(value, ",")...)
+	}
+
+	return list
+}
+
+func pick(list []string, i, hops int) string {
+	switch {
+	case len(list) == 0:
+		return ""
+	case len(list) == hops:
+		return list[i]
+	default:
+		return list[0]
+	}
+}
+
+// parseNode parses a node identifier, which is either an IPv4 address or a bracketed IPv6
+// address, both with an optional port. Obfuscated identifiers and "unknown" result in an
+// invalid address.
+func parseNode(node string) netip.AddrPort {
+	if addrport, err := netip.ParseAddrPort(node); err == nil {
+		return netip.AddrPortFrom(addrport.Addr().Unmap(), addrport.Port())
+	}
+
+	if len(node) > 1 && node[0] == '[' && node[len(node)-1] == ']' {
+		node = node[1 : len(node)-1]
+	}
+
+	// X-Forwarded-For can also contain bare IPv6 addresses.
+	addr, err := netip.ParseAddr(node)
+	if err != nil || addr.Zone() != "" {
+		return netip.AddrPort{}
+	}
+
+	return netip.AddrPortFrom(addr.Unmap(), 0)
+}
+
+// cutUnquoted works like strings.Cut, except separators inside quoted strings are ignored.
+func cutUnquoted(str string, sep byte) (before, after string) {
+	var quoted, escaped bool
+
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			return str[:i], str[i+1:]
+		}
+	}
+
+	return str, ""
+}
+
+func unquote(str string) string {
+	if len(str) < 2 || str[0] != '"' || str[len(str)-1] != '"' {
+		return str
+	}
+
+	if unquoted, err := strconv.Unquote(str); err == nil {
+		return unquoted
+	}
+
+	return str[1 : len(str)-1]
+}
+
+func isValidHost(host string) bool {
+	if len(host) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(host); i++ {
+		if c := host[i]; c <= ' ' || c >= 0x7f || c == '/' || c == '\\' || c == '@' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"net"
+	"testing"
+
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/construct"
+	"github.com/indigo-web/indigo/transport/dummy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealIP(t *testing.T) {
+	mware := RealIP("10.0.0.0/8", "192.168.0.1")
+
+	resolve := func(remote string, headers ...string) http.Forwarded {
+		request := construct.Request(config.Default(), dummy.NewNopClient())
+		request.Remote, _ = net.ResolveTCPAddr("tcp", remote)
+		for i := 0; i < len(headers); i += 2 {
+			request.Headers.Add(headers[i], headers[i+1])
+		}
+
+		var fwd http.Forwarded
+		mware(func(request *http.Request) *http.Response {
+			fwd = request.Env.Forwarded
+			return http.Respond(request)
+		}, request)
+
+		return fwd
+	}
+
+	t.Run("Forwarded", func(t *testing.T) {
+		fwd := resolve("10.0.0.1:1234",
+			"Forwarded", `for=203.0.113.5;proto=https;host=example.com, for="[2001:db8::1]:4711"`,
+			"Forwarded", `for=192.168.0.1;proto=http`,
+		)
+		require.Equal(t, "[2001:db8::1]:4711", fwd.Addr.String())
+		require.Empty(t, fwd.Scheme)
+		require.Empty(t, fwd.Host)
+
+		fwd = resolve("10.0.0.1:1234",
+			"Forwarded", `For="203.0.113.5:80";Proto=HTTPS;Host="example.com", for=10.1.1.1`,
+		)
+		require.Equal(t, "203.0.113.5:80", fwd.Addr.String())
+		require.Equal(t, "https", fwd.Scheme)
+		require.Equal(t, "example.com", fwd.Host)
+	})
+
+	t.Run("Forwarded obfuscated", func(t *testing.T) {
+		fwd := resolve("10.0.0.1:1234", "Forwarded", `for=203.0.113.5, for=_hidden;proto=https, for=10.0.0.2`)
+		require.Nil(t, fwd.Addr)
+		require.Equal(t, "https", fwd.Scheme)
+	})
+
+	t.Run("all trusted", func(t *testing.T) {
+		fwd := resolve("10.0.0.1:1234", "X-Forwarded-For", "10.0.0.3, 10.0.0.2")
+		require.Equal(t, "10.0.0.3:0", fwd.Addr.String())
+	})
+
+	t.Run("X-Forwarded-For", func(t *testing.T) {
+		fwd := resolve("10.0.0.1:1234",
+			"X-Forwarded-For", "1.1.1.1, 203.0.113.5",
+			"X-Forwarded-For", "10.0.0.2",
+			"X-Forwarded-Proto", "https",
+			"X-Forwarded-Host", "example.com",
+		)
+		require.Equal(t, "203.0.113.5:0", fwd.Addr.String())
+		require.Equal(t, "https", fwd.Scheme)
+		require.Equal(t, "example.com", fwd.Host)
+
+		fwd = resolve("10.0.0.1:1234",
+			"X-Forwarded-For", "203.0.113.5, 10.0.0.2",
+			"X-Forwarded-Proto", "http, https",
+		)
+		require.Equal(t, "203.0.113.5:0", fwd.Addr.String())
+		require.Equal(t, "http", fwd.Scheme)
+	})
+
+	t.Run("X-Real-IP", func(t *testing.T) {
+		fwd := resolve("192.168.0.1:1234", "X-Real-IP", "2001:db8::1")
+		require.Equal(t, "[2001:db8::1]:0", fwd.Addr.String())
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		fwd := resolve("10.0.0.1:1234",
+			"X-Real-IP", "1.1.1.1",
+			"X-Forwarded-For", "2.2.2.2",
+			"Forwarded", "for=3.3.3.3",
+		)
+		require.Equal(t, "3.3.3.3:0", fwd.Addr.String())
+	})
+
+	t.Run("untrusted peer", func(t *testing.T) {
+		fwd := resolve("192.168.0.2:1234",
+			"X-Forwarded-For", "203.0.113.5",
+			"X-Forwarded-Proto", "https",
+		)
+		require.Equal(t, http.Forwarded{}, fwd)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		fwd := resolve("10.0.0.1:1234",
+			"X-Forwarded-For", "not an ip",
+			"X-Forwarded-Proto", "gopher",
+			"X-Forwarded-Host", "evil.com/path",
+		)
+		require.Equal(t, http.Forwarded{}, fwd)
+	})
+
+	t.Run("bad network", func(t *testing.T) {
+		require.PanicsWithError(t, "bad network: 10.0.0.0/33", func() {
+			RealIP("10.0.0.0/33")
+		})
+	})
+}
+
+func location(response *http.Response) string {
+	for _, header := range response.Expose().Headers {
+		if header.Key == "Location" {
+			return header.Value
+		}
+	}
+
+	return ""
+}
+
+func TestHTTPSOnly(t *testing.T) {
+	mware := HTTPSOnly(HTTPOnlyParams{Port: "443"})
+
+	call := func(fwd http.Forwarded) *http.Response {
+		request := construct.Request(config.Default(), dummy.NewNopClient())
+		request.Path = "/hello"
+		request.Headers.Add("Host", "localhost:8080")
+		request.Env.Forwarded = fwd
+
+		return mware(http.Respond, request)
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		response := call(http.Forwarded{})
+		require.Equal(t, status.MovedPermanently, response.Expose().Code)
+		require.Equal(t, "https://localhost:443/hello", location(response))
+	})
+
+	t.Run("forwarded https", func(t *testing.T) {
+		response := call(http.Forwarded{Scheme: "https"})
+		require.Equal(t, status.OK, response.Expose().Code)
+	})
+
+	t.Run("forwarded host", func(t *testing.T) {
+		response := call(http.Forwarded{Scheme: "http", Host: "[::1]:80"})
+		require.Equal(t, "https://[::1]:443/hello", location(response))
+	})
+}
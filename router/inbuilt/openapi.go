@@ -0,0 +1,198 @@
+package inbuilt
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/router/inbuilt/openapi"
+)
+
+// OpenAPI generates the OpenAPI 3.1 document of all the routes registered so far, including the
+// ones of the groups and the parents. Routes are described by their Docs, if any, whereas the
+// models attached by TypedRoute make up the parameters, request bodies and responses.
+// Path wildcards become path parameters named after them. The models are described in every
+// passed media type, which are usually the http.Response.MediaTypes, or in JSON if none passed.
+// Routes without the response model are documented by the default response, except for typed
+// handlers, whose nil models are responded by status.NoContent.
+func (r *Router) OpenAPI(info openapi.Info, mediaTypes ...mime.MIME) *openapi.Document {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []mime.MIME{mime.JSON}
+	}
+
+	doc := openapi.New(info)
+	gen := openapi.NewGenerator().MediaTypes(mediaTypes...)
+
+	for _, route := range r.catalog.routes {
+		if route.docs.Hidden {
+			continue
+		}
+
+		path, wildcards := openAPIPath(route.path)
+		item := doc.Paths[path]
+		if item == nil {
+			item = &openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+
+		(*item)[strings.ToLower(route.method.String())] = operation(gen, route, wildcards)
+	}
+
+	doc.Components = gen.Components()
+
+	return doc
+}
+
+// ServeOpenAPI serves the OpenAPI document at the path. It's encoded in YAML if the path has
+// the .yaml or .yml extension, otherwise in JSON. The document is generated once requested for
+// the first time, so it includes the routes registered afterward, too, and the models are described
+// in every media type of the config.Config.Media. The route itself is excluded from the document.
+func (r *Router) ServeOpenAPI(path string, info openapi.Info) *Router {
+	var (
+		once        sync.Once
+		spec        []byte
+		err         error
+		contentType = mime.JSON
+		encode      = (*openapi.Document).JSON
+	)
+
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		contentType, encode = mime.YAML, (*openapi.Document).YAML
+	}
+
+	return r.
+		Get(path, func(request *http.Request) *http.Response {
+			once.Do(func() {
+				spec, err = encode(r.OpenAPI(info, request.Respond().MediaTypes()...))
+			})
+
+			if err != nil {
+				return http.Error(request, err)
+			}
+
+			return request.Respond().
+				ContentType(contentType).
+				Bytes(spec)
+		}).
+		Describe(Docs{Hidden: true})
+}
+
+func operation(gen *openapi.Generator, route *routeInfo, wildcards []string) *openapi.Operation {
+	docs := route.docs
+	op := &openapi.Operation{
+		Tags:        docs.Tags,
+		Summary:     docs.Summary,
+		Description: docs.Description,
+		OperationID: docs.OperationID,
+		Deprecated:  docs.Deprecated,
+		Responses:   make(map[string]*openapi.Response),
+	}
+
+	in, out := route.in, route.out
+	if docs.Request != nil {
+		in = reflect.TypeOf(docs.Request)
+	}
+
+	if docs.Response != nil {
+		out = reflect.TypeOf(docs.Response)
+	}
+
+	if in != nil {
+		op.Parameters, op.RequestBody = gen.Input(in)
+	}
+
+	for _, name := range wildcards {
+		i := findParam(op.Parameters, name)
+		if i == -1 {
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &openapi.Schema{Type: "string"},
+			})
+			i = len(op.Parameters) - 1
+		}
+
+		op.Parameters[i].Description = docs.Params[name]
+	}
+
+	if out != nil {
+		op.Responses[status.StringCode(status.OK)] = &openapi.Response{
+			Description: status.String(status.OK),
+			Content:     gen.Content(out),
+		}
+	} else {
+		// nothing is known about responses of plain handlers.
+		op.Responses["default"] = &openapi.Response{Description: "Response"}
+	}
+
+	if route.out != nil && nullable(route.out) {
+		// typed handlers respond nil models by status.NoContent.
+		op.Responses[status.StringCode(status.NoContent)] = &openapi.Response{
+			Description: status.String(status.NoContent),
+		}
+	}
+
+	if len(op.Parameters) > 0 || op.RequestBody != nil {
+		for _, code := range []status.Code{status.BadRequest, status.UnprocessableEntity} {
+			op.Responses[status.StringCode(code)] = &openapi.Response{Description: status.String(code)}
+		}
+	}
+
+	return op
+}
+
+func nullable(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Interface
+}
+
+func findParam(params []openapi.Parameter, name string) int {
+	for i, param := range params {
+		if param.In == "path" && param.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// openAPIPath converts the path template into the OpenAPI notation, e.g. /users/:id into
+// /users/{id}, returning the names of the wildcards. Anonymous wildcards are named by their
+// position.
+func openAPIPath(path string) (string, []string) {
+	var (
+		b         strings.Builder
+		wildcards []string
+	)
+
+	for {
+		colon := strings.IndexByte(path, ':')
+		if colon == -1 {
+			b.WriteString(path)
+			break
+		}
+
+		b.WriteString(path[:colon])
+		path = path[colon+1:]
+
+		boundary := strings.IndexByte(path, '/')
+		if boundary == -1 {
+			boundary = len(path)
+		}
+
+		name := strings.TrimSuffix(path[:boundary], "...")
+		if len(name) == 0 {
+			name = "param" + strconv.Itoa(len(wildcards)+1)
+		}
+
+		wildcards = append(wildcards, name)
+		b.WriteString("{" + name + "}")
+		path = path[boundary:]
+	}
+
+	return b.String(), wildcards
+}
@@ -0,0 +1,345 @@
+package openapi
+
+import (
+	"encoding"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/indigo-web/indigo/http/form"
+	"github.com/indigo-web/indigo/http/mime"
+)
+
+// Generator produces schemas of Go types. Named structs are collected into the components
+// and referenced, so recursive types are supported.
+//
+// Structs are described by their json tags, whereas the validate tags (as of the
+// validate.Validator) are turned into the constraints, e.g. required or minLength.
+type Generator struct {
+	schemas    map[string]*Schema
+	names      map[reflect.Type]string
+	mediaTypes []string
+}
+
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas:    make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+		mediaTypes: []string{mime.JSON},
+	}
+}
+
+// MediaTypes sets the media types the models are encoded in, both in requests and responses.
+// By default, it's JSON only.
+func (g *Generator) MediaTypes(types ...string) *Generator {
+	g.mediaTypes = types
+	return g
+}
+
+// Content describes the model encoded in each of the media types.
+func (g *Generator) Content(typ reflect.Type) map[string]MediaType {
+	schema := g.Schema(typ)
+	content := make(map[string]MediaType, len(g.mediaTypes))
+	for _, m := range g.mediaTypes {
+		content[m] = MediaType{Schema: schema}
+	}
+
+	return content
+}
+
+// Components returns the collected schemas, or nil if there are none.
+func (g *Generator) Components() *Components {
+	if len(g.schemas) == 0 {
+		return nil
+	}
+
+	return &Components{Schemas: g.schemas}
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	bytesType           = reflect.TypeOf([]byte(nil))
+	formDataType        = reflect.TypeOf(form.Data{})
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Schema returns the schema of the type.
+func (g *Generator) Schema(typ reflect.Type) *Schema {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case typ == bytesType:
+		return &Schema{Type: "string", Format: "byte"}
+	case typ == formDataType:
+		return &Schema{Type: "string", Format: "binary"}
+	case typ.Kind() != reflect.String && isText(typ):
+		return &Schema{Type: "string"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.Schema(typ.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.Schema(typ.Elem())}
+	case reflect.Struct:
+		if len(typ.Name()) == 0 {
+			return g.object(typ)
+		}
+
+		return g.ref(typ)
+	default:
+		// interfaces and anything else may hold arbitrary values.
+		return &Schema{}
+	}
+}
+
+func (g *Generator) ref(typ reflect.Type) *Schema {
+	name, found := g.names[typ]
+	if !found {
+		name = g.name(typ)
+		g.names[typ] = name
+		// the placeholder terminates the recursion of self-referencing types.
+		placeholder := new(Schema)
+		g.schemas[name] = placeholder
+		*placeholder = *g.object(typ)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// name returns the unique name of the type among the components.
+func (g *Generator) name(typ reflect.Type) string {
+	base := typ.Name()
+	if i := strings.IndexByte(base, '['); i != -1 {
+		// generic types are named after their type arguments, which are hardly readable.
+		base = base[:i]
+	}
+
+	name := base
+	for i := 2; ; i++ {
+		if _, taken := g.schemas[name]; !taken {
+			return name
+		}
+
+		name = base + strconv.Itoa(i)
+	}
+}
+
+func (g *Generator) object(typ reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.properties(schema, typ)
+
+	return schema
+}
+
+func (g *Generator) properties(schema *Schema, typ reflect.Type) {
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && len(name) == 0 && fieldType.Kind() == reflect.Struct {
+			g.properties(schema, fieldType)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		prop, required := g.field(field)
+		schema.Properties[name] = prop
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// field returns the schema of the struct field, constrained by its validate tag.
+func (g *Generator) field(field reflect.StructField) (schema *Schema, required bool) {
+	schema = g.Schema(field.Type)
+	if len(schema.Ref) > 0 {
+		// constraints can't be applied to the referenced schema without altering it.
+		return schema, strings.Contains(","+field.Tag.Get("validate")+",", ",required,")
+	}
+
+	for _, token := range strings.Split(field.Tag.Get("validate"), ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(token), "=")
+		switch rule {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			constrain(schema, rule, param)
+		case "regex":
+			schema.Pattern = param
+		case "enum":
+			for _, option := range strings.Split(param, "|") {
+				schema.Enum = append(schema.Enum, option)
+			}
+		case "email":
+			schema.Format = "email"
+		case "uuid":
+			schema.Format = "uuid"
+		}
+	}
+
+	return schema, required
+}
+
+func constrain(schema *Schema, rule, param string) {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	var lower, upper **int
+	switch schema.Type {
+	case "integer", "number":
+		if rule != "max" {
+			schema.Minimum = &bound
+		}
+		if rule != "min" {
+			schema.Maximum = &bound
+		}
+
+		return
+	case "string":
+		lower, upper = &schema.MinLength, &schema.MaxLength
+	case "array":
+		lower, upper = &schema.MinItems, &schema.MaxItems
+	default:
+		return
+	}
+
+	n := int(bound)
+	if rule != "max" {
+		*lower = &n
+	}
+	if rule != "min" {
+		*upper = &n
+	}
+}
+
+// Input describes the struct bound by the http.Bind. The fields tagged by path, query, header
+// and cookie become parameters, whereas the form ones make up the form request body. The JSON
+// request body is described by the whole struct in each of the media types, if any of its JSON
+// fields isn't bound otherwise.
+func (g *Generator) Input(typ reflect.Type) (params []Parameter, body *RequestBody) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	var (
+		formSchema *Schema
+		hasBody    bool
+	)
+
+	for _, field := range fields(typ) {
+		source, key, bound := binding(field)
+		switch {
+		case !bound:
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			hasBody = hasBody || name != "-"
+		case source == "form":
+			if formSchema == nil {
+				formSchema = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+			}
+
+			prop, required := g.field(field)
+			formSchema.Properties[key] = prop
+			if required {
+				formSchema.Required = append(formSchema.Required, key)
+			}
+		default:
+			schema, required := g.field(field)
+			params = append(params, Parameter{
+				Name:     key,
+				In:       source,
+				Required: required || source == "path",
+				Schema:   schema,
+			})
+		}
+	}
+
+	switch {
+	case formSchema != nil:
+		body = &RequestBody{Content: map[string]MediaType{
+			mime.FormUrlencoded: {Schema: formSchema},
+			mime.Multipart:      {Schema: formSchema},
+		}}
+	case hasBody:
+		body = &RequestBody{
+			Required: true,
+			Content:  g.Content(typ),
+		}
+	}
+
+	return params, body
+}
+
+// fields returns the exported fields of the struct, including the ones of embedded structs.
+func fields(typ reflect.Type) (result []reflect.StructField) {
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			result = append(result, fields(field.Type)...)
+			continue
+		}
+
+		if field.IsExported() {
+			result = append(result, field)
+		}
+	}
+
+	return result
+}
+
+// binding returns the source of the field, as of the http.Bind.
+func binding(field reflect.StructField) (source, key string, found bool) {
+	for _, source = range []string{"path", "query", "header", "cookie", "form"} {
+		if key, found = field.Tag.Lookup(source); found {
+			return source, key, true
+		}
+	}
+
+	return "", "", false
+}
+
+func isText(typ reflect.Type) bool {
+	ptr := reflect.PointerTo(typ)
+	return (typ.Implements(textMarshalerType) || ptr.Implements(textMarshalerType)) &&
+		ptr.Implements(textUnmarshalerType)
+}
@@ -0,0 +1,141 @@
+package openapi
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/indigo-web/indigo/http/form"
+	"github.com/stretchr/testify/require"
+)
+
+type Node struct {
+	Value    string  `json:"value" validate:"required,max=16"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+type Meta struct {
+	Created time.Time `json:"created"`
+}
+
+type Account struct {
+	Meta
+	ID     string            `json:"id" validate:"uuid"`
+	Email  string            `json:"email" validate:"required,email"`
+	Age    int               `json:"age" validate:"min=18"`
+	Role   string            `json:"role" validate:"enum=admin|user"`
+	Tags   []string          `json:"tags" validate:"max=3"`
+	Addr   netip.Addr        `json:"addr"`
+	Labels map[string]string `json:"labels"`
+	Any    any               `json:"any"`
+	Tree   *Node             `json:"tree"`
+	Secret string            `json:"-"`
+	hidden string
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func integer(n int) *int {
+	return &n
+}
+
+func TestGenerator(t *testing.T) {
+	t.Run("schema", func(t *testing.T) {
+		gen := NewGenerator()
+		require.Equal(t, &Schema{Ref: "#/components/schemas/Account"}, gen.Schema(reflect.TypeOf(&Account{})))
+
+		schemas := gen.Components().Schemas
+		require.Equal(t, &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"created": {Type: "string", Format: "date-time"},
+				"id":      {Type: "string", Format: "uuid"},
+				"email":   {Type: "string", Format: "email"},
+				"age":     {Type: "integer", Format: "int64", Minimum: float(18)},
+				"role":    {Type: "string", Enum: []any{"admin", "user"}},
+				"tags":    {Type: "array", Items: &Schema{Type: "string"}, MaxItems: integer(3)},
+				"addr":    {Type: "string"},
+				"labels":  {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+				"any":     {},
+				"tree":    {Ref: "#/components/schemas/Node"},
+			},
+			Required: []string{"email"},
+		}, schemas["Account"])
+
+		require.Equal(t, &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"value": {Type: "string", MaxLength: integer(16)},
+				"children": {
+					Type:  "array",
+					Items: &Schema{Ref: "#/components/schemas/Node"},
+				},
+			},
+			Required: []string{"value"},
+		}, schemas["Node"])
+	})
+
+	t.Run("input", func(t *testing.T) {
+		type query struct {
+			ID    int    `path:"id" json:"-"`
+			Page  int    `query:"page" validate:"min=1"`
+			Token string `header:"X-Token" validate:"required"`
+		}
+
+		gen := NewGenerator()
+		params, body := gen.Input(reflect.TypeOf(query{}))
+		require.Nil(t, body)
+		require.Equal(t, []Parameter{
+			{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}},
+			{Name: "page", In: "query", Schema: &Schema{Type: "integer", Format: "int64", Minimum: float(1)}},
+			{Name: "X-Token", In: "header", Required: true, Schema: &Schema{Type: "string"}},
+		}, params)
+		require.Nil(t, gen.Components())
+
+		type create struct {
+			ID   int    `path:"id" json:"-"`
+			Name string `json:"name"`
+		}
+
+		_, body = gen.Input(reflect.TypeOf(create{}))
+		require.NotNil(t, body)
+		require.True(t, body.Required)
+		require.Equal(t, "#/components/schemas/create", body.Content["application/json"].Schema.Ref)
+
+		_, body = NewGenerator().MediaTypes("application/json", "application/xml").Input(reflect.TypeOf(create{}))
+		require.Len(t, body.Content, 2)
+		require.Equal(t, "#/components/schemas/create", body.Content["application/xml"].Schema.Ref)
+
+		type upload struct {
+			Name   string    `form:"name" validate:"required"`
+			Avatar form.Data `form:"avatar"`
+		}
+
+		params, body = gen.Input(reflect.TypeOf(upload{}))
+		require.Empty(t, params)
+		require.Equal(t, &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"name":   {Type: "string"},
+				"avatar": {Type: "string", Format: "binary"},
+			},
+			Required: []string{"name"},
+		}, body.Content["multipart/form-data"].Schema)
+	})
+
+	t.Run("name collision", func(t *testing.T) {
+		outer := reflect.TypeOf(Account{})
+
+		type Account struct {
+			Name string `json:"name"`
+		}
+
+		gen := NewGenerator()
+		require.Equal(t, "#/components/schemas/Account", gen.Schema(outer).Ref)
+		require.Equal(t, "#/components/schemas/Account2", gen.Schema(reflect.TypeOf(Account{})).Ref)
+		require.Equal(t, "#/components/schemas/Account", gen.Schema(outer).Ref)
+	})
+}
@@ -0,0 +1,171 @@
+package inbuilt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/kv"
+	"github.com/indigo-web/indigo/router/inbuilt/openapi"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type getUser struct {
+	ID int `path:"id" json:"-"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	info := openapi.Info{Title: "users", Version: "1.0.0"}
+
+	newRouter := func() *Router {
+		r := New()
+		TypedRoute(r.Group("/teams/:team"), method.POST, "/users", func(context.Context, createUser) (*user, error) {
+			return nil, nil
+		}).
+			Describe(Docs{
+				Summary: "Create a user",
+				Tags:    []string{"users"},
+				Params:  map[string]string{"team": "name of the team"},
+			})
+
+		TypedRoute(r, method.GET, "/users/:id", func(context.Context, getUser) (user, error) {
+			return user{}, nil
+		})
+		r.Get("/users/:id/avatar", Typed(func(context.Context, getUser) (user, error) {
+			return user{}, nil
+		}))
+		r.Resource("/health").
+			Get(http.Respond).
+			Describe(Docs{Summary: "Health check", Response: struct {
+				Up bool `json:"up"`
+			}{}})
+		r.Get("/files/:path...", http.Respond).
+			Describe(Docs{Deprecated: true})
+
+		return r
+	}
+
+	t.Run("document", func(t *testing.T) {
+		mediaTypes := getRequest(method.GET, "/").Respond().MediaTypes()
+		doc := newRouter().OpenAPI(info, mediaTypes...)
+		require.Equal(t, openapi.Version, doc.OpenAPI)
+		require.Equal(t, info, doc.Info)
+		require.Len(t, doc.Paths, 5)
+
+		create := (*doc.Paths["/teams/{team}/users"])["post"]
+		require.NotNil(t, create)
+		require.Equal(t, "Create a user", create.Summary)
+		require.Equal(t, []string{"users"}, create.Tags)
+		require.Equal(t, []openapi.Parameter{{
+			Name:        "team",
+			In:          "path",
+			Description: "name of the team",
+			Required:    true,
+			Schema:      &openapi.Schema{Type: "string"},
+		}}, create.Parameters)
+		require.Equal(t,
+			"#/components/schemas/createUser",
+			create.RequestBody.Content[mime.JSON].Schema.Ref,
+		)
+		require.Equal(t,
+			"#/components/schemas/user",
+			create.Responses["200"].Content[mime.JSON].Schema.Ref,
+		)
+		for _, m := range []string{mime.XML, mime.ApplicationXML, mime.CBOR, mime.MsgPack} {
+			require.Contains(t, create.RequestBody.Content, m)
+			require.Contains(t, create.Responses["200"].Content, m)
+		}
+		require.Contains(t, create.Responses, "204", "nil models are responded by 204")
+		require.Contains(t, create.Responses, "400")
+		require.Contains(t, create.Responses, "422")
+
+		get := (*doc.Paths["/users/{id}"])["get"]
+		require.NotNil(t, get)
+		require.Nil(t, get.RequestBody)
+		require.NotContains(t, get.Responses, "204")
+		require.Equal(t, []openapi.Parameter{{
+			Name:     "id",
+			In:       "path",
+			Required: true,
+			Schema:   &openapi.Schema{Type: "integer", Format: "int64"},
+		}}, get.Parameters)
+
+		avatar := (*doc.Paths["/users/{id}/avatar"])["get"]
+		require.NotContains(t, avatar.Responses, "200", "models of bare typed handlers are unknown")
+		require.NotContains(t, avatar.Responses, "204")
+		require.Contains(t, avatar.Responses, "default")
+
+		health := (*doc.Paths["/health"])["get"]
+		require.Equal(t, "Health check", health.Summary)
+		require.Empty(t, health.Parameters)
+		require.Equal(t, "boolean", health.Responses["200"].Content[mime.JSON].Schema.Properties["up"].Type)
+
+		files := (*doc.Paths["/files/{path}"])["get"]
+		require.True(t, files.Deprecated)
+		require.Equal(t, "path", files.Parameters[0].Name)
+		require.NotContains(t, files.Responses, "200")
+		require.NotContains(t, files.Responses, "204", "plain handlers aren't known to respond nothing")
+		require.Equal(t, &openapi.Response{Description: "Response"}, files.Responses["default"])
+
+		schemas := doc.Components.Schemas
+		require.Contains(t, schemas, "createUser")
+		require.Contains(t, schemas, "user")
+		require.Equal(t, []string{"name"}, schemas["createUser"].Required)
+	})
+
+	t.Run("JSON only", func(t *testing.T) {
+		doc := newRouter().OpenAPI(info)
+		create := (*doc.Paths["/teams/{team}/users"])["post"]
+		require.Len(t, create.RequestBody.Content, 1)
+		require.Len(t, create.Responses["200"].Content, 1)
+	})
+
+	t.Run("describe without route", func(t *testing.T) {
+		require.Panics(t, func() {
+			New().Describe(Docs{Summary: "nothing"})
+		})
+	})
+
+	serve := func(t *testing.T, path string) (*http.Response, []byte) {
+		r := newRouter().
+			ServeOpenAPI(path, info).
+			Build()
+
+		resp := r.OnRequest(getRequest(method.GET, path))
+		require.Equal(t, status.OK, resp.Expose().Code)
+
+		return resp, []byte(readbody(t, resp.Expose().Stream))
+	}
+
+	t.Run("serve JSON", func(t *testing.T) {
+		resp, body := serve(t, "/openapi.json")
+		headers := kv.NewFromPairs(resp.Expose().Headers)
+		require.Contains(t, headers.Value("Content-Type"), mime.JSON)
+
+		var doc openapi.Document
+		require.NoError(t, json.Unmarshal(body, &doc))
+		require.Equal(t, openapi.Version, doc.OpenAPI)
+		require.Len(t, doc.Paths, 5, "the document itself must not be listed")
+
+		create := (*doc.Paths["/teams/{team}/users"])["post"]
+		for _, m := range []string{mime.JSON, mime.XML, mime.CBOR, mime.MsgPack} {
+			require.Contains(t, create.RequestBody.Content, m, "media types come from the config")
+		}
+	})
+
+	t.Run("serve YAML", func(t *testing.T) {
+		resp, body := serve(t, "/openapi.yaml")
+		headers := kv.NewFromPairs(resp.Expose().Headers)
+		require.Contains(t, headers.Value("Content-Type"), mime.YAML)
+
+		var doc openapi.Document
+		require.NoError(t, yaml.Unmarshal(body, &doc))
+		require.Equal(t, "users", doc.Info.Title)
+		require.Contains(t, doc.Paths, "/users/{id}")
+	})
+}
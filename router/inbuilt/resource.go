@@ -1,6 +1,8 @@
 package inbuilt
 
 import (
+	"io/fs"
+
 	"github.com/indigo-web/indigo/http/method"
 )
 
@@ -20,6 +22,12 @@ func (r Resource) Use(middlewares ...Middleware) Resource {
 	return r
 }
 
+// Describe attaches the docs to the most recently registered method of the resource
+func (r Resource) Describe(docs Docs) Resource {
+	r.group.Describe(docs)
+	return r
+}
+
 // Static adds a catcher of prefix, that automatically returns files from defined root
 // directory
 func (r Resource) Static(prefix, root string) Resource {
@@ -27,6 +35,12 @@ func (r Resource) Static(prefix, root string) Resource {
 	return r
 }
 
+// StaticFS adds a catcher of prefix, that automatically returns files from the file system
+func (r Resource) StaticFS(prefix string, fsys fs.FS) Resource {
+	r.group.StaticFS(prefix, fsys)
+	return r
+}
+
 // Route is a shortcut to group.Route, providing the extra empty path to the call
 func (r Resource) Route(method method.Method, fun Handler, mwares ...Middleware) Resource {
 	r.group.Route(method, "", fun, mwares...)
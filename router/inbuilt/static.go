@@ -2,12 +2,9 @@ package inbuilt
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
-
-	"github.com/indigo-web/indigo/http"
-	"github.com/indigo-web/indigo/http/mime"
-	"github.com/indigo-web/indigo/http/status"
 )
 
 // Static adds a catcher of prefix, that automatically returns files from defined root
@@ -22,33 +19,25 @@ func (r *Router) Static(prefix, root string, mwares ...Middleware) *Router {
 		panic(fmt.Sprintf("%s: not a directory", root))
 	}
 
-	fs := os.DirFS(root)
-
-	return r.Get(prefix+"/:path...", func(request *http.Request) *http.Response {
-		path := request.Vars.Value("path")
-		if !isSafe(path) {
-			return http.Error(request, status.ErrBadRequest)
-		}
+	return r.StaticFS(prefix, os.DirFS(root), mwares...)
+}
 
-		// TODO: cache descriptors
-		file, err := fs.Open(path)
-		if err != nil {
-			return http.
-				Error(request, status.ErrNotFound).
-				String(err.(*os.PathError).Err.Error())
-		}
+// StaticFS adds a catcher of prefix, that automatically returns files from the file system,
+// e.g. embed.FS
+func (r *Router) StaticFS(prefix string, fsys fs.FS, mwares ...Middleware) *Router {
+	return r.Files(prefix, NewFileServer(fsys), mwares...)
+}
 
-		fstat, err := file.Stat()
-		if err != nil {
-			return http.
-				Error(request, status.ErrInternalServerError).
-				String(err.(*os.PathError).Err.Error())
-		}
+// Files adds a catcher of prefix, that serves files by the file server. The prefix itself
+// is served as the root directory
+func (r *Router) Files(prefix string, server *FileServer, mwares ...Middleware) *Router {
+	prefix = strings.TrimSuffix(prefix, "/")
+	handler := server.Handler()
+	if len(prefix) > 0 {
+		r.Get(prefix, handler, mwares...)
+	}
 
-		return http.
-			Stream(request, file, fstat.Size()).
-			ContentType(mime.Guess(path))
-	}, mwares...)
+	return r.Get(prefix+"/:path...", handler, mwares...)
 }
 
 // isSafe checks for path traversal (basically - double dots)
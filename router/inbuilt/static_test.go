@@ -1,8 +1,15 @@
 package inbuilt
 
 import (
+	"io"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/internal/response"
+	"github.com/indigo-web/indigo/kv"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,3 +31,202 @@ func TestSafePath(t *testing.T) {
 		require.False(t, isSafe(tc))
 	}
 }
+
+func TestFileServer(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":            {Data: []byte("<h1>root</h1>")},
+		"app.js":                {Data: []byte("console.log(1)")},
+		"assets/logo.svg":       {Data: []byte("<svg/>")},
+		"assets/<script>.txt":   {Data: []byte("xss")},
+		"docs/guide/index.html": {Data: []byte("guide")},
+		"empty.txt":             {Data: []byte{}},
+	}
+
+	serve := func(t *testing.T, r *Router, path string) (*response.Fields, string) {
+		request := getRequest(method.GET, path)
+		resp := r.Build().OnRequest(request).Expose()
+		if resp.Stream == nil {
+			return resp, ""
+		}
+
+		body := readbody(t, resp.Stream)
+		if c, ok := resp.Stream.(io.Closer); ok {
+			require.NoError(t, c.Close())
+		}
+
+		return resp, body
+	}
+
+	header := func(resp *response.Fields, key string) string {
+		value, _ := kv.NewFromPairs(resp.Headers).Lookup(key)
+		return value
+	}
+
+	t.Run("files", func(t *testing.T) {
+		r := New().StaticFS("/static", files)
+
+		resp, body := serve(t, r, "/static/app.js")
+		require.Equal(t, status.OK, resp.Code)
+		require.Equal(t, "console.log(1)", body)
+		require.NotEmpty(t, header(resp, "etag"))
+
+		resp, body = serve(t, r, "/static/docs/guide/")
+		require.Equal(t, status.OK, resp.Code)
+		require.Equal(t, "guide", body)
+
+		resp, _ = serve(t, r, "/static/docs/guide")
+		require.Equal(t, status.MovedPermanently, resp.Code)
+		require.Equal(t, "guide/", header(resp, "location"))
+
+		resp, body = serve(t, r, "/static/")
+		require.Equal(t, status.OK, resp.Code)
+		require.Equal(t, "<h1>root</h1>", body)
+
+		resp, _ = serve(t, r, "/static")
+		require.Equal(t, status.MovedPermanently, resp.Code)
+		require.Equal(t, "static/", header(resp, "location"))
+
+		resp, _ = serve(t, r, "/static/empty.txt")
+		require.Equal(t, status.OK, resp.Code)
+		require.Nil(t, resp.Stream)
+
+		resp, _ = serve(t, r, "/static/assets")
+		require.Equal(t, status.NotFound, resp.Code)
+
+		resp, _ = serve(t, r, "/static/nothing.txt")
+		require.Equal(t, status.NotFound, resp.Code)
+
+		resp, _ = serve(t, r, "/static/../index.html")
+		require.Equal(t, status.BadRequest, resp.Code)
+	})
+
+	t.Run("spa", func(t *testing.T) {
+		r := New().Files("", NewFileServer(files).SPA("/index.html"))
+
+		resp, body := serve(t, r, "/users/42")
+		require.Equal(t, status.OK, resp.Code)
+		require.Equal(t, "<h1>root</h1>", body)
+
+		resp, body = serve(t, r, "/assets/logo.svg")
+		require.Equal(t, status.OK, resp.Code)
+		require.Equal(t, "<svg/>", body)
+
+		resp, body = serve(t, r, "/assets")
+		require.Equal(t, status.OK, resp.Code)
+		require.Equal(t, "<h1>root</h1>", body)
+	})
+
+	t.Run("browse", func(t *testing.T) {
+		r := New().Files("/static", NewFileServer(files).Browse(true))
+
+		resp, _ := serve(t, r, "/static/assets")
+		require.Equal(t, status.MovedPermanently, resp.Code)
+
+		resp, body := serve(t, r, "/static/assets/")
+		require.Equal(t, status.OK, resp.Code)
+		require.Contains(t, body, `<a href="/static/assets/logo.svg">logo.svg</a>`)
+		require.Contains(t, body, `<a href="/static/assets/%3Cscript%3E.txt">&lt;script&gt;.txt</a>`)
+		require.Contains(t, body, `<a href="../">../</a>`)
+	})
+
+	t.Run("cache control", func(t *testing.T) {
+		server := NewFileServer(files).
+			CacheControl("*.html", "no-cache").
+			CacheControl("assets/*", "max-age=31536000, immutable")
+		r := New().Files("/", server)
+
+		resp, _ := serve(t, r, "/docs/guide/index.html")
+		require.Equal(t, "no-cache", header(resp, "cache-control"))
+
+		resp, _ = serve(t, r, "/assets/logo.svg")
+		require.Equal(t, "max-age=31536000, immutable", header(resp, "cache-control"))
+
+		resp, _ = serve(t, r, "/app.js")
+		require.Empty(t, header(resp, "cache-control"))
+	})
+
+	t.Run("precompressed", func(t *testing.T) {
+		files := fstest.MapFS{
+			"app.js":       {Data: []byte("plain")},
+			"app.js.gz":    {Data: []byte("gzipped")},
+			"app.js.zst":   {Data: []byte("zstd")},
+			"logo.svg":     {Data: []byte("<svg/>")},
+			"data.json.br": {Data: []byte("brotli")},
+			"data.json":    {Data: []byte("{}")},
+		}
+		r := New().Files("/", NewFileServer(files).Precompressed("br", "zstd", "gzip")).Build()
+
+		serve := func(t *testing.T, path string, acceptEncoding ...string) (*response.Fields, string) {
+			request := getRequest(method.GET, path)
+			request.AcceptEncoding = acceptEncoding
+			resp := r.OnRequest(request).Expose()
+			body := readbody(t, resp.Stream)
+			require.NoError(t, resp.Stream.(io.Closer).Close())
+			return resp, body
+		}
+
+		resp, body := serve(t, "/app.js", "gzip", "deflate", "br", "zstd")
+		require.Equal(t, "zstd", resp.Precompressed)
+		require.Equal(t, "zstd", body)
+		require.Equal(t, "Accept-Encoding", header(resp, "vary"))
+		require.Equal(t, "text/javascript", header(resp, "content-type"))
+
+		resp, body = serve(t, "/app.js", "gzip;q=1.0", "zstd;q=0.5")
+		require.Equal(t, "gzip", resp.Precompressed)
+		require.Equal(t, "gzipped", body)
+
+		resp, body = serve(t, "/app.js", "*;q=0.1", "zstd;q=0")
+		require.Equal(t, "gzip", resp.Precompressed)
+		require.Equal(t, "gzipped", body)
+
+		resp, body = serve(t, "/app.js", "identity")
+		require.Empty(t, resp.Precompressed)
+		require.Equal(t, "plain", body)
+		require.Equal(t, "Accept-Encoding", header(resp, "vary"))
+
+		resp, body = serve(t, "/data.json", "gzip", "zstd")
+		require.Empty(t, resp.Precompressed)
+		require.Equal(t, "{}", body)
+		require.Equal(t, "Accept-Encoding", header(resp, "vary"))
+
+		resp, body = serve(t, "/logo.svg", "gzip", "br")
+		require.Empty(t, resp.Precompressed)
+		require.Equal(t, "<svg/>", body)
+		require.Empty(t, header(resp, "vary"))
+
+		r = New().StaticFS("/", files).Build()
+		resp, body = serve(t, "/app.js", "gzip", "zstd")
+		require.Empty(t, resp.Precompressed)
+		require.Equal(t, "plain", body)
+		require.Empty(t, header(resp, "vary"))
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		files := fstest.MapFS{
+			"file.txt": {Data: []byte("first"), ModTime: time.Unix(1, 0)},
+		}
+		server := NewFileServer(files).Cache(time.Hour)
+		r := New().Files("/", server)
+
+		_, body := serve(t, r, "/file.txt")
+		require.Equal(t, "first", body)
+		require.Len(t, server.cache, 1)
+		cached := server.cache["file.txt"]
+
+		files["file.txt"] = &fstest.MapFile{Data: []byte("second"), ModTime: time.Unix(2, 0)}
+		_, body = serve(t, r, "/file.txt")
+		require.Equal(t, "first", body, "must be served from the cache until expired")
+
+		server.Invalidate()
+		require.Empty(t, server.cache)
+		require.Zero(t, cached.refs.Load())
+
+		_, body = serve(t, r, "/file.txt")
+		require.Equal(t, "second", body)
+
+		server.cache["file.txt"].expires = time.Time{}
+		files["file.txt"] = &fstest.MapFile{Data: []byte("third!"), ModTime: time.Unix(3, 0)}
+		_, body = serve(t, r, "/file.txt")
+		require.Equal(t, "third!", body, "expired descriptors must be revalidated")
+	})
+}
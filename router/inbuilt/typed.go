@@ -0,0 +1,79 @@
+package inbuilt
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/status"
+)
+
+// Typed adapts the function into an ordinary Handler. The In is bound from the request by
+// http.Bind (so it must be a struct, possibly an empty one) and validated, and the Out is
+// serialized by http.Negotiate. Nil pointers and interfaces result in status.NoContent
+// instead.
+//
+// Returned errors are responded via http.Error, if they are or wrap the status.HTTPError
+// (including validation errors). Any other error results in status.InternalServerError,
+// without exposing its message to the client.
+//
+// In order for the models to be described in the Router.OpenAPI, the handler must be registered
+// by TypedRoute instead.
+func Typed[In, Out any](fn func(ctx context.Context, in In) (Out, error)) Handler {
+	if reflect.TypeFor[In]().Kind() != reflect.Struct {
+		panic("inbuilt: input of a typed handler must be a struct")
+	}
+
+	handler := func(request *http.Request) *http.Response {
+		var in In
+		if err := http.Bind(request, &in); err != nil {
+			return typedError(request, err)
+		}
+
+		out, err := fn(request.Ctx, in)
+		if err != nil {
+			return typedError(request, err)
+		}
+
+		if isNil(out) {
+			return http.Code(request, status.NoContent)
+		}
+
+		return http.Negotiate(request, out)
+	}
+
+	return handler
+}
+
+// TypedRoute registers the typed handler the same way as Route(m, path, Typed(fn), middlewares...)
+// does, additionally attaching its models to the route, so they're described in the OpenAPI
+// document. As methods cannot be generic, the router is passed explicitly.
+func TypedRoute[In, Out any](
+	r *Router, m method.Method, path string, fn func(ctx context.Context, in In) (Out, error),
+	middlewares ...Middleware,
+) *Router {
+	r.Route(m, path, Typed(fn), middlewares...)
+	r.last.in, r.last.out = reflect.TypeFor[In](), reflect.TypeFor[Out]()
+
+	return r
+}
+
+func typedError(request *http.Request, err error) *http.Response {
+	var httpErr status.HTTPError
+	if !errors.As(err, &httpErr) {
+		err = status.ErrInternalServerError
+	}
+
+	return http.Error(request, err)
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(v)
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
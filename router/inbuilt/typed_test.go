@@ -0,0 +1,116 @@
+package inbuilt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/indigo-web/indigo/config"
+	"github.com/indigo-web/indigo/http"
+	"github.com/indigo-web/indigo/http/method"
+	"github.com/indigo-web/indigo/http/mime"
+	"github.com/indigo-web/indigo/http/status"
+	"github.com/indigo-web/indigo/http/validate"
+	"github.com/indigo-web/indigo/internal/construct"
+	"github.com/indigo-web/indigo/transport/dummy"
+	"github.com/stretchr/testify/require"
+)
+
+type createUser struct {
+	Team string `path:"team" json:"-"`
+	Name string `json:"name" validate:"required"`
+}
+
+type user struct {
+	Team string `json:"team"`
+	Name string `json:"name"`
+}
+
+func typedRequest(path, body string) *http.Request {
+	cfg := config.Default()
+	cfg.Validator = validate.New()
+	request := construct.Request(cfg, dummy.NewNopClient())
+	request.Method = method.POST
+	request.Path = path
+	request.ContentType = mime.JSON
+	request.ContentLength = len(body)
+	request.Body = http.NewBody(dummy.NewMockClient([]byte(body)))
+	request.Body.Reset(request)
+
+	return request
+}
+
+func TestTyped(t *testing.T) {
+	errForbidden := errors.New("forbidden team")
+
+	create := func(_ context.Context, in createUser) (*user, error) {
+		switch in.Team {
+		case "nobody":
+			return nil, nil
+		case "gone":
+			return nil, status.ErrGone
+		case "secret":
+			return nil, errForbidden
+		}
+
+		return &user{Team: in.Team, Name: in.Name}, nil
+	}
+
+	var calls int
+	counter := func(next Handler, request *http.Request) *http.Response {
+		calls++
+		return next(request)
+	}
+
+	r := New().
+		Route(method.POST, "/teams/:team/users", Typed(create), counter).
+		Build()
+
+	t.Run("happy path", func(t *testing.T) {
+		resp := r.OnRequest(typedRequest("/teams/core/users", `{"name": "Pavlo"}`))
+		require.Equal(t, status.OK, resp.Expose().Code)
+		require.JSONEq(t, `{"team":"core","name":"Pavlo"}`, string(resp.Expose().Buffer))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		resp := r.OnRequest(typedRequest("/teams/nobody/users", `{"name": "Pavlo"}`))
+		require.Equal(t, status.NoContent, resp.Expose().Code)
+	})
+
+	t.Run("bad body", func(t *testing.T) {
+		resp := r.OnRequest(typedRequest("/teams/core/users", `{"name": `))
+		require.Equal(t, status.BadRequest, resp.Expose().Code)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		resp := r.OnRequest(typedRequest("/teams/core/users", `{}`))
+		require.Equal(t, status.UnprocessableEntity, resp.Expose().Code)
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		resp := r.OnRequest(typedRequest("/teams/gone/users", `{"name": "Pavlo"}`))
+		require.Equal(t, status.Gone, resp.Expose().Code)
+	})
+
+	t.Run("ordinary error", func(t *testing.T) {
+		resp := r.OnRequest(typedRequest("/teams/secret/users", `{"name": "Pavlo"}`))
+		require.Equal(t, status.InternalServerError, resp.Expose().Code)
+		require.Nil(t, resp.Expose().Stream)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		request := typedRequest("/teams/core/users", `{"name": "Pavlo"}`)
+		request.Headers.Add("Accept", "text/html")
+		resp := r.OnRequest(request)
+		require.Equal(t, status.NotAcceptable, resp.Expose().Code)
+	})
+
+	t.Run("non-struct input", func(t *testing.T) {
+		require.Panics(t, func() {
+			Typed(func(context.Context, string) (string, error) {
+				return "", nil
+			})
+		})
+	})
+}
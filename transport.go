@@ -1,6 +1,7 @@
 package indigo
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -15,9 +16,9 @@ import (
 	"time"
 
 	"github.com/indigo-web/indigo/config"
-	"github.com/indigo-web/indigo/http/codec"
 	"github.com/indigo-web/indigo/http/serve"
 	"github.com/indigo-web/indigo/internal/codecutil"
+	"github.com/indigo-web/indigo/internal/netutil"
 	"github.com/indigo-web/indigo/router"
 	"github.com/indigo-web/indigo/transport"
 	"golang.org/x/crypto/acme/autocert"
@@ -26,22 +27,70 @@ import (
 type Transport struct {
 	addr          string
 	inner         transport.Transport
-	spawnCallback func(cfg *config.Config, r router.Router, c []codec.Codec) func(net.Conn)
+	spawnCallback func(ctx context.Context, cfg *config.Config, r router.Router, c codecutil.Cache) func(net.Conn, *transport.Tracker)
 }
 
 func TCP() Transport {
 	return Transport{
 		inner: transport.NewTCP(),
-		spawnCallback: func(cfg *config.Config, r router.Router, c []codec.Codec) func(net.Conn) {
-			acceptString := codecutil.AcceptEncoding(c)
+		spawnCallback: func(
+			ctx context.Context, cfg *config.Config, r router.Router, c codecutil.Cache,
+		) func(net.Conn, *transport.Tracker) {
+			return func(conn net.Conn, tracker *transport.Tracker) {
+				serve.HTTP1(ctx, cfg, conn, tracker, 0, r, c.Clone())
+			}
+		},
+	}
+}
 
-			return func(conn net.Conn) {
-				serve.HTTP1(cfg, conn, 0, r, codecutil.NewCache(c, acceptString))
+// Unix returns a transport listening on a Unix domain socket, where the address is the socket
+// path. Paths starting with @ are bound in the abstract namespace (Linux only). Otherwise, a stale
+// socket file left by a previous run is removed, and the optional perm is applied to the new one.
+// The peer process credentials are available via Request.Env.Cred where supported.
+func Unix(perm ...os.FileMode) Transport {
+	var mode os.FileMode
+	if len(perm) > 0 {
+		mode = perm[0]
+	}
+
+	return Transport{
+		inner: transport.NewUnix(mode),
+		spawnCallback: func(
+			ctx context.Context, cfg *config.Config, r router.Router, c codecutil.Cache,
+		) func(net.Conn, *transport.Tracker) {
+			return func(conn net.Conn, tracker *transport.Tracker) {
+				serve.HTTP1(ctx, cfg, conn, tracker, 0, r, c.Clone())
 			}
 		},
 	}
 }
 
+// ProxyProtocol enables PROXY protocol v1 and v2 support, so Request.Remote holds the original
+// client address instead of the proxy's one. Connections from the trusted networks (CIDRs or
+// single IPs) must start with the header, whereas ones from other sources are served as is.
+// At least one network must be passed, as trusting the headers of arbitrary clients lets them
+// spoof their addresses. If that's intended (e.g. the port isn't reachable but by the proxy),
+// 0.0.0.0/0 and ::/0 must be passed explicitly. Peers of Unix sockets are trusted regardless.
+// Invalid network panics. Supported by TCP, TLS and Unix transports.
+func (t Transport) ProxyProtocol(trusted ...string) Transport {
+	if len(trusted) == 0 {
+		panic("at least one trusted network is required for PROXY protocol")
+	}
+
+	p, ok := t.inner.(interface {
+		EnableProxyProtocol(transport.ProxyProtocol)
+	})
+	if !ok {
+		panic("the transport doesn't support PROXY protocol")
+	}
+
+	networks := netutil.MustParseNetworks(trusted)
+
+	p.EnableProxyProtocol(transport.ProxyProtocol{Trusted: networks})
+
+	return t
+}
+
 func TLS(certs ...tls.Certificate) Transport {
 	if len(certs) == 0 {
 		panic("need at least one certificate")
@@ -103,15 +152,39 @@ func Cert(cert, key string) tls.Certificate {
 	return c
 }
 
+// newTLSTransport makes a TLS transport of the config. The config is cloned, so it can be safely
+// shared by the caller, e.g. with another server.
 func newTLSTransport(cfg *tls.Config) Transport {
+	cfg = cfg.Clone()
+	if len(cfg.NextProtos) == 0 {
+		// advertise HTTP/2 support via ALPN
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+	}
+
 	return Transport{
 		inner: transport.NewTLS(cfg),
-		spawnCallback: func(cfg *config.Config, r router.Router, c []codec.Codec) func(net.Conn) {
-			acceptString := codecutil.AcceptEncoding(c)
-
-			return func(conn net.Conn) {
-				ver := conn.(*tls.Conn).ConnectionState().Version
-				serve.HTTP1(cfg, conn, ver, r, codecutil.NewCache(c, acceptString))
+		spawnCallback: func(
+			ctx context.Context, cfg *config.Config, r router.Router, c codecutil.Cache,
+		) func(net.Conn, *transport.Tracker) {
+			return func(conn net.Conn, tracker *transport.Tracker) {
+				tlsConn := conn.(*tls.Conn)
+				// the handshake must be completed beforehand, as otherwise neither the TLS version
+				// nor the negotiated protocol are known.
+				hsctx, cancel := context.WithTimeout(ctx, cfg.NET.ReadTimeout)
+				err := tlsConn.HandshakeContext(hsctx)
+				cancel()
+				if err != nil {
+					return
+				}
+
+				state := tlsConn.ConnectionState()
+				codecs := c.Clone()
+
+				if state.NegotiatedProtocol == "h2" {
+					serve.HTTP2(ctx, cfg, conn, tracker, state.Version, r, codecs)
+				} else {
+					serve.HTTP1(ctx, cfg, conn, tracker, state.Version, r, codecs)
+				}
 			}
 		},
 	}
@@ -1,7 +1,11 @@
 package transport
 
 import (
+	"errors"
 	"net"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/indigo-web/indigo/internal/timer"
@@ -16,11 +20,27 @@ type Client interface {
 	Close() error
 }
 
+// aLongTimeAgo is a non-zero time in the past, used to immediately interrupt blocking reads.
+var aLongTimeAgo = time.Unix(1, 0)
+
 type client struct {
 	conn    net.Conn
 	buff    []byte
 	pending []byte
 	timeout time.Duration
+	remote  net.Addr
+	watch   watcher
+}
+
+// watcher reads the connection in background, detecting whether the peer has gone away.
+type watcher struct {
+	mu       sync.Mutex
+	active   atomic.Bool
+	aborting atomic.Bool
+	done     chan struct{}
+	buff     [1]byte
+	n        int
+	err      error
 }
 
 func NewClient(conn net.Conn, timeout time.Duration, buff []byte) Client {
@@ -34,6 +54,10 @@ func NewClient(conn net.Conn, timeout time.Duration, buff []byte) Client {
 // Read reads data into the internal buffer and returns a piece of it back. Timeouts are also
 // handled automatically.
 func (c *client) Read() ([]byte, error) {
+	if c.watch.active.Load() {
+		c.unwatch()
+	}
+
 	if len(c.pending) > 0 {
 		pending := c.pending
 		c.pending = nil
@@ -45,15 +69,80 @@ func (c *client) Read() ([]byte, error) {
 		return nil, err
 	}
 
+	if err := c.watch.err; err != nil {
+		return nil, err
+	}
+
 	n, err := c.conn.Read(c.buff)
 	return c.buff[:n], err
 }
 
 // Pending returns data (if any) preserved via Pushback.
 func (c *client) Pending() []byte {
+	if c.watch.active.Load() {
+		c.unwatch()
+	}
+
 	return c.pending
 }
 
+// Watch starts reading the connection in background, calling the callback if the peer has gone
+// away. It is impossible if there's data pending, in which case false is returned. Reading is
+// aborted by the next call to Read, Pending or Conn, preserving the data read so far.
+func (c *client) Watch(cb func()) bool {
+	w := &c.watch
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.active.Load() || len(c.pending) > 0 || w.err != nil {
+		return false
+	}
+
+	if err := c.conn.SetReadDeadline(time.Time{}); err != nil {
+		return false
+	}
+
+	w.active.Store(true)
+	w.aborting.Store(false)
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+
+		w.n, w.err = c.conn.Read(w.buff[:])
+		if w.err != nil && errors.Is(w.err, os.ErrDeadlineExceeded) && w.aborting.Load() {
+			w.err = nil
+		}
+
+		if w.err != nil {
+			cb()
+		}
+	}()
+
+	return true
+}
+
+func (c *client) unwatch() {
+	w := &c.watch
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.active.Load() {
+		return
+	}
+
+	w.aborting.Store(true)
+	// wake the reader up. Read deadline is updated before every read anyway.
+	_ = c.conn.SetReadDeadline(aLongTimeAgo)
+	<-w.done
+	w.active.Store(false)
+
+	if w.n > 0 {
+		c.pending = w.buff[:w.n]
+		w.n = 0
+	}
+}
+
 // Pushback preserves a chunk of data from previous read for the next read.
 func (c *client) Pushback(b []byte) {
 	c.pending = b
@@ -61,6 +150,10 @@ func (c *client) Pushback(b []byte) {
 
 // Conn unwraps the underlying net.Conn.
 func (c *client) Conn() net.Conn {
+	if c.watch.active.Load() {
+		c.unwatch()
+	}
+
 	return c.conn
 }
 
@@ -69,9 +162,18 @@ func (c *client) Write(b []byte) (int, error) {
 	return c.conn.Write(b)
 }
 
-// Remote returns the remote address of the connection.
+// Remote returns the remote address of the connection. Unix socket connections are
+// represented by *UnixAddr.
 func (c *client) Remote() net.Addr {
-	return c.conn.RemoteAddr()
+	if c.remote == nil {
+		if uc, ok := c.conn.(*net.UnixConn); ok {
+			c.remote = unixRemote(uc)
+		} else {
+			c.remote = c.conn.RemoteAddr()
+		}
+	}
+
+	return c.remote
 }
 
 // Close closes the connection.
@@ -0,0 +1,33 @@
+//go:build linux
+
+package transport
+
+import (
+	"net"
+	"syscall"
+)
+
+func peerCred(conn *net.UnixConn) *Cred {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	var (
+		ucred *syscall.Ucred
+		serr  error
+	)
+
+	err = raw.Control(func(fd uintptr) {
+		ucred, serr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil || serr != nil {
+		return nil
+	}
+
+	return &Cred{
+		PID: ucred.Pid,
+		UID: ucred.Uid,
+		GID: ucred.Gid,
+	}
+}
@@ -0,0 +1,588 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/indigo-web/indigo/internal/netutil"
+)
+
+var (
+	ErrBadProxyHeader     = errors.New("malformed PROXY protocol header")
+	ErrMissingProxyHeader = errors.New("PROXY protocol header is required from trusted sources")
+	ErrUntrustedProxy     = errors.New("PROXY protocol header from untrusted source")
+)
+
+const (
+	proxyV1Prefix    = "PROXY "
+	proxyV1MaxLen    = 107
+	proxyV2Signature = "\r\n\r\n\x00\r\nQUIT\n"
+	proxyV2HeaderLen = 16
+)
+
+// PROXY protocol v2 TLV types.
+const (
+	ProxyTLVALPN      uint8 = 0x01
+	ProxyTLVAuthority uint8 = 0x02
+	ProxyTLVCRC32C    uint8 = 0x03
+	ProxyTLVNoop      uint8 = 0x04
+	ProxyTLVUniqueID  uint8 = 0x05
+	ProxyTLVSSL       uint8 = 0x20
+	ProxyTLVNetNS     uint8 = 0x30
+
+	proxySSLVersion uint8 = 0x21
+	proxySSLCN      uint8 = 0x22
+	proxySSLCipher  uint8 = 0x23
+	proxySSLSigAlg  uint8 = 0x24
+	proxySSLKeyAlg  uint8 = 0x25
+)
+
+// ProxyProtocol configures PROXY protocol v1 and v2 support. Connections from the trusted
+// sources must start with the header, whereas ones from other sources are served as is.
+// Untrusted peers sending the header anyway are dropped, as they are likely trying to spoof
+// their addresses.
+type ProxyProtocol struct {
+	// Trusted are the networks of the proxies. Empty list trusts every source, as well as
+	// non-IP (e.g. Unix socket) peers are always trusted.
+	Trusted []netip.Prefix
+}
+
+// ProxyHeader is the PROXY protocol header received from the proxy.
+type ProxyHeader struct {
+	// Version is either 1 or 2.
+	Version uint8
+	// Local means the connection was established by the proxy on its own (e.g. health checks),
+	// so the addresses aren't replaced.
+	Local bool
+	// Source and Destination are the original client and server addresses. Both are nil if
+	// the proxy doesn't know them.
+	Source, Destination net.Addr
+	// ALPN is the protocol negotiated by the proxy with the client.
+	ALPN string
+	// Authority is the host name provided by the client (usually via SNI).
+	Authority string
+	// UniqueID is the connection identifier assigned by the proxy.
+	UniqueID []byte
+	// SSL is not nil if the client connected to the proxy over TLS.
+	SSL *ProxySSL
+	// TLVs contains all the received TLVs, including unknown ones.
+	TLVs []ProxyTLV
+}
+
+// ProxySSL describes the TLS connection between the client and the proxy.
+type ProxySSL struct {
+	// Client is the bit field of PP2_CLIENT_* flags.
+	Client uint8
+	// Verified tells whether the client certificate was verified successfully.
+	Verified bool
+	// Version is the TLS version, e.g. "TLSv1.3".
+	Version string
+	// CN is the common name of the client certificate.
+	CN     string
+	Cipher string
+	SigAlg string
+	KeyAlg string
+}
+
+// ProxyTLV is a single type-length-value extension of the PROXY protocol v2 header.
+type ProxyTLV struct {
+	Type  uint8
+	Value []byte
+}
+
+// ProxyHeaderOf returns the PROXY protocol header received via the connection, or nil.
+func ProxyHeaderOf(conn net.Conn) *ProxyHeader {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+
+	if pc, ok := conn.(*proxyConn); ok {
+		return pc.Header()
+	}
+
+	return nil
+}
+
+// proxyListener wraps accepted connections, so they read the header first.
+type proxyListener struct {
+	listener
+	trusted []netip.Prefix
+	// timeout is set as the transport starts listening.
+	timeout atomic.Int64
+}
+
+func newProxyListener(l listener, cfg ProxyProtocol) *proxyListener {
+	return &proxyListener{
+		listener: l,
+		trusted:  cfg.Trusted,
+	}
+}
+
+func (p *proxyListener) Accept() (net.Conn, error) {
+	conn, err := p.listener.Accept()
+	if err != nil {
+		return conn, err
+	}
+
+	if !p.isTrusted(conn.RemoteAddr()) {
+		return &untrustedConn{Conn: conn}, nil
+	}
+
+	// the header is read lazily in order to not block the accept loop.
+	return &proxyConn{
+		Conn:    conn,
+		timeout: time.Duration(p.timeout.Load()),
+	}, nil
+}
+
+func (p *proxyListener) isTrusted(addr net.Addr) bool {
+	if len(p.trusted) == 0 {
+		return true
+	}
+
+	ip, ok := netutil.AddrOf(addr)
+	if !ok {
+		// non-IP peers, e.g. Unix sockets
+		return true
+	}
+
+	return netutil.Contains(p.trusted, ip)
+}
+
+// proxyConn reads the PROXY protocol header on the first use.
+type proxyConn struct {
+	net.Conn
+	timeout time.Duration
+	once    sync.Once
+	header  *ProxyHeader
+	err     error
+	// pending is the data read past the header.
+	pending []byte
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.header, c.pending, c.err = readProxyHeader(c.Conn, c.timeout)
+		if c.err != nil {
+			_ = c.Conn.Close()
+		}
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+
+	return c.Conn.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.header != nil && !c.header.Local && c.header.Source != nil {
+		return c.header.Source
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.init()
+	if c.header != nil && !c.header.Local && c.header.Destination != nil {
+		return c.header.Destination
+	}
+
+	return c.Conn.LocalAddr()
+}
+
+// Header returns the received header. It is nil if the header is malformed.
+func (c *proxyConn) Header() *ProxyHeader {
+	c.init()
+	return c.header
+}
+
+// untrustedConn drops the connection if it starts with the PROXY protocol header.
+type untrustedConn struct {
+	net.Conn
+	once sync.Once
+	err  error
+	// pending is the data read while looking for the header.
+	pending []byte
+}
+
+func (c *untrustedConn) init() {
+	c.once.Do(func() {
+		buff := make([]byte, len(proxyV2Signature))
+		n := 0
+
+		// read no more than necessary to tell whether it's the header, so clients sending
+		// short messages don't get stuck.
+		for n < len(buff) && mayBeProxyHeader(buff[:n]) && !isProxyHeader(buff[:n]) {
+			m, err := c.Conn.Read(buff[n:])
+			n += m
+			if err != nil {
+				c.err = err
+				break
+			}
+		}
+
+		if isProxyHeader(buff[:n]) {
+			c.err = ErrUntrustedProxy
+			_ = c.Conn.Close()
+			return
+		}
+
+		c.pending = buff[:n]
+	})
+}
+
+func (c *untrustedConn) Read(b []byte) (int, error) {
+	c.init()
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.Conn.Read(b)
+}
+
+// mayBeProxyHeader tells whether the data is a prefix of either v1 or v2 header.
+func mayBeProxyHeader(data []byte) bool {
+	return strings.HasPrefix(proxyV1Prefix, string(data)) || strings.HasPrefix(proxyV2Signature, string(data))
+}
+
+func isProxyHeader(data []byte) bool {
+	return strings.HasPrefix(string(data), proxyV1Prefix) || string(data) == proxyV2Signature
+}
+
+func readProxyHeader(conn net.Conn, timeout time.Duration) (*ProxyHeader, []byte, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, nil, err
+	}
+
+	r := bufio.NewReaderSize(conn, 256)
+	header, err := parseProxyHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, nil, err
+	}
+
+	var pending []byte
+	if n := r.Buffered(); n > 0 {
+		buffered, _ := r.Peek(n)
+		pending = bytes.Clone(buffered)
+	}
+
+	return header, pending, nil
+}
+
+func parseProxyHeader(r *bufio.Reader) (*ProxyHeader, error) {
+	// the shortest possible header, "PROXY UNKNOWN\r\n", is still longer than 12 bytes.
+	prefix, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(prefix) > 0 {
+			return nil, ErrBadProxyHeader
+		}
+
+		return nil, err
+	}
+
+	switch {
+	case string(prefix) == proxyV2Signature:
+		return parseProxyV2(r)
+	case strings.HasPrefix(string(prefix), proxyV1Prefix):
+		return parseProxyV1(r)
+	default:
+		return nil, ErrMissingProxyHeader
+	}
+}
+
+func parseProxyV1(r *bufio.Reader) (*ProxyHeader, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, ErrBadProxyHeader
+		}
+
+		return nil, err
+	}
+
+	if len(line) > proxyV1MaxLen || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrBadProxyHeader
+	}
+
+	fields := strings.Split(string(line[len(proxyV1Prefix):len(line)-2]), " ")
+	header := &ProxyHeader{Version: 1}
+
+	switch fields[0] {
+	case "UNKNOWN":
+		// the rest of the line must be ignored.
+		header.Local = true
+		return header, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, ErrBadProxyHeader
+	}
+
+	if len(fields) != 5 {
+		return nil, ErrBadProxyHeader
+	}
+
+	src, err1 := parseV1Addr(fields[0], fields[1], fields[3])
+	dst, err2 := parseV1Addr(fields[0], fields[2], fields[4])
+	if err1 != nil || err2 != nil {
+		return nil, ErrBadProxyHeader
+	}
+
+	header.Source, header.Destination = src, dst
+
+	return header, nil
+}
+
+func parseV1Addr(family, host, port string) (*net.TCPAddr, error) {
+	ip, err := netip.ParseAddr(host)
+	if err != nil || ip.Is4() != (family == "TCP4") {
+		return nil, ErrBadProxyHeader
+	}
+
+	// leading zeroes are forbidden.
+	if len(port) == 0 || len(port) > 1 && port[0] == '0' {
+		return nil, ErrBadProxyHeader
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrBadProxyHeader
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(p))), nil
+}
+
+func parseProxyV2(r *bufio.Reader) (*ProxyHeader, error) {
+	raw := make([]byte, proxyV2HeaderLen)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, err
+	}
+
+	verCmd, family := raw[12], raw[13]
+	if verCmd>>4 != 2 {
+		return nil, ErrBadProxyHeader
+	}
+
+	header := &ProxyHeader{Version: 2}
+	switch verCmd & 0xf {
+	case 0:
+		header.Local = true
+	case 1:
+	default:
+		return nil, ErrBadProxyHeader
+	}
+
+	length := int(binary.BigEndian.Uint16(raw[14:16]))
+	raw = append(raw, make([]byte, length)...)
+	if _, err := io.ReadFull(r, raw[proxyV2HeaderLen:]); err != nil {
+		return nil, err
+	}
+
+	payload := raw[proxyV2HeaderLen:]
+
+	var addrLen int
+	switch family >> 4 {
+	case 0: // AF_UNSPEC
+	case 1: // AF_INET
+		addrLen = 12
+	case 2: // AF_INET6
+		addrLen = 36
+	case 3: // AF_UNIX
+		addrLen = 216
+	default:
+		return nil, ErrBadProxyHeader
+	}
+
+	if len(payload) < addrLen {
+		return nil, ErrBadProxyHeader
+	}
+
+	if !header.Local {
+		header.Source, header.Destination = parseV2Addrs(family, payload[:addrLen])
+	}
+
+	if err := parseTLVs(header, raw, proxyV2HeaderLen+addrLen); err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+func parseV2Addrs(family byte, data []byte) (src, dst net.Addr) {
+	transport := family & 0xf
+
+	switch family >> 4 {
+	case 1, 2:
+		ipLen := (len(data) - 4) / 2
+		srcIP, _ := netip.AddrFromSlice(data[:ipLen])
+		dstIP, _ := netip.AddrFromSlice(data[ipLen : 2*ipLen])
+		srcPort := binary.BigEndian.Uint16(data[2*ipLen:])
+		dstPort := binary.BigEndian.Uint16(data[2*ipLen+2:])
+		srcAddr, dstAddr := netip.AddrPortFrom(srcIP, srcPort), netip.AddrPortFrom(dstIP, dstPort)
+
+		if transport == 2 {
+			return net.UDPAddrFromAddrPort(srcAddr), net.UDPAddrFromAddrPort(dstAddr)
+		}
+
+		return net.TCPAddrFromAddrPort(srcAddr), net.TCPAddrFromAddrPort(dstAddr)
+	case 3:
+		network := "unix"
+		if transport == 2 {
+			network = "unixgram"
+		}
+
+		return &net.UnixAddr{Name: cstring(data[:108]), Net: network},
+			&net.UnixAddr{Name: cstring(data[108:]), Net: network}
+	default:
+		return nil, nil
+	}
+}
+
+func cstring(b []byte) string {
+	if end := bytes.IndexByte(b, 0); end != -1 {
+		b = b[:end]
+	}
+
+	return string(b)
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// parseTLVs parses TLVs of the raw header, starting from the offset.
+func parseTLVs(header *ProxyHeader, raw []byte, offset int) error {
+	tlvs, err := splitTLVs(raw[offset:])
+	if err != nil {
+		return err
+	}
+
+	header.TLVs = tlvs
+
+	for i, tlv := range tlvs {
+		switch tlv.Type {
+		case ProxyTLVALPN:
+			header.ALPN = string(tlv.Value)
+		case ProxyTLVAuthority:
+			header.Authority = string(tlv.Value)
+		case ProxyTLVUniqueID:
+			if len(tlv.Value) > 128 {
+				return ErrBadProxyHeader
+			}
+
+			header.UniqueID = tlv.Value
+		case ProxyTLVCRC32C:
+			if len(tlv.Value) != 4 {
+				return ErrBadProxyHeader
+			}
+
+			want := binary.BigEndian.Uint32(tlv.Value)
+			// the checksum is calculated with its own value zeroed.
+			valueOffset := offset + tlvOffset(tlvs[:i]) + 3
+			checked := bytes.Clone(raw)
+			clear(checked[valueOffset : valueOffset+4])
+			if crc32.Checksum(checked, castagnoli) != want {
+				return ErrBadProxyHeader
+			}
+		case ProxyTLVSSL:
+			ssl, err := parseSSL(tlv.Value)
+			if err != nil {
+				return err
+			}
+
+			header.SSL = ssl
+		}
+	}
+
+	return nil
+}
+
+func tlvOffset(preceding []ProxyTLV) (offset int) {
+	for _, tlv := range preceding {
+		offset += 3 + len(tlv.Value)
+	}
+
+	return offset
+}
+
+func splitTLVs(data []byte) (tlvs []ProxyTLV, err error) {
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return nil, ErrBadProxyHeader
+		}
+
+		length := int(binary.BigEndian.Uint16(data[1:3]))
+		if len(data) < 3+length {
+			return nil, ErrBadProxyHeader
+		}
+
+		tlvs = append(tlvs, ProxyTLV{Type: data[0], Value: data[3 : 3+length]})
+		data = data[3+length:]
+	}
+
+	return tlvs, nil
+}
+
+func parseSSL(data []byte) (*ProxySSL, error) {
+	if len(data) < 5 {
+		return nil, ErrBadProxyHeader
+	}
+
+	ssl := &ProxySSL{
+		Client:   data[0],
+		Verified: binary.BigEndian.Uint32(data[1:5]) == 0,
+	}
+
+	subs, err := splitTLVs(data[5:])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sub := range subs {
+		switch sub.Type {
+		case proxySSLVersion:
+			ssl.Version = string(sub.Value)
+		case proxySSLCN:
+			ssl.CN = string(sub.Value)
+		case proxySSLCipher:
+			ssl.Cipher = string(sub.Value)
+		case proxySSLSigAlg:
+			ssl.SigAlg = string(sub.Value)
+		case proxySSLKeyAlg:
+			ssl.KeyAlg = string(sub.Value)
+		}
+	}
+
+	return ssl, nil
+}
@@ -0,0 +1,284 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parseString(header string) (*ProxyHeader, error) {
+	return parseProxyHeader(bufio.NewReaderSize(strings.NewReader(header), 256))
+}
+
+type tlv struct {
+	typ   uint8
+	value []byte
+}
+
+func appendTLV(b []byte, t tlv) []byte {
+	b = append(b, t.typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(t.value)))
+	return append(b, t.value...)
+}
+
+func proxyV2(cmd, family byte, addrs []byte, tlvs ...tlv) []byte {
+	var payload []byte
+	payload = append(payload, addrs...)
+	for _, t := range tlvs {
+		payload = appendTLV(payload, t)
+	}
+
+	header := append([]byte(proxyV2Signature), 0x20|cmd, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+
+	return append(header, payload...)
+}
+
+func ipv4Addrs() []byte {
+	addrs := []byte{192, 168, 0, 1, 10, 0, 0, 1}
+	addrs = binary.BigEndian.AppendUint16(addrs, 56324)
+	return binary.BigEndian.AppendUint16(addrs, 443)
+}
+
+func TestProxyHeader(t *testing.T) {
+	t.Run("v1 TCP4", func(t *testing.T) {
+		header, err := parseString("PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\n")
+		require.NoError(t, err)
+		require.Equal(t, uint8(1), header.Version)
+		require.False(t, header.Local)
+		require.Equal(t, "192.168.0.1:56324", header.Source.String())
+		require.Equal(t, "10.0.0.1:443", header.Destination.String())
+	})
+
+	t.Run("v1 TCP6", func(t *testing.T) {
+		header, err := parseString("PROXY TCP6 ::1 2001:db8::1 1 65535\r\n")
+		require.NoError(t, err)
+		require.Equal(t, "[::1]:1", header.Source.String())
+		require.Equal(t, "[2001:db8::1]:65535", header.Destination.String())
+	})
+
+	t.Run("v1 UNKNOWN", func(t *testing.T) {
+		header, err := parseString("PROXY UNKNOWN whatever\r\n")
+		require.NoError(t, err)
+		require.True(t, header.Local)
+		require.Nil(t, header.Source)
+	})
+
+	t.Run("v1 malformed", func(t *testing.T) {
+		for _, tc := range []string{
+			"PROXY TCP4 192.168.0.1 10.0.0.1 56324\r\n",
+			"PROXY TCP4 ::1 10.0.0.1 56324 443\r\n",
+			"PROXY TCP4 192.168.0.1 10.0.0.1 056324 443\r\n",
+			"PROXY TCP4 192.168.0.1 10.0.0.1 65536 443\r\n",
+			"PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\n",
+			"PROXY UDP4 192.168.0.1 10.0.0.1 56324 443\r\n",
+			"PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n",
+		} {
+			_, err := parseString(tc)
+			require.ErrorIs(t, err, ErrBadProxyHeader, tc)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := parseString("GET / HTTP/1.1\r\n\r\n")
+		require.ErrorIs(t, err, ErrMissingProxyHeader)
+	})
+
+	t.Run("v2 IPv4 with TLVs", func(t *testing.T) {
+		ssl := []byte{0x07, 0, 0, 0, 0}
+		ssl = appendTLV(ssl, tlv{proxySSLVersion, []byte("TLSv1.3")})
+		ssl = appendTLV(ssl, tlv{proxySSLCN, []byte("client")})
+
+		raw := proxyV2(1, 0x11, ipv4Addrs(),
+			tlv{ProxyTLVALPN, []byte("h2")},
+			tlv{ProxyTLVAuthority, []byte("example.com")},
+			tlv{ProxyTLVUniqueID, []byte{1, 2, 3}},
+			tlv{ProxyTLVSSL, ssl},
+			tlv{0xe0, []byte("custom")},
+		)
+
+		header, err := parseString(string(raw) + "GET /")
+		require.NoError(t, err)
+		require.Equal(t, uint8(2), header.Version)
+		require.Equal(t, "192.168.0.1:56324", header.Source.String())
+		require.IsType(t, new(net.TCPAddr), header.Source)
+		require.Equal(t, "10.0.0.1:443", header.Destination.String())
+		require.Equal(t, "h2", header.ALPN)
+		require.Equal(t, "example.com", header.Authority)
+		require.Equal(t, []byte{1, 2, 3}, header.UniqueID)
+		require.Equal(t, &ProxySSL{
+			Client:   0x07,
+			Verified: true,
+			Version:  "TLSv1.3",
+			CN:       "client",
+		}, header.SSL)
+		require.Len(t, header.TLVs, 5)
+		require.Equal(t, ProxyTLV{Type: 0xe0, Value: []byte("custom")}, header.TLVs[4])
+	})
+
+	t.Run("v2 IPv6 UDP", func(t *testing.T) {
+		addrs := make([]byte, 36)
+		addrs[15], addrs[31] = 1, 2
+		binary.BigEndian.PutUint16(addrs[32:], 1000)
+		binary.BigEndian.PutUint16(addrs[34:], 2000)
+
+		header, err := parseString(string(proxyV2(1, 0x22, addrs)))
+		require.NoError(t, err)
+		require.Equal(t, "[::1]:1000", header.Source.String())
+		require.IsType(t, new(net.UDPAddr), header.Source)
+		require.Equal(t, "[::2]:2000", header.Destination.String())
+	})
+
+	t.Run("v2 unix", func(t *testing.T) {
+		addrs := make([]byte, 216)
+		copy(addrs, "/run/client.sock")
+		copy(addrs[108:], "/run/server.sock")
+
+		header, err := parseString(string(proxyV2(1, 0x31, addrs)))
+		require.NoError(t, err)
+		require.Equal(t, &net.UnixAddr{Name: "/run/client.sock", Net: "unix"}, header.Source)
+		require.Equal(t, &net.UnixAddr{Name: "/run/server.sock", Net: "unix"}, header.Destination)
+	})
+
+	t.Run("v2 LOCAL", func(t *testing.T) {
+		header, err := parseString(string(proxyV2(0, 0x00, nil)))
+		require.NoError(t, err)
+		require.True(t, header.Local)
+		require.Nil(t, header.Source)
+	})
+
+	t.Run("v2 CRC32C", func(t *testing.T) {
+		raw := proxyV2(1, 0x11, ipv4Addrs(), tlv{ProxyTLVCRC32C, make([]byte, 4)})
+		binary.BigEndian.PutUint32(raw[len(raw)-4:], crc32.Checksum(raw, crc32.MakeTable(crc32.Castagnoli)))
+		_, err := parseString(string(raw))
+		require.NoError(t, err)
+
+		raw[len(raw)-1]++
+		_, err = parseString(string(raw))
+		require.ErrorIs(t, err, ErrBadProxyHeader)
+	})
+
+	t.Run("v2 malformed", func(t *testing.T) {
+		for name, raw := range map[string][]byte{
+			"version":   append(append([]byte(proxyV2Signature), 0x11, 0x11), 0, 0),
+			"command":   proxyV2(2, 0x11, ipv4Addrs()),
+			"family":    proxyV2(1, 0x41, ipv4Addrs()),
+			"short":     proxyV2(1, 0x21, ipv4Addrs()),
+			"tlv":       append(proxyV2(1, 0x11, ipv4Addrs()), 0),
+			"truncated": proxyV2(1, 0x11, ipv4Addrs())[:20],
+		} {
+			if name == "tlv" {
+				// fix the length up, so it covers the truncated TLV.
+				binary.BigEndian.PutUint16(raw[14:], uint16(len(raw)-proxyV2HeaderLen))
+			}
+
+			_, err := parseString(string(raw))
+			require.Error(t, err, name)
+		}
+	})
+}
+
+func TestProxyListener(t *testing.T) {
+	listen := func(t *testing.T, trusted ...netip.Prefix) (*proxyListener, string) {
+		l, err := bindTCP("127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = l.Close()
+		})
+
+		pl := newProxyListener(l, ProxyProtocol{Trusted: trusted})
+		pl.timeout.Store(int64(200 * time.Millisecond))
+
+		return pl, l.Addr().String()
+	}
+
+	accept := func(t *testing.T, pl *proxyListener, addr, data string) net.Conn {
+		client, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+
+		if len(data) > 0 {
+			_, err = io.WriteString(client, data)
+			require.NoError(t, err)
+		}
+
+		conn, err := pl.Accept()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+
+		return conn
+	}
+
+	t.Run("trusted", func(t *testing.T) {
+		pl, addr := listen(t, netip.MustParsePrefix("127.0.0.0/8"))
+		conn := accept(t, pl, addr, "PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n\r\n")
+		require.Equal(t, "192.168.0.1:56324", conn.RemoteAddr().String())
+		require.Equal(t, "10.0.0.1:443", conn.LocalAddr().String())
+		require.Equal(t, "192.168.0.1:56324", ProxyHeaderOf(conn).Source.String())
+
+		data := make([]byte, 64)
+		n, err := conn.Read(data)
+		require.NoError(t, err)
+		require.Equal(t, "GET / HTTP/1.1\r\n\r\n", string(data[:n]))
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		pl, addr := listen(t, netip.MustParsePrefix("10.0.0.0/8"))
+		conn := accept(t, pl, addr, "GET / HTTP/1.1\r\n\r\n")
+		require.Nil(t, ProxyHeaderOf(conn))
+		require.Contains(t, conn.RemoteAddr().String(), "127.0.0.1:")
+
+		data, err := io.ReadAll(io.LimitReader(conn, 18))
+		require.NoError(t, err)
+		require.Equal(t, "GET / HTTP/1.1\r\n\r\n", string(data))
+	})
+
+	t.Run("untrusted short message", func(t *testing.T) {
+		pl, addr := listen(t, netip.MustParsePrefix("10.0.0.0/8"))
+		conn := accept(t, pl, addr, "GET")
+		data := make([]byte, 64)
+		n, err := conn.Read(data)
+		require.NoError(t, err)
+		require.Equal(t, "GET", string(data[:n]))
+	})
+
+	t.Run("untrusted with header", func(t *testing.T) {
+		for _, header := range []string{
+			"PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\n",
+			proxyV2Signature + "\x21\x11\x00\x0c",
+		} {
+			pl, addr := listen(t, netip.MustParsePrefix("10.0.0.0/8"))
+			conn := accept(t, pl, addr, header+"GET / HTTP/1.1\r\n\r\n")
+			_, err := conn.Read(make([]byte, 64))
+			require.ErrorIs(t, err, ErrUntrustedProxy)
+		}
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		pl, addr := listen(t)
+		conn := accept(t, pl, addr, "GET / HTTP/1.1\r\n\r\n")
+		_, err := conn.Read(make([]byte, 64))
+		require.ErrorIs(t, err, ErrMissingProxyHeader)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		pl, addr := listen(t)
+		conn := accept(t, pl, addr, "PROXY TCP4")
+		start := time.Now()
+		_, err := conn.Read(make([]byte, 64))
+		require.Error(t, err)
+		require.Less(t, time.Since(start), time.Second)
+	})
+}
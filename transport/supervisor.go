@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"context"
 	"net"
 	"sync/atomic"
 
@@ -16,19 +17,42 @@ type Transport interface {
 }
 
 type Supervisor struct {
-	stopped *atomic.Bool
-	ts      []boundTransport
-	stopch  chan struct{}
+	stopped    *atomic.Bool
+	ts         []boundTransport
+	stopch     chan struct{}
+	shutdownch chan shutdownRequest
+	conns      *registry
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+type shutdownRequest struct {
+	ctx      context.Context
+	progress func(phase ShutdownPhase, connections int)
+	result   chan error
 }
 
 func NewSupervisor() Supervisor {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return Supervisor{
-		stopped: new(atomic.Bool),
-		stopch:  make(chan struct{}),
+		stopped:    new(atomic.Bool),
+		stopch:     make(chan struct{}),
+		shutdownch: make(chan shutdownRequest),
+		conns:      newRegistry(),
+		ctx:        ctx,
+		cancel:     cancel,
 	}
 }
 
-func (s *Supervisor) Add(addr string, transport Transport, cb func(net.Conn)) error {
+// Context returns the context, which is cancelled as soon as the supervisor starts stopping.
+func (s *Supervisor) Context() context.Context {
+	return s.ctx
+}
+
+// Add binds the transport. Every accepted connection is tracked, which is required for the
+// graceful shutdown.
+func (s *Supervisor) Add(addr string, transport Transport, cb func(net.Conn, *Tracker)) error {
 	err := transport.Bind(addr)
 	if err != nil {
 		s.close()
@@ -36,8 +60,12 @@ func (s *Supervisor) Add(addr string, transport Transport, cb func(net.Conn)) er
 	}
 
 	s.ts = append(s.ts, boundTransport{
-		cb: cb,
-		t:  transport,
+		cb: func(conn net.Conn) {
+			tracker := s.conns.add(conn)
+			cb(conn, tracker)
+			s.conns.remove(tracker)
+		},
+		t: transport,
 	})
 
 	return nil
@@ -67,6 +95,11 @@ func (s *Supervisor) Run(cfg config.NET) error {
 		drain(errch, len(s.ts))
 		s.stopch <- struct{}{}
 
+		return nil
+	case req := <-s.shutdownch:
+		s.shutdown(req)
+		drain(errch, len(s.ts))
+
 		return nil
 	}
 }
@@ -78,6 +111,67 @@ func (s *Supervisor) Stop() {
 	}
 }
 
+// Shutdown gracefully stops the supervisor. Listeners are closed at once, as well as idle
+// connections, whereas the busy ones are closed after serving their current requests. If the
+// ctx is done before all the connections are closed, the remaining ones are closed forcibly and
+// the ctx error is returned without waiting for them to wrap up. The progress callback may be nil.
+func (s *Supervisor) Shutdown(ctx context.Context, progress func(phase ShutdownPhase, connections int)) error {
+	if s.stopped.Load() {
+		return nil
+	}
+
+	req := shutdownRequest{
+		ctx:      ctx,
+		progress: progress,
+		result:   make(chan error, 1),
+	}
+
+	select {
+	case s.shutdownch <- req:
+		return <-req.result
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Supervisor) shutdown(req shutdownRequest) {
+	s.stopped.Store(true)
+
+	for _, t := range s.ts {
+		t.t.Stop()
+		t.t.Close()
+	}
+
+	busy := s.conns.drain()
+	if req.progress != nil {
+		req.progress(ShutdownDraining, busy)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for _, t := range s.ts {
+			t.t.Wait()
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		req.result <- nil
+	case <-req.ctx.Done():
+		s.cancel()
+		remaining := s.conns.close()
+		if req.progress != nil {
+			req.progress(ShutdownForcing, remaining)
+		}
+
+		req.result <- req.ctx.Err()
+		<-done
+	}
+}
+
 func (s *Supervisor) stop() {
 	if s.stopped.Load() {
 		return
@@ -89,6 +183,9 @@ func (s *Supervisor) stop() {
 		t.t.Stop()
 	}
 
+	// let the connections know they must wrap up.
+	s.cancel()
+
 	for _, t := range s.ts {
 		t.t.Wait()
 		t.t.Close()
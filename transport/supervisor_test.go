@@ -1,7 +1,10 @@
 package transport
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"sync/atomic"
 	"testing"
@@ -137,4 +140,80 @@ func TestSupervisor(t *testing.T) {
 			require.Fail(t, "supervisor did not stop running on time")
 		}
 	})
+	t.Run("shutdown", func(t *testing.T) {
+		sup, err := newSupervisor(
+			newMock(100*time.Millisecond, nil, false),
+			newMock(200*time.Millisecond, nil, false),
+		)
+		require.NoError(t, err)
+		c := runParallel(func() error {
+			return sup.Run(config.Default().NET)
+		})
+		time.Sleep(200 * time.Millisecond)
+
+		var phases []ShutdownPhase
+		err = sup.Shutdown(context.Background(), func(phase ShutdownPhase, connections int) {
+			phases = append(phases, phase)
+			require.Zero(t, connections)
+		})
+		require.NoError(t, err)
+		require.Equal(t, []ShutdownPhase{ShutdownDraining}, phases)
+
+		select {
+		case err = <-c:
+			require.NoError(t, err)
+		case <-time.After(300 * time.Millisecond):
+			require.Fail(t, "supervisor did not stop running on time")
+		}
+
+		require.NoError(t, sup.Shutdown(context.Background(), nil))
+		require.ErrorIs(t, sup.Context().Err(), context.Canceled)
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	newConn := func(r *registry) (*Tracker, net.Conn) {
+		server, client := net.Pipe()
+		return r.add(server), client
+	}
+
+	isClosed := func(conn net.Conn) bool {
+		_ = conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+		return errors.Is(err, io.EOF)
+	}
+
+	r := newRegistry()
+	idle, idleClient := newConn(r)
+	require.True(t, idle.Idle())
+	busy, busyClient := newConn(r)
+	busy.Active()
+	var drained bool
+	fresh, freshClient := newConn(r)
+	custom, customClient := newConn(r)
+	custom.OnDrain(func() bool {
+		drained = true
+		return false
+	})
+
+	require.Equal(t, 2, r.drain())
+	require.True(t, isClosed(idleClient))
+	require.True(t, isClosed(freshClient), "connections without requests must be considered idle")
+	require.False(t, isClosed(busyClient))
+	require.True(t, busy.Draining())
+	require.False(t, busy.Idle())
+	require.True(t, drained)
+
+	late, _ := newConn(r)
+	require.True(t, late.Draining())
+
+	r.remove(idle)
+	r.remove(fresh)
+	require.Equal(t, 3, r.close())
+	require.True(t, isClosed(busyClient))
+	require.True(t, isClosed(customClient))
+
+	var nilTracker *Tracker
+	require.True(t, nilTracker.Idle())
+	require.False(t, nilTracker.Draining())
 }
@@ -17,9 +17,11 @@ type listener interface {
 }
 
 type TCP struct {
-	l    listener
-	wg   *sync.WaitGroup
-	stop *atomic.Bool
+	l     listener
+	wg    *sync.WaitGroup
+	stop  *atomic.Bool
+	proxy *ProxyProtocol
+	pl    *proxyListener
 }
 
 func NewTCP() *TCP {
@@ -44,12 +46,37 @@ func bindTCP(addr string) (*net.TCPListener, error) {
 	return net.ListenTCP("tcp", tcpaddr)
 }
 
-func (t *TCP) Bind(addr string) (err error) {
-	t.l, err = bindTCP(addr)
-	return err
+// EnableProxyProtocol makes the transport expect the PROXY protocol header at the beginning of
+// connections. Must be called before binding.
+func (t *TCP) EnableProxyProtocol(cfg ProxyProtocol) {
+	t.proxy = &cfg
+}
+
+func (t *TCP) Bind(addr string) error {
+	l, err := bindTCP(addr)
+	if err != nil {
+		return err
+	}
+
+	t.l = t.wrap(l)
+	return nil
+}
+
+// wrap wraps the listener into the PROXY protocol one, if enabled.
+func (t *TCP) wrap(l listener) listener {
+	if t.proxy == nil {
+		return l
+	}
+
+	t.pl = newProxyListener(l, *t.proxy)
+	return t.pl
 }
 
 func (t *TCP) Listen(cfg config.NET, cb func(conn net.Conn)) error {
+	if t.pl != nil {
+		t.pl.timeout.Store(int64(cfg.ProxyHeaderTimeout))
+	}
+
 	for !t.stop.Load() {
 		err := t.l.SetDeadline(timer.Now().Add(cfg.AcceptLoopInterruptPeriod))
 		if err != nil {
@@ -65,8 +92,8 @@ func (t *TCP) Listen(cfg config.NET, cb func(conn net.Conn)) error {
 			return err
 		}
 
+		t.wg.Add(1)
 		go func(conn net.Conn) {
-			t.wg.Add(1)
 			cb(conn)
 			_ = conn.Close()
 			t.wg.Done()
@@ -11,7 +11,10 @@ type TLS struct {
 }
 
 func NewTLS(cfg *tls.Config) *TLS {
-	return &TLS{cfg: cfg}
+	return &TLS{
+		TCP: newTCP(nil),
+		cfg: cfg,
+	}
 }
 
 func (t *TLS) Bind(addr string) error {
@@ -20,14 +23,15 @@ func (t *TLS) Bind(addr string) error {
 		return err
 	}
 
-	l := tls.NewListener(tcp, t.cfg)
-	t.TCP = newTCP(tlsAdapter{tcp, l})
+	// the PROXY protocol header precedes the TLS handshake.
+	inner := t.wrap(tcp)
+	t.l = tlsAdapter{inner, tls.NewListener(inner, t.cfg)}
 
 	return nil
 }
 
 type tlsAdapter struct {
-	*net.TCPListener
+	listener
 	tls net.Listener
 }
 
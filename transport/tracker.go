@@ -0,0 +1,177 @@
+package transport
+
+import (
+	"net"
+	"sync"
+)
+
+// ShutdownPhase describes the progress of the graceful shutdown.
+type ShutdownPhase uint8
+
+const (
+	// ShutdownDraining means the server doesn't accept new connections anymore and idle ones
+	// are closed. The remaining connections are closed after serving their current requests.
+	ShutdownDraining ShutdownPhase = iota + 1
+	// ShutdownForcing means the deadline was exceeded and the remaining connections are
+	// closed forcibly.
+	ShutdownForcing
+)
+
+func (p ShutdownPhase) String() string {
+	switch p {
+	case ShutdownDraining:
+		return "draining"
+	case ShutdownForcing:
+		return "forcing"
+	default:
+		return "unknown"
+	}
+}
+
+// Tracker tracks the state of a single connection, so the graceful shutdown can close idle
+// connections immediately and let busy ones finish. Nil Tracker is valid and never drains.
+type Tracker struct {
+	conn     net.Conn
+	mu       sync.Mutex
+	idle     bool
+	draining bool
+	onDrain  func() (closed bool)
+}
+
+// Idle marks the connection as waiting for the next request. If the server is draining, false
+// is returned, and the connection must be closed instead.
+func (t *Tracker) Idle() bool {
+	if t == nil {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.draining {
+		return false
+	}
+
+	t.idle = true
+	return true
+}
+
+// Active marks the connection as serving a request.
+func (t *Tracker) Active() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.idle = false
+	t.mu.Unlock()
+}
+
+// Draining tells whether the server is shutting down, so the connection must be closed after
+// the current request.
+func (t *Tracker) Draining() bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.draining
+}
+
+// OnDrain makes the protocol responsible for wrapping the connection up, which is useful when
+// the client can be notified about it (e.g. HTTP/2 GOAWAY). The callback is called once the
+// server starts shutting down, or immediately if it already did, and reports whether the
+// connection was idle and therefore closed right away.
+func (t *Tracker) OnDrain(cb func() (closed bool)) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.onDrain = cb
+	draining := t.draining
+	t.mu.Unlock()
+
+	if draining {
+		_ = cb()
+	}
+}
+
+// drain notifies the connection about the shutdown and closes it if idle.
+func (t *Tracker) drain() (closed bool) {
+	t.mu.Lock()
+	t.draining = true
+	idle, cb := t.idle, t.onDrain
+	t.mu.Unlock()
+
+	switch {
+	case cb != nil:
+		return cb()
+	case idle:
+		_ = t.conn.Close()
+		return true
+	default:
+		return false
+	}
+}
+
+// registry keeps trackers of all the open connections.
+type registry struct {
+	mu       sync.Mutex
+	conns    map[*Tracker]struct{}
+	draining bool
+}
+
+func newRegistry() *registry {
+	return &registry{conns: make(map[*Tracker]struct{})}
+}
+
+func (r *registry) add(conn net.Conn) *Tracker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the connection hasn't sent a request yet, so there's nothing to wait for.
+	t := &Tracker{conn: conn, idle: true, draining: r.draining}
+	r.conns[t] = struct{}{}
+
+	return t
+}
+
+func (r *registry) remove(t *Tracker) {
+	r.mu.Lock()
+	delete(r.conns, t)
+	r.mu.Unlock()
+}
+
+// drain notifies all the connections about the shutdown and returns how many are left busy.
+func (r *registry) drain() (busy int) {
+	r.mu.Lock()
+	r.draining = true
+	trackers := make([]*Tracker, 0, len(r.conns))
+	for t := range r.conns {
+		trackers = append(trackers, t)
+	}
+	r.mu.Unlock()
+
+	for _, t := range trackers {
+		if !t.drain() {
+			busy++
+		}
+	}
+
+	return busy
+}
+
+// close forcibly closes all the remaining connections and returns their number.
+func (r *registry) close() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for t := range r.conns {
+		_ = t.conn.Close()
+	}
+
+	return len(r.conns)
+}
@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Unix listens on a Unix domain socket. Addresses starting with @ are bound in the abstract
+// namespace, which is Linux-only and never leaves files behind.
+type Unix struct {
+	TCP
+	perm os.FileMode
+}
+
+// NewUnix returns a new Unix socket transport. Non-zero perm is applied to the socket file.
+func NewUnix(perm os.FileMode) *Unix {
+	return &Unix{
+		TCP:  newTCP(nil),
+		perm: perm,
+	}
+}
+
+func (u *Unix) Bind(path string) error {
+	abstract := isAbstract(path)
+	if !abstract {
+		if err := removeStale(path); err != nil {
+			return err
+		}
+	}
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return err
+	}
+
+	if !abstract && u.perm != 0 {
+		if err = os.Chmod(path, u.perm); err != nil {
+			_ = l.Close()
+			return err
+		}
+	}
+
+	// the socket file is removed as soon as the listener is closed.
+	l.SetUnlinkOnClose(true)
+	u.l = u.wrap(l)
+
+	return nil
+}
+
+func isAbstract(path string) bool {
+	return len(path) > 0 && path[0] == '@'
+}
+
+// removeStale removes the socket file, if it was left by a process which isn't listening
+// anymore. Files which aren't sockets are never touched.
+func removeStale(path string) error {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	if stat.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s: file exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s: address already in use", path)
+	}
+
+	return os.Remove(path)
+}
+
+// Cred holds credentials of the process on the other side of a Unix socket.
+type Cred struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// UnixAddr is the remote address of a Unix socket connection. As clients are rarely bound
+// to a path, the address of the listening socket is used instead.
+type UnixAddr struct {
+	// Path is the path of the listening socket.
+	Path string
+	// Cred contains the peer credentials. It is nil on platforms not supporting SO_PEERCRED.
+	Cred *Cred
+}
+
+func (*UnixAddr) Network() string {
+	return "unix"
+}
+
+func (u *UnixAddr) String() string {
+	buff := append([]byte("unix:"), u.Path...)
+	if u.Cred != nil {
+		buff = append(buff, "[pid="...)
+		buff = strconv.AppendInt(buff, int64(u.Cred.PID), 10)
+		buff = append(buff, ",uid="...)
+		buff = strconv.AppendUint(buff, uint64(u.Cred.UID), 10)
+		buff = append(buff, ",gid="...)
+		buff = strconv.AppendUint(buff, uint64(u.Cred.GID), 10)
+		buff = append(buff, ']')
+	}
+
+	return string(buff)
+}
+
+func unixRemote(conn *net.UnixConn) *UnixAddr {
+	return &UnixAddr{
+		Path: conn.LocalAddr().String(),
+		Cred: peerCred(conn),
+	}
+}